@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"RaceAll/internal/acc"
 	"RaceAll/internal/broadcast"
 	"RaceAll/internal/logger"
 	"RaceAll/internal/sharedmemory"
@@ -14,6 +15,7 @@ type App struct {
 	ctx               context.Context
 	sharedMemService  *sharedmemory.Service
 	connectionManager *broadcast.ConnectionManager
+	pipeline          *acc.BroadcastPipeline
 }
 
 // NewApp creates a new App application struct
@@ -36,10 +38,17 @@ func (a *App) startup(ctx context.Context) {
 		fmt.Printf("Error starting shared memory service: %v\n", err)
 	}
 
-	// Configurar e iniciar connection manager
-	config := broadcast.DefaultConfig()
+	// Configurar e iniciar connection manager con los datos de broadcasting.json
+	config, err := broadcast.LoadConfig("", false)
+	if err != nil {
+		fmt.Printf("Error loading broadcasting.json, using defaults: %v\n", err)
+	}
 	a.connectionManager = broadcast.NewConnectionManager(config, a.sharedMemService)
 
+	// El DataManager sigue el modo de conexión del connection manager
+	a.pipeline = acc.NewBroadcastPipeline()
+	a.connectionManager.SetPipeline(a.pipeline)
+
 	if err := a.connectionManager.Start(); err != nil {
 		fmt.Printf("Error starting connection manager: %v\n", err)
 	}
@@ -48,6 +57,7 @@ func (a *App) startup(ctx context.Context) {
 // shutdown is called at application termination
 func (a *App) shutdown(ctx context.Context) {
 	if a.connectionManager != nil {
+		a.connectionManager.SetPipeline(nil)
 		a.connectionManager.Stop()
 	}
 
@@ -76,3 +86,48 @@ func (a *App) IsConnected() bool {
 	}
 	return a.connectionManager.IsConnected()
 }
+
+// SetSpectatorMode activa el modo espectador: la conexión de broadcast se
+// mantiene sin el juego abierto en esta PC, para seguir un servidor remoto
+// o dedicado, y el DataManager ignora shared memory
+func (a *App) SetSpectatorMode(enabled bool) error {
+	if a.connectionManager == nil {
+		return fmt.Errorf("connection manager not started")
+	}
+
+	mode := broadcast.ModeLocal
+	if enabled {
+		mode = broadcast.ModeSpectator
+	}
+	return a.connectionManager.SetMode(mode)
+}
+
+// GetDataManager devuelve el DataManager alimentado por el broadcast
+func (a *App) GetDataManager() *acc.DataManager {
+	if a.pipeline == nil {
+		return nil
+	}
+	return a.pipeline.DataManager()
+}
+
+// IsSpectatorMode verifica si el broadcast está en modo espectador
+func (a *App) IsSpectatorMode() bool {
+	return a.connectionManager != nil && a.connectionManager.Mode() == broadcast.ModeSpectator
+}
+
+// StartBroadcastCapture graba los paquetes de broadcast en un archivo de
+// captura, para reproducir después una sesión reportada por un usuario
+func (a *App) StartBroadcastCapture(path string) error {
+	if a.connectionManager == nil {
+		return fmt.Errorf("connection manager not started")
+	}
+	return a.connectionManager.StartCapture(path)
+}
+
+// StopBroadcastCapture termina la captura de paquetes de broadcast
+func (a *App) StopBroadcastCapture() error {
+	if a.connectionManager == nil {
+		return nil
+	}
+	return a.connectionManager.StopCapture()
+}
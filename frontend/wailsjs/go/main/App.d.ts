@@ -7,3 +7,7 @@ export function GetBroadcastService():Promise<broadcast.Service>;
 export function Greet(arg1:string):Promise<string>;
 
 export function IsConnected():Promise<boolean>;
+
+export function IsSpectatorMode():Promise<boolean>;
+
+export function SetSpectatorMode(arg1:boolean):Promise<void>;
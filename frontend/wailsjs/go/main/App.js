@@ -13,3 +13,11 @@ export function Greet(arg1) {
 export function IsConnected() {
   return window['go']['main']['App']['IsConnected']();
 }
+
+export function IsSpectatorMode() {
+  return window['go']['main']['App']['IsSpectatorMode']();
+}
+
+export function SetSpectatorMode(arg1) {
+  return window['go']['main']['App']['SetSpectatorMode'](arg1);
+}
@@ -0,0 +1,561 @@
+package director
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"RaceAll/internal/acc/incidents"
+	"RaceAll/internal/acc/leaderboard"
+	"RaceAll/internal/broadcast"
+)
+
+// Controller envía los cambios de foco y cámara a ACC
+// (broadcast.Service y broadcast.Client lo implementan)
+type Controller interface {
+	SetFocusAndCamera(carIndex uint16, cameraSet, camera string) error
+}
+
+// Reason es el motivo por el que el director sigue a un auto
+type Reason int
+
+const (
+	ReasonLeader Reason = iota
+	ReasonBattle
+	ReasonPitStop
+	ReasonPositionChange
+	ReasonIncident
+	ReasonManual
+)
+
+func (r Reason) String() string {
+	switch r {
+	case ReasonLeader:
+		return "Leader"
+	case ReasonBattle:
+		return "Battle"
+	case ReasonPitStop:
+		return "PitStop"
+	case ReasonPositionChange:
+		return "PositionChange"
+	case ReasonIncident:
+		return "Incident"
+	case ReasonManual:
+		return "Manual"
+	default:
+		return "Unknown"
+	}
+}
+
+// Config configura las decisiones del director
+type Config struct {
+	// MinDwell es el tiempo mínimo en un plano antes de cualquier cambio automático
+	MinDwell time.Duration
+
+	// MaxDwell es el tiempo tras el cual se cambia de auto aunque el
+	// candidato tenga menor prioridad que el plano actual
+	MaxDwell time.Duration
+
+	// CameraDwell es el tiempo tras el cual se cambia de cámara sin cambiar de auto
+	CameraDwell time.Duration
+
+	// EventWindow es cuánto tiempo un incidente, adelantamiento o parada en
+	// boxes sigue siendo candidato
+	EventWindow time.Duration
+
+	// BattleGap es el intervalo máximo entre dos autos para considerarlo una batalla
+	BattleGap time.Duration
+
+	// Priorities ordena los motivos; a mayor valor, antes se corta al auto
+	Priorities map[Reason]int
+
+	// CameraSets son los sets de cámara preferidos por motivo, en orden.
+	// Se usa el primero que exista en TrackData.CameraSets.
+	CameraSets map[Reason][]string
+}
+
+// DefaultConfig prioriza incidentes sobre adelantamientos, paradas en boxes,
+// batallas y, sin nada que mostrar, el líder
+func DefaultConfig() Config {
+	return Config{
+		MinDwell:    5 * time.Second,
+		MaxDwell:    25 * time.Second,
+		CameraDwell: 10 * time.Second,
+		EventWindow: 10 * time.Second,
+		BattleGap:   time.Second,
+		Priorities: map[Reason]int{
+			ReasonLeader:         10,
+			ReasonBattle:         40,
+			ReasonPitStop:        60,
+			ReasonPositionChange: 80,
+			ReasonIncident:       100,
+		},
+		CameraSets: map[Reason][]string{
+			ReasonLeader:         {"set1", "set2", "Helicam", "Onboard"},
+			ReasonBattle:         {"set1", "set2", "Onboard"},
+			ReasonPitStop:        {"pitlane", "set1"},
+			ReasonPositionChange: {"set1", "set2", "Helicam"},
+			ReasonIncident:       {"Helicam", "set1", "set2"},
+		},
+	}
+}
+
+// Shot es un plano elegido por el director (o por el operador con Override)
+type Shot struct {
+	CarIndex  uint16
+	Reason    Reason
+	Priority  int
+	CameraSet string
+	Camera    string
+
+	// Start es el tiempo de sesión en que empezó el plano
+	Start time.Duration
+}
+
+// Candidate es un auto que el director podría seguir
+type Candidate struct {
+	CarIndex uint16
+	Reason   Reason
+	Priority int
+
+	// Score desempata candidatos con la misma prioridad
+	Score float64
+}
+
+type carEvent struct {
+	reason Reason
+	at     time.Duration
+}
+
+// Director elige a qué auto y con qué cámara enfocar a partir del
+// leaderboard y los incidentes, y envía los cambios con SetFocusAndCamera
+type Director struct {
+	config     Config
+	controller Controller
+	cameraSets map[string][]string
+
+	positions   []leaderboard.DriverPosition
+	lastPosRank map[uint16]int
+	lastInPit   map[uint16]bool
+	events      map[uint16]carEvent
+	sessionTime time.Duration
+
+	shot         Shot
+	hasShot      bool
+	cameraStart  time.Duration
+	cameraCursor int
+	locked       bool
+
+	callbacks []func(Shot)
+	mu        sync.Mutex
+
+	// sendMu ordena los envíos al Controller para que un plano automático no
+	// llegue a ACC después de un Override; se toma antes que mu
+	sendMu sync.Mutex
+}
+
+// NewDirector crea un director con la configuración por defecto. No envía
+// cambios hasta que se configura un Controller.
+func NewDirector() *Director {
+	return &Director{
+		config:      DefaultConfig(),
+		cameraSets:  make(map[string][]string),
+		lastPosRank: make(map[uint16]int),
+		lastInPit:   make(map[uint16]bool),
+		events:      make(map[uint16]carEvent),
+		callbacks:   make([]func(Shot), 0),
+	}
+}
+
+// SetConfig reemplaza la configuración
+func (d *Director) SetConfig(config Config) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.config = config
+}
+
+// SetController configura a dónde se envían los cambios (nil desactiva el director)
+func (d *Director) SetController(controller Controller) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.controller = controller
+}
+
+// OnShot registra un callback para cada plano enviado a ACC
+func (d *Director) OnShot(callback func(Shot)) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.callbacks = append(d.callbacks, callback)
+}
+
+// UpdateTrackData actualiza las cámaras disponibles en el circuito
+func (d *Director) UpdateTrackData(trackData *broadcast.TrackData) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.cameraSets = make(map[string][]string, len(trackData.CameraSets))
+	for set, cameras := range trackData.CameraSets {
+		d.cameraSets[set] = append([]string(nil), cameras...)
+	}
+}
+
+// HandleIncident registra un incidente como candidato
+func (d *Director) HandleIncident(incident incidents.Incident) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.events[incident.CarIndex] = carEvent{reason: ReasonIncident, at: d.sessionTime}
+}
+
+// Update procesa el leaderboard de un ciclo de broadcast y, si corresponde,
+// cambia de plano. Devuelve el plano enviado a ACC, si hubo cambio.
+func (d *Director) Update(positions []leaderboard.DriverPosition, sessionTime time.Duration) (Shot, bool) {
+	d.mu.Lock()
+
+	d.sessionTime = sessionTime
+	d.positions = append(d.positions[:0], positions...)
+	d.detectEvents()
+	d.expireEvents()
+
+	previous, hadShot, cameraStart := d.shot, d.hasShot, d.cameraStart
+	shot, changed := d.decide()
+	if !changed {
+		d.mu.Unlock()
+		return Shot{}, false
+	}
+
+	d.mu.Unlock()
+
+	d.sendMu.Lock()
+	defer d.sendMu.Unlock()
+
+	// Un Override entre la decisión y el envío gana: no se envía el plano
+	d.mu.Lock()
+	if d.locked || d.controller == nil || !d.hasShot || d.shot != shot {
+		d.mu.Unlock()
+		return Shot{}, false
+	}
+	controller := d.controller
+	callbacks := d.callbacks
+	d.mu.Unlock()
+
+	if err := controller.SetFocusAndCamera(shot.CarIndex, shot.CameraSet, shot.Camera); err != nil {
+		// Se reintenta en el próximo ciclo; Override espera a sendMu, pero
+		// Reset pudo cambiar el plano mientras tanto
+		d.mu.Lock()
+		if d.hasShot && d.shot == shot {
+			d.shot, d.hasShot, d.cameraStart = previous, hadShot, cameraStart
+		}
+		d.mu.Unlock()
+		return Shot{}, false
+	}
+
+	for _, callback := range callbacks {
+		callback(shot)
+	}
+	return shot, true
+}
+
+// Lock detiene los cambios automáticos hasta Unlock; el operador tiene el control
+func (d *Director) Lock() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.locked = true
+}
+
+// Unlock devuelve el control al director. El plano actual se mantiene al
+// menos MinDwell desde el último cambio.
+func (d *Director) Unlock() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.locked = false
+}
+
+// IsLocked indica si el operador tiene el control
+func (d *Director) IsLocked() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.locked
+}
+
+// Override enfoca un auto y cámara elegidos por el operador y bloquea el director
+func (d *Director) Override(carIndex uint16, cameraSet, camera string) error {
+	d.sendMu.Lock()
+	defer d.sendMu.Unlock()
+
+	d.mu.Lock()
+	controller := d.controller
+	d.locked = true
+	d.mu.Unlock()
+
+	if controller != nil {
+		if err := controller.SetFocusAndCamera(carIndex, cameraSet, camera); err != nil {
+			return err
+		}
+	}
+
+	d.mu.Lock()
+	d.setShotLocked(Shot{
+		CarIndex:  carIndex,
+		Reason:    ReasonManual,
+		Priority:  d.config.Priorities[ReasonManual],
+		CameraSet: cameraSet,
+		Camera:    camera,
+		Start:     d.sessionTime,
+	})
+	d.mu.Unlock()
+	return nil
+}
+
+// CurrentShot devuelve el plano actual
+func (d *Director) CurrentShot() (Shot, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.shot, d.hasShot
+}
+
+// Candidates devuelve los candidatos actuales ordenados de mejor a peor
+func (d *Director) Candidates() []Candidate {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.candidatesLocked()
+}
+
+// Reset olvida el estado de la sesión, manteniendo configuración y bloqueo
+func (d *Director) Reset() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.positions = nil
+	d.lastPosRank = make(map[uint16]int)
+	d.lastInPit = make(map[uint16]bool)
+	d.events = make(map[uint16]carEvent)
+	d.sessionTime = 0
+	d.shot = Shot{}
+	d.hasShot = false
+}
+
+// detectEvents compara el leaderboard con el anterior para encontrar
+// adelantamientos y entradas a boxes
+func (d *Director) detectEvents() {
+	for _, pos := range d.positions {
+		inPit := pos.Location != broadcast.CarLocationTrack && pos.Location != broadcast.CarLocationNone
+		wasInPit, known := d.lastInPit[pos.CarIndex]
+		previous, hasPrevious := d.lastPosRank[pos.CarIndex]
+
+		switch {
+		case known && inPit && !wasInPit:
+			d.events[pos.CarIndex] = carEvent{reason: ReasonPitStop, at: d.sessionTime}
+		case hasPrevious && !inPit && pos.Position > 0 && pos.Position < previous:
+			// Las posiciones ganadas por paradas de otros no son adelantamientos
+			if !d.positionGainedInPits(pos) {
+				d.events[pos.CarIndex] = carEvent{reason: ReasonPositionChange, at: d.sessionTime}
+			}
+		}
+
+		d.lastInPit[pos.CarIndex] = inPit
+		if pos.Position > 0 {
+			d.lastPosRank[pos.CarIndex] = pos.Position
+		}
+	}
+}
+
+// positionGainedInPits indica si el auto que ahora va detrás está en boxes
+func (d *Director) positionGainedInPits(pos leaderboard.DriverPosition) bool {
+	for _, other := range d.positions {
+		if other.Position == pos.Position+1 {
+			return other.Location != broadcast.CarLocationTrack
+		}
+	}
+	return false
+}
+
+func (d *Director) expireEvents() {
+	for carIndex, event := range d.events {
+		if d.sessionTime-event.at > d.config.EventWindow || d.sessionTime < event.at {
+			delete(d.events, carIndex)
+		}
+	}
+}
+
+// candidatesLocked lista los autos a seguir, de mejor a peor
+func (d *Director) candidatesLocked() []Candidate {
+	candidates := make([]Candidate, 0)
+	onTrack := make(map[uint16]bool, len(d.positions))
+	for _, pos := range d.positions {
+		onTrack[pos.CarIndex] = true
+	}
+
+	for carIndex, event := range d.events {
+		if !onTrack[carIndex] {
+			continue
+		}
+		// Los eventos más recientes primero
+		candidates = append(candidates, Candidate{
+			CarIndex: carIndex,
+			Reason:   event.reason,
+			Priority: d.config.Priorities[event.reason],
+			Score:    event.at.Seconds(),
+		})
+	}
+
+	for i, pos := range d.positions {
+		if i == 0 {
+			candidates = append(candidates, Candidate{
+				CarIndex: pos.CarIndex,
+				Reason:   ReasonLeader,
+				Priority: d.config.Priorities[ReasonLeader],
+			})
+			continue
+		}
+
+		interval := time.Duration(pos.IntervalMS) * time.Millisecond
+		ahead := d.positions[i-1]
+		if interval <= 0 || interval > d.config.BattleGap {
+			continue
+		}
+		if pos.Location != broadcast.CarLocationTrack || ahead.Location != broadcast.CarLocationTrack {
+			continue
+		}
+
+		// Se sigue al atacante; mejor cuanto más cerca y más adelante
+		candidates = append(candidates, Candidate{
+			CarIndex: pos.CarIndex,
+			Reason:   ReasonBattle,
+			Priority: d.config.Priorities[ReasonBattle],
+			Score:    1/(1+interval.Seconds()) + 1/float64(pos.Position),
+		})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].Priority != candidates[j].Priority {
+			return candidates[i].Priority > candidates[j].Priority
+		}
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		return candidates[i].CarIndex < candidates[j].CarIndex
+	})
+	return candidates
+}
+
+// decide elige el próximo plano respetando el bloqueo y los tiempos mínimos
+func (d *Director) decide() (Shot, bool) {
+	if d.controller == nil || d.locked || len(d.positions) == 0 {
+		return Shot{}, false
+	}
+
+	candidates := d.candidatesLocked()
+	if len(candidates) == 0 {
+		return Shot{}, false
+	}
+	best := candidates[0]
+
+	if d.hasShot {
+		onShot := d.sessionTime - d.shot.Start
+		if onShot < d.config.MinDwell && onShot >= 0 {
+			return Shot{}, false
+		}
+
+		// La prioridad del plano es la del mejor candidato del auto actual;
+		// baja cuando expira el evento que lo motivó
+		current := 0
+		for _, candidate := range candidates {
+			if candidate.CarIndex == d.shot.CarIndex {
+				current = candidate.Priority
+				break
+			}
+		}
+		d.shot.Priority = current
+
+		switch {
+		case onShot >= d.config.MaxDwell:
+			// Plano demasiado largo: pasar al mejor candidato de otro auto
+			other, found := Candidate{}, false
+			for _, candidate := range candidates {
+				if candidate.CarIndex != d.shot.CarIndex {
+					other, found = candidate, true
+					break
+				}
+			}
+			if found {
+				best = other
+				break
+			}
+			return d.rotateCamera(best)
+
+		case best.CarIndex == d.shot.CarIndex:
+			return d.rotateCamera(best)
+
+		case best.Priority <= current:
+			// Un candidato de menor prioridad espera a que termine el plano actual
+			return Shot{}, false
+		}
+	}
+
+	shot := Shot{
+		CarIndex: best.CarIndex,
+		Reason:   best.Reason,
+		Priority: best.Priority,
+		Start:    d.sessionTime,
+	}
+	shot.CameraSet, shot.Camera = d.pickCamera(best.Reason, false)
+	d.setShotLocked(shot)
+	return shot, true
+}
+
+// rotateCamera sigue en el mismo auto y cambia de cámara cada CameraDwell
+func (d *Director) rotateCamera(best Candidate) (Shot, bool) {
+	d.shot.Reason, d.shot.Priority = best.Reason, best.Priority
+	if d.sessionTime-d.cameraStart < d.config.CameraDwell {
+		return Shot{}, false
+	}
+
+	d.shot.CameraSet, d.shot.Camera = d.pickCamera(best.Reason, true)
+	d.cameraStart = d.sessionTime
+	return d.shot, true
+}
+
+func (d *Director) setShotLocked(shot Shot) {
+	d.shot = shot
+	d.hasShot = true
+	d.cameraStart = shot.Start
+}
+
+// pickCamera elige el set preferido para el motivo que exista en el circuito
+// y rota entre sus cámaras. next fuerza una cámara distinta a la actual.
+func (d *Director) pickCamera(reason Reason, next bool) (string, string) {
+	set := ""
+	for _, preferred := range d.config.CameraSets[reason] {
+		if cameras, ok := d.cameraSets[preferred]; ok && len(cameras) > 0 {
+			set = preferred
+			break
+		}
+	}
+
+	// Sin sets conocidos se usa el primero en orden alfabético
+	if set == "" {
+		names := make([]string, 0, len(d.cameraSets))
+		for name, cameras := range d.cameraSets {
+			if len(cameras) > 0 {
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			return d.shot.CameraSet, d.shot.Camera
+		}
+		sort.Strings(names)
+		set = names[0]
+	}
+
+	cameras := d.cameraSets[set]
+	if next || set == d.shot.CameraSet {
+		d.cameraCursor++
+	}
+	camera := cameras[d.cameraCursor%len(cameras)]
+	if next && set == d.shot.CameraSet && camera == d.shot.Camera && len(cameras) > 1 {
+		d.cameraCursor++
+		camera = cameras[d.cameraCursor%len(cameras)]
+	}
+	return set, camera
+}
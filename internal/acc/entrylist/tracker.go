@@ -30,7 +30,7 @@ type CarData struct {
 	Drivers []DriverInfo
 
 	// Datos en tiempo real
-	RealtimeUpdate *broadcast.RealtimeCarUpdate
+	RealtimeUpdate *broadcast.CarUpdate
 
 	// Control
 	LastUpdate time.Time
@@ -89,7 +89,7 @@ func (elt *EntryListTracker) UpdateCarInfo(carInfo *broadcast.CarInfo) {
 }
 
 // UpdateRealtimeCarUpdate actualiza los datos en tiempo real de un auto
-func (elt *EntryListTracker) UpdateRealtimeCarUpdate(carUpdate *broadcast.RealtimeCarUpdate) {
+func (elt *EntryListTracker) UpdateRealtimeCarUpdate(carUpdate *broadcast.CarUpdate) {
 	elt.mu.Lock()
 	defer elt.mu.Unlock()
 
@@ -104,7 +104,11 @@ func (elt *EntryListTracker) UpdateRealtimeCarUpdate(carUpdate *broadcast.Realti
 		elt.cars[carIndex] = carData
 	}
 
-	carData.RealtimeUpdate = carUpdate
+	// Se copia: quien llama reutiliza carUpdate en el siguiente ciclo
+	if carData.RealtimeUpdate == nil {
+		carData.RealtimeUpdate = &broadcast.CarUpdate{}
+	}
+	*carData.RealtimeUpdate = *carUpdate
 	carData.LastUpdate = time.Now()
 }
 
@@ -136,6 +140,14 @@ func (elt *EntryListTracker) GetCarCount() int {
 	return len(elt.cars)
 }
 
+// RemoveCar elimina un auto que dejó la sesión
+func (elt *EntryListTracker) RemoveCar(carIndex uint16) {
+	elt.mu.Lock()
+	defer elt.mu.Unlock()
+
+	delete(elt.cars, carIndex)
+}
+
 // Cleanup limpia autos que no han recibido actualizaciones en un tiempo
 func (elt *EntryListTracker) Cleanup(maxAge time.Duration) {
 	elt.mu.Lock()
@@ -0,0 +1,221 @@
+package events
+
+import (
+	"sync"
+	"time"
+
+	"RaceAll/internal/sharedmemory"
+)
+
+// EventType representa el tipo de evento detectado en shared memory
+type EventType int
+
+const (
+	EventLapCompleted EventType = iota
+	EventSectorChanged
+	EventPitLaneEntered
+	EventPitLaneExited
+	EventPitBoxEntered
+	EventPitBoxExited
+	EventFlagChanged
+	EventSessionChanged
+	EventStatusChanged
+)
+
+// String devuelve el nombre del tipo de evento
+func (t EventType) String() string {
+	switch t {
+	case EventLapCompleted:
+		return "LapCompleted"
+	case EventSectorChanged:
+		return "SectorChanged"
+	case EventPitLaneEntered:
+		return "PitLaneEntered"
+	case EventPitLaneExited:
+		return "PitLaneExited"
+	case EventPitBoxEntered:
+		return "PitBoxEntered"
+	case EventPitBoxExited:
+		return "PitBoxExited"
+	case EventFlagChanged:
+		return "FlagChanged"
+	case EventSessionChanged:
+		return "SessionChanged"
+	case EventStatusChanged:
+		return "StatusChanged"
+	default:
+		return "Unknown"
+	}
+}
+
+// Event es un cambio detectado entre dos frames consecutivos
+type Event struct {
+	Type      EventType
+	Timestamp time.Time
+
+	// Previous y Current son el valor anterior y nuevo del campo que cambió
+	// (vueltas, sector, bandera, estado, índice de sesión...)
+	Previous int32
+	Current  int32
+
+	CompletedLaps int32
+	Sector        int32
+	Fuel          float32
+
+	// LapTimeMs es el tiempo de la vuelta (LapCompleted) o del sector
+	// anterior (SectorChanged) en milisegundos
+	LapTimeMs int32
+	IsValid   bool
+}
+
+// Flag devuelve la bandera de un evento FlagChanged
+func (e Event) Flag() sharedmemory.ACFlagType {
+	return sharedmemory.ACFlagType(e.Current)
+}
+
+// Status devuelve el estado de un evento StatusChanged
+func (e Event) Status() sharedmemory.ACStatus {
+	return sharedmemory.ACStatus(e.Current)
+}
+
+// EventDetector convierte frames consecutivos de shared memory en eventos
+type EventDetector struct {
+	last        sharedmemory.Graphics
+	initialized bool
+	callbacks   []func(Event)
+	mu          sync.Mutex
+}
+
+// NewEventDetector crea un nuevo detector de eventos
+func NewEventDetector() *EventDetector {
+	return &EventDetector{
+		callbacks: make([]func(Event), 0),
+	}
+}
+
+// OnEvent registra un callback para cada evento detectado
+func (ed *EventDetector) OnEvent(callback func(Event)) {
+	ed.mu.Lock()
+	defer ed.mu.Unlock()
+	ed.callbacks = append(ed.callbacks, callback)
+}
+
+// Update compara el frame con el anterior y devuelve los eventos detectados.
+// El primer frame solo establece la referencia y no genera eventos.
+func (ed *EventDetector) Update(physics *sharedmemory.Physics, graphics *sharedmemory.Graphics) []Event {
+	if graphics == nil {
+		return nil
+	}
+
+	ed.mu.Lock()
+
+	if !ed.initialized {
+		ed.last = *graphics
+		ed.initialized = true
+		ed.mu.Unlock()
+		return nil
+	}
+
+	// Frame repetido, nada que comparar
+	if graphics.PacketId == ed.last.PacketId {
+		ed.mu.Unlock()
+		return nil
+	}
+
+	now := time.Now()
+	prev := &ed.last
+	var detected []Event
+
+	newEvent := func(eventType EventType, previous, current int32) Event {
+		event := Event{
+			Type:          eventType,
+			Timestamp:     now,
+			Previous:      previous,
+			Current:       current,
+			CompletedLaps: graphics.CompletedLaps,
+			Sector:        graphics.CurrentSectorIndex,
+			IsValid:       graphics.IsValidLap != 0,
+		}
+		if physics != nil {
+			event.Fuel = physics.Fuel
+		}
+		return event
+	}
+
+	if graphics.Status != prev.Status {
+		detected = append(detected, newEvent(EventStatusChanged, int32(prev.Status), int32(graphics.Status)))
+	}
+
+	// Un cambio de sesión reinicia vueltas y sectores, no son eventos de vuelta
+	sessionChanged := graphics.SessionIndex != prev.SessionIndex || graphics.Session != prev.Session
+	if sessionChanged {
+		detected = append(detected, newEvent(EventSessionChanged, prev.SessionIndex, graphics.SessionIndex))
+	}
+
+	if !sessionChanged && graphics.Status != sharedmemory.ACOff {
+		if graphics.CompletedLaps > prev.CompletedLaps {
+			event := newEvent(EventLapCompleted, prev.CompletedLaps, graphics.CompletedLaps)
+			event.LapTimeMs = graphics.ILastTime
+			// IsValidLap ya se refiere a la vuelta nueva
+			event.IsValid = prev.IsValidLap != 0
+			detected = append(detected, event)
+		}
+
+		if graphics.CurrentSectorIndex != prev.CurrentSectorIndex {
+			event := newEvent(EventSectorChanged, prev.CurrentSectorIndex, graphics.CurrentSectorIndex)
+			event.LapTimeMs = graphics.LastSectorTime
+			detected = append(detected, event)
+		}
+	}
+
+	if graphics.IsInPitLane != prev.IsInPitLane {
+		if graphics.IsInPitLane != 0 {
+			detected = append(detected, newEvent(EventPitLaneEntered, prev.IsInPitLane, graphics.IsInPitLane))
+		} else {
+			detected = append(detected, newEvent(EventPitLaneExited, prev.IsInPitLane, graphics.IsInPitLane))
+		}
+	}
+
+	if graphics.IsInPit != prev.IsInPit {
+		if graphics.IsInPit != 0 {
+			detected = append(detected, newEvent(EventPitBoxEntered, prev.IsInPit, graphics.IsInPit))
+		} else {
+			detected = append(detected, newEvent(EventPitBoxExited, prev.IsInPit, graphics.IsInPit))
+		}
+	}
+
+	if graphics.Flag != prev.Flag {
+		detected = append(detected, newEvent(EventFlagChanged, int32(prev.Flag), int32(graphics.Flag)))
+	}
+
+	ed.last = *graphics
+	callbacks := ed.callbacks
+	ed.mu.Unlock()
+
+	// Llamar callbacks fuera del lock
+	for _, event := range detected {
+		for _, callback := range callbacks {
+			callback(event)
+		}
+	}
+
+	return detected
+}
+
+// Reset olvida el último frame; el siguiente vuelve a ser la referencia
+func (ed *EventDetector) Reset() {
+	ed.mu.Lock()
+	defer ed.mu.Unlock()
+	ed.initialized = false
+	ed.last = sharedmemory.Graphics{}
+}
+
+// HasEvent indica si la lista contiene un evento del tipo dado
+func HasEvent(events []Event, eventType EventType) bool {
+	for _, event := range events {
+		if event.Type == eventType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,340 @@
+package highlights
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"RaceAll/internal/broadcast"
+	"RaceAll/internal/logger"
+)
+
+const (
+	// Intervalo mínimo (tiempo de sesión) entre dos highlights enviados a ACC.
+	// Un accidente múltiple genera varios eventos seguidos que ya quedan
+	// cubiertos por el mismo highlight.
+	DefaultMinInterval = 3 * time.Second
+
+	// Versión del formato del índice local
+	IndexVersion = 1
+)
+
+// Saver envía el comando SaveManualReplayHighlight a ACC
+// (broadcast.Service y broadcast.Client lo implementan)
+type Saver interface {
+	SaveManualReplayHighlight() error
+}
+
+// Highlight es una entrada del índice local de highlights
+type Highlight struct {
+	ID int
+
+	// Manual indica que se creó con Bookmark; si no, EventType es el evento que lo generó
+	Manual    bool
+	EventType broadcast.BroadcastingEventType
+
+	SessionTime time.Duration
+	CarIndex    int32 // -1 si no hay auto asociado
+	RaceNumber  int32
+	DriverName  string
+	Message     string
+	Note        string
+	CreatedAt   time.Time
+
+	// Saved indica si se envió SaveManualReplayHighlight a ACC para esta entrada
+	Saved bool
+}
+
+type highlightIndex struct {
+	Version    int
+	Highlights []Highlight
+}
+
+// HighlightManager guarda highlights de replay en ACC al ocurrir ciertos
+// eventos de broadcast y mantiene un índice local con tiempo de sesión y auto
+type HighlightManager struct {
+	saver        Saver
+	autoEvents   map[broadcast.BroadcastingEventType]bool
+	minInterval  time.Duration
+	highlights   []Highlight
+	nextID       int
+	sessionTime  time.Duration
+	lastSaved    time.Duration
+	hasLastSaved bool
+	callbacks    []func(Highlight)
+	mu           sync.RWMutex
+}
+
+// NewHighlightManager crea un gestor que guarda automáticamente accidentes y
+// mejores vueltas de la sesión
+func NewHighlightManager() *HighlightManager {
+	return &HighlightManager{
+		autoEvents: map[broadcast.BroadcastingEventType]bool{
+			broadcast.BroadcastingEventTypeAccident:       true,
+			broadcast.BroadcastingEventTypeBestSessionLap: true,
+		},
+		minInterval: DefaultMinInterval,
+		highlights:  make([]Highlight, 0),
+		nextID:      1,
+		callbacks:   make([]func(Highlight), 0),
+	}
+}
+
+// SetSaver configura a dónde se envían los highlights. Sin saver solo se
+// actualiza el índice local.
+func (hm *HighlightManager) SetSaver(saver Saver) {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+	hm.saver = saver
+}
+
+// SetAutoSaveEvents reemplaza los tipos de evento que generan un highlight.
+// Sin argumentos desactiva el guardado automático.
+func (hm *HighlightManager) SetAutoSaveEvents(types ...broadcast.BroadcastingEventType) {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+
+	hm.autoEvents = make(map[broadcast.BroadcastingEventType]bool, len(types))
+	for _, t := range types {
+		hm.autoEvents[t] = true
+	}
+}
+
+// SetMinInterval configura el intervalo mínimo entre highlights enviados a ACC
+func (hm *HighlightManager) SetMinInterval(interval time.Duration) {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+
+	if interval < 0 {
+		interval = 0
+	}
+	hm.minInterval = interval
+}
+
+// OnHighlight registra un callback para cada entrada nueva del índice
+func (hm *HighlightManager) OnHighlight(callback func(Highlight)) {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+	hm.callbacks = append(hm.callbacks, callback)
+}
+
+// UpdateSessionTime actualiza el tiempo de sesión usado para los highlights
+// manuales y para eventos sin tiempo
+func (hm *HighlightManager) UpdateSessionTime(sessionTime time.Duration) {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+	hm.sessionTime = sessionTime
+}
+
+// HandleEvent procesa un evento de broadcast. Si su tipo está seleccionado
+// crea una entrada en el índice y guarda el highlight en ACC.
+func (hm *HighlightManager) HandleEvent(event *broadcast.BroadcastingEvent, carInfo *broadcast.CarInfo) (Highlight, bool) {
+	if event == nil {
+		return Highlight{}, false
+	}
+
+	hm.mu.Lock()
+	if !hm.autoEvents[event.Type] {
+		hm.mu.Unlock()
+		return Highlight{}, false
+	}
+
+	sessionTime := hm.sessionTime
+	if event.TimeMs > 0 {
+		sessionTime = time.Duration(event.TimeMs) * time.Millisecond
+	}
+
+	highlight := Highlight{
+		EventType:   event.Type,
+		SessionTime: sessionTime,
+		CarIndex:    event.CarId,
+		Message:     event.Msg,
+	}
+	if carInfo == nil {
+		carInfo = event.CarData
+	}
+	if carInfo != nil {
+		highlight.RaceNumber = carInfo.RaceNumber
+		highlight.DriverName = carInfo.GetCurrentDriverName()
+	}
+
+	// Eventos muy seguidos quedan cubiertos por el highlight anterior
+	save := !hm.hasLastSaved || sessionTime-hm.lastSaved >= hm.minInterval || sessionTime < hm.lastSaved
+	highlight, pending := hm.addLocked(highlight, save)
+	hm.mu.Unlock()
+
+	highlight, err := hm.finish(highlight, pending)
+	if err != nil {
+		logger.Warnf("Failed to save replay highlight %d: %v", highlight.ID, err)
+	}
+	return highlight, true
+}
+
+// Bookmark guarda un highlight manual del auto indicado (-1 para ninguno)
+func (hm *HighlightManager) Bookmark(carIndex int32, note string) (Highlight, error) {
+	hm.mu.Lock()
+	highlight, pending := hm.addLocked(Highlight{
+		Manual:      true,
+		SessionTime: hm.sessionTime,
+		CarIndex:    carIndex,
+		Note:        note,
+	}, true)
+	hm.mu.Unlock()
+
+	return hm.finish(highlight, pending)
+}
+
+// pendingSave es un highlight que falta enviar a ACC, con el último guardado
+// anterior para restaurarlo si el envío falla
+type pendingSave struct {
+	saver        Saver
+	lastSaved    time.Duration
+	hasLastSaved bool
+}
+
+// addLocked agrega la entrada al índice. Si hay que guardarla en ACC reserva
+// el intervalo mínimo y devuelve el envío pendiente, que se hace sin el lock.
+func (hm *HighlightManager) addLocked(highlight Highlight, save bool) (Highlight, *pendingSave) {
+	highlight.ID = hm.nextID
+	highlight.CreatedAt = time.Now()
+	hm.nextID++
+	hm.highlights = append(hm.highlights, highlight)
+
+	if !save || hm.saver == nil {
+		return highlight, nil
+	}
+
+	pending := &pendingSave{saver: hm.saver, lastSaved: hm.lastSaved, hasLastSaved: hm.hasLastSaved}
+	hm.lastSaved = highlight.SessionTime
+	hm.hasLastSaved = true
+	return highlight, pending
+}
+
+// finish envía el highlight pendiente a ACC, marca la entrada como guardada
+// y avisa a los callbacks
+func (hm *HighlightManager) finish(highlight Highlight, pending *pendingSave) (Highlight, error) {
+	var err error
+	if pending != nil {
+		err = pending.saver.SaveManualReplayHighlight()
+	}
+
+	hm.mu.Lock()
+	switch {
+	case pending != nil && err == nil:
+		highlight.Saved = true
+		for i := range hm.highlights {
+			if hm.highlights[i].ID == highlight.ID {
+				hm.highlights[i].Saved = true
+				break
+			}
+		}
+	case pending != nil && hm.hasLastSaved && hm.lastSaved == highlight.SessionTime:
+		// El próximo evento vuelve a intentarlo
+		hm.lastSaved, hm.hasLastSaved = pending.lastSaved, pending.hasLastSaved
+	}
+	callbacks := hm.callbacks
+	hm.mu.Unlock()
+
+	for _, callback := range callbacks {
+		callback(highlight)
+	}
+	return highlight, err
+}
+
+// GetHighlights devuelve todas las entradas del índice en orden de creación
+func (hm *HighlightManager) GetHighlights() []Highlight {
+	hm.mu.RLock()
+	defer hm.mu.RUnlock()
+
+	result := make([]Highlight, len(hm.highlights))
+	copy(result, hm.highlights)
+	return result
+}
+
+// GetHighlightsForCar devuelve las entradas de un auto
+func (hm *HighlightManager) GetHighlightsForCar(carIndex int32) []Highlight {
+	hm.mu.RLock()
+	defer hm.mu.RUnlock()
+
+	result := make([]Highlight, 0)
+	for _, highlight := range hm.highlights {
+		if highlight.CarIndex == carIndex {
+			result = append(result, highlight)
+		}
+	}
+	return result
+}
+
+// WriteIndex escribe el índice en JSON
+func (hm *HighlightManager) WriteIndex(w io.Writer) error {
+	index := highlightIndex{
+		Version:    IndexVersion,
+		Highlights: hm.GetHighlights(),
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(index)
+}
+
+// LoadIndex reemplaza el índice con uno escrito por WriteIndex
+func (hm *HighlightManager) LoadIndex(r io.Reader) error {
+	var index highlightIndex
+	if err := json.NewDecoder(r).Decode(&index); err != nil {
+		return err
+	}
+
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+
+	hm.highlights = index.Highlights
+	if hm.highlights == nil {
+		hm.highlights = make([]Highlight, 0)
+	}
+	hm.nextID = 1
+	for _, highlight := range hm.highlights {
+		if highlight.ID >= hm.nextID {
+			hm.nextID = highlight.ID + 1
+		}
+	}
+	return nil
+}
+
+// SaveIndexFile escribe el índice en un archivo
+func (hm *HighlightManager) SaveIndexFile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err := hm.WriteIndex(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// LoadIndexFile carga el índice desde un archivo
+func (hm *HighlightManager) LoadIndexFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return hm.LoadIndex(file)
+}
+
+// Reset vacía el índice al cambiar de sesión
+func (hm *HighlightManager) Reset() {
+	hm.mu.Lock()
+	defer hm.mu.Unlock()
+
+	hm.highlights = make([]Highlight, 0)
+	hm.nextID = 1
+	hm.sessionTime = 0
+	hm.lastSaved = 0
+	hm.hasLastSaved = false
+}
@@ -33,7 +33,7 @@ type Incident struct {
 // IncidentTracker rastrea incidentes durante la sesión
 type IncidentTracker struct {
 	incidents          []Incident
-	realtimeCarHistory map[float64]map[uint16]*broadcast.RealtimeCarUpdate
+	realtimeCarHistory map[float64]map[uint16]broadcast.CarUpdate
 	lastAccidentTime   time.Time
 	trackDistance      float32
 	currentSessionTime time.Duration
@@ -52,7 +52,7 @@ const (
 func NewIncidentTracker() *IncidentTracker {
 	return &IncidentTracker{
 		incidents:          make([]Incident, 0),
-		realtimeCarHistory: make(map[float64]map[uint16]*broadcast.RealtimeCarUpdate),
+		realtimeCarHistory: make(map[float64]map[uint16]broadcast.CarUpdate),
 		callbacks:          make([]func(Incident), 0),
 	}
 }
@@ -72,7 +72,7 @@ func (it *IncidentTracker) UpdateTrackData(trackMeters float32) {
 }
 
 // UpdateRealtimeCarUpdate añade datos de actualización en tiempo real al historial
-func (it *IncidentTracker) UpdateRealtimeCarUpdate(carUpdate *broadcast.RealtimeCarUpdate, sessionTime time.Duration) {
+func (it *IncidentTracker) UpdateRealtimeCarUpdate(carUpdate *broadcast.CarUpdate, sessionTime time.Duration) {
 	it.mu.Lock()
 	defer it.mu.Unlock()
 
@@ -85,11 +85,12 @@ func (it *IncidentTracker) UpdateRealtimeCarUpdate(carUpdate *broadcast.Realtime
 
 	// Crear entrada en el historial si no existe
 	if _, exists := it.realtimeCarHistory[float64(key)]; !exists {
-		it.realtimeCarHistory[float64(key)] = make(map[uint16]*broadcast.RealtimeCarUpdate)
+		it.realtimeCarHistory[float64(key)] = make(map[uint16]broadcast.CarUpdate)
 	}
 
-	// Actualizar o añadir datos del auto
-	it.realtimeCarHistory[float64(key)][carUpdate.CarIndex] = carUpdate
+	// Actualizar o añadir datos del auto; se guarda una copia porque quien
+	// llama reutiliza carUpdate
+	it.realtimeCarHistory[float64(key)][carUpdate.CarIndex] = *carUpdate
 
 	// Limpiar historial antiguo
 	it.cleanOldHistory(float64(key))
@@ -131,10 +132,8 @@ func (it *IncidentTracker) HandleAccidentEvent(event *broadcast.BroadcastingEven
 	}
 
 	// Determinar ubicación aproximada
-	if carUpdate != nil {
-		splinePercent := int(carUpdate.SplinePosition * 100)
-		incident.Location = formatLocation(splinePercent)
-	}
+	splinePercent := int(carUpdate.SplinePosition * 100)
+	incident.Location = formatLocation(splinePercent)
 
 	it.incidents = append(it.incidents, incident)
 
@@ -182,7 +181,7 @@ func (it *IncidentTracker) Clear() {
 	defer it.mu.Unlock()
 
 	it.incidents = make([]Incident, 0)
-	it.realtimeCarHistory = make(map[float64]map[uint16]*broadcast.RealtimeCarUpdate)
+	it.realtimeCarHistory = make(map[float64]map[uint16]broadcast.CarUpdate)
 	it.lastAccidentTime = time.Time{}
 }
 
@@ -55,7 +55,7 @@ func NewLapTracker(carIndex uint16) *LapTracker {
 }
 
 // UpdateFromBroadcast actualiza el tracker con datos del broadcast
-func (lt *LapTracker) UpdateFromBroadcast(lapInfo *broadcast.LapInfo) {
+func (lt *LapTracker) UpdateFromBroadcast(lapInfo *broadcast.Lap) {
 	if lapInfo == nil {
 		return
 	}
@@ -74,14 +74,14 @@ func (lt *LapTracker) UpdateFromBroadcast(lapInfo *broadcast.LapInfo) {
 	}
 
 	// Extraer tiempos de sectores
-	if len(lapInfo.Splits) >= 1 && lapInfo.Splits[0] != nil {
-		lap.Sector1 = *lapInfo.Splits[0]
+	if lapInfo.HasSplit(0) {
+		lap.Sector1 = lapInfo.Splits[0]
 	}
-	if len(lapInfo.Splits) >= 2 && lapInfo.Splits[1] != nil {
-		lap.Sector2 = *lapInfo.Splits[1]
+	if lapInfo.HasSplit(1) {
+		lap.Sector2 = lapInfo.Splits[1]
 	}
-	if len(lapInfo.Splits) >= 3 && lapInfo.Splits[2] != nil {
-		lap.Sector3 = *lapInfo.Splits[2]
+	if lapInfo.HasSplit(2) {
+		lap.Sector3 = lapInfo.Splits[2]
 	}
 
 	// Actualizar mejores sectores
@@ -54,7 +54,7 @@ func NewLeaderboardTracker() *LeaderboardTracker {
 // Update actualiza el leaderboard con datos del broadcast
 func (lt *LeaderboardTracker) Update(
 	cars map[uint16]*broadcast.CarInfo,
-	updates map[uint16]*broadcast.RealtimeCarUpdate,
+	updates map[uint16]*broadcast.CarUpdate,
 	sessionType broadcast.RaceSessionType,
 	playerCarIndex uint16,
 ) {
@@ -2,18 +2,23 @@ package acc
 
 import (
 	"RaceAll/internal/acc/cars"
+	"RaceAll/internal/acc/director"
 	"RaceAll/internal/acc/entrylist"
+	"RaceAll/internal/acc/events"
 	"RaceAll/internal/acc/fuel"
 	"RaceAll/internal/acc/gaps"
+	"RaceAll/internal/acc/highlights"
 	"RaceAll/internal/acc/incidents"
 	"RaceAll/internal/acc/laps"
 	"RaceAll/internal/acc/leaderboard"
 	"RaceAll/internal/acc/session"
 	"RaceAll/internal/acc/sessiontime"
+	"RaceAll/internal/acc/stewards"
 	"RaceAll/internal/acc/telemetry"
 	"RaceAll/internal/acc/trackposition"
 	"RaceAll/internal/acc/tracks"
 	"RaceAll/internal/acc/tyres"
+	"RaceAll/internal/acc/world"
 	"RaceAll/internal/broadcast"
 	"RaceAll/internal/sharedmemory"
 )
@@ -32,6 +37,11 @@ type DataManager struct {
 	incidentTracker  *incidents.IncidentTracker
 	sessionTimer     *sessiontime.SessionTimeTracker
 	entryListTracker *entrylist.EntryListTracker
+	eventDetector    *events.EventDetector
+	worldModel       *world.WorldModel
+	highlightManager *highlights.HighlightManager
+	director         *director.Director
+	reviewQueue      *stewards.ReviewQueue
 
 	// Información del auto
 	carModel cars.CarModel
@@ -40,11 +50,21 @@ type DataManager struct {
 
 	// Estado
 	initialized bool
+
+	// spectator indica que no hay shared memory local: el auto de
+	// referencia es el auto enfocado del broadcast
+	spectator bool
+	// spectatorLaps guarda las vueltas de cada auto enfocado en modo
+	// espectador, para no perderlas en cada cambio de foco
+	spectatorLaps map[uint16]*laps.LapTracker
+	// sharedMemory indica que llegaron datos de shared memory desde el
+	// último Initialize
+	sharedMemory bool
 }
 
 // NewDataManager crea un nuevo gestor de datos ACC
 func NewDataManager() *DataManager {
-	return &DataManager{
+	dm := &DataManager{
 		sessionTracker:   session.NewSessionTracker(),
 		leaderboard:      leaderboard.NewLeaderboardTracker(),
 		telemetryProc:    telemetry.NewTelemetryProcessor(),
@@ -53,8 +73,24 @@ func NewDataManager() *DataManager {
 		incidentTracker:  incidents.NewIncidentTracker(),
 		sessionTimer:     sessiontime.NewSessionTimeTracker(),
 		entryListTracker: entrylist.NewEntryListTracker(),
+		eventDetector:    events.NewEventDetector(),
+		worldModel:       world.NewWorldModel(),
+		highlightManager: highlights.NewHighlightManager(),
+		director:         director.NewDirector(),
+		reviewQueue:      stewards.NewReviewQueue(),
 		initialized:      false,
 	}
+
+	// Los incidentes son candidatos del director
+	dm.incidentTracker.OnIncident(dm.director.HandleIncident)
+
+	// y entran a la cola de revisión de los comisarios
+	dm.reviewQueue.SetCarResolver(dm.resolveCar)
+	dm.incidentTracker.OnIncident(func(incident incidents.Incident) {
+		dm.reviewQueue.Add(incident)
+	})
+
+	return dm
 }
 
 // Initialize inicializa el data manager con información del auto
@@ -64,8 +100,10 @@ func (dm *DataManager) Initialize(carModel cars.CarModel, carIndex uint16, track
 	dm.trackID = trackID
 
 	dm.lapTracker = laps.NewLapTracker(carIndex)
+	dm.spectatorLaps = nil
 	dm.fuelCalculator = fuel.NewFuelCalculator(carModel)
 	dm.tyresTracker = tyres.NewTyresTracker()
+	dm.sharedMemory = false
 
 	// Inicializar gap tracker con la distancia del circuito
 	trackInfo := tracks.GetTrackInfo(trackID)
@@ -74,12 +112,34 @@ func (dm *DataManager) Initialize(carModel cars.CarModel, carIndex uint16, track
 	dm.initialized = true
 }
 
+// SetSpectator activa el modo espectador, para sesiones seguidas solo por
+// broadcast (servidor remoto o dedicado). El auto de referencia pasa a ser
+// el auto enfocado y se ignoran los datos de shared memory, que pueden ser
+// de otra sesión del juego local.
+func (dm *DataManager) SetSpectator(spectator bool) {
+	dm.spectator = spectator
+	if spectator {
+		dm.sharedMemory = false
+	}
+}
+
+// IsSpectator devuelve true en modo espectador
+func (dm *DataManager) IsSpectator() bool {
+	return dm.spectator
+}
+
+// HasSharedMemory devuelve true si hay datos de shared memory. Sin ellos la
+// telemetría, el combustible y los neumáticos devuelven valores vacíos.
+func (dm *DataManager) HasSharedMemory() bool {
+	return dm.sharedMemory
+}
+
 // UpdateFromBroadcast actualiza con datos del broadcast
 func (dm *DataManager) UpdateFromBroadcast(
 	realtimeUpdate *broadcast.RealtimeUpdate,
-	carUpdate *broadcast.RealtimeCarUpdate,
+	carUpdate *broadcast.CarUpdate,
 	allCars map[uint16]*broadcast.CarInfo,
-	allUpdates map[uint16]*broadcast.RealtimeCarUpdate,
+	allUpdates map[uint16]*broadcast.CarUpdate,
 ) {
 	if !dm.initialized {
 		return
@@ -90,6 +150,7 @@ func (dm *DataManager) UpdateFromBroadcast(
 
 	// Actualizar session timer
 	dm.sessionTimer.Update(realtimeUpdate.TimeOfDay)
+	dm.highlightManager.UpdateSessionTime(realtimeUpdate.SessionTime)
 
 	// Actualizar entry list
 	if allCars != nil {
@@ -109,11 +170,28 @@ func (dm *DataManager) UpdateFromBroadcast(
 
 			// Actualizar incident tracker con datos en tiempo real
 			dm.incidentTracker.UpdateRealtimeCarUpdate(update, realtimeUpdate.SessionTime)
+
+			// Asociar CarIndex de broadcast con CarID de shared memory
+			dm.worldModel.UpdateFromBroadcast(update)
 		}
 	}
 
+	// En modo espectador se sigue al auto enfocado, con las vueltas que se
+	// le registraron mientras estuvo enfocado
+	if dm.spectator && realtimeUpdate.FocusedCarIndex >= 0 {
+		if focused := uint16(realtimeUpdate.FocusedCarIndex); focused != dm.carIndex {
+			dm.lapTracker = dm.swapSpectatorLapTracker(focused)
+			dm.carIndex = focused
+		}
+		// Sin shared memory el modelo del auto sale de la entry list
+		if carData := dm.entryListTracker.GetCarData(dm.carIndex); carData != nil {
+			dm.carModel = carData.CarModelType
+		}
+		carUpdate = allUpdates[dm.carIndex]
+	}
+
 	// Actualizar vueltas si se completó una
-	if carUpdate != nil && carUpdate.LastLap.HasValidLapTime() {
+	if carUpdate != nil && carUpdate.LastLap.HasLapTime() {
 		dm.lapTracker.UpdateFromBroadcast(&carUpdate.LastLap)
 	}
 
@@ -125,9 +203,28 @@ func (dm *DataManager) UpdateFromBroadcast(
 			realtimeUpdate.SessionType,
 			dm.carIndex,
 		)
+
+		// El director decide el plano con el leaderboard actualizado
+		dm.director.Update(dm.leaderboard.GetPositions(), realtimeUpdate.SessionTime)
 	}
 }
 
+// swapSpectatorLapTracker guarda el tracker del auto enfocado hasta ahora y
+// devuelve el del nuevo auto enfocado
+func (dm *DataManager) swapSpectatorLapTracker(focused uint16) *laps.LapTracker {
+	if dm.spectatorLaps == nil {
+		dm.spectatorLaps = make(map[uint16]*laps.LapTracker)
+	}
+	dm.spectatorLaps[dm.carIndex] = dm.lapTracker
+
+	tracker, exists := dm.spectatorLaps[focused]
+	if !exists {
+		tracker = laps.NewLapTracker(focused)
+		dm.spectatorLaps[focused] = tracker
+	}
+	return tracker
+}
+
 // HandleBroadcastEvent maneja eventos de broadcast (como accidentes)
 func (dm *DataManager) HandleBroadcastEvent(event *broadcast.BroadcastingEvent, carInfo *broadcast.CarInfo) {
 	if !dm.initialized {
@@ -139,6 +236,21 @@ func (dm *DataManager) HandleBroadcastEvent(event *broadcast.BroadcastingEvent,
 	case broadcast.BroadcastingEventTypeAccident:
 		dm.incidentTracker.HandleAccidentEvent(event, carInfo)
 	}
+
+	// Guardar highlight si el tipo de evento está seleccionado
+	dm.highlightManager.HandleEvent(event, carInfo)
+}
+
+// HandleEntryListChange aplica los autos que entran, salen o cambian de
+// piloto, para que el leaderboard no conserve autos desconectados
+func (dm *DataManager) HandleEntryListChange(change *broadcast.EntryListChange) {
+	switch change.Type {
+	case broadcast.CarLeft:
+		dm.entryListTracker.RemoveCar(change.CarIndex)
+		dm.positionGraph.RemoveCar(change.CarIndex)
+	default:
+		dm.entryListTracker.UpdateCarInfo(&change.Car)
+	}
 }
 
 // UpdateTrackData actualiza información del circuito
@@ -148,6 +260,7 @@ func (dm *DataManager) UpdateTrackData(trackData *broadcast.TrackData) {
 	}
 
 	dm.incidentTracker.UpdateTrackData(float32(trackData.TrackMeters))
+	dm.director.UpdateTrackData(trackData)
 }
 
 // UpdateFromSharedMemory actualiza con datos de shared memory
@@ -156,15 +269,22 @@ func (dm *DataManager) UpdateFromSharedMemory(
 	graphics *sharedmemory.Graphics,
 	static *sharedmemory.Static,
 ) {
-	if !dm.initialized {
+	if !dm.initialized || dm.spectator {
 		return
 	}
+	dm.sharedMemory = true
 
 	// Procesar telemetría
 	_ = dm.telemetryProc.ProcessPhysics(physics)
 
-	// Actualizar combustible (detectar vuelta completada)
-	lapCompleted := false // Se puede determinar con lógica adicional
+	// Actualizar posiciones de todos los autos
+	dm.worldModel.Update(graphics)
+
+	// Detectar eventos (vuelta completada, pits, banderas...)
+	detected := dm.eventDetector.Update(physics, graphics)
+
+	// Actualizar combustible
+	lapCompleted := events.HasEvent(detected, events.EventLapCompleted)
 	_ = dm.fuelCalculator.Update(physics.Fuel, lapCompleted)
 
 	// Actualizar neumáticos
@@ -186,18 +306,27 @@ func (dm *DataManager) GetSessionData() *session.SessionState {
 	return dm.sessionTracker.GetCurrentState()
 }
 
-// GetLapData devuelve datos de vueltas
+// GetLapData devuelve datos de vueltas, nil antes de Initialize
 func (dm *DataManager) GetLapData() *laps.LapData {
+	if dm.lapTracker == nil {
+		return nil
+	}
 	return dm.lapTracker.GetBestLap()
 }
 
-// GetFuelData devuelve datos de combustible
+// GetFuelData devuelve datos de combustible, vacíos sin shared memory
 func (dm *DataManager) GetFuelData(currentFuel float32) fuel.FuelData {
+	if dm.fuelCalculator == nil || !dm.sharedMemory {
+		return fuel.FuelData{}
+	}
 	return dm.fuelCalculator.Update(currentFuel, false)
 }
 
-// GetTyresData devuelve datos de neumáticos
+// GetTyresData devuelve datos de neumáticos, vacíos sin shared memory
 func (dm *DataManager) GetTyresData() [4]tyres.TyreData {
+	if dm.tyresTracker == nil || !dm.sharedMemory {
+		return [4]tyres.TyreData{}
+	}
 	return dm.tyresTracker.GetAllTyres()
 }
 
@@ -221,6 +350,7 @@ func (dm *DataManager) Reset() {
 	if dm.lapTracker != nil {
 		dm.lapTracker = laps.NewLapTracker(dm.carIndex)
 	}
+	dm.spectatorLaps = nil
 	if dm.fuelCalculator != nil {
 		dm.fuelCalculator.Reset()
 	}
@@ -232,7 +362,11 @@ func (dm *DataManager) Reset() {
 	dm.incidentTracker.Clear()
 	dm.sessionTimer.Reset()
 	dm.entryListTracker.Clear()
+	dm.eventDetector.Reset()
+	dm.worldModel.Reset()
+	dm.director.Reset()
 	dm.initialized = false
+	dm.sharedMemory = false
 }
 
 // IsInitialized verifica si el manager está inicializado
@@ -290,6 +424,48 @@ func (dm *DataManager) GetSessionTimeTracker() *sessiontime.SessionTimeTracker {
 	return dm.sessionTimer
 }
 
+// GetEventDetector devuelve el detector de eventos de shared memory
+func (dm *DataManager) GetEventDetector() *events.EventDetector {
+	return dm.eventDetector
+}
+
+// GetWorldModel devuelve el modelo de posiciones 3D de los autos
+func (dm *DataManager) GetWorldModel() *world.WorldModel {
+	return dm.worldModel
+}
+
+// GetHighlightManager devuelve el gestor de highlights de replay. El índice
+// no se borra en Reset para conservar los highlights entre sesiones.
+func (dm *DataManager) GetHighlightManager() *highlights.HighlightManager {
+	return dm.highlightManager
+}
+
+// GetDirector devuelve el director automático de cámaras. No envía cambios
+// hasta que se le asigna un Controller (por ejemplo el broadcast.Service).
+func (dm *DataManager) GetDirector() *director.Director {
+	return dm.director
+}
+
+// GetReviewQueue devuelve la cola de revisión de incidentes. Las decisiones
+// no se borran en Reset para poder exportar el reporte al final del evento.
+func (dm *DataManager) GetReviewQueue() *stewards.ReviewQueue {
+	return dm.reviewQueue
+}
+
+// resolveCar completa número y piloto de un auto desde la lista de entrada
+func (dm *DataManager) resolveCar(carIndex uint16) (stewards.InvolvedCar, bool) {
+	carData := dm.entryListTracker.GetCarData(carIndex)
+	if carData == nil {
+		return stewards.InvolvedCar{}, false
+	}
+
+	return stewards.InvolvedCar{
+		CarIndex:   carIndex,
+		RaceNumber: carData.RaceNumber,
+		DriverName: carData.GetCurrentDriverName(),
+	}, true
+}
+
 // GetEntryListTracker devuelve el tracker de lista de entrada
 func (dm *DataManager) GetEntryListTracker() *entrylist.EntryListTracker {
 	return dm.entryListTracker
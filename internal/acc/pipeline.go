@@ -0,0 +1,116 @@
+package acc
+
+import (
+	"sync"
+
+	"RaceAll/internal/broadcast"
+)
+
+// BroadcastPipeline alimenta un DataManager propio con los mensajes de una
+// conexión de broadcast. Se usa como broadcast.Pipeline en un
+// ConnectionRegistry para analizar cada servidor por separado, o en un
+// ConnectionManager, que le pasa su modo de conexión.
+type BroadcastPipeline struct {
+	dataManager *DataManager
+
+	realtimeUpdate *broadcast.RealtimeUpdate
+	cars           map[uint16]*broadcast.CarInfo
+	updates        map[uint16]*broadcast.CarUpdate
+	carIndex       uint16
+	mu             sync.Mutex
+}
+
+var _ broadcast.SpectatorPipeline = (*BroadcastPipeline)(nil)
+
+// NewBroadcastPipeline crea un pipeline con un DataManager nuevo, en modo
+// espectador hasta que se llame a SetSpectator
+func NewBroadcastPipeline() *BroadcastPipeline {
+	// Las conexiones de un ConnectionRegistry se siguen solo por broadcast
+	dataManager := NewDataManager()
+	dataManager.SetSpectator(true)
+
+	return &BroadcastPipeline{
+		dataManager: dataManager,
+		cars:        make(map[uint16]*broadcast.CarInfo),
+		updates:     make(map[uint16]*broadcast.CarUpdate),
+	}
+}
+
+// NewBroadcastPipelineFactory devuelve una fábrica para ConnectionRegistry.SetPipelineFactory
+func NewBroadcastPipelineFactory() broadcast.PipelineFactory {
+	return func(name string) broadcast.Pipeline {
+		return NewBroadcastPipeline()
+	}
+}
+
+// HandleMessage procesa un mensaje de la conexión
+func (bp *BroadcastPipeline) HandleMessage(msg broadcast.BroadcastMessage) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+
+	switch payload := msg.Payload.(type) {
+	case broadcast.ConnectionState:
+		// Una nueva conexión empieza con la sesión vacía
+		if payload.Success && bp.dataManager.IsInitialized() {
+			bp.dataManager.Reset()
+			bp.cars = make(map[uint16]*broadcast.CarInfo)
+			bp.updates = make(map[uint16]*broadcast.CarUpdate)
+		}
+
+	case broadcast.TrackData:
+		// Sin shared memory no hay auto del jugador: se usa el auto enfocado
+		if !bp.dataManager.IsInitialized() {
+			if bp.realtimeUpdate != nil && bp.realtimeUpdate.FocusedCarIndex >= 0 {
+				bp.carIndex = uint16(bp.realtimeUpdate.FocusedCarIndex)
+			}
+			bp.dataManager.Initialize(0, bp.carIndex, payload.TrackId)
+		}
+		bp.dataManager.UpdateTrackData(&payload)
+
+	case broadcast.CarInfo:
+		car := payload
+		bp.cars[car.CarIndex] = &car
+
+	case broadcast.EntryListChange:
+		change := payload
+		if change.Type == broadcast.CarLeft {
+			delete(bp.cars, change.CarIndex)
+			delete(bp.updates, change.CarIndex)
+		} else {
+			bp.cars[change.CarIndex] = &change.Car
+		}
+		bp.dataManager.HandleEntryListChange(&change)
+
+	case broadcast.RealtimeUpdate:
+		update := payload
+		bp.realtimeUpdate = &update
+
+		// Un RealtimeUpdate por ciclo: procesar el estado completo una vez
+		bp.dataManager.UpdateFromBroadcast(&update, bp.updates[bp.carIndex], bp.cars, bp.updates)
+
+	case broadcast.CarUpdate:
+		// Cada auto reutiliza su valor: un car update por auto y ciclo
+		if update, ok := bp.updates[payload.CarIndex]; ok {
+			*update = payload
+		} else {
+			update := payload
+			bp.updates[payload.CarIndex] = &update
+		}
+
+	case broadcast.BroadcastingEvent:
+		event := payload
+		bp.dataManager.HandleBroadcastEvent(&event, bp.cars[uint16(event.CarId)])
+	}
+}
+
+// SetSpectator activa o desactiva el modo espectador del DataManager
+func (bp *BroadcastPipeline) SetSpectator(spectator bool) {
+	bp.mu.Lock()
+	defer bp.mu.Unlock()
+	bp.dataManager.SetSpectator(spectator)
+}
+
+// DataManager devuelve el gestor de datos del pipeline
+func (bp *BroadcastPipeline) DataManager() *DataManager {
+	return bp.dataManager
+}
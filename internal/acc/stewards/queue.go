@@ -0,0 +1,487 @@
+package stewards
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"RaceAll/internal/acc/incidents"
+	"RaceAll/internal/errors"
+)
+
+const moduleName = "stewards"
+
+// Status es el estado de revisión de un incidente
+type Status int
+
+const (
+	StatusPending Status = iota
+	StatusReviewed
+	StatusPenalty
+	StatusNoAction
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusPending:
+		return "Pending"
+	case StatusReviewed:
+		return "Reviewed"
+	case StatusPenalty:
+		return "Penalty"
+	case StatusNoAction:
+		return "NoAction"
+	default:
+		return "Unknown"
+	}
+}
+
+// MarshalText exporta el estado por nombre en el reporte JSON
+func (s Status) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// ReplayRequester pide un instant replay a ACC
+// (broadcast.Service y broadcast.Client lo implementan)
+type ReplayRequester interface {
+	RequestInstantReplay(startSessionTime, durationMS float32, initialFocusedCarIndex int32, cameraSet, camera string) error
+}
+
+// ReplayConfig configura el replay que se abre al revisar un incidente
+type ReplayConfig struct {
+	// PreRoll es cuánto antes del incidente empieza el replay
+	PreRoll time.Duration
+	// Duration es la duración del replay
+	Duration time.Duration
+
+	CameraSet string
+	Camera    string
+}
+
+// DefaultReplayConfig muestra desde 5 segundos antes con la cámara helicóptero
+func DefaultReplayConfig() ReplayConfig {
+	return ReplayConfig{
+		PreRoll:   5 * time.Second,
+		Duration:  15 * time.Second,
+		CameraSet: "Helicam",
+		Camera:    "Helicam",
+	}
+}
+
+// InvolvedCar es un auto implicado en un incidente
+type InvolvedCar struct {
+	CarIndex   uint16
+	RaceNumber int32
+	DriverName string
+}
+
+// CarResolver completa número y piloto de un auto a partir de su índice
+type CarResolver func(carIndex uint16) (InvolvedCar, bool)
+
+// Note es una nota de los comisarios sobre un incidente
+type Note struct {
+	Text      string
+	CreatedAt time.Time
+}
+
+// ReviewEntry es un incidente en la cola de revisión
+type ReviewEntry struct {
+	ID       int
+	Incident incidents.Incident
+	Status   Status
+
+	// Penalty describe la sanción cuando Status es StatusPenalty
+	Penalty string
+
+	InvolvedCars []InvolvedCar
+	Notes        []Note
+
+	// Replays cuenta las veces que se abrió el replay del incidente
+	Replays   int
+	DecidedAt time.Time
+}
+
+// Report es el reporte de comisarios exportado
+type Report struct {
+	GeneratedAt time.Time
+	Summary     map[string]int
+	Entries     []ReviewEntry
+}
+
+// ReviewQueue es la cola de incidentes a revisar por los comisarios
+type ReviewQueue struct {
+	entries  []*ReviewEntry
+	nextID   int
+	replay   ReplayConfig
+	replayer ReplayRequester
+	resolver CarResolver
+	mu       sync.RWMutex
+}
+
+// NewReviewQueue crea una cola vacía con el replay por defecto
+func NewReviewQueue() *ReviewQueue {
+	return &ReviewQueue{
+		entries: make([]*ReviewEntry, 0),
+		nextID:  1,
+		replay:  DefaultReplayConfig(),
+	}
+}
+
+// SetReplayRequester configura a dónde se piden los instant replays
+func (rq *ReviewQueue) SetReplayRequester(replayer ReplayRequester) {
+	rq.mu.Lock()
+	defer rq.mu.Unlock()
+	rq.replayer = replayer
+}
+
+// SetReplayConfig configura el replay que abre Open
+func (rq *ReviewQueue) SetReplayConfig(config ReplayConfig) {
+	rq.mu.Lock()
+	defer rq.mu.Unlock()
+	rq.replay = config
+}
+
+// SetCarResolver configura cómo se completan los autos implicados
+func (rq *ReviewQueue) SetCarResolver(resolver CarResolver) {
+	rq.mu.Lock()
+	defer rq.mu.Unlock()
+	rq.resolver = resolver
+}
+
+// Add agrega un incidente a la cola como pendiente
+func (rq *ReviewQueue) Add(incident incidents.Incident) ReviewEntry {
+	rq.mu.Lock()
+	defer rq.mu.Unlock()
+
+	entry := &ReviewEntry{
+		ID:           rq.nextID,
+		Incident:     incident,
+		Status:       StatusPending,
+		InvolvedCars: make([]InvolvedCar, 0),
+		Notes:        make([]Note, 0),
+	}
+	rq.nextID++
+
+	rq.addInvolvedLocked(entry, InvolvedCar{
+		CarIndex:   incident.CarIndex,
+		RaceNumber: incident.RaceNumber,
+		DriverName: incident.DriverName,
+	})
+	for _, carIndex := range incident.InvolvedCars {
+		rq.addInvolvedLocked(entry, InvolvedCar{CarIndex: carIndex})
+	}
+
+	rq.entries = append(rq.entries, entry)
+	return copyEntry(entry)
+}
+
+// Entries devuelve todas las entradas en orden de llegada
+func (rq *ReviewQueue) Entries() []ReviewEntry {
+	rq.mu.RLock()
+	defer rq.mu.RUnlock()
+
+	result := make([]ReviewEntry, len(rq.entries))
+	for i, entry := range rq.entries {
+		result[i] = copyEntry(entry)
+	}
+	return result
+}
+
+// Pending devuelve las entradas pendientes de revisión
+func (rq *ReviewQueue) Pending() []ReviewEntry {
+	rq.mu.RLock()
+	defer rq.mu.RUnlock()
+
+	result := make([]ReviewEntry, 0)
+	for _, entry := range rq.entries {
+		if entry.Status == StatusPending {
+			result = append(result, copyEntry(entry))
+		}
+	}
+	return result
+}
+
+// Get devuelve una entrada por ID
+func (rq *ReviewQueue) Get(id int) (ReviewEntry, bool) {
+	rq.mu.RLock()
+	defer rq.mu.RUnlock()
+
+	entry := rq.findLocked(id)
+	if entry == nil {
+		return ReviewEntry{}, false
+	}
+	return copyEntry(entry), true
+}
+
+// Open pide a ACC un instant replay del incidente, desde PreRoll antes y
+// enfocado en el auto principal
+func (rq *ReviewQueue) Open(id int) error {
+	rq.mu.Lock()
+	entry := rq.findLocked(id)
+	if entry == nil {
+		rq.mu.Unlock()
+		return errors.NewErrorWithContext(moduleName, "Open", errors.ErrReviewEntryUnknown, fmt.Sprint(id))
+	}
+
+	replayer := rq.replayer
+	config := rq.replay
+	incident := entry.Incident
+	rq.mu.Unlock()
+
+	if replayer == nil {
+		return errors.NewError(moduleName, "Open", errors.ErrNotConnected)
+	}
+
+	start := incident.SessionTime - config.PreRoll
+	if start < 0 {
+		start = 0
+	}
+
+	err := replayer.RequestInstantReplay(
+		float32(start.Milliseconds()),
+		float32(config.Duration.Milliseconds()),
+		int32(incident.CarIndex),
+		config.CameraSet,
+		config.Camera,
+	)
+	if err != nil {
+		return errors.NewError(moduleName, "Open", err)
+	}
+
+	rq.mu.Lock()
+	entry.Replays++
+	rq.mu.Unlock()
+	return nil
+}
+
+// Decide registra la decisión de los comisarios. penalty solo se guarda
+// con StatusPenalty.
+func (rq *ReviewQueue) Decide(id int, status Status, penalty string) error {
+	if status < StatusPending || status > StatusNoAction {
+		return errors.NewErrorWithContext(moduleName, "Decide", errors.ErrInvalidReviewState, status.String())
+	}
+
+	rq.mu.Lock()
+	defer rq.mu.Unlock()
+
+	entry := rq.findLocked(id)
+	if entry == nil {
+		return errors.NewErrorWithContext(moduleName, "Decide", errors.ErrReviewEntryUnknown, fmt.Sprint(id))
+	}
+
+	entry.Status = status
+	entry.Penalty = ""
+	if status == StatusPenalty {
+		entry.Penalty = penalty
+	}
+
+	entry.DecidedAt = time.Time{}
+	if status != StatusPending {
+		entry.DecidedAt = time.Now()
+	}
+	return nil
+}
+
+// AddNote agrega una nota a una entrada
+func (rq *ReviewQueue) AddNote(id int, text string) error {
+	rq.mu.Lock()
+	defer rq.mu.Unlock()
+
+	entry := rq.findLocked(id)
+	if entry == nil {
+		return errors.NewErrorWithContext(moduleName, "AddNote", errors.ErrReviewEntryUnknown, fmt.Sprint(id))
+	}
+
+	entry.Notes = append(entry.Notes, Note{Text: text, CreatedAt: time.Now()})
+	return nil
+}
+
+// AddInvolvedCar agrega un auto implicado (sin duplicados)
+func (rq *ReviewQueue) AddInvolvedCar(id int, carIndex uint16) error {
+	rq.mu.Lock()
+	defer rq.mu.Unlock()
+
+	entry := rq.findLocked(id)
+	if entry == nil {
+		return errors.NewErrorWithContext(moduleName, "AddInvolvedCar", errors.ErrReviewEntryUnknown, fmt.Sprint(id))
+	}
+
+	rq.addInvolvedLocked(entry, InvolvedCar{CarIndex: carIndex})
+	return nil
+}
+
+// RemoveInvolvedCar quita un auto de los implicados
+func (rq *ReviewQueue) RemoveInvolvedCar(id int, carIndex uint16) error {
+	rq.mu.Lock()
+	defer rq.mu.Unlock()
+
+	entry := rq.findLocked(id)
+	if entry == nil {
+		return errors.NewErrorWithContext(moduleName, "RemoveInvolvedCar", errors.ErrReviewEntryUnknown, fmt.Sprint(id))
+	}
+
+	cars := entry.InvolvedCars[:0]
+	for _, car := range entry.InvolvedCars {
+		if car.CarIndex != carIndex {
+			cars = append(cars, car)
+		}
+	}
+	entry.InvolvedCars = cars
+	return nil
+}
+
+// Report genera el reporte con todas las entradas y un resumen por estado
+func (rq *ReviewQueue) Report() Report {
+	entries := rq.Entries()
+
+	summary := map[string]int{
+		StatusPending.String():  0,
+		StatusReviewed.String(): 0,
+		StatusPenalty.String():  0,
+		StatusNoAction.String(): 0,
+	}
+	for _, entry := range entries {
+		summary[entry.Status.String()]++
+	}
+
+	return Report{
+		GeneratedAt: time.Now(),
+		Summary:     summary,
+		Entries:     entries,
+	}
+}
+
+// WriteReportJSON escribe el reporte en JSON
+func (rq *ReviewQueue) WriteReportJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(rq.Report())
+}
+
+// WriteReport escribe el reporte en texto, ordenado por tiempo de sesión
+func (rq *ReviewQueue) WriteReport(w io.Writer) error {
+	report := rq.Report()
+	sort.SliceStable(report.Entries, func(i, j int) bool {
+		return report.Entries[i].Incident.SessionTime < report.Entries[j].Incident.SessionTime
+	})
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Steward report - %s\n", report.GeneratedAt.Format("2006-01-02 15:04"))
+	fmt.Fprintf(&b, "Incidents: %d (pending %d, reviewed %d, penalty %d, no action %d)\n",
+		len(report.Entries),
+		report.Summary[StatusPending.String()],
+		report.Summary[StatusReviewed.String()],
+		report.Summary[StatusPenalty.String()],
+		report.Summary[StatusNoAction.String()])
+
+	for _, entry := range report.Entries {
+		fmt.Fprintf(&b, "\n#%d [%s] %s", entry.ID, entry.Status, formatSessionTime(entry.Incident.SessionTime))
+		if entry.Incident.Location != "" {
+			fmt.Fprintf(&b, " %s", entry.Incident.Location)
+		}
+		if entry.Incident.Message != "" {
+			fmt.Fprintf(&b, " - %s", entry.Incident.Message)
+		}
+		b.WriteString("\n")
+
+		cars := make([]string, len(entry.InvolvedCars))
+		for i, car := range entry.InvolvedCars {
+			cars[i] = formatCar(car)
+		}
+		fmt.Fprintf(&b, "   Involved: %s\n", strings.Join(cars, ", "))
+
+		if entry.Status == StatusPenalty && entry.Penalty != "" {
+			fmt.Fprintf(&b, "   Penalty: %s\n", entry.Penalty)
+		}
+		for _, note := range entry.Notes {
+			fmt.Fprintf(&b, "   Note: %s\n", note.Text)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// SaveReportFile escribe el reporte en un archivo: JSON si la extensión es
+// .json, texto en cualquier otro caso
+func (rq *ReviewQueue) SaveReportFile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = rq.WriteReportJSON(file)
+	} else {
+		err = rq.WriteReport(file)
+	}
+	if err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Clear vacía la cola
+func (rq *ReviewQueue) Clear() {
+	rq.mu.Lock()
+	defer rq.mu.Unlock()
+
+	rq.entries = make([]*ReviewEntry, 0)
+	rq.nextID = 1
+}
+
+func (rq *ReviewQueue) findLocked(id int) *ReviewEntry {
+	for _, entry := range rq.entries {
+		if entry.ID == id {
+			return entry
+		}
+	}
+	return nil
+}
+
+func (rq *ReviewQueue) addInvolvedLocked(entry *ReviewEntry, car InvolvedCar) {
+	for _, existing := range entry.InvolvedCars {
+		if existing.CarIndex == car.CarIndex {
+			return
+		}
+	}
+
+	if car.RaceNumber == 0 && rq.resolver != nil {
+		if resolved, ok := rq.resolver(car.CarIndex); ok {
+			car = resolved
+		}
+	}
+	entry.InvolvedCars = append(entry.InvolvedCars, car)
+}
+
+// copyEntry copia una entrada sin compartir slices con la cola
+func copyEntry(entry *ReviewEntry) ReviewEntry {
+	result := *entry
+	result.InvolvedCars = append([]InvolvedCar(nil), entry.InvolvedCars...)
+	result.Notes = append([]Note(nil), entry.Notes...)
+	result.Incident.InvolvedCars = append([]uint16(nil), entry.Incident.InvolvedCars...)
+	return result
+}
+
+func formatCar(car InvolvedCar) string {
+	if car.RaceNumber == 0 {
+		return fmt.Sprintf("car %d", car.CarIndex)
+	}
+	if car.DriverName == "" {
+		return fmt.Sprintf("#%d", car.RaceNumber)
+	}
+	return fmt.Sprintf("#%d %s", car.RaceNumber, car.DriverName)
+}
+
+func formatSessionTime(d time.Duration) string {
+	minutes := int(d / time.Minute)
+	seconds := (d % time.Minute).Seconds()
+	return fmt.Sprintf("%d:%06.3f", minutes, seconds)
+}
@@ -0,0 +1,425 @@
+package world
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"RaceAll/internal/broadcast"
+	"RaceAll/internal/sharedmemory"
+)
+
+const (
+	// Muestras de posición guardadas por auto (~2 s a 60 Hz)
+	DefaultHistorySize = 120
+	// Distancia máxima (m) para asociar un auto de broadcast con uno de shared memory
+	MatchRadius = 10.0
+)
+
+// Vec3 es una posición o velocidad en coordenadas del mundo de ACC (Y hacia arriba)
+type Vec3 struct {
+	X, Y, Z float32
+}
+
+// Sub devuelve v - o
+func (v Vec3) Sub(o Vec3) Vec3 {
+	return Vec3{v.X - o.X, v.Y - o.Y, v.Z - o.Z}
+}
+
+// Scale devuelve v multiplicado por s
+func (v Vec3) Scale(s float32) Vec3 {
+	return Vec3{v.X * s, v.Y * s, v.Z * s}
+}
+
+// Dot devuelve el producto escalar
+func (v Vec3) Dot(o Vec3) float32 {
+	return v.X*o.X + v.Y*o.Y + v.Z*o.Z
+}
+
+// Length devuelve el módulo del vector
+func (v Vec3) Length() float32 {
+	return float32(math.Sqrt(float64(v.Dot(v))))
+}
+
+// Sample es una posición registrada en un instante
+type Sample struct {
+	Position  Vec3
+	Timestamp time.Time
+}
+
+// Car es el estado de un auto en el modelo del mundo
+type Car struct {
+	CarID    int32
+	CarIndex uint16
+	IsPlayer bool
+	Position Vec3
+	// Velocity en m/s, calculada a partir de las dos últimas muestras
+	Velocity   Vec3
+	LastUpdate time.Time
+}
+
+// Speed devuelve la velocidad en m/s
+func (c Car) Speed() float32 {
+	return c.Velocity.Length()
+}
+
+// Proximity describe la posición de otro auto respecto a uno de referencia
+type Proximity struct {
+	CarID    int32
+	CarIndex uint16
+	Distance float32
+	// Bearing en grados (-180, 180] respecto a la dirección de marcha del
+	// auto de referencia, positivo hacia +X
+	Bearing float32
+	// ClosingSpeed en m/s, positivo cuando los autos se acercan
+	ClosingSpeed float32
+}
+
+type carState struct {
+	car     Car
+	history []Sample
+	next    int
+	count   int
+}
+
+// WorldModel mantiene la posición 3D de todos los autos a partir de
+// Graphics.CarCoordinates, a la frecuencia de shared memory
+type WorldModel struct {
+	cars         map[int32]*carState
+	carIndex     map[int32]uint16
+	playerCarID  int32
+	historySize  int
+	lastPacketID int32
+	mu           sync.RWMutex
+}
+
+// NewWorldModel crea un modelo vacío con el historial por defecto
+func NewWorldModel() *WorldModel {
+	return NewWorldModelWithHistory(DefaultHistorySize)
+}
+
+// NewWorldModelWithHistory crea un modelo guardando historySize muestras por auto
+func NewWorldModelWithHistory(historySize int) *WorldModel {
+	if historySize < 2 {
+		historySize = 2
+	}
+	return &WorldModel{
+		cars:        make(map[int32]*carState),
+		carIndex:    make(map[int32]uint16),
+		historySize: historySize,
+		playerCarID: -1,
+	}
+}
+
+// Update añade las posiciones de un frame de Graphics
+func (wm *WorldModel) Update(graphics *sharedmemory.Graphics) {
+	wm.UpdateAt(graphics, time.Now())
+}
+
+// UpdateAt añade las posiciones de un frame de Graphics tomado en el instante at.
+// Los autos que ya no aparecen en el frame se eliminan.
+func (wm *WorldModel) UpdateAt(graphics *sharedmemory.Graphics, at time.Time) {
+	if graphics == nil {
+		return
+	}
+
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+
+	if graphics.PacketId == wm.lastPacketID && len(wm.cars) > 0 {
+		return
+	}
+	wm.lastPacketID = graphics.PacketId
+	wm.playerCarID = graphics.PlayerCarID
+
+	active := int(graphics.ActiveCars)
+	if active > len(graphics.CarID) {
+		active = len(graphics.CarID)
+	}
+
+	seen := make(map[int32]bool, active)
+	for i := 0; i < active; i++ {
+		id := graphics.CarID[i]
+		coords := graphics.CarCoordinates[i]
+		position := Vec3{coords[0], coords[1], coords[2]}
+		seen[id] = true
+
+		state, exists := wm.cars[id]
+		if !exists {
+			state = &carState{
+				car:     Car{CarID: id},
+				history: make([]Sample, wm.historySize),
+			}
+			wm.cars[id] = state
+		}
+
+		// Velocidad a partir de la muestra anterior
+		if state.count > 0 {
+			if dt := at.Sub(state.car.LastUpdate).Seconds(); dt > 0 {
+				state.car.Velocity = position.Sub(state.car.Position).Scale(float32(1 / dt))
+			}
+		}
+
+		state.car.Position = position
+		state.car.LastUpdate = at
+		state.car.IsPlayer = id == graphics.PlayerCarID
+		state.car.CarIndex = wm.carIndexLocked(id)
+
+		state.history[state.next] = Sample{Position: position, Timestamp: at}
+		state.next = (state.next + 1) % len(state.history)
+		if state.count < len(state.history) {
+			state.count++
+		}
+	}
+
+	// Un CarID reutilizado no debe heredar el CarIndex del auto anterior
+	for id := range wm.cars {
+		if !seen[id] {
+			delete(wm.cars, id)
+			delete(wm.carIndex, id)
+		}
+	}
+}
+
+// UpdateFromBroadcast asocia el CarIndex de broadcast con el CarID de shared
+// memory buscando el auto más cercano a su posición (WorldPosX/WorldPosY
+// corresponden a X/Z). Sin asociación, se asume CarID == CarIndex.
+func (wm *WorldModel) UpdateFromBroadcast(update *broadcast.CarUpdate) {
+	if update == nil {
+		return
+	}
+
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+
+	target := Vec3{X: update.WorldPosX, Z: update.WorldPosY}
+	bestID := int32(-1)
+	best, second := float32(math.MaxFloat32), float32(math.MaxFloat32)
+
+	for id, state := range wm.cars {
+		d := Vec3{X: state.car.Position.X, Z: state.car.Position.Z}.Sub(target).Length()
+		if d < best {
+			bestID, best, second = id, d, best
+		} else if d < second {
+			second = d
+		}
+	}
+
+	// Solo asociar si el candidato es claramente el más cercano
+	if bestID < 0 || best > MatchRadius || second < 2*best {
+		return
+	}
+
+	// Un CarIndex corresponde a un único CarID
+	for id, index := range wm.carIndex {
+		if index == update.CarIndex && id != bestID {
+			delete(wm.carIndex, id)
+			if state, ok := wm.cars[id]; ok {
+				state.car.CarIndex = uint16(id)
+			}
+		}
+	}
+
+	wm.carIndex[bestID] = update.CarIndex
+	wm.cars[bestID].car.CarIndex = update.CarIndex
+}
+
+// CarIndex devuelve el CarIndex de broadcast de un CarID. El segundo valor
+// indica si la asociación se confirmó con datos de broadcast.
+func (wm *WorldModel) CarIndex(carID int32) (uint16, bool) {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+
+	index, ok := wm.carIndex[carID]
+	if !ok {
+		return uint16(carID), false
+	}
+	return index, true
+}
+
+func (wm *WorldModel) carIndexLocked(carID int32) uint16 {
+	if index, ok := wm.carIndex[carID]; ok {
+		return index
+	}
+	return uint16(carID)
+}
+
+// GetCar devuelve el estado de un auto
+func (wm *WorldModel) GetCar(carID int32) (Car, bool) {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+
+	state, ok := wm.cars[carID]
+	if !ok {
+		return Car{}, false
+	}
+	return state.car, true
+}
+
+// GetPlayerCar devuelve el auto del jugador
+func (wm *WorldModel) GetPlayerCar() (Car, bool) {
+	wm.mu.RLock()
+	playerCarID := wm.playerCarID
+	wm.mu.RUnlock()
+	return wm.GetCar(playerCarID)
+}
+
+// GetCars devuelve todos los autos ordenados por CarID
+func (wm *WorldModel) GetCars() []Car {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+
+	cars := make([]Car, 0, len(wm.cars))
+	for _, state := range wm.cars {
+		cars = append(cars, state.car)
+	}
+	sort.Slice(cars, func(i, j int) bool { return cars[i].CarID < cars[j].CarID })
+	return cars
+}
+
+// GetHistory devuelve las muestras de un auto, de la más antigua a la más reciente
+func (wm *WorldModel) GetHistory(carID int32) []Sample {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+
+	state, ok := wm.cars[carID]
+	if !ok {
+		return nil
+	}
+
+	history := make([]Sample, 0, state.count)
+	start := (state.next - state.count + len(state.history)) % len(state.history)
+	for i := 0; i < state.count; i++ {
+		history = append(history, state.history[(start+i)%len(state.history)])
+	}
+	return history
+}
+
+// Distance devuelve la distancia en metros entre dos autos
+func (wm *WorldModel) Distance(carA, carB int32) (float32, bool) {
+	a, b, ok := wm.pair(carA, carB)
+	if !ok {
+		return 0, false
+	}
+	return b.Position.Sub(a.Position).Length(), true
+}
+
+// Bearing devuelve el ángulo (grados) de carB visto desde carA respecto a su
+// dirección de marcha. Con carA detenido se usa el eje +Z como referencia.
+func (wm *WorldModel) Bearing(carA, carB int32) (float32, bool) {
+	a, b, ok := wm.pair(carA, carB)
+	if !ok {
+		return 0, false
+	}
+	return bearing(a, b), true
+}
+
+// RelativeVelocity devuelve la velocidad de carB respecto a carA en m/s
+func (wm *WorldModel) RelativeVelocity(carA, carB int32) (Vec3, bool) {
+	a, b, ok := wm.pair(carA, carB)
+	if !ok {
+		return Vec3{}, false
+	}
+	return b.Velocity.Sub(a.Velocity), true
+}
+
+// Nearest devuelve hasta n autos más cercanos a carID, del más cercano al más lejano
+func (wm *WorldModel) Nearest(carID int32, n int) []Proximity {
+	result := wm.proximities(carID)
+	if n >= 0 && len(result) > n {
+		result = result[:n]
+	}
+	return result
+}
+
+// Within devuelve los autos a menos de radius metros de carID
+func (wm *WorldModel) Within(carID int32, radius float32) []Proximity {
+	all := wm.proximities(carID)
+	for i, p := range all {
+		if p.Distance > radius {
+			return all[:i]
+		}
+	}
+	return all
+}
+
+func (wm *WorldModel) proximities(carID int32) []Proximity {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+
+	ref, ok := wm.cars[carID]
+	if !ok {
+		return nil
+	}
+
+	result := make([]Proximity, 0, len(wm.cars)-1)
+	for id, state := range wm.cars {
+		if id == carID {
+			continue
+		}
+		result = append(result, proximity(ref.car, state.car))
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Distance < result[j].Distance })
+	return result
+}
+
+func (wm *WorldModel) pair(carA, carB int32) (Car, Car, bool) {
+	wm.mu.RLock()
+	defer wm.mu.RUnlock()
+
+	a, okA := wm.cars[carA]
+	b, okB := wm.cars[carB]
+	if !okA || !okB {
+		return Car{}, Car{}, false
+	}
+	return a.car, b.car, true
+}
+
+// Reset elimina todos los autos y asociaciones
+func (wm *WorldModel) Reset() {
+	wm.mu.Lock()
+	defer wm.mu.Unlock()
+
+	wm.cars = make(map[int32]*carState)
+	wm.carIndex = make(map[int32]uint16)
+	wm.playerCarID = -1
+	wm.lastPacketID = 0
+}
+
+func proximity(ref, other Car) Proximity {
+	offset := other.Position.Sub(ref.Position)
+	distance := offset.Length()
+
+	var closing float32
+	if distance > 0 {
+		closing = -other.Velocity.Sub(ref.Velocity).Dot(offset) / distance
+	}
+
+	return Proximity{
+		CarID:        other.CarID,
+		CarIndex:     other.CarIndex,
+		Distance:     distance,
+		Bearing:      bearing(ref, other),
+		ClosingSpeed: closing,
+	}
+}
+
+func bearing(ref, other Car) float32 {
+	offset := other.Position.Sub(ref.Position)
+	angle := math.Atan2(float64(offset.X), float64(offset.Z))
+
+	if ref.Velocity.X != 0 || ref.Velocity.Z != 0 {
+		angle -= math.Atan2(float64(ref.Velocity.X), float64(ref.Velocity.Z))
+	}
+
+	degrees := angle * 180 / math.Pi
+	for degrees > 180 {
+		degrees -= 360
+	}
+	for degrees <= -180 {
+		degrees += 360
+	}
+	return float32(degrees)
+}
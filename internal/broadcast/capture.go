@@ -0,0 +1,398 @@
+package broadcast
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"RaceAll/internal/errors"
+)
+
+// Capture file layout (little endian):
+//
+//	header:  magic "RABCCAP\x00", format version, server address, start time
+//	records: offset (ns since start), direction, length, datagram
+//
+// Offsets come from the monotonic clock, so a capture replays with the
+// original pacing even if the wall clock jumped while it was taken.
+const (
+	captureMagic         = "RABCCAP\x00"
+	CaptureFormatVersion = 1
+
+	// maxCapturedDatagram bounds the length read back for a single record
+	maxCapturedDatagram = 64 * 1024
+)
+
+// CaptureDirection tells whether a datagram was received from or sent to ACC
+type CaptureDirection uint8
+
+const (
+	CaptureInbound CaptureDirection = iota
+	CaptureOutbound
+)
+
+func (d CaptureDirection) String() string {
+	switch d {
+	case CaptureInbound:
+		return "Inbound"
+	case CaptureOutbound:
+		return "Outbound"
+	default:
+		return "Unknown"
+	}
+}
+
+// CaptureHeader describes the connection a capture was taken from
+type CaptureHeader struct {
+	FormatVersion uint16
+	Address       string
+	StartTime     time.Time
+}
+
+// CapturedDatagram is a datagram read back from a capture
+type CapturedDatagram struct {
+	// Offset is the time since the start of the capture
+	Offset    time.Duration
+	Direction CaptureDirection
+	Data      []byte
+}
+
+// CaptureWriter writes every datagram of a broadcast connection to a capture
+type CaptureWriter struct {
+	mu     sync.Mutex
+	out    *bufio.Writer
+	closer io.Closer
+	closed bool
+	start  time.Time
+	header CaptureHeader
+}
+
+// NewCaptureWriter creates a capture writing to w. The header is written
+// immediately, offsets are measured from this call.
+func NewCaptureWriter(w io.Writer, address string) (*CaptureWriter, error) {
+	cw := &CaptureWriter{
+		out:   bufio.NewWriter(w),
+		start: time.Now(),
+	}
+	if c, ok := w.(io.Closer); ok {
+		cw.closer = c
+	}
+
+	cw.header = CaptureHeader{
+		FormatVersion: CaptureFormatVersion,
+		Address:       address,
+		StartTime:     cw.start,
+	}
+	if err := writeCaptureHeader(cw.out, cw.header); err != nil {
+		return nil, NewError("NewCaptureWriter", err)
+	}
+	return cw, nil
+}
+
+// CreateCapture creates (or truncates) a capture file
+func CreateCapture(path, address string) (*CaptureWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, NewError("CreateCapture", err)
+	}
+
+	cw, err := NewCaptureWriter(file, address)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return cw, nil
+}
+
+// WriteDatagram records a datagram stamped with the current time
+func (cw *CaptureWriter) WriteDatagram(direction CaptureDirection, data []byte) error {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	if cw.closed {
+		return NewError("WriteDatagram", errors.ErrRecordingClosed)
+	}
+
+	offset := time.Since(cw.start).Nanoseconds()
+	if err := binary.Write(cw.out, binary.LittleEndian, offset); err != nil {
+		return NewError("WriteDatagram", err)
+	}
+	if err := cw.out.WriteByte(byte(direction)); err != nil {
+		return NewError("WriteDatagram", err)
+	}
+	if err := binary.Write(cw.out, binary.LittleEndian, uint32(len(data))); err != nil {
+		return NewError("WriteDatagram", err)
+	}
+	if _, err := cw.out.Write(data); err != nil {
+		return NewError("WriteDatagram", err)
+	}
+	return nil
+}
+
+// Header returns the header written to the capture
+func (cw *CaptureWriter) Header() CaptureHeader {
+	return cw.header
+}
+
+// Close flushes pending datagrams and closes the underlying file
+func (cw *CaptureWriter) Close() error {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+
+	if cw.closed {
+		return nil
+	}
+	cw.closed = true
+
+	err := cw.out.Flush()
+	if cw.closer != nil {
+		if closeErr := cw.closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		return NewError("Close", err)
+	}
+	return nil
+}
+
+// isClosed returns true once the capture was closed, also by a client that
+// failed to write to it
+func (cw *CaptureWriter) isClosed() bool {
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	return cw.closed
+}
+
+// CaptureReader reads datagrams back from a capture
+type CaptureReader struct {
+	in     *bufio.Reader
+	closer io.Closer
+	header CaptureHeader
+}
+
+// NewCaptureReader reads the capture header from r
+func NewCaptureReader(r io.Reader) (*CaptureReader, error) {
+	cr := &CaptureReader{in: bufio.NewReader(r)}
+	if c, ok := r.(io.Closer); ok {
+		cr.closer = c
+	}
+
+	header, err := readCaptureHeader(cr.in)
+	if err != nil {
+		return nil, NewError("NewCaptureReader", err)
+	}
+	cr.header = header
+	return cr, nil
+}
+
+// OpenCapture opens a capture file
+func OpenCapture(path string) (*CaptureReader, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, NewError("OpenCapture", err)
+	}
+
+	cr, err := NewCaptureReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return cr, nil
+}
+
+// Header returns the capture header
+func (cr *CaptureReader) Header() CaptureHeader {
+	return cr.header
+}
+
+// Next returns the next datagram, or io.EOF at the end of the capture.
+// A capture cut short (e.g. after a crash) ends at the last complete record.
+func (cr *CaptureReader) Next() (*CapturedDatagram, error) {
+	var offset int64
+	if err := binary.Read(cr.in, binary.LittleEndian, &offset); err != nil {
+		return nil, captureEOF(err)
+	}
+
+	direction, err := cr.in.ReadByte()
+	if err != nil {
+		return nil, captureEOF(err)
+	}
+
+	var length uint32
+	if err := binary.Read(cr.in, binary.LittleEndian, &length); err != nil {
+		return nil, captureEOF(err)
+	}
+	if length > maxCapturedDatagram {
+		return nil, NewError("Next", errors.ErrInvalidRecording)
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(cr.in, data); err != nil {
+		return nil, captureEOF(err)
+	}
+
+	return &CapturedDatagram{
+		Offset:    time.Duration(offset),
+		Direction: CaptureDirection(direction),
+		Data:      data,
+	}, nil
+}
+
+// Close closes the underlying file
+func (cr *CaptureReader) Close() error {
+	if cr.closer != nil {
+		return cr.closer.Close()
+	}
+	return nil
+}
+
+func captureEOF(err error) error {
+	if err == io.ErrUnexpectedEOF {
+		return io.EOF
+	}
+	return err
+}
+
+func writeCaptureHeader(w io.Writer, header CaptureHeader) error {
+	if _, err := io.WriteString(w, captureMagic); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, header.FormatVersion); err != nil {
+		return err
+	}
+	if err := writeString(w, header.Address); err != nil {
+		return err
+	}
+	return binary.Write(w, binary.LittleEndian, header.StartTime.UnixNano())
+}
+
+func readCaptureHeader(r io.Reader) (CaptureHeader, error) {
+	var header CaptureHeader
+
+	magic := make([]byte, len(captureMagic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return header, err
+	}
+	if string(magic) != captureMagic {
+		return header, errors.ErrInvalidRecording
+	}
+
+	if err := binary.Read(r, binary.LittleEndian, &header.FormatVersion); err != nil {
+		return header, err
+	}
+	if header.FormatVersion != CaptureFormatVersion {
+		return header, errors.ErrUnsupportedRecording
+	}
+
+	address, err := readString(r)
+	if err != nil {
+		return header, err
+	}
+	header.Address = address
+
+	var startNanos int64
+	if err := binary.Read(r, binary.LittleEndian, &startNanos); err != nil {
+		return header, err
+	}
+	header.StartTime = time.Unix(0, startNanos)
+
+	return header, nil
+}
+
+// ReplayStats counts the datagrams handled by a Replayer
+type ReplayStats struct {
+	Inbound  int
+	Outbound int
+	Errors   int
+}
+
+// Replayer pushes the inbound datagrams of a capture through a Protocol,
+// so a reported session can be reproduced with the same callbacks
+type Replayer struct {
+	capture  *CaptureReader
+	protocol *Protocol
+	speed    float64
+	stats    ReplayStats
+
+	// OnDatagram is called for every datagram, including outbound ones,
+	// before inbound datagrams are processed
+	OnDatagram func(CapturedDatagram)
+}
+
+// NewReplayer creates a replayer at original pace. The protocol is usually
+// created with a send function that discards requests.
+func NewReplayer(capture *CaptureReader, protocol *Protocol) *Replayer {
+	return &Replayer{
+		capture:  capture,
+		protocol: protocol,
+		speed:    1,
+	}
+}
+
+// SetSpeed sets the replay rate (1 is original pace, 4 is four times
+// faster, 0 processes datagrams as fast as possible)
+func (r *Replayer) SetSpeed(speed float64) {
+	if speed < 0 {
+		speed = 0
+	}
+	r.speed = speed
+}
+
+// Run replays the capture until its end or until ctx is cancelled.
+// Decode errors are counted in Stats and do not stop the replay.
+func (r *Replayer) Run(ctx context.Context) error {
+	start := time.Now()
+
+	for {
+		datagram, err := r.capture.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if r.speed > 0 {
+			due := time.Duration(float64(datagram.Offset) / r.speed)
+			if wait := due - time.Since(start); wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return ctx.Err()
+				case <-timer.C:
+				}
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		if r.OnDatagram != nil {
+			r.OnDatagram(*datagram)
+		}
+
+		if datagram.Direction != CaptureInbound {
+			r.stats.Outbound++
+			continue
+		}
+
+		r.stats.Inbound++
+		if err := r.protocol.ProcessMessage(datagram.Data); err != nil {
+			r.stats.Errors++
+		}
+	}
+}
+
+// Stats returns the datagrams handled so far. Call it after Run returns.
+func (r *Replayer) Stats() ReplayStats {
+	return r.stats
+}
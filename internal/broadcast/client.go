@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"sync"
 	"time"
 
 	"RaceAll/internal/errors"
@@ -23,6 +24,7 @@ const (
 type Client struct {
 	logger   zerolog.Logger
 	conn     *net.UDPConn
+	connMu   sync.Mutex
 	protocol *Protocol
 
 	// Configuration
@@ -32,11 +34,29 @@ type Client struct {
 	commandPassword          string
 	msRealtimeUpdateInterval int32
 	timeout                  time.Duration
+	carTimeout               time.Duration
+	reconnect                ReconnectConfig
 
 	// Control
 	ctx    context.Context
 	cancel context.CancelFunc
 
+	// Connection state
+	state                ClientState
+	attempt              int
+	registered           bool
+	registrationDeadline time.Time
+	rejection            error
+	rejectionReason      string
+	stateMu              sync.RWMutex
+
+	// Packet capture
+	capture   *CaptureWriter
+	captureMu sync.Mutex
+
+	// OnStateChanged is called on every state transition
+	OnStateChanged func(StateChange)
+
 	// Public callbacks - delegated to protocol
 	OnConnectionStateChanged func(ConnectionState)
 	OnTrackDataUpdate        func(TrackData)
@@ -44,6 +64,12 @@ type Client struct {
 	OnRealtimeUpdate         func(RealtimeUpdate)
 	OnRealtimeCarUpdate      func(RealtimeCarUpdate)
 	OnBroadcastingEvent      func(BroadcastingEvent)
+	OnEntryListChange        func(EntryListChange)
+
+	// OnCarUpdate receives car updates without allocating. The update is
+	// pooled and only valid during the call; OnRealtimeCarUpdate, when set,
+	// gets a copy with allocated lap times.
+	OnCarUpdate func(*CarUpdate)
 }
 
 // NewClient create a new instance of the broadcasting client
@@ -56,6 +82,8 @@ func NewClient(address, displayName, connectionPassword, commandPassword string,
 		commandPassword:          commandPassword,
 		msRealtimeUpdateInterval: msRealtimeUpdateInterval,
 		timeout:                  DefaultTimeout,
+		carTimeout:               DefaultCarTimeout,
+		reconnect:                DefaultReconnectConfig(),
 	}
 }
 
@@ -64,8 +92,32 @@ func (c *Client) SetTimeout(timeout time.Duration) {
 	c.timeout = timeout
 }
 
+// SetCarTimeout sets how long a car may go without updates before it is
+// reported as CarLeft, zero or less disables it. It applies from the next
+// connection.
+func (c *Client) SetCarTimeout(timeout time.Duration) {
+	c.carTimeout = timeout
+}
+
+// SetReconnectConfig sets the registration timeout and backoff used by Run.
+// Fields left at zero keep their default.
+func (c *Client) SetReconnectConfig(config ReconnectConfig) {
+	c.reconnect = config.withDefaults()
+}
+
+// State returns the current connection state
+func (c *Client) State() ClientState {
+	c.stateMu.RLock()
+	defer c.stateMu.RUnlock()
+	return c.state
+}
+
 // Connect establishes the connection with ACC
 func (c *Client) Connect() error {
+	return c.connect(context.Background())
+}
+
+func (c *Client) connect(parent context.Context) error {
 	// Resolve UDP address
 	raddr, err := net.ResolveUDPAddr("udp", c.address)
 	if err != nil {
@@ -74,59 +126,86 @@ func (c *Client) Connect() error {
 	}
 
 	// Create UDP connection
-	c.conn, err = net.DialUDP("udp", nil, raddr)
+	conn, err := net.DialUDP("udp", nil, raddr)
 	if err != nil {
 		c.logger.Error().Err(err).Msg("Error creating UDP connection")
 		return NewError("Connect", fmt.Errorf("failed to dial UDP: %w", err))
 	}
 
 	// Create protocol handler
-	c.protocol = NewProtocol(c.address, c.send, c.logger)
+	protocol := NewProtocol(c.address, c.send, c.logger)
+	protocol.SetCarTimeout(c.carTimeout)
 
 	// Configure protocol callbacks
-	c.protocol.OnConnectionStateChanged = func(state ConnectionState) {
+	protocol.OnConnectionStateChanged = func(state ConnectionState) {
+		c.handleRegistration(state)
+
 		if c.OnConnectionStateChanged != nil {
 			c.OnConnectionStateChanged(state)
 		}
 	}
 
-	c.protocol.OnTrackDataUpdate = func(trackData TrackData) {
+	protocol.OnTrackDataUpdate = func(trackData TrackData) {
 		if c.OnTrackDataUpdate != nil {
 			c.OnTrackDataUpdate(trackData)
 		}
 	}
 
-	c.protocol.OnEntrylistUpdate = func(carInfo CarInfo) {
+	protocol.OnEntrylistUpdate = func(carInfo CarInfo) {
 		if c.OnEntrylistUpdate != nil {
 			c.OnEntrylistUpdate(carInfo)
 		}
 	}
 
-	c.protocol.OnRealtimeUpdate = func(update RealtimeUpdate) {
+	protocol.OnRealtimeUpdate = func(update RealtimeUpdate) {
 		if c.OnRealtimeUpdate != nil {
 			c.OnRealtimeUpdate(update)
 		}
 	}
 
-	c.protocol.OnRealtimeCarUpdate = func(update RealtimeCarUpdate) {
+	protocol.OnCarUpdate = func(update *CarUpdate) {
+		if c.OnCarUpdate != nil {
+			c.OnCarUpdate(update)
+		}
 		if c.OnRealtimeCarUpdate != nil {
-			c.OnRealtimeCarUpdate(update)
+			c.OnRealtimeCarUpdate(update.RealtimeCarUpdate())
 		}
 	}
 
-	c.protocol.OnBroadcastingEvent = func(event BroadcastingEvent) {
+	protocol.OnBroadcastingEvent = func(event BroadcastingEvent) {
 		if c.OnBroadcastingEvent != nil {
 			c.OnBroadcastingEvent(event)
 		}
 	}
 
+	protocol.OnEntryListChange = func(change EntryListChange) {
+		if c.OnEntryListChange != nil {
+			c.OnEntryListChange(change)
+		}
+	}
+
 	// Create context for control
-	c.ctx, c.cancel = context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(parent)
+
+	// Commands may be sent from other goroutines while reconnecting
+	c.connMu.Lock()
+	c.conn = conn
+	c.protocol = protocol
+	c.ctx, c.cancel = ctx, cancel
+	c.connMu.Unlock()
 
 	c.logger.Info().Str("address", c.address).Msg("Connected to ACC")
 
+	c.stateMu.Lock()
+	c.attempt++
+	c.registered = false
+	c.rejection = nil
+	c.registrationDeadline = time.Now().Add(c.reconnect.RegistrationTimeout)
+	c.stateMu.Unlock()
+	c.setState(StateRegistering, "", nil, 0)
+
 	// Request connection
-	if err := c.protocol.RequestConnection(c.displayName, c.connectionPassword, c.msRealtimeUpdateInterval, c.commandPassword); err != nil {
+	if err := protocol.RequestConnection(c.displayName, c.connectionPassword, c.msRealtimeUpdateInterval, c.commandPassword); err != nil {
 		c.logger.Error().Err(err).Msg("Error requesting connection")
 		return NewError("Connect", fmt.Errorf("failed to request connection: %w", err))
 	}
@@ -134,53 +213,148 @@ func (c *Client) Connect() error {
 	return nil
 }
 
-// Listen starts listening for messages from the server
+// handleRegistration moves to Connected or Readonly, or records why ACC
+// rejected the registration so listen can return it
+func (c *Client) handleRegistration(state ConnectionState) {
+	reason := registrationReason(state)
+
+	if !state.Success {
+		c.stateMu.Lock()
+		c.rejection = NewErrorWithContext("Register", errors.ErrRegistrationRejected, reason)
+		c.rejectionReason = reason
+		c.stateMu.Unlock()
+		return
+	}
+
+	c.stateMu.Lock()
+	c.registered = true
+	c.stateMu.Unlock()
+
+	if state.IsReadonly {
+		c.setState(StateReadonly, reason, nil, 0)
+	} else {
+		c.setState(StateConnected, reason, nil, 0)
+	}
+}
+
+// setState records a transition and notifies OnStateChanged
+func (c *Client) setState(to ClientState, reason string, err error, retryIn time.Duration) {
+	c.stateMu.Lock()
+	change := StateChange{
+		From:    c.state,
+		To:      to,
+		Reason:  reason,
+		Err:     err,
+		Attempt: c.attempt,
+		RetryIn: retryIn,
+		Time:    time.Now(),
+	}
+	c.state = to
+	c.stateMu.Unlock()
+
+	if change.From == change.To {
+		return
+	}
+
+	c.logger.Info().
+		Str("from", change.From.String()).
+		Str("to", change.To.String()).
+		Str("reason", reason).
+		Int("attempt", change.Attempt).
+		Msg("Estado de conexión")
+
+	if c.OnStateChanged != nil {
+		c.OnStateChanged(change)
+	}
+}
+
+// Listen starts listening for messages from the server. It returns when the
+// client is disconnected, the registration is rejected or times out, or no
+// data arrives within the timeout; the client is then Lost.
 func (c *Client) Listen() error {
-	if c.conn == nil {
-		return NewError("Listen", errors.ErrConnectionClosed)
+	reason, err := c.listen()
+	if err != nil {
+		c.setState(StateLost, reason, err, 0)
+	}
+	return err
+}
+
+// listen is Listen without the final transition. On error it also returns
+// the reason reported in the StateChange.
+func (c *Client) listen() (string, error) {
+	c.connMu.Lock()
+	conn, protocol, ctx := c.conn, c.protocol, c.ctx
+	c.connMu.Unlock()
+
+	if conn == nil {
+		return "connection closed", NewError("Listen", errors.ErrConnectionClosed)
 	}
 
 	buffer := make([]byte, ReadBufferSize)
 
 	for {
 		select {
-		case <-c.ctx.Done():
+		case <-ctx.Done():
 			c.logger.Info().Msg("Deteniendo listener")
-			return nil
+			return "", nil
 		default:
-			// Set read timeout
-			if err := c.conn.SetReadDeadline(time.Now().Add(c.timeout)); err != nil {
+			// Set read timeout, shorter while waiting for the registration result
+			deadline := time.Now().Add(c.timeout)
+			registering := c.State() == StateRegistering
+			if registering {
+				c.stateMu.RLock()
+				if c.registrationDeadline.Before(deadline) {
+					deadline = c.registrationDeadline
+				}
+				c.stateMu.RUnlock()
+			}
+			if err := conn.SetReadDeadline(deadline); err != nil {
 				c.logger.Error().Err(err).Msg("Error setting deadline")
-				return NewError("Listen", err)
+				return err.Error(), NewError("Listen", err)
 			}
 
 			// Read data
-			n, err := c.conn.Read(buffer)
+			n, err := conn.Read(buffer)
 			if err != nil {
-				if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
-					c.logger.Error().Msg("Timeout: ACC no respondió")
-					return NewError("Listen", errors.ErrTimeout)
-				}
-
 				// If the context was canceled, it's not an error
 				select {
-				case <-c.ctx.Done():
-					return nil
+				case <-ctx.Done():
+					return "", nil
 				default:
-					c.logger.Error().Err(err).Msg("Error reading data")
-					return NewError("Listen", err)
 				}
+
+				if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+					if registering {
+						c.logger.Error().Msg("Timeout: ACC no respondió al registro")
+						return "registration timeout", NewError("Listen", errors.ErrRegistrationTimeout)
+					}
+					c.logger.Error().Msg("Timeout: ACC no respondió")
+					return "no data received from ACC", NewError("Listen", errors.ErrTimeout)
+				}
+
+				c.logger.Error().Err(err).Msg("Error reading data")
+				return err.Error(), NewError("Listen", err)
 			}
 
 			if n > 0 {
-				// Process message
-				data := make([]byte, n)
-				copy(data, buffer[:n])
+				// Messages are decoded before the next read, the buffer is
+				// not copied
+				data := buffer[:n]
+				c.captureDatagram(CaptureInbound, data)
 
-				if err := c.protocol.ProcessMessage(data); err != nil {
+				if err := protocol.ProcessMessage(data); err != nil {
 					c.logger.Error().Err(err).Msg("Error processing message")
 					// don't return the error, continue listening
 				}
+
+				// ACC sends nothing else after rejecting a registration
+				c.stateMu.RLock()
+				rejection, reason := c.rejection, c.rejectionReason
+				c.stateMu.RUnlock()
+				if rejection != nil {
+					c.logger.Error().Err(rejection).Msg("Registro rechazado por ACC")
+					return reason, rejection
+				}
 			}
 		}
 	}
@@ -195,12 +369,86 @@ func (c *Client) ConnectAndListen() error {
 	return c.Listen()
 }
 
+// Run connects and keeps the client registered until ctx is cancelled.
+// After a rejected or timed out registration, or a lost connection, it
+// registers again after an exponential backoff with jitter. It only returns
+// an error when ReconnectConfig.MaxAttempts attempts in a row failed.
+func (c *Client) Run(ctx context.Context) error {
+	c.stateMu.Lock()
+	c.attempt = 0
+	c.stateMu.Unlock()
+
+	failures := 0
+	for {
+		reason := "connection failed"
+		err := c.connect(ctx)
+		if err == nil {
+			reason, err = c.listen()
+		}
+		c.closeConn()
+
+		if ctx.Err() != nil {
+			c.setState(StateDisconnected, "stopped", nil, 0)
+			return nil
+		}
+		if err == nil {
+			// The listener stopped without error: Disconnect was called
+			return nil
+		}
+
+		c.stateMu.Lock()
+		if c.registered {
+			// A registered connection was lost, start the backoff again
+			failures = 0
+			c.attempt = 0
+		}
+		c.stateMu.Unlock()
+		failures++
+
+		if c.reconnect.MaxAttempts > 0 && failures >= c.reconnect.MaxAttempts {
+			c.setState(StateLost, reason, err, 0)
+			return NewErrorWithContext("Run", err, fmt.Sprintf("%d attempts failed", failures))
+		}
+
+		delay := c.reconnect.Backoff(failures, nil)
+		c.setState(StateLost, reason, err, delay)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			c.setState(StateDisconnected, "stopped", nil, 0)
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+// closeConn closes the UDP socket without unregistering
+func (c *Client) closeConn() {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+
+	if c.cancel != nil {
+		c.cancel()
+	}
+
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+}
+
 func (c *Client) send(data []byte) error {
-	if c.conn == nil {
+	c.connMu.Lock()
+	conn := c.conn
+	c.connMu.Unlock()
+
+	if conn == nil {
 		return NewError("send", errors.ErrConnectionClosed)
 	}
 
-	n, err := c.conn.Write(data)
+	n, err := conn.Write(data)
 	if err != nil {
 		c.logger.Error().Err(err).Msg("Error al enviar datos")
 		return NewError("send", err)
@@ -210,84 +458,197 @@ func (c *Client) send(data []byte) error {
 		return NewError("send", errors.ErrPartialWrite)
 	}
 
+	c.captureDatagram(CaptureOutbound, data)
 	return nil
 }
 
+// StartCapture writes every inbound and outbound datagram to a capture file.
+// It can be called before Connect to include the registration.
+func (c *Client) StartCapture(path string) error {
+	c.captureMu.Lock()
+	defer c.captureMu.Unlock()
+
+	if c.capture != nil {
+		return NewError("StartCapture", errors.ErrCaptureActive)
+	}
+
+	capture, err := CreateCapture(path, c.address)
+	if err != nil {
+		return err
+	}
+
+	c.capture = capture
+	c.logger.Info().Str("path", path).Msg("Capturando paquetes de broadcast")
+	return nil
+}
+
+// StopCapture finishes the current capture, if any
+func (c *Client) StopCapture() error {
+	c.captureMu.Lock()
+	defer c.captureMu.Unlock()
+
+	if c.capture == nil {
+		return nil
+	}
+
+	err := c.capture.Close()
+	c.capture = nil
+	return err
+}
+
+// IsCapturing returns true while datagrams are being captured
+func (c *Client) IsCapturing() bool {
+	c.captureMu.Lock()
+	defer c.captureMu.Unlock()
+	return c.capture != nil
+}
+
+// setCapture replaces the capture without closing the previous one; the
+// Service owns captures that span several clients
+func (c *Client) setCapture(capture *CaptureWriter) {
+	c.captureMu.Lock()
+	defer c.captureMu.Unlock()
+	c.capture = capture
+}
+
+func (c *Client) captureDatagram(direction CaptureDirection, data []byte) {
+	c.captureMu.Lock()
+	defer c.captureMu.Unlock()
+
+	if c.capture == nil {
+		return
+	}
+
+	if err := c.capture.WriteDatagram(direction, data); err != nil {
+		c.logger.Error().Err(err).Msg("Error al capturar paquete, deteniendo captura")
+		c.capture.Close()
+		c.capture = nil
+	}
+}
+
+// Disconnect unregisters from ACC and closes the connection
 func (c *Client) Disconnect() error {
-	if c.cancel != nil {
-		c.cancel()
+	c.connMu.Lock()
+	cancel, protocol := c.cancel, c.protocol
+	c.connMu.Unlock()
+
+	if cancel != nil {
+		cancel()
 	}
 
-	if c.protocol != nil {
-		if err := c.protocol.Disconnect(); err != nil {
+	if protocol != nil {
+		if err := protocol.Disconnect(); err != nil {
 			c.logger.Warn().Err(err).Msg("Error al enviar mensaje de desconexión")
 		}
 	}
 
+	c.connMu.Lock()
 	if c.conn != nil {
 		if err := c.conn.Close(); err != nil {
+			c.connMu.Unlock()
 			c.logger.Warn().Err(err).Msg("Error al cerrar conexión UDP")
 			return err
 		}
 		c.conn = nil
 	}
+	c.connMu.Unlock()
 
+	c.setState(StateDisconnected, "disconnected", nil, 0)
 	c.logger.Info().Msg("Desconectado de ACC")
 	return nil
 }
 
+// currentProtocol returns the protocol of the current connection, nil before Connect
+func (c *Client) currentProtocol() *Protocol {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+	return c.protocol
+}
+
 func (c *Client) GetCarInfo(carIndex uint16) (*CarInfo, bool) {
-	if c.protocol == nil {
+	protocol := c.currentProtocol()
+	if protocol == nil {
 		return nil, false
 	}
-	return c.protocol.GetCarInfo(carIndex)
+	return protocol.GetCarInfo(carIndex)
+}
+
+func (c *Client) GetEntryList() []CarInfo {
+	protocol := c.currentProtocol()
+	if protocol == nil {
+		return nil
+	}
+	return protocol.GetEntryList()
+}
+
+func (c *Client) GetTrackData() (TrackData, bool) {
+	protocol := c.currentProtocol()
+	if protocol == nil {
+		return TrackData{}, false
+	}
+	return protocol.GetTrackData()
 }
 
 func (c *Client) RequestEntryList() error {
-	if c.protocol == nil {
+	protocol := c.currentProtocol()
+	if protocol == nil {
 		return NewError("RequestEntryList", errors.ErrProtocolNotInitialized)
 	}
-	return c.protocol.RequestEntryList()
+	return protocol.RequestEntryList()
 }
 
 func (c *Client) RequestTrackData() error {
-	if c.protocol == nil {
+	protocol := c.currentProtocol()
+	if protocol == nil {
 		return NewError("RequestTrackData", errors.ErrProtocolNotInitialized)
 	}
-	return c.protocol.RequestTrackData()
+	return protocol.RequestTrackData()
 }
 
 func (c *Client) SetFocus(carIndex uint16) error {
-	if c.protocol == nil {
+	protocol := c.currentProtocol()
+	if protocol == nil {
 		return NewError("SetFocus", errors.ErrProtocolNotInitialized)
 	}
-	return c.protocol.SetFocus(carIndex)
+	return protocol.SetFocus(carIndex)
 }
 
 func (c *Client) SetCamera(cameraSet, camera string) error {
-	if c.protocol == nil {
+	protocol := c.currentProtocol()
+	if protocol == nil {
 		return NewError("SetCamera", errors.ErrProtocolNotInitialized)
 	}
-	return c.protocol.SetCamera(cameraSet, camera)
+	return protocol.SetCamera(cameraSet, camera)
 }
 
 func (c *Client) SetFocusAndCamera(carIndex uint16, cameraSet, camera string) error {
-	if c.protocol == nil {
+	protocol := c.currentProtocol()
+	if protocol == nil {
 		return NewError("SetFocusAndCamera", errors.ErrProtocolNotInitialized)
 	}
-	return c.protocol.SetFocusAndCamera(carIndex, cameraSet, camera)
+	return protocol.SetFocusAndCamera(carIndex, cameraSet, camera)
 }
 
 func (c *Client) RequestInstantReplay(startSessionTime, durationMS float32, initialFocusedCarIndex int32, initialCameraSet, initialCamera string) error {
-	if c.protocol == nil {
+	protocol := c.currentProtocol()
+	if protocol == nil {
 		return NewError("RequestInstantReplay", errors.ErrProtocolNotInitialized)
 	}
-	return c.protocol.RequestInstantReplay(startSessionTime, durationMS, initialFocusedCarIndex, initialCameraSet, initialCamera)
+	return protocol.RequestInstantReplay(startSessionTime, durationMS, initialFocusedCarIndex, initialCameraSet, initialCamera)
 }
 
 func (c *Client) RequestHUDPage(hudPage string) error {
-	if c.protocol == nil {
+	protocol := c.currentProtocol()
+	if protocol == nil {
 		return NewError("RequestHUDPage", errors.ErrProtocolNotInitialized)
 	}
-	return c.protocol.RequestHUDPage(hudPage)
+	return protocol.RequestHUDPage(hudPage)
+}
+
+func (c *Client) SaveManualReplayHighlight() error {
+	protocol := c.currentProtocol()
+	if protocol == nil {
+		return NewError("SaveManualReplayHighlight", errors.ErrProtocolNotInitialized)
+	}
+	return protocol.SaveManualReplayHighlight()
 }
@@ -0,0 +1,487 @@
+package broadcast
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"RaceAll/internal/errors"
+)
+
+// Commander sends commands to ACC. Client and Service send them right
+// away; CommandQueue rate-limits them and waits for ACC to apply them.
+type Commander interface {
+	SetFocus(carIndex uint16) error
+	SetCamera(cameraSet, camera string) error
+	SetFocusAndCamera(carIndex uint16, cameraSet, camera string) error
+	RequestInstantReplay(startSessionTime, durationMS float32, initialFocusedCarIndex int32, initialCameraSet, initialCamera string) error
+	RequestHUDPage(hudPage string) error
+}
+
+var (
+	_ Commander = (*Client)(nil)
+	_ Commander = (*Service)(nil)
+	_ Commander = (*CommandQueue)(nil)
+)
+
+// CommandType is the kind of a queued command
+type CommandType int
+
+const (
+	CommandFocus CommandType = iota
+	CommandCamera
+	CommandFocusAndCamera
+	CommandInstantReplay
+	CommandHUDPage
+)
+
+func (t CommandType) String() string {
+	switch t {
+	case CommandFocus:
+		return "Focus"
+	case CommandCamera:
+		return "Camera"
+	case CommandFocusAndCamera:
+		return "FocusAndCamera"
+	case CommandInstantReplay:
+		return "InstantReplay"
+	case CommandHUDPage:
+		return "HUDPage"
+	default:
+		return "Unknown"
+	}
+}
+
+// Command is a command for ACC, only the fields of its Type are used
+type Command struct {
+	Type CommandType
+
+	CarIndex  uint16
+	CameraSet string
+	Camera    string
+	HUDPage   string
+
+	// Instant replay
+	StartSessionTime       float32
+	DurationMS             float32
+	InitialFocusedCarIndex int32
+}
+
+// commandTarget is a part of the ACC state changed by commands
+type commandTarget uint8
+
+const (
+	targetFocus commandTarget = 1 << iota
+	targetCamera
+	targetReplay
+	targetHUD
+)
+
+func (c Command) targets() commandTarget {
+	switch c.Type {
+	case CommandFocus:
+		return targetFocus
+	case CommandCamera:
+		return targetCamera
+	case CommandFocusAndCamera:
+		return targetFocus | targetCamera
+	case CommandInstantReplay:
+		return targetReplay
+	case CommandHUDPage:
+		return targetHUD
+	default:
+		return 0
+	}
+}
+
+// supersedes returns true if c changes everything other changes, so other
+// does not need to be applied anymore
+func (c Command) supersedes(other Command) bool {
+	return other.targets()&^c.targets() == 0
+}
+
+// confirmedBy returns true if the realtime update shows the command applied.
+// replayStarted tells whether the update is the first one of a replay, a
+// replay already playing does not confirm a new one.
+func (c Command) confirmedBy(update RealtimeUpdate, replayStarted bool) bool {
+	focused := update.FocusedCarIndex == int32(c.CarIndex)
+	camera := update.ActiveCameraSet == c.CameraSet && update.ActiveCamera == c.Camera
+
+	switch c.Type {
+	case CommandFocus:
+		return focused
+	case CommandCamera:
+		return camera
+	case CommandFocusAndCamera:
+		return focused && camera
+	case CommandInstantReplay:
+		return replayStarted
+	case CommandHUDPage:
+		return update.CurrentHudPage == c.HUDPage
+	default:
+		return false
+	}
+}
+
+func (c Command) send(commander Commander) error {
+	switch c.Type {
+	case CommandFocus:
+		return commander.SetFocus(c.CarIndex)
+	case CommandCamera:
+		return commander.SetCamera(c.CameraSet, c.Camera)
+	case CommandFocusAndCamera:
+		return commander.SetFocusAndCamera(c.CarIndex, c.CameraSet, c.Camera)
+	case CommandInstantReplay:
+		return commander.RequestInstantReplay(c.StartSessionTime, c.DurationMS, c.InitialFocusedCarIndex, c.CameraSet, c.Camera)
+	case CommandHUDPage:
+		return commander.RequestHUDPage(c.HUDPage)
+	default:
+		return NewError("SendCommand", errors.ErrInvalidMessageType)
+	}
+}
+
+// CommandResult is the outcome of a queued command
+type CommandResult struct {
+	Command Command
+
+	// Err is nil when a realtime update confirmed the command. Otherwise it
+	// wraps ErrCommandSuperseded, ErrCommandNotConfirmed,
+	// ErrCommandQueueClosed or the error of the last send.
+	Err error
+
+	// Attempts is how many times the command was sent
+	Attempts int
+
+	// Latency is the time from the first send to the confirmation
+	Latency time.Duration
+}
+
+// CommandQueueConfig controls the rate limit and retries of a CommandQueue
+type CommandQueueConfig struct {
+	// MinInterval is the minimum time between two commands sent to ACC
+	MinInterval time.Duration
+
+	// ConfirmTimeout is how long a sent command waits for a realtime update
+	// showing it applied before it is sent again
+	ConfirmTimeout time.Duration
+
+	// MaxAttempts is how many times a command is sent before it fails
+	MaxAttempts int
+}
+
+// DefaultCommandQueueConfig sends up to 10 commands per second and each
+// command up to 3 times, one second apart
+func DefaultCommandQueueConfig() CommandQueueConfig {
+	return CommandQueueConfig{
+		MinInterval:    100 * time.Millisecond,
+		ConfirmTimeout: time.Second,
+		MaxAttempts:    3,
+	}
+}
+
+// withDefaults fills the fields left at zero
+func (cc CommandQueueConfig) withDefaults() CommandQueueConfig {
+	defaults := DefaultCommandQueueConfig()
+	if cc.MinInterval <= 0 {
+		cc.MinInterval = defaults.MinInterval
+	}
+	if cc.ConfirmTimeout <= 0 {
+		cc.ConfirmTimeout = defaults.ConfirmTimeout
+	}
+	if cc.MaxAttempts <= 0 {
+		cc.MaxAttempts = defaults.MaxAttempts
+	}
+	return cc
+}
+
+type queuedCommand struct {
+	command   Command
+	result    chan CommandResult
+	attempts  int
+	firstSent time.Time
+	sentAt    time.Time
+	done      bool
+}
+
+// finish delivers the result once, must be called with the queue lock held
+func (qc *queuedCommand) finish(err error, now time.Time) {
+	if qc.done {
+		return
+	}
+	qc.done = true
+
+	result := CommandResult{Command: qc.command, Err: err, Attempts: qc.attempts}
+	if err == nil {
+		result.Latency = now.Sub(qc.firstSent)
+	}
+	qc.result <- result
+	close(qc.result)
+}
+
+// CommandQueue sends commands to ACC no faster than MinInterval. A new
+// command supersedes the queued and unconfirmed commands it overrides, so
+// rapid clicks or a director only send the last camera. Each command is
+// confirmed by a RealtimeUpdate, passed to HandleRealtimeUpdate, and sent
+// again until MaxAttempts when it is not.
+type CommandQueue struct {
+	commander Commander
+	config    CommandQueueConfig
+
+	// pending wait to be sent, sending is being sent and sent wait for
+	// a confirmation
+	pending  []*queuedCommand
+	sending  *queuedCommand
+	sent     []*queuedCommand
+	lastSend time.Time
+
+	// stopped is set by Stop until the next Start, Submit fails right away
+	// meanwhile
+	stopped bool
+
+	// replayPlaying is IsReplayPlaying of the last realtime update
+	replayPlaying bool
+
+	wake   chan struct{}
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+}
+
+// NewCommandQueue creates a queue that sends through commander. Commands
+// can be submitted before Start, they are sent once it is started.
+func NewCommandQueue(commander Commander, config CommandQueueConfig) *CommandQueue {
+	return &CommandQueue{
+		commander: commander,
+		config:    config.withDefaults(),
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// Start starts sending the queued commands
+func (q *CommandQueue) Start() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.stopped = false
+	if q.cancel != nil {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	q.cancel = cancel
+
+	q.wg.Add(1)
+	go q.run(ctx)
+}
+
+// Stop stops sending and fails the commands not confirmed yet with
+// ErrCommandQueueClosed, as well as the commands submitted until Start
+func (q *CommandQueue) Stop() {
+	q.mu.Lock()
+	cancel := q.cancel
+	q.cancel = nil
+	q.stopped = true
+	q.mu.Unlock()
+
+	if cancel == nil {
+		return
+	}
+	cancel()
+	q.wg.Wait()
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	err := NewError("CommandQueue", errors.ErrCommandQueueClosed)
+	now := time.Now()
+	for _, qc := range q.pending {
+		qc.finish(err, now)
+	}
+	for _, qc := range q.sent {
+		qc.finish(err, now)
+	}
+	q.pending, q.sent = nil, nil
+}
+
+// Submit queues a command. The channel receives its result and is closed.
+func (q *CommandQueue) Submit(command Command) <-chan CommandResult {
+	qc := &queuedCommand{command: command, result: make(chan CommandResult, 1)}
+
+	q.mu.Lock()
+	now := time.Now()
+	if q.stopped {
+		qc.finish(NewError("CommandQueue", errors.ErrCommandQueueClosed), now)
+		q.mu.Unlock()
+		return qc.result
+	}
+	q.pending = q.supersedeLocked(q.pending, command, now)
+	q.sent = q.supersedeLocked(q.sent, command, now)
+	if q.sending != nil && command.supersedes(q.sending.command) {
+		q.sending.finish(NewError("CommandQueue", errors.ErrCommandSuperseded), now)
+	}
+	q.pending = append(q.pending, qc)
+	q.mu.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return qc.result
+}
+
+// supersedeLocked fails and removes the commands overridden by command
+func (q *CommandQueue) supersedeLocked(commands []*queuedCommand, command Command, now time.Time) []*queuedCommand {
+	kept := commands[:0]
+	for _, qc := range commands {
+		if command.supersedes(qc.command) {
+			qc.finish(NewError("CommandQueue", errors.ErrCommandSuperseded), now)
+			continue
+		}
+		kept = append(kept, qc)
+	}
+	return kept
+}
+
+// Pending returns how many commands are waiting to be sent or confirmed
+func (q *CommandQueue) Pending() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	count := len(q.pending) + len(q.sent)
+	if q.sending != nil {
+		count++
+	}
+	return count
+}
+
+// HandleRealtimeUpdate confirms the sent commands the update shows applied
+func (q *CommandQueue) HandleRealtimeUpdate(update RealtimeUpdate) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	replayStarted := update.IsReplayPlaying && !q.replayPlaying
+	q.replayPlaying = update.IsReplayPlaying
+
+	now := time.Now()
+	kept := q.sent[:0]
+	for _, qc := range q.sent {
+		if qc.command.confirmedBy(update, replayStarted) {
+			qc.finish(nil, now)
+			continue
+		}
+		kept = append(kept, qc)
+	}
+	q.sent = kept
+}
+
+func (q *CommandQueue) run(ctx context.Context) {
+	defer q.wg.Done()
+
+	ticker := time.NewTicker(q.config.MinInterval)
+	defer ticker.Stop()
+
+	for {
+		q.step(time.Now())
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-q.wake:
+		}
+	}
+}
+
+// step queues the unconfirmed commands again, or fails them after
+// MaxAttempts, and sends the next command if the rate limit allows it
+func (q *CommandQueue) step(now time.Time) {
+	q.mu.Lock()
+
+	var retries []*queuedCommand
+	kept := q.sent[:0]
+	for _, qc := range q.sent {
+		switch {
+		case now.Sub(qc.sentAt) < q.config.ConfirmTimeout:
+			kept = append(kept, qc)
+		case qc.attempts < q.config.MaxAttempts:
+			retries = append(retries, qc)
+		default:
+			qc.finish(NewError("CommandQueue", errors.ErrCommandNotConfirmed), now)
+		}
+	}
+	q.sent = kept
+	// Retries go first, newer commands must still be applied after them
+	q.pending = append(retries, q.pending...)
+
+	if len(q.pending) == 0 || now.Sub(q.lastSend) < q.config.MinInterval {
+		q.mu.Unlock()
+		return
+	}
+
+	qc := q.pending[0]
+	q.pending = q.pending[1:]
+	q.sending = qc
+	q.lastSend = now
+	q.mu.Unlock()
+
+	err := qc.command.send(q.commander)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.sending = nil
+	if qc.done {
+		// Superseded while it was being sent
+		return
+	}
+
+	qc.attempts++
+	if qc.attempts == 1 {
+		qc.firstSent = now
+	}
+	qc.sentAt = now
+
+	if err != nil {
+		qc.finish(err, now)
+		return
+	}
+	q.sent = append(q.sent, qc)
+}
+
+// SetFocus queues a focus change without waiting for its result
+func (q *CommandQueue) SetFocus(carIndex uint16) error {
+	q.Submit(Command{Type: CommandFocus, CarIndex: carIndex})
+	return nil
+}
+
+// SetCamera queues a camera change without waiting for its result
+func (q *CommandQueue) SetCamera(cameraSet, camera string) error {
+	q.Submit(Command{Type: CommandCamera, CameraSet: cameraSet, Camera: camera})
+	return nil
+}
+
+// SetFocusAndCamera queues a focus and camera change without waiting for
+// its result, so the queue can be the director.Controller
+func (q *CommandQueue) SetFocusAndCamera(carIndex uint16, cameraSet, camera string) error {
+	q.Submit(Command{Type: CommandFocusAndCamera, CarIndex: carIndex, CameraSet: cameraSet, Camera: camera})
+	return nil
+}
+
+// RequestInstantReplay queues an instant replay without waiting for its result
+func (q *CommandQueue) RequestInstantReplay(startSessionTime, durationMS float32, initialFocusedCarIndex int32, initialCameraSet, initialCamera string) error {
+	q.Submit(Command{
+		Type:                   CommandInstantReplay,
+		StartSessionTime:       startSessionTime,
+		DurationMS:             durationMS,
+		InitialFocusedCarIndex: initialFocusedCarIndex,
+		CameraSet:              initialCameraSet,
+		Camera:                 initialCamera,
+	})
+	return nil
+}
+
+// RequestHUDPage queues a HUD page change without waiting for its result
+func (q *CommandQueue) RequestHUDPage(hudPage string) error {
+	q.Submit(Command{Type: CommandHUDPage, HUDPage: hudPage})
+	return nil
+}
@@ -1,13 +1,48 @@
 package broadcast
 
 import (
+	"RaceAll/internal/errors"
 	"RaceAll/internal/logger"
+	"RaceAll/internal/pubsub"
 	"RaceAll/internal/sharedmemory"
 	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// ConnectionMode decide qué mantiene viva la conexión de broadcast
+type ConnectionMode int
+
+const (
+	// ModeLocal conecta mientras shared memory muestra el juego abierto en
+	// esta PC
+	ModeLocal ConnectionMode = iota
+
+	// ModeSpectator mantiene la conexión sin shared memory, para seguir un
+	// servidor remoto o el puerto de broadcast de un servidor dedicado. El
+	// cliente se reconecta solo cuando ACC deja de responder.
+	ModeSpectator
+)
+
+func (m ConnectionMode) String() string {
+	switch m {
+	case ModeLocal:
+		return "Local"
+	case ModeSpectator:
+		return "Spectator"
+	default:
+		return "Unknown"
+	}
+}
+
+// SpectatorPipeline es un Pipeline que sigue el modo de conexión: el
+// ConnectionManager que lo alimenta le pasa ModeSpectator con SetSpectator
+type SpectatorPipeline interface {
+	Pipeline
+	SetSpectator(spectator bool)
+}
+
 // ConnectionManager maneja la reconexión automática del broadcast
 type ConnectionManager struct {
 	service          *Service
@@ -19,15 +54,58 @@ type ConnectionManager struct {
 	isMonitoring     bool
 	lastPacketID     int32
 	config           Config
+
+	// mode es un ConnectionMode; atómico porque Stop espera al monitoreo
+	// con mu tomado
+	mode atomic.Int32
+
+	// pipeline recibe los mensajes del servicio a través de pipelineSub
+	pipeline     SpectatorPipeline
+	pipelineSub  *pubsub.Subscription[BroadcastMessage]
+	pipelineDone chan struct{}
 }
 
-// NewConnectionManager crea un nuevo gestor de conexiones
+// NewConnectionManager crea un nuevo gestor de conexiones. Sin servicio de
+// shared memory (nil) arranca en ModeSpectator.
 func NewConnectionManager(config Config, smService *sharedmemory.Service) *ConnectionManager {
-	return &ConnectionManager{
+	mode := ModeLocal
+	if smService == nil {
+		mode = ModeSpectator
+	}
+
+	cm := &ConnectionManager{
 		config:           config,
 		sharedMemService: smService,
 		lastPacketID:     0,
 	}
+	cm.mode.Store(int32(mode))
+	return cm
+}
+
+// SetMode cambia el modo de conexión, también mientras está monitoreando,
+// y lo pasa al pipeline. ModeLocal sin servicio de shared memory no se
+// puede usar.
+func (cm *ConnectionManager) SetMode(mode ConnectionMode) error {
+	if mode == ModeLocal && cm.sharedMemService == nil {
+		return NewError("SetMode", errors.ErrSharedMemoryNotFound)
+	}
+
+	// mu ordena el cambio de modo con SetPipeline
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if ConnectionMode(cm.mode.Swap(int32(mode))) != mode {
+		logger.Infof("Broadcast connection mode: %s", mode)
+	}
+	if cm.pipeline != nil {
+		cm.pipeline.SetSpectator(mode == ModeSpectator)
+	}
+	return nil
+}
+
+// Mode devuelve el modo de conexión
+func (cm *ConnectionManager) Mode() ConnectionMode {
+	return ConnectionMode(cm.mode.Load())
 }
 
 // Start inicia el monitoreo de conexión
@@ -42,8 +120,11 @@ func (cm *ConnectionManager) Start() error {
 	cm.ctx, cm.cancel = context.WithCancel(context.Background())
 	cm.isMonitoring = true
 
-	// Iniciar servicio de broadcast
-	cm.service = NewService(cm.config)
+	// Iniciar servicio de broadcast; se reutiliza si ya existe para no perder
+	// una captura iniciada antes de Start
+	if cm.service == nil {
+		cm.service = NewService(cm.config)
+	}
 
 	// Iniciar goroutine de monitoreo
 	cm.wg.Add(1)
@@ -67,6 +148,9 @@ func (cm *ConnectionManager) Stop() {
 
 	if cm.service != nil {
 		cm.service.Stop()
+		if err := cm.service.StopCapture(); err != nil {
+			logger.Errorf("Failed to close broadcast capture: %v", err)
+		}
 	}
 
 	cm.isMonitoring = false
@@ -86,11 +170,28 @@ func (cm *ConnectionManager) monitorConnection() {
 		case <-cm.ctx.Done():
 			return
 		case <-ticker.C:
-			cm.checkAndReconnect()
+			if cm.Mode() == ModeSpectator {
+				cm.checkSpectator()
+			} else {
+				cm.checkAndReconnect()
+			}
 		}
 	}
 }
 
+// checkSpectator mantiene el servicio en marcha sin mirar shared memory. El
+// cliente ya vuelve a registrarse cuando ACC rechaza el registro o deja de
+// enviar datos; solo hace falta arrancarlo de nuevo si se detuvo tras
+// agotar ReconnectConfig.MaxAttempts.
+func (cm *ConnectionManager) checkSpectator() {
+	if cm.service != nil && cm.service.IsRunning() {
+		return
+	}
+
+	logger.Info("Spectator mode, connecting broadcast")
+	cm.connectBroadcast()
+}
+
 // checkAndReconnect verifica el estado del juego y reconecta si es necesario
 func (cm *ConnectionManager) checkAndReconnect() {
 	if cm.sharedMemService == nil {
@@ -167,6 +268,43 @@ func (cm *ConnectionManager) connectBroadcast() {
 	}
 }
 
+// SetPipeline alimenta pipeline con los mensajes del servicio de broadcast,
+// también entre reconexiones, y le pasa el modo de conexión actual y los
+// cambios de SetMode. Con nil se deja de alimentar el pipeline anterior.
+func (cm *ConnectionManager) SetPipeline(pipeline SpectatorPipeline) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if cm.pipelineSub != nil {
+		cm.service.Unsubscribe(cm.pipelineSub.C())
+		<-cm.pipelineDone
+		cm.pipeline, cm.pipelineSub, cm.pipelineDone = nil, nil, nil
+	}
+	if pipeline == nil {
+		return
+	}
+
+	if cm.service == nil {
+		cm.service = NewService(cm.config)
+	}
+	pipeline.SetSpectator(cm.Mode() == ModeSpectator)
+
+	// Como en ConnectionRegistry, un pipeline lento no frena al cliente UDP
+	sub := cm.service.SubscribeWithOptions(pubsub.Options{
+		Policy:     pubsub.DropOldest,
+		BufferSize: pipelineBufferSize,
+	})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for msg := range sub.C() {
+			pipeline.HandleMessage(msg)
+		}
+	}()
+
+	cm.pipeline, cm.pipelineSub, cm.pipelineDone = pipeline, sub, done
+}
+
 // GetService devuelve el servicio de broadcast
 func (cm *ConnectionManager) GetService() *Service {
 	cm.mu.RLock()
@@ -174,9 +312,49 @@ func (cm *ConnectionManager) GetService() *Service {
 	return cm.service
 }
 
-// IsConnected verifica si el broadcast está conectado
+// StartCapture graba todos los paquetes de broadcast en un archivo de
+// captura. La captura sigue entre reconexiones hasta StopCapture o Stop.
+func (cm *ConnectionManager) StartCapture(path string) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if cm.service == nil {
+		cm.service = NewService(cm.config)
+	}
+	return cm.service.StartCapture(path)
+}
+
+// StopCapture termina la captura en curso, si hay una
+func (cm *ConnectionManager) StopCapture() error {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	if cm.service == nil {
+		return nil
+	}
+	return cm.service.StopCapture()
+}
+
+// IsCapturing verifica si se están grabando paquetes de broadcast
+func (cm *ConnectionManager) IsCapturing() bool {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+	return cm.service != nil && cm.service.IsCapturing()
+}
+
+// IsConnected verifica si el broadcast está conectado, es decir registrado
+// en ACC y recibiendo datos
 func (cm *ConnectionManager) IsConnected() bool {
+	return cm.State().IsRegistered()
+}
+
+// State devuelve el estado de la conexión de broadcast
+func (cm *ConnectionManager) State() ClientState {
 	cm.mu.RLock()
 	defer cm.mu.RUnlock()
-	return cm.service != nil && cm.service.IsRunning()
+
+	if cm.service == nil || !cm.service.IsRunning() {
+		return StateDisconnected
+	}
+	return cm.service.State()
 }
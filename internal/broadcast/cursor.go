@@ -0,0 +1,333 @@
+package broadcast
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+
+	"RaceAll/internal/errors"
+)
+
+// Cursor decodes little-endian fields straight from a datagram without
+// allocating. The first failure sticks: later reads return zero values and
+// Err reports the failing field and its offset.
+type Cursor struct {
+	data   []byte
+	offset int
+
+	// scope prefixes field names in errors, e.g. the lap being decoded
+	scope string
+	err   error
+}
+
+// Reset points the cursor at the start of data and clears the error
+func (c *Cursor) Reset(data []byte) {
+	c.data = data
+	c.offset = 0
+	c.scope = ""
+	c.err = nil
+}
+
+// Err returns the first decode error, a *errors.DecodeError
+func (c *Cursor) Err() error {
+	return c.err
+}
+
+// Offset returns the offset of the next field
+func (c *Cursor) Offset() int {
+	return c.offset
+}
+
+// Remaining returns the bytes left after the offset
+func (c *Cursor) Remaining() int {
+	return len(c.data) - c.offset
+}
+
+func (c *Cursor) fail(field string, err error) {
+	c.failAt(field, c.offset, err)
+}
+
+// failAt records an error of the field starting at offset. The field name
+// is only built on failure so successful decodes do not allocate.
+func (c *Cursor) failAt(field string, offset int, err error) {
+	if c.err != nil {
+		return
+	}
+	if c.scope != "" {
+		field = c.scope + "." + field
+	}
+	c.err = errors.NewDecodeError(field, offset, err)
+}
+
+// take returns the next n bytes, or nil after recording a truncation
+func (c *Cursor) take(field string, n int) []byte {
+	if c.err != nil {
+		return nil
+	}
+	if c.Remaining() < n {
+		c.fail(field, io.ErrUnexpectedEOF)
+		return nil
+	}
+	b := c.data[c.offset : c.offset+n]
+	c.offset += n
+	return b
+}
+
+func (c *Cursor) Uint8(field string) uint8 {
+	b := c.take(field, 1)
+	if b == nil {
+		return 0
+	}
+	return b[0]
+}
+
+func (c *Cursor) Uint16(field string) uint16 {
+	b := c.take(field, 2)
+	if b == nil {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(b)
+}
+
+func (c *Cursor) Int32(field string) int32 {
+	b := c.take(field, 4)
+	if b == nil {
+		return 0
+	}
+	return int32(binary.LittleEndian.Uint32(b))
+}
+
+func (c *Cursor) Float32(field string) float32 {
+	b := c.take(field, 4)
+	if b == nil {
+		return 0
+	}
+	return math.Float32frombits(binary.LittleEndian.Uint32(b))
+}
+
+// StringBytes returns a length-prefixed string as a slice of the datagram,
+// only valid until the datagram buffer is reused
+func (c *Cursor) StringBytes(field string) []byte {
+	start := c.offset
+	length := int(c.Uint16(field))
+	if c.err != nil {
+		return nil
+	}
+	if length > c.Remaining() {
+		c.failAt(field, start, fmt.Errorf("%w: string of %d bytes, %d left", errors.ErrLengthOutOfRange, length, c.Remaining()))
+		return nil
+	}
+	return c.take(field, length)
+}
+
+// StringInto decodes a length-prefixed string into dst, keeping the
+// current value without allocating when the text did not change
+func (c *Cursor) StringInto(field string, dst *string) {
+	b := c.StringBytes(field)
+	if c.err != nil || string(b) == *dst {
+		return
+	}
+	*dst = string(b)
+}
+
+// Lap decodes a lap into dst with the same layout as readLap
+func (c *Cursor) Lap(field string, dst *Lap) {
+	c.scope = field
+
+	dst.LaptimeMS = c.Int32("lapTime")
+	dst.CarIndex = c.Uint16("carIndex")
+	dst.DriverIndex = c.Uint16("driverIndex")
+
+	start := c.offset
+	splitCount := int(c.Uint8("splitCount"))
+	if c.err == nil && splitCount*minSplitSize > c.Remaining() {
+		c.failAt("splitCount", start, fmt.Errorf("%w: %d splits, %d bytes left", errors.ErrLengthOutOfRange, splitCount, c.Remaining()))
+	}
+
+	// All splits are read to stay aligned, only the first three are kept
+	dst.Splits = [3]int32{InvalidSectorTime, InvalidSectorTime, InvalidSectorTime}
+	for i := 0; i < splitCount && c.err == nil; i++ {
+		split := c.Int32("split")
+		if i < len(dst.Splits) {
+			dst.Splits[i] = split
+		}
+	}
+
+	dst.IsInvalid = c.Uint8("isInvalid") > 0
+	dst.IsValidForBest = c.Uint8("isValidForBest") > 0
+	isOutlap := c.Uint8("isOutlap") > 0
+	isInlap := c.Uint8("isInlap") > 0
+
+	switch {
+	case isOutlap:
+		dst.Type = LapTypeOutlap
+	case isInlap:
+		dst.Type = LapTypeInlap
+	default:
+		dst.Type = LapTypeRegular
+	}
+
+	c.scope = ""
+}
+
+// Lap is LapInfo without pointers, filled by the allocation-free decode
+// path. Missing times hold InvalidLapTime and InvalidSectorTime.
+type Lap struct {
+	LaptimeMS      int32
+	Splits         [3]int32
+	CarIndex       uint16
+	DriverIndex    uint16
+	IsInvalid      bool
+	IsValidForBest bool
+	Type           LapType
+}
+
+// HasLapTime returns true if the lap has a time
+func (l *Lap) HasLapTime() bool {
+	return l.LaptimeMS != InvalidLapTime
+}
+
+// HasSplit returns true if sector i has a time
+func (l *Lap) HasSplit(i int) bool {
+	return i >= 0 && i < len(l.Splits) && l.Splits[i] != InvalidSectorTime
+}
+
+// GetLapTimeMS returns the sum of the sector times, like LapInfo.GetLapTimeMS
+func (l *Lap) GetLapTimeMS() int32 {
+	var totalTime int32
+	for i, split := range l.Splits {
+		if l.HasSplit(i) {
+			totalTime += split
+		}
+	}
+	return totalTime
+}
+
+// LapInfo converts the lap to LapInfo, allocating the time pointers
+func (l *Lap) LapInfo() LapInfo {
+	lap := LapInfo{
+		CarIndex:       l.CarIndex,
+		DriverIndex:    l.DriverIndex,
+		IsInvalid:      l.IsInvalid,
+		IsValidForBest: l.IsValidForBest,
+		Type:           l.Type,
+	}
+	if l.HasLapTime() {
+		laptime := l.LaptimeMS
+		lap.LaptimeMS = &laptime
+	}
+	for i := range l.Splits {
+		if l.HasSplit(i) {
+			split := l.Splits[i]
+			lap.Splits[i] = &split
+		}
+	}
+	return lap
+}
+
+// CarUpdate is RealtimeCarUpdate without pointers. The protocol decodes
+// every car update into pooled CarUpdate values, see Protocol.OnCarUpdate.
+type CarUpdate struct {
+	CarIndex       uint16
+	DriverIndex    uint16
+	DriverCount    byte
+	Gear           int8
+	WorldPosX      float32
+	WorldPosY      float32
+	Heading        float32
+	CarLocation    CarLocationEnum
+	Kmh            uint16
+	Position       uint16
+	CupPosition    uint16
+	TrackPosition  uint16
+	SplinePosition float32
+	Laps           uint16
+	Delta          int32
+	BestSessionLap Lap
+	LastLap        Lap
+	CurrentLap     Lap
+}
+
+// RealtimeCarUpdate converts the update, allocating the lap time pointers
+func (u *CarUpdate) RealtimeCarUpdate() RealtimeCarUpdate {
+	return RealtimeCarUpdate{
+		CarIndex:       u.CarIndex,
+		DriverIndex:    u.DriverIndex,
+		DriverCount:    u.DriverCount,
+		Gear:           u.Gear,
+		WorldPosX:      u.WorldPosX,
+		WorldPosY:      u.WorldPosY,
+		Heading:        u.Heading,
+		CarLocation:    u.CarLocation,
+		Kmh:            u.Kmh,
+		Position:       u.Position,
+		CupPosition:    u.CupPosition,
+		TrackPosition:  u.TrackPosition,
+		SplinePosition: u.SplinePosition,
+		Laps:           u.Laps,
+		Delta:          u.Delta,
+		BestSessionLap: u.BestSessionLap.LapInfo(),
+		LastLap:        u.LastLap.LapInfo(),
+		CurrentLap:     u.CurrentLap.LapInfo(),
+	}
+}
+
+var carUpdatePool = sync.Pool{
+	New: func() interface{} {
+		return new(CarUpdate)
+	},
+}
+
+// AcquireCarUpdate returns a CarUpdate from the pool
+func AcquireCarUpdate() *CarUpdate {
+	return carUpdatePool.Get().(*CarUpdate)
+}
+
+// ReleaseCarUpdate returns an update to the pool. It must not be used after.
+func ReleaseCarUpdate(update *CarUpdate) {
+	*update = CarUpdate{}
+	carUpdatePool.Put(update)
+}
+
+// DecodeRealtimeCarUpdate decodes the body of a realtime car update into
+// dst without allocating. It accepts and rejects the same input as
+// UnmarshalRealtimeCarUpdate.
+func DecodeRealtimeCarUpdate(data []byte, dst *CarUpdate) error {
+	var c Cursor
+	c.Reset(data)
+	return decodeRealtimeCarUpdate(&c, dst)
+}
+
+func decodeRealtimeCarUpdate(c *Cursor, dst *CarUpdate) error {
+	dst.CarIndex = c.Uint16("carIndex")
+	if c.err == nil {
+		if err := ValidateCarIndex(dst.CarIndex); err != nil {
+			c.failAt("carIndex", 0, err)
+		}
+	}
+
+	dst.DriverIndex = c.Uint16("driverIndex")
+	dst.DriverCount = c.Uint8("driverCount")
+	dst.Gear = int8(c.Uint8("gear")) - 2
+	dst.WorldPosX = c.Float32("worldPosX")
+	dst.WorldPosY = c.Float32("worldPosY")
+	dst.Heading = c.Float32("heading")
+	dst.CarLocation = CarLocationEnum(c.Uint8("carLocation"))
+	dst.Kmh = c.Uint16("kmh")
+	dst.Position = c.Uint16("position")
+	dst.CupPosition = c.Uint16("cupPosition")
+	dst.TrackPosition = c.Uint16("trackPosition")
+	dst.SplinePosition = c.Float32("splinePosition")
+	dst.Laps = c.Uint16("laps")
+	dst.Delta = c.Int32("delta")
+	c.Lap("bestSessionLap", &dst.BestSessionLap)
+	c.Lap("lastLap", &dst.LastLap)
+	c.Lap("currentLap", &dst.CurrentLap)
+
+	if c.err != nil {
+		return NewError("DecodeRealtimeCarUpdate", c.err)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package broadcast
+
+import (
+	"fmt"
+	"io"
+
+	"RaceAll/internal/errors"
+)
+
+// Maximum counts accepted in inbound messages. ACC stays well below them;
+// larger values come from corrupt or hostile datagrams.
+const (
+	MaxEntryListCars = 200
+	MaxDrivers       = 10
+	MaxCameraSets    = 50
+	MaxCamerasPerSet = 100
+	MaxHUDPages      = 50
+)
+
+// Minimum encoded size of repeated elements, used to reject counts that
+// cannot fit in the bytes left before allocating or looping
+const (
+	minStringSize    = 2
+	minCarIndexSize  = 2
+	minCameraSetSize = minStringSize + 1
+	minDriverSize    = 3*minStringSize + 1 + 2
+	minSplitSize     = 4
+)
+
+// messageReader counts the bytes read from a message body so decode errors
+// can name the offset of the failing field. When the underlying reader knows
+// how many bytes are left (bytes.Reader), lengths and counts are checked
+// against them.
+type messageReader struct {
+	r      io.Reader
+	offset int
+	field  int // offset where the last field started
+	length func() int
+}
+
+func newMessageReader(r io.Reader) *messageReader {
+	if mr, ok := r.(*messageReader); ok {
+		return mr
+	}
+
+	mr := &messageReader{r: r}
+	if lr, ok := r.(interface{ Len() int }); ok {
+		mr.length = lr.Len
+	}
+	return mr
+}
+
+func (mr *messageReader) Read(p []byte) (int, error) {
+	n, err := mr.r.Read(p)
+	mr.offset += n
+	return n, err
+}
+
+// fail wraps the error of the last field read
+func (mr *messageReader) fail(field string, err error) error {
+	return errors.NewDecodeError(field, mr.field, err)
+}
+
+// checkCount validates a count against its maximum and the bytes left
+func (mr *messageReader) checkCount(field string, count, limit, minSize int) error {
+	if count > limit {
+		return mr.fail(field, NewValidationError(field, count, fmt.Sprintf("exceeds maximum of %d", limit)))
+	}
+	if left, ok := remaining(mr); ok && count*minSize > left {
+		return mr.fail(field, fmt.Errorf("%w: %d elements need at least %d bytes, %d left",
+			errors.ErrLengthOutOfRange, count, count*minSize, left))
+	}
+	return nil
+}
+
+// markField records that the next bytes read from r start a new field
+func markField(r io.Reader) {
+	if mr, ok := r.(*messageReader); ok {
+		mr.field = mr.offset
+	}
+}
+
+// remaining returns the bytes left in r, if r knows it
+func remaining(r io.Reader) (int, bool) {
+	switch reader := r.(type) {
+	case *messageReader:
+		if reader.length == nil {
+			return 0, false
+		}
+		return reader.length(), true
+	case interface{ Len() int }:
+		return reader.Len(), true
+	}
+	return 0, false
+}
@@ -0,0 +1,201 @@
+package broadcast
+
+import (
+	"slices"
+	"time"
+)
+
+// DefaultCarTimeout is how long a car may go without realtime updates
+// before it is removed from the entry list
+const DefaultCarTimeout = 10 * time.Second
+
+// EntryListChangeType is the kind of change between two entry lists
+type EntryListChangeType int
+
+const (
+	// CarJoined is reported when the details of a new car arrive
+	CarJoined EntryListChangeType = iota
+	// CarLeft is reported when a car is missing from a new entry list or
+	// stopped sending updates
+	CarLeft
+	// DriverSwapped is reported when the current driver of a car changes
+	DriverSwapped
+	// CarInfoChanged is reported when any other detail of a car changes
+	CarInfoChanged
+)
+
+func (t EntryListChangeType) String() string {
+	switch t {
+	case CarJoined:
+		return "CarJoined"
+	case CarLeft:
+		return "CarLeft"
+	case DriverSwapped:
+		return "DriverSwapped"
+	case CarInfoChanged:
+		return "CarInfoChanged"
+	default:
+		return "Unknown"
+	}
+}
+
+// EntryListChange is a car joining, leaving or changing in the entry list
+type EntryListChange struct {
+	Type     EntryListChangeType
+	CarIndex uint16
+
+	// Car is the car after the change, or the last known data for CarLeft
+	Car CarInfo
+
+	// Previous is the car before the change, empty for CarJoined
+	Previous CarInfo
+
+	// TimedOut is set on CarLeft when the car stopped sending updates
+	TimedOut bool
+
+	Time time.Time
+}
+
+// SetCarTimeout sets how long a car may go without realtime updates before
+// it is reported as CarLeft. Zero or less disables the timeout. It must be
+// called before messages are processed.
+func (p *Protocol) SetCarTimeout(timeout time.Duration) {
+	p.carTimeout = timeout
+}
+
+// applyEntryList keeps the known cars that are still in the list and
+// removes the others. New cars get a placeholder until their details arrive.
+func (p *Protocol) applyEntryList(carIndexes []uint16, now time.Time) []EntryListChange {
+	p.entryListMutex.Lock()
+	defer p.entryListMutex.Unlock()
+
+	var changes []EntryListChange
+	for carIndex, carInfo := range p.entryListCars {
+		if !slices.Contains(carIndexes, carIndex) {
+			changes = p.removeCarLocked(changes, carInfo, false, now)
+		}
+	}
+
+	for _, carIndex := range carIndexes {
+		if _, exists := p.entryListCars[carIndex]; !exists {
+			p.entryListCars[carIndex] = &CarInfo{CarIndex: carIndex}
+			p.lastSeen[carIndex] = now
+		}
+	}
+
+	return changes
+}
+
+// applyEntryListCar stores the details of a car and returns the change
+// they make, false if nothing changed
+func (p *Protocol) applyEntryListCar(carInfo CarInfo, now time.Time) (EntryListChange, bool) {
+	p.entryListMutex.Lock()
+	previous, exists := p.entryListCars[carInfo.CarIndex]
+	p.entryListCars[carInfo.CarIndex] = &carInfo
+	p.lastSeen[carInfo.CarIndex] = now
+	p.entryListMutex.Unlock()
+
+	change := EntryListChange{CarIndex: carInfo.CarIndex, Car: carInfo, Time: now}
+
+	switch {
+	case !exists || len(previous.Drivers) == 0:
+		// Cars without drivers are placeholders of the entry list
+		change.Type = CarJoined
+	case previous.CurrentDriverIndex != carInfo.CurrentDriverIndex:
+		change.Type = DriverSwapped
+		change.Previous = *previous
+	case !equalCarInfo(*previous, carInfo):
+		change.Type = CarInfoChanged
+		change.Previous = *previous
+	default:
+		return change, false
+	}
+
+	return change, true
+}
+
+// swapDriverLocked records the driver of a car update that differs from
+// the entry list. The car info is replaced, not modified, since GetCarInfo
+// hands out pointers to it. Must be called with entryListMutex held.
+func (p *Protocol) swapDriverLocked(carInfo *CarInfo, driverIndex uint16, now time.Time) EntryListChange {
+	updated := *carInfo
+	updated.CurrentDriverIndex = byte(driverIndex)
+	p.entryListCars[carInfo.CarIndex] = &updated
+
+	return EntryListChange{
+		Type:     DriverSwapped,
+		CarIndex: carInfo.CarIndex,
+		Car:      updated,
+		Previous: *carInfo,
+		Time:     now,
+	}
+}
+
+// expireCars removes the cars without updates for longer than the car timeout
+func (p *Protocol) expireCars(now time.Time) []EntryListChange {
+	if p.carTimeout <= 0 {
+		return nil
+	}
+
+	p.entryListMutex.Lock()
+	defer p.entryListMutex.Unlock()
+
+	var changes []EntryListChange
+	for carIndex, seen := range p.lastSeen {
+		if now.Sub(seen) > p.carTimeout {
+			changes = p.removeCarLocked(changes, p.entryListCars[carIndex], true, now)
+		}
+	}
+	return changes
+}
+
+// removeCarLocked deletes a car and appends its CarLeft change. Placeholders
+// never joined, so they leave silently. Must be called with entryListMutex
+// held.
+func (p *Protocol) removeCarLocked(changes []EntryListChange, carInfo *CarInfo, timedOut bool, now time.Time) []EntryListChange {
+	if carInfo == nil {
+		return changes
+	}
+
+	delete(p.entryListCars, carInfo.CarIndex)
+	delete(p.lastSeen, carInfo.CarIndex)
+
+	if len(carInfo.Drivers) == 0 {
+		return changes
+	}
+
+	return append(changes, EntryListChange{
+		Type:     CarLeft,
+		CarIndex: carInfo.CarIndex,
+		Car:      *carInfo,
+		TimedOut: timedOut,
+		Time:     now,
+	})
+}
+
+// notifyEntryListChanges logs the changes and calls OnEntryListChange
+func (p *Protocol) notifyEntryListChanges(changes ...EntryListChange) {
+	for _, change := range changes {
+		p.logger.Info().
+			Str("change", change.Type.String()).
+			Uint16("carIndex", change.CarIndex).
+			Int32("raceNumber", change.Car.RaceNumber).
+			Bool("timedOut", change.TimedOut).
+			Msg("Cambio en entry list")
+
+		if p.OnEntryListChange != nil {
+			p.OnEntryListChange(change)
+		}
+	}
+}
+
+func equalCarInfo(a, b CarInfo) bool {
+	return a.CarIndex == b.CarIndex &&
+		a.CarModelType == b.CarModelType &&
+		a.TeamName == b.TeamName &&
+		a.RaceNumber == b.RaceNumber &&
+		a.CupCategory == b.CupCategory &&
+		a.CurrentDriverIndex == b.CurrentDriverIndex &&
+		a.Nationality == b.Nationality &&
+		slices.Equal(a.Drivers, b.Drivers)
+}
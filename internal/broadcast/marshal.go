@@ -2,8 +2,13 @@ package broadcast
 
 import (
 	"encoding/binary"
+	"fmt"
 	"io"
 	"math"
+	"sort"
+	"time"
+
+	"RaceAll/internal/errors"
 )
 
 const (
@@ -15,10 +20,30 @@ func writeUint8(w io.Writer, value uint8) error {
 	return binary.Write(w, binary.LittleEndian, value)
 }
 
+func writeUint16(w io.Writer, value uint16) error {
+	return binary.Write(w, binary.LittleEndian, value)
+}
+
 func writeInt32(w io.Writer, value int32) error {
 	return binary.Write(w, binary.LittleEndian, value)
 }
 
+func writeFloat32(w io.Writer, value float32) error {
+	return binary.Write(w, binary.LittleEndian, value)
+}
+
+// writeMilliseconds writes a duration as the float32 milliseconds ACC uses
+func writeMilliseconds(w io.Writer, d time.Duration) error {
+	return writeFloat32(w, float32(d.Milliseconds()))
+}
+
+func writeBool(w io.Writer, value bool) error {
+	if value {
+		return writeUint8(w, 1)
+	}
+	return writeUint8(w, 0)
+}
+
 func writeString(w io.Writer, s string) error {
 	length := uint16(len(s))
 	if err := binary.Write(w, binary.LittleEndian, length); err != nil {
@@ -29,43 +54,56 @@ func writeString(w io.Writer, s string) error {
 }
 
 func readUint8(r io.Reader) (uint8, error) {
+	markField(r)
 	var value uint8
 	err := binary.Read(r, binary.LittleEndian, &value)
 	return value, err
 }
 
 func readInt8(r io.Reader) (int8, error) {
+	markField(r)
 	var value int8
 	err := binary.Read(r, binary.LittleEndian, &value)
 	return value, err
 }
 
 func readUint16(r io.Reader) (uint16, error) {
+	markField(r)
 	var value uint16
 	err := binary.Read(r, binary.LittleEndian, &value)
 	return value, err
 }
 
 func readInt32(r io.Reader) (int32, error) {
+	markField(r)
 	var value int32
 	err := binary.Read(r, binary.LittleEndian, &value)
 	return value, err
 }
 
 func readFloat32(r io.Reader) (float32, error) {
+	markField(r)
 	var value float32
 	err := binary.Read(r, binary.LittleEndian, &value)
 	return value, err
 }
 
+// readString reads a length-prefixed string. The length is checked against
+// the bytes left when the reader knows them, so a corrupt prefix cannot
+// force a large allocation.
 func readString(r io.Reader) (string, error) {
+	markField(r)
 	var length uint16
 	if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
 		return "", err
 	}
 
+	if left, ok := remaining(r); ok && int(length) > left {
+		return "", fmt.Errorf("%w: string of %d bytes, %d left", errors.ErrLengthOutOfRange, length, left)
+	}
+
 	buffer := make([]byte, length)
-	if err := binary.Read(r, binary.LittleEndian, &buffer); err != nil {
+	if _, err := io.ReadFull(r, buffer); err != nil {
 		return "", err
 	}
 
@@ -77,7 +115,7 @@ func readLap(r io.Reader) (LapInfo, error) {
 
 	lapTimeMs, err := readInt32(r)
 	if err != nil {
-		return lap, err
+		return lap, fmt.Errorf("failed to read lapTime: %w", err)
 	}
 
 	if lapTimeMs == InvalidLapTime {
@@ -88,27 +126,35 @@ func readLap(r io.Reader) (LapInfo, error) {
 
 	carIndex, err := readUint16(r)
 	if err != nil {
-		return lap, err
+		return lap, fmt.Errorf("failed to read carIndex: %w", err)
 	}
 	lap.CarIndex = carIndex
 
 	driverIndex, err := readUint16(r)
 	if err != nil {
-		return lap, err
+		return lap, fmt.Errorf("failed to read driverIndex: %w", err)
 	}
 	lap.DriverIndex = driverIndex
 
 	splitCount, err := readUint8(r)
 	if err != nil {
-		return lap, err
+		return lap, fmt.Errorf("failed to read splitCount: %w", err)
+	}
+
+	if left, ok := remaining(r); ok && int(splitCount)*minSplitSize > left {
+		return lap, fmt.Errorf("%w: %d splits, %d bytes left", errors.ErrLengthOutOfRange, splitCount, left)
 	}
 
-	for i := uint8(0); i < splitCount && i < 3; i++ {
+	// All splits are read to stay aligned, only the first three are kept
+	for i := uint8(0); i < splitCount; i++ {
 		splitTime, err := readInt32(r)
 		if err != nil {
-			return lap, err
+			return lap, fmt.Errorf("failed to read split %d: %w", i, err)
 		}
 
+		if int(i) >= len(lap.Splits) {
+			continue
+		}
 		if splitTime == InvalidSectorTime {
 			lap.Splits[i] = nil
 		} else {
@@ -118,24 +164,24 @@ func readLap(r io.Reader) (LapInfo, error) {
 
 	isInvalid, err := readUint8(r)
 	if err != nil {
-		return lap, err
+		return lap, fmt.Errorf("failed to read isInvalid: %w", err)
 	}
 	lap.IsInvalid = isInvalid > 0
 
 	isValidForBest, err := readUint8(r)
 	if err != nil {
-		return lap, err
+		return lap, fmt.Errorf("failed to read isValidForBest: %w", err)
 	}
 	lap.IsValidForBest = isValidForBest > 0
 
 	isOutlap, err := readUint8(r)
 	if err != nil {
-		return lap, err
+		return lap, fmt.Errorf("failed to read isOutlap: %w", err)
 	}
 
 	isInlap, err := readUint8(r)
 	if err != nil {
-		return lap, err
+		return lap, fmt.Errorf("failed to read isInlap: %w", err)
 	}
 
 	if isOutlap > 0 {
@@ -149,6 +195,46 @@ func readLap(r io.Reader) (LapInfo, error) {
 	return lap, nil
 }
 
+func writeLap(w io.Writer, lap LapInfo) error {
+	lapTimeMs := InvalidLapTime
+	if lap.LaptimeMS != nil {
+		lapTimeMs = *lap.LaptimeMS
+	}
+	if err := writeInt32(w, lapTimeMs); err != nil {
+		return err
+	}
+	if err := writeUint16(w, lap.CarIndex); err != nil {
+		return err
+	}
+	if err := writeUint16(w, lap.DriverIndex); err != nil {
+		return err
+	}
+
+	if err := writeUint8(w, uint8(len(lap.Splits))); err != nil {
+		return err
+	}
+	for _, split := range lap.Splits {
+		splitTime := InvalidSectorTime
+		if split != nil {
+			splitTime = *split
+		}
+		if err := writeInt32(w, splitTime); err != nil {
+			return err
+		}
+	}
+
+	if err := writeBool(w, lap.IsInvalid); err != nil {
+		return err
+	}
+	if err := writeBool(w, lap.IsValidForBest); err != nil {
+		return err
+	}
+	if err := writeBool(w, lap.Type == LapTypeOutlap); err != nil {
+		return err
+	}
+	return writeBool(w, lap.Type == LapTypeInlap)
+}
+
 func MarshalRegistrationRequest(displayName, connectionPassword string, msRealtimeUpdateInterval int32, commandPassword string) ([]byte, error) {
 	buffer := GetBuffer()
 	defer PutBuffer(buffer)
@@ -319,3 +405,353 @@ func MarshalHUDPageRequest(connectionId int32, hudPage string) ([]byte, error) {
 	copy(result, buffer.Bytes())
 	return result, nil
 }
+
+func MarshalSaveManualReplayHighlightRequest(connectionId int32) ([]byte, error) {
+	buffer := GetBuffer()
+	defer PutBuffer(buffer)
+
+	if err := writeUint8(buffer, byte(OutboundSaveManualReplayHighlight)); err != nil {
+		return nil, NewError("MarshalSaveManualReplayHighlightRequest", err)
+	}
+	if err := writeInt32(buffer, connectionId); err != nil {
+		return nil, NewError("MarshalSaveManualReplayHighlightRequest", err)
+	}
+
+	result := make([]byte, buffer.Len())
+	copy(result, buffer.Bytes())
+	return result, nil
+}
+
+// Inbound messages, as sent by ACC. Used by the mock server and by tooling
+// that needs to produce broadcast traffic.
+
+func MarshalRegistrationResult(state ConnectionState) ([]byte, error) {
+	buffer := GetBuffer()
+	defer PutBuffer(buffer)
+
+	if err := writeUint8(buffer, byte(InboundRegistrationResult)); err != nil {
+		return nil, NewError("MarshalRegistrationResult", err)
+	}
+	if err := writeInt32(buffer, state.ConnectionId); err != nil {
+		return nil, NewError("MarshalRegistrationResult", err)
+	}
+	if err := writeBool(buffer, state.Success); err != nil {
+		return nil, NewError("MarshalRegistrationResult", err)
+	}
+	// ACC sends 0 for read-only connections
+	if err := writeBool(buffer, !state.IsReadonly); err != nil {
+		return nil, NewError("MarshalRegistrationResult", err)
+	}
+	if err := writeString(buffer, state.ErrorMsg); err != nil {
+		return nil, NewError("MarshalRegistrationResult", err)
+	}
+
+	result := make([]byte, buffer.Len())
+	copy(result, buffer.Bytes())
+	return result, nil
+}
+
+func MarshalRealtimeUpdate(update RealtimeUpdate) ([]byte, error) {
+	buffer := GetBuffer()
+	defer PutBuffer(buffer)
+
+	if err := writeUint8(buffer, byte(InboundRealtimeUpdate)); err != nil {
+		return nil, NewError("MarshalRealtimeUpdate", err)
+	}
+	if err := writeUint16(buffer, update.EventIndex); err != nil {
+		return nil, NewError("MarshalRealtimeUpdate", err)
+	}
+	if err := writeUint16(buffer, update.SessionIndex); err != nil {
+		return nil, NewError("MarshalRealtimeUpdate", err)
+	}
+	if err := writeUint8(buffer, byte(update.SessionType)); err != nil {
+		return nil, NewError("MarshalRealtimeUpdate", err)
+	}
+	if err := writeUint8(buffer, byte(update.Phase)); err != nil {
+		return nil, NewError("MarshalRealtimeUpdate", err)
+	}
+	if err := writeMilliseconds(buffer, update.SessionTime); err != nil {
+		return nil, NewError("MarshalRealtimeUpdate", err)
+	}
+	if err := writeMilliseconds(buffer, update.SessionEndTime); err != nil {
+		return nil, NewError("MarshalRealtimeUpdate", err)
+	}
+	if err := writeInt32(buffer, update.FocusedCarIndex); err != nil {
+		return nil, NewError("MarshalRealtimeUpdate", err)
+	}
+	if err := writeString(buffer, update.ActiveCameraSet); err != nil {
+		return nil, NewError("MarshalRealtimeUpdate", err)
+	}
+	if err := writeString(buffer, update.ActiveCamera); err != nil {
+		return nil, NewError("MarshalRealtimeUpdate", err)
+	}
+	if err := writeString(buffer, update.CurrentHudPage); err != nil {
+		return nil, NewError("MarshalRealtimeUpdate", err)
+	}
+	if err := writeBool(buffer, update.IsReplayPlaying); err != nil {
+		return nil, NewError("MarshalRealtimeUpdate", err)
+	}
+	if update.IsReplayPlaying {
+		if err := writeFloat32(buffer, update.ReplaySessionTime); err != nil {
+			return nil, NewError("MarshalRealtimeUpdate", err)
+		}
+		if err := writeFloat32(buffer, update.ReplayRemainingTime); err != nil {
+			return nil, NewError("MarshalRealtimeUpdate", err)
+		}
+	}
+	if err := writeMilliseconds(buffer, update.TimeOfDay); err != nil {
+		return nil, NewError("MarshalRealtimeUpdate", err)
+	}
+	if err := writeUint8(buffer, update.AmbientTemp); err != nil {
+		return nil, NewError("MarshalRealtimeUpdate", err)
+	}
+	if err := writeUint8(buffer, update.TrackTemp); err != nil {
+		return nil, NewError("MarshalRealtimeUpdate", err)
+	}
+	if err := writeUint8(buffer, uint8(update.Clouds*10)); err != nil {
+		return nil, NewError("MarshalRealtimeUpdate", err)
+	}
+	if err := writeUint8(buffer, uint8(update.RainLevel*10)); err != nil {
+		return nil, NewError("MarshalRealtimeUpdate", err)
+	}
+	if err := writeUint8(buffer, uint8(update.Wetness*10)); err != nil {
+		return nil, NewError("MarshalRealtimeUpdate", err)
+	}
+	if err := writeLap(buffer, update.BestSessionLap); err != nil {
+		return nil, NewError("MarshalRealtimeUpdate", err)
+	}
+
+	result := make([]byte, buffer.Len())
+	copy(result, buffer.Bytes())
+	return result, nil
+}
+
+func MarshalRealtimeCarUpdate(update RealtimeCarUpdate) ([]byte, error) {
+	buffer := GetBuffer()
+	defer PutBuffer(buffer)
+
+	if err := writeUint8(buffer, byte(InboundRealtimeCarUpdate)); err != nil {
+		return nil, NewError("MarshalRealtimeCarUpdate", err)
+	}
+	if err := writeUint16(buffer, update.CarIndex); err != nil {
+		return nil, NewError("MarshalRealtimeCarUpdate", err)
+	}
+	if err := writeUint16(buffer, update.DriverIndex); err != nil {
+		return nil, NewError("MarshalRealtimeCarUpdate", err)
+	}
+	if err := writeUint8(buffer, update.DriverCount); err != nil {
+		return nil, NewError("MarshalRealtimeCarUpdate", err)
+	}
+	// Gears are sent with an offset of 2 (R = 1, N = 2)
+	if err := writeUint8(buffer, uint8(update.Gear+2)); err != nil {
+		return nil, NewError("MarshalRealtimeCarUpdate", err)
+	}
+	if err := writeFloat32(buffer, update.WorldPosX); err != nil {
+		return nil, NewError("MarshalRealtimeCarUpdate", err)
+	}
+	if err := writeFloat32(buffer, update.WorldPosY); err != nil {
+		return nil, NewError("MarshalRealtimeCarUpdate", err)
+	}
+	if err := writeFloat32(buffer, update.Heading); err != nil {
+		return nil, NewError("MarshalRealtimeCarUpdate", err)
+	}
+	if err := writeUint8(buffer, byte(update.CarLocation)); err != nil {
+		return nil, NewError("MarshalRealtimeCarUpdate", err)
+	}
+	if err := writeUint16(buffer, update.Kmh); err != nil {
+		return nil, NewError("MarshalRealtimeCarUpdate", err)
+	}
+	if err := writeUint16(buffer, update.Position); err != nil {
+		return nil, NewError("MarshalRealtimeCarUpdate", err)
+	}
+	if err := writeUint16(buffer, update.CupPosition); err != nil {
+		return nil, NewError("MarshalRealtimeCarUpdate", err)
+	}
+	if err := writeUint16(buffer, update.TrackPosition); err != nil {
+		return nil, NewError("MarshalRealtimeCarUpdate", err)
+	}
+	if err := writeFloat32(buffer, update.SplinePosition); err != nil {
+		return nil, NewError("MarshalRealtimeCarUpdate", err)
+	}
+	if err := writeUint16(buffer, update.Laps); err != nil {
+		return nil, NewError("MarshalRealtimeCarUpdate", err)
+	}
+	if err := writeInt32(buffer, update.Delta); err != nil {
+		return nil, NewError("MarshalRealtimeCarUpdate", err)
+	}
+	if err := writeLap(buffer, update.BestSessionLap); err != nil {
+		return nil, NewError("MarshalRealtimeCarUpdate", err)
+	}
+	if err := writeLap(buffer, update.LastLap); err != nil {
+		return nil, NewError("MarshalRealtimeCarUpdate", err)
+	}
+	if err := writeLap(buffer, update.CurrentLap); err != nil {
+		return nil, NewError("MarshalRealtimeCarUpdate", err)
+	}
+
+	result := make([]byte, buffer.Len())
+	copy(result, buffer.Bytes())
+	return result, nil
+}
+
+func MarshalEntryList(connectionId int32, carIndexes []uint16) ([]byte, error) {
+	buffer := GetBuffer()
+	defer PutBuffer(buffer)
+
+	if err := writeUint8(buffer, byte(InboundEntryList)); err != nil {
+		return nil, NewError("MarshalEntryList", err)
+	}
+	if err := writeInt32(buffer, connectionId); err != nil {
+		return nil, NewError("MarshalEntryList", err)
+	}
+	if err := writeUint16(buffer, uint16(len(carIndexes))); err != nil {
+		return nil, NewError("MarshalEntryList", err)
+	}
+	for _, carIndex := range carIndexes {
+		if err := writeUint16(buffer, carIndex); err != nil {
+			return nil, NewError("MarshalEntryList", err)
+		}
+	}
+
+	result := make([]byte, buffer.Len())
+	copy(result, buffer.Bytes())
+	return result, nil
+}
+
+func MarshalEntryListCar(car CarInfo) ([]byte, error) {
+	buffer := GetBuffer()
+	defer PutBuffer(buffer)
+
+	if err := writeUint8(buffer, byte(InboundEntryListCar)); err != nil {
+		return nil, NewError("MarshalEntryListCar", err)
+	}
+	if err := writeUint16(buffer, car.CarIndex); err != nil {
+		return nil, NewError("MarshalEntryListCar", err)
+	}
+	if err := writeUint8(buffer, car.CarModelType); err != nil {
+		return nil, NewError("MarshalEntryListCar", err)
+	}
+	if err := writeString(buffer, car.TeamName); err != nil {
+		return nil, NewError("MarshalEntryListCar", err)
+	}
+	if err := writeInt32(buffer, car.RaceNumber); err != nil {
+		return nil, NewError("MarshalEntryListCar", err)
+	}
+	if err := writeUint8(buffer, car.CupCategory); err != nil {
+		return nil, NewError("MarshalEntryListCar", err)
+	}
+	if err := writeUint8(buffer, car.CurrentDriverIndex); err != nil {
+		return nil, NewError("MarshalEntryListCar", err)
+	}
+	if err := writeUint16(buffer, uint16(car.Nationality)); err != nil {
+		return nil, NewError("MarshalEntryListCar", err)
+	}
+	if err := writeUint8(buffer, uint8(len(car.Drivers))); err != nil {
+		return nil, NewError("MarshalEntryListCar", err)
+	}
+	for _, driver := range car.Drivers {
+		if err := writeString(buffer, driver.FirstName); err != nil {
+			return nil, NewError("MarshalEntryListCar", err)
+		}
+		if err := writeString(buffer, driver.LastName); err != nil {
+			return nil, NewError("MarshalEntryListCar", err)
+		}
+		if err := writeString(buffer, driver.ShortName); err != nil {
+			return nil, NewError("MarshalEntryListCar", err)
+		}
+		if err := writeUint8(buffer, byte(driver.Category)); err != nil {
+			return nil, NewError("MarshalEntryListCar", err)
+		}
+		if err := writeUint16(buffer, uint16(driver.Nationality)); err != nil {
+			return nil, NewError("MarshalEntryListCar", err)
+		}
+	}
+
+	result := make([]byte, buffer.Len())
+	copy(result, buffer.Bytes())
+	return result, nil
+}
+
+// MarshalTrackData writes the camera sets sorted by name so the output is stable
+func MarshalTrackData(connectionId int32, trackData TrackData) ([]byte, error) {
+	buffer := GetBuffer()
+	defer PutBuffer(buffer)
+
+	if err := writeUint8(buffer, byte(InboundTrackData)); err != nil {
+		return nil, NewError("MarshalTrackData", err)
+	}
+	if err := writeInt32(buffer, connectionId); err != nil {
+		return nil, NewError("MarshalTrackData", err)
+	}
+	if err := writeString(buffer, trackData.TrackName); err != nil {
+		return nil, NewError("MarshalTrackData", err)
+	}
+	if err := writeInt32(buffer, trackData.TrackId); err != nil {
+		return nil, NewError("MarshalTrackData", err)
+	}
+	if err := writeInt32(buffer, trackData.TrackMeters); err != nil {
+		return nil, NewError("MarshalTrackData", err)
+	}
+
+	setNames := make([]string, 0, len(trackData.CameraSets))
+	for name := range trackData.CameraSets {
+		setNames = append(setNames, name)
+	}
+	sort.Strings(setNames)
+
+	if err := writeUint8(buffer, uint8(len(setNames))); err != nil {
+		return nil, NewError("MarshalTrackData", err)
+	}
+	for _, name := range setNames {
+		if err := writeString(buffer, name); err != nil {
+			return nil, NewError("MarshalTrackData", err)
+		}
+		cameras := trackData.CameraSets[name]
+		if err := writeUint8(buffer, uint8(len(cameras))); err != nil {
+			return nil, NewError("MarshalTrackData", err)
+		}
+		for _, camera := range cameras {
+			if err := writeString(buffer, camera); err != nil {
+				return nil, NewError("MarshalTrackData", err)
+			}
+		}
+	}
+
+	if err := writeUint8(buffer, uint8(len(trackData.HUDPages))); err != nil {
+		return nil, NewError("MarshalTrackData", err)
+	}
+	for _, hudPage := range trackData.HUDPages {
+		if err := writeString(buffer, hudPage); err != nil {
+			return nil, NewError("MarshalTrackData", err)
+		}
+	}
+
+	result := make([]byte, buffer.Len())
+	copy(result, buffer.Bytes())
+	return result, nil
+}
+
+func MarshalBroadcastingEvent(event BroadcastingEvent) ([]byte, error) {
+	buffer := GetBuffer()
+	defer PutBuffer(buffer)
+
+	if err := writeUint8(buffer, byte(InboundBroadcastingEvent)); err != nil {
+		return nil, NewError("MarshalBroadcastingEvent", err)
+	}
+	if err := writeUint8(buffer, byte(event.Type)); err != nil {
+		return nil, NewError("MarshalBroadcastingEvent", err)
+	}
+	if err := writeString(buffer, event.Msg); err != nil {
+		return nil, NewError("MarshalBroadcastingEvent", err)
+	}
+	if err := writeInt32(buffer, event.TimeMs); err != nil {
+		return nil, NewError("MarshalBroadcastingEvent", err)
+	}
+	if err := writeInt32(buffer, event.CarId); err != nil {
+		return nil, NewError("MarshalBroadcastingEvent", err)
+	}
+
+	result := make([]byte, buffer.Len())
+	copy(result, buffer.Bytes())
+	return result, nil
+}
@@ -0,0 +1,684 @@
+package broadcast
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"RaceAll/internal/errors"
+)
+
+const (
+	// MockServerAddress is the default address of the mock server, a free local port
+	MockServerAddress = "127.0.0.1:0"
+
+	// mockMinUpdateInterval is the fastest realtime update interval served
+	mockMinUpdateInterval = 10 * time.Millisecond
+)
+
+// MockCar is a scripted car of the mock server. The car drives laps of
+// constant length LapTime around a circle with the length of the track.
+type MockCar struct {
+	// Info is the entry list data of the car
+	Info CarInfo
+
+	// LapTime is the time the car takes to complete a lap
+	LapTime time.Duration
+
+	// StartSpline is the spline position of the car when the server starts
+	StartSpline float32
+
+	// Location is the location reported for the car
+	Location CarLocationEnum
+}
+
+// MockServerConfig configures the mock ACC broadcasting server
+type MockServerConfig struct {
+	// Address to listen on, MockServerAddress picks a free port
+	Address string
+
+	// ConnectionPassword must be sent by clients to register
+	ConnectionPassword string
+
+	// CommandPassword grants command access. Clients that don't send it,
+	// or every client when it is empty, are registered as read-only.
+	CommandPassword string
+
+	// Track is the track data served to clients
+	Track TrackData
+
+	// Cars is the scripted field
+	Cars []MockCar
+
+	// Session is the initial session state. SessionTime is advanced by the server.
+	Session RealtimeUpdate
+}
+
+// MockClient describes a client registered in the mock server
+type MockClient struct {
+	ConnectionId     int32
+	DisplayName      string
+	UpdateIntervalMS int32
+	IsReadonly       bool
+	Address          string
+}
+
+// MockCommand is a command received by the mock server
+type MockCommand struct {
+	// ConnectionId is the connection that sent the command
+	ConnectionId int32
+
+	// Type is the outbound message type of the command
+	Type OutboundMessageType
+
+	// Request is the decoded command (FocusRequest, InstantReplayRequest or
+	// HUDPageRequest), nil for SaveManualReplayHighlight
+	Request interface{}
+
+	// Applied is false when the command was ignored because the client is read-only
+	Applied bool
+
+	// ReceivedAt is the time the command arrived
+	ReceivedAt time.Time
+}
+
+// MockServer emulates the ACC broadcasting endpoint on a local UDP port, so
+// Client, Protocol and ConnectionManager can be exercised without the game
+type MockServer struct {
+	config  MockServerConfig
+	conn    *net.UDPConn
+	started time.Time
+
+	clients          map[string]*mockClient
+	nextConnectionId int32
+	session          RealtimeUpdate
+	commands         []MockCommand
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+}
+
+type mockClient struct {
+	info   MockClient
+	addr   *net.UDPAddr
+	cancel context.CancelFunc
+}
+
+// DefaultMockServerConfig returns a race at Monza with three cars, using the
+// same passwords as DefaultConfig
+func DefaultMockServerConfig() MockServerConfig {
+	return MockServerConfig{
+		Address:            MockServerAddress,
+		ConnectionPassword: "asd",
+		CommandPassword:    "cmd",
+		Track: TrackData{
+			TrackName:   "Monza Circuit",
+			TrackId:     1,
+			TrackMeters: 5793,
+			CameraSets: map[string][]string{
+				"set1":      {"CameraPit1", "Camera1", "Camera2"},
+				"Onboard":   {"Onboard0", "Onboard1", "Onboard2"},
+				"Helicam":   {"Helicam"},
+				"pitlane":   {"CameraPit1"},
+				"Drivable":  {"DashPro", "Bonnet", "Chase"},
+				"setVR":     {"CameraVR1"},
+				"Broadcast": {"Broadcast1"},
+			},
+			HUDPages: []string{"Blank", "Basic HUD", "Help", "TimeTable", "Broadcasting", "TrackMap"},
+		},
+		Cars: []MockCar{
+			{
+				Info: CarInfo{
+					CarIndex:     1,
+					CarModelType: 25,
+					TeamName:     "Mercedes-AMG Team",
+					RaceNumber:   88,
+					CupCategory:  0,
+					Nationality:  NationalityGermany,
+					Drivers: []DriverInfo{
+						{FirstName: "Raffaele", LastName: "Marciello", ShortName: "MAR", Category: DriverCategoryPlatinum, Nationality: NationalityItaly},
+						{FirstName: "Jules", LastName: "Gounon", ShortName: "GOU", Category: DriverCategoryPlatinum, Nationality: NationalityFrance},
+					},
+				},
+				LapTime:     107 * time.Second,
+				StartSpline: 0.30,
+				Location:    CarLocationTrack,
+			},
+			{
+				Info: CarInfo{
+					CarIndex:     2,
+					CarModelType: 24,
+					TeamName:     "AF Corse",
+					RaceNumber:   51,
+					CupCategory:  0,
+					Nationality:  NationalityItaly,
+					Drivers: []DriverInfo{
+						{FirstName: "Alessandro", LastName: "Pier Guidi", ShortName: "PIE", Category: DriverCategoryPlatinum, Nationality: NationalityItaly},
+					},
+				},
+				LapTime:     108 * time.Second,
+				StartSpline: 0.25,
+				Location:    CarLocationTrack,
+			},
+			{
+				Info: CarInfo{
+					CarIndex:     3,
+					CarModelType: 31,
+					TeamName:     "Team WRT",
+					RaceNumber:   32,
+					CupCategory:  1,
+					Nationality:  NationalityBelgium,
+					Drivers: []DriverInfo{
+						{FirstName: "Dries", LastName: "Vanthoor", ShortName: "VAN", Category: DriverCategoryGold, Nationality: NationalityBelgium},
+					},
+				},
+				LapTime:     109 * time.Second,
+				StartSpline: 0.20,
+				Location:    CarLocationTrack,
+			},
+		},
+		Session: RealtimeUpdate{
+			SessionType:     RaceSessionTypeRace,
+			Phase:           SessionPhaseSession,
+			SessionEndTime:  time.Hour,
+			FocusedCarIndex: 1,
+			ActiveCameraSet: "set1",
+			ActiveCamera:    "Camera1",
+			CurrentHudPage:  "Basic HUD",
+			TimeOfDay:       14 * time.Hour,
+			AmbientTemp:     24,
+			TrackTemp:       31,
+		},
+	}
+}
+
+// NewMockServer creates a mock server. Call Start to begin serving.
+func NewMockServer(config MockServerConfig) *MockServer {
+	if config.Address == "" {
+		config.Address = MockServerAddress
+	}
+
+	return &MockServer{
+		config:           config,
+		clients:          make(map[string]*mockClient),
+		nextConnectionId: 1,
+		session:          config.Session,
+	}
+}
+
+// Start opens the UDP socket and starts serving
+func (s *MockServer) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn != nil {
+		return NewError("Start", errors.ErrAlreadyConnected)
+	}
+
+	laddr, err := net.ResolveUDPAddr("udp", s.config.Address)
+	if err != nil {
+		return NewError("Start", fmt.Errorf("failed to resolve address: %w", err))
+	}
+
+	s.conn, err = net.ListenUDP("udp", laddr)
+	if err != nil {
+		return NewError("Start", fmt.Errorf("failed to listen: %w", err))
+	}
+
+	s.started = time.Now()
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+
+	s.wg.Add(1)
+	go s.readLoop(s.conn)
+
+	return nil
+}
+
+// Stop closes the socket and stops every client stream
+func (s *MockServer) Stop() {
+	s.mu.Lock()
+	if s.conn == nil {
+		s.mu.Unlock()
+		return
+	}
+
+	s.cancel()
+	s.conn.Close()
+	s.conn = nil
+	for key, client := range s.clients {
+		client.cancel()
+		delete(s.clients, key)
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// Addr returns the address the server listens on, in host:port form
+func (s *MockServer) Addr() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return ""
+	}
+	return s.conn.LocalAddr().String()
+}
+
+// Port returns the port the server listens on
+func (s *MockServer) Port() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		return 0
+	}
+	return s.conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+// Clients returns the registered clients ordered by connection id
+func (s *MockServer) Clients() []MockClient {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	clients := make([]MockClient, 0, len(s.clients))
+	for _, client := range s.clients {
+		clients = append(clients, client.info)
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i].ConnectionId < clients[j].ConnectionId })
+	return clients
+}
+
+// Commands returns the commands received so far
+func (s *MockServer) Commands() []MockCommand {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	commands := make([]MockCommand, len(s.commands))
+	copy(commands, s.commands)
+	return commands
+}
+
+// Session returns the current session state, including the effect of the
+// commands applied so far
+func (s *MockServer) Session() RealtimeUpdate {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessionLocked(time.Now())
+}
+
+// SendEvent sends a broadcasting event to every registered client
+func (s *MockServer) SendEvent(event BroadcastingEvent) error {
+	data, err := MarshalBroadcastingEvent(event)
+	if err != nil {
+		return err
+	}
+	return s.sendAll(data)
+}
+
+func (s *MockServer) readLoop(conn *net.UDPConn) {
+	defer s.wg.Done()
+
+	buffer := make([]byte, ReadBufferSize)
+	failures := 0
+
+	for {
+		n, addr, err := conn.ReadFromUDP(buffer)
+		if err != nil {
+			failures++
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-time.After(readErrorBackoff.Backoff(failures, nil)):
+			}
+			continue
+		}
+		failures = 0
+
+		if n > 0 {
+			data := make([]byte, n)
+			copy(data, buffer[:n])
+			s.handleMessage(data, addr)
+		}
+	}
+}
+
+func (s *MockServer) handleMessage(data []byte, addr *net.UDPAddr) {
+	reader := bytes.NewReader(data[1:])
+
+	switch OutboundMessageType(data[0]) {
+	case OutboundRegisterCommandApplication:
+		request, err := UnmarshalRegistrationRequest(reader)
+		if err == nil {
+			s.register(request, addr)
+		}
+
+	case OutboundUnregisterCommandApplication:
+		if _, err := UnmarshalConnectionRequest(reader); err == nil {
+			s.unregister(addr)
+		}
+
+	case OutboundRequestEntryList:
+		connectionId, err := UnmarshalConnectionRequest(reader)
+		if err == nil && s.isRegistered(addr, connectionId) {
+			s.sendEntryList(connectionId, addr)
+		}
+
+	case OutboundRequestTrackData:
+		connectionId, err := UnmarshalConnectionRequest(reader)
+		if err == nil && s.isRegistered(addr, connectionId) {
+			if data, err := MarshalTrackData(connectionId, s.config.Track); err == nil {
+				s.send(data, addr)
+			}
+		}
+
+	case OutboundChangeFocus:
+		if request, err := UnmarshalFocusRequest(reader); err == nil {
+			s.command(addr, request.ConnectionId, OutboundChangeFocus, request, func() {
+				if request.CarIndex != nil {
+					s.session.FocusedCarIndex = int32(*request.CarIndex)
+				}
+				if request.CameraSet != nil && request.Camera != nil {
+					s.session.ActiveCameraSet = *request.CameraSet
+					s.session.ActiveCamera = *request.Camera
+				}
+			})
+		}
+
+	case OutboundInstantReplayRequest:
+		if request, err := UnmarshalInstantReplayRequest(reader); err == nil {
+			s.command(addr, request.ConnectionId, OutboundInstantReplayRequest, request, nil)
+		}
+
+	case OutboundSaveManualReplayHighlight:
+		if connectionId, err := UnmarshalConnectionRequest(reader); err == nil {
+			s.command(addr, connectionId, OutboundSaveManualReplayHighlight, nil, nil)
+		}
+
+	case OutboundChangeHUDPage:
+		if request, err := UnmarshalHUDPageRequest(reader); err == nil {
+			s.command(addr, request.ConnectionId, OutboundChangeHUDPage, request, func() {
+				s.session.CurrentHudPage = request.HUDPage
+			})
+		}
+	}
+}
+
+func (s *MockServer) register(request RegistrationRequest, addr *net.UDPAddr) {
+	state := ConnectionState{ConnectionId: -1}
+
+	switch {
+	case request.ProtocolVersion != BroadcastingProtocolVersion:
+		state.ErrorMsg = fmt.Sprintf("Protocol version mismatch, expected %d", BroadcastingProtocolVersion)
+	case request.ConnectionPassword != s.config.ConnectionPassword:
+		state.ErrorMsg = "Password incorrect"
+	default:
+		state.Success = true
+		state.IsReadonly = s.config.CommandPassword == "" || request.CommandPassword != s.config.CommandPassword
+	}
+
+	if state.Success {
+		s.mu.Lock()
+		// A client registering again from the same address replaces its old stream
+		if old, exists := s.clients[addr.String()]; exists {
+			old.cancel()
+		}
+
+		state.ConnectionId = s.nextConnectionId
+		s.nextConnectionId++
+
+		ctx, cancel := context.WithCancel(s.ctx)
+		client := &mockClient{
+			info: MockClient{
+				ConnectionId:     state.ConnectionId,
+				DisplayName:      request.DisplayName,
+				UpdateIntervalMS: request.UpdateIntervalMS,
+				IsReadonly:       state.IsReadonly,
+				Address:          addr.String(),
+			},
+			addr:   addr,
+			cancel: cancel,
+		}
+		s.clients[addr.String()] = client
+		s.mu.Unlock()
+
+		if data, err := MarshalRegistrationResult(state); err == nil {
+			s.send(data, addr)
+		}
+
+		s.wg.Add(1)
+		go s.streamLoop(ctx, client)
+		return
+	}
+
+	if data, err := MarshalRegistrationResult(state); err == nil {
+		s.send(data, addr)
+	}
+}
+
+func (s *MockServer) unregister(addr *net.UDPAddr) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if client, exists := s.clients[addr.String()]; exists {
+		client.cancel()
+		delete(s.clients, addr.String())
+	}
+}
+
+func (s *MockServer) isRegistered(addr *net.UDPAddr, connectionId int32) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	client, exists := s.clients[addr.String()]
+	return exists && client.info.ConnectionId == connectionId
+}
+
+// command records a command and applies it to the session when the client
+// is allowed to send commands
+func (s *MockServer) command(addr *net.UDPAddr, connectionId int32, messageType OutboundMessageType, request interface{}, apply func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	client, exists := s.clients[addr.String()]
+	if !exists || client.info.ConnectionId != connectionId {
+		return
+	}
+
+	applied := !client.info.IsReadonly
+	if applied && apply != nil {
+		apply()
+	}
+
+	s.commands = append(s.commands, MockCommand{
+		ConnectionId: connectionId,
+		Type:         messageType,
+		Request:      request,
+		Applied:      applied,
+		ReceivedAt:   time.Now(),
+	})
+}
+
+func (s *MockServer) sendEntryList(connectionId int32, addr *net.UDPAddr) {
+	carIndexes := make([]uint16, len(s.config.Cars))
+	for i, car := range s.config.Cars {
+		carIndexes[i] = car.Info.CarIndex
+	}
+
+	data, err := MarshalEntryList(connectionId, carIndexes)
+	if err != nil {
+		return
+	}
+	s.send(data, addr)
+
+	for _, car := range s.config.Cars {
+		if data, err := MarshalEntryListCar(car.Info); err == nil {
+			s.send(data, addr)
+		}
+	}
+}
+
+// streamLoop sends a realtime update followed by one car update per car at
+// the interval requested by the client
+func (s *MockServer) streamLoop(ctx context.Context, client *mockClient) {
+	defer s.wg.Done()
+
+	interval := time.Duration(client.info.UpdateIntervalMS) * time.Millisecond
+	if interval < mockMinUpdateInterval {
+		interval = mockMinUpdateInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.mu.Lock()
+			session := s.sessionLocked(now)
+			s.mu.Unlock()
+
+			if data, err := MarshalRealtimeUpdate(session); err == nil {
+				s.send(data, client.addr)
+			}
+
+			for _, update := range s.carUpdates(now) {
+				if data, err := MarshalRealtimeCarUpdate(update); err == nil {
+					s.send(data, client.addr)
+				}
+			}
+		}
+	}
+}
+
+func (s *MockServer) sessionLocked(now time.Time) RealtimeUpdate {
+	session := s.session
+	session.SessionTime = s.session.SessionTime + now.Sub(s.started)
+	if s.session.SessionEndTime > 0 {
+		session.SessionEndTime = s.session.SessionEndTime - now.Sub(s.started)
+		if session.SessionEndTime < 0 {
+			session.SessionEndTime = 0
+		}
+	}
+	return session
+}
+
+// carUpdates computes the state of every scripted car at the given time
+func (s *MockServer) carUpdates(now time.Time) []RealtimeCarUpdate {
+	elapsed := now.Sub(s.started)
+	trackMeters := float64(s.config.Track.TrackMeters)
+	radius := trackMeters / (2 * math.Pi)
+
+	updates := make([]RealtimeCarUpdate, len(s.config.Cars))
+	distance := make([]float64, len(s.config.Cars))
+
+	for i, car := range s.config.Cars {
+		lapTime := car.LapTime
+		if lapTime <= 0 {
+			lapTime = 2 * time.Minute
+		}
+
+		progress := float64(car.StartSpline) + elapsed.Seconds()/lapTime.Seconds()
+		laps := math.Floor(progress)
+		spline := progress - laps
+		angle := spline * 2 * math.Pi
+		distance[i] = progress
+
+		update := RealtimeCarUpdate{
+			CarIndex:       car.Info.CarIndex,
+			DriverIndex:    uint16(car.Info.CurrentDriverIndex),
+			DriverCount:    byte(len(car.Info.Drivers)),
+			Gear:           4,
+			WorldPosX:      float32(radius * math.Cos(angle)),
+			WorldPosY:      float32(radius * math.Sin(angle)),
+			Heading:        float32(angle + math.Pi/2),
+			CarLocation:    car.Location,
+			Kmh:            uint16(trackMeters / lapTime.Seconds() * 3.6),
+			SplinePosition: float32(spline),
+			Laps:           uint16(laps),
+		}
+
+		currentLapMs := int32(spline * float64(lapTime.Milliseconds()))
+		update.CurrentLap = mockLap(car.Info, currentLapMs)
+		if laps > 0 {
+			update.LastLap = mockLap(car.Info, int32(lapTime.Milliseconds()))
+			update.BestSessionLap = update.LastLap
+		} else {
+			update.LastLap = mockLap(car.Info, InvalidLapTime)
+			update.BestSessionLap = update.LastLap
+		}
+
+		updates[i] = update
+	}
+
+	// Positions by distance covered, track positions by spline
+	order := make([]int, len(updates))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return distance[order[a]] > distance[order[b]] })
+	for position, i := range order {
+		updates[i].Position = uint16(position + 1)
+		updates[i].CupPosition = uint16(position + 1)
+	}
+
+	sort.Slice(order, func(a, b int) bool { return updates[order[a]].SplinePosition > updates[order[b]].SplinePosition })
+	for position, i := range order {
+		updates[i].TrackPosition = uint16(position + 1)
+	}
+
+	return updates
+}
+
+func mockLap(car CarInfo, lapTimeMs int32) LapInfo {
+	lap := LapInfo{
+		CarIndex:       car.CarIndex,
+		DriverIndex:    uint16(car.CurrentDriverIndex),
+		IsValidForBest: true,
+		Type:           LapTypeRegular,
+	}
+	if lapTimeMs != InvalidLapTime {
+		lap.LaptimeMS = &lapTimeMs
+	}
+	return lap
+}
+
+func (s *MockServer) send(data []byte, addr *net.UDPAddr) {
+	s.mu.Lock()
+	conn := s.conn
+	s.mu.Unlock()
+
+	if conn != nil {
+		conn.WriteToUDP(data, addr)
+	}
+}
+
+func (s *MockServer) sendAll(data []byte) error {
+	s.mu.Lock()
+	conn := s.conn
+	addrs := make([]*net.UDPAddr, 0, len(s.clients))
+	for _, client := range s.clients {
+		addrs = append(addrs, client.addr)
+	}
+	s.mu.Unlock()
+
+	if conn == nil {
+		return NewError("SendEvent", errors.ErrNotConnected)
+	}
+
+	for _, addr := range addrs {
+		if _, err := conn.WriteToUDP(data, addr); err != nil {
+			return NewError("SendEvent", err)
+		}
+	}
+	return nil
+}
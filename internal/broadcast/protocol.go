@@ -2,6 +2,7 @@ package broadcast
 
 import (
 	"bytes"
+	"sort"
 	"sync"
 	"time"
 
@@ -17,8 +18,13 @@ type Protocol struct {
 
 	// Caché de entry list para evitar grandes paquetes UDP
 	entryListCars  map[uint16]*CarInfo
+	trackData      *TrackData
 	entryListMutex sync.RWMutex
 
+	// Último mensaje de cada auto, para detectar autos que se fueron
+	lastSeen   map[uint16]time.Time
+	carTimeout time.Duration
+
 	lastEntryListRequest time.Time
 
 	// Callbacks
@@ -28,6 +34,13 @@ type Protocol struct {
 	OnRealtimeUpdate         func(RealtimeUpdate)
 	OnRealtimeCarUpdate      func(RealtimeCarUpdate)
 	OnBroadcastingEvent      func(BroadcastingEvent)
+
+	// OnEntryListChange recibe los autos que entran, salen o cambian
+	OnEntryListChange func(EntryListChange)
+
+	// OnCarUpdate recibe cada car update sin asignaciones. El valor viene de
+	// un pool y solo es válido durante la llamada.
+	OnCarUpdate func(*CarUpdate)
 }
 
 func NewProtocol(connectionIdentifier string, sendFunc func([]byte) error, logger zerolog.Logger) *Protocol {
@@ -36,6 +49,8 @@ func NewProtocol(connectionIdentifier string, sendFunc func([]byte) error, logge
 		sendFunc:             sendFunc,
 		logger:               logger,
 		entryListCars:        make(map[uint16]*CarInfo),
+		lastSeen:             make(map[uint16]time.Time),
+		carTimeout:           DefaultCarTimeout,
 		lastEntryListRequest: time.Now(),
 	}
 }
@@ -45,15 +60,15 @@ func (p *Protocol) ProcessMessage(data []byte) error {
 		return nil
 	}
 
-	reader := bytes.NewReader(data)
+	messageType := InboundMessageType(data[0])
 
-	msgType, err := readUint8(reader)
-	if err != nil {
-		p.logger.Error().Err(err).Msg("Error leyendo tipo de mensaje")
-		return err
+	// Los car updates llegan por cada auto en cada intervalo: se decodifican
+	// directo del datagrama, sin reader
+	if messageType == InboundRealtimeCarUpdate {
+		return p.handleRealtimeCarUpdate(data[1:])
 	}
 
-	messageType := InboundMessageType(msgType)
+	reader := bytes.NewReader(data[1:])
 
 	switch messageType {
 	case InboundRegistrationResult:
@@ -66,12 +81,10 @@ func (p *Protocol) ProcessMessage(data []byte) error {
 		return p.handleTrackData(reader)
 	case InboundRealtimeUpdate:
 		return p.handleRealtimeUpdate(reader)
-	case InboundRealtimeCarUpdate:
-		return p.handleRealtimeCarUpdate(reader)
 	case InboundBroadcastingEvent:
 		return p.handleBroadcastingEvent(reader)
 	default:
-		p.logger.Warn().Msgf("Tipo de mensaje desconocido: %d", msgType)
+		p.logger.Warn().Msgf("Tipo de mensaje desconocido: %d", data[0])
 	}
 
 	return nil
@@ -117,13 +130,8 @@ func (p *Protocol) handleEntryList(reader *bytes.Reader) error {
 		Int("carCount", len(carIndexes)).
 		Msg("Entry list recibida")
 
-	// Limpiar el caché y preparar para recibir detalles de cada auto
-	p.entryListMutex.Lock()
-	p.entryListCars = make(map[uint16]*CarInfo)
-	for _, carIndex := range carIndexes {
-		p.entryListCars[carIndex] = &CarInfo{CarIndex: carIndex}
-	}
-	p.entryListMutex.Unlock()
+	// Conservar los autos conocidos y preparar para recibir detalles de los nuevos
+	p.notifyEntryListChanges(p.applyEntryList(carIndexes, time.Now())...)
 
 	return nil
 }
@@ -141,13 +149,14 @@ func (p *Protocol) handleEntryListCar(reader *bytes.Reader) error {
 		Int32("raceNumber", carInfo.RaceNumber).
 		Msg("Entry list car recibida")
 
-	p.entryListMutex.Lock()
-	p.entryListCars[carInfo.CarIndex] = &carInfo
-	p.entryListMutex.Unlock()
+	change, changed := p.applyEntryListCar(carInfo, time.Now())
 
 	if p.OnEntrylistUpdate != nil {
 		p.OnEntrylistUpdate(carInfo)
 	}
+	if changed {
+		p.notifyEntryListChanges(change)
+	}
 
 	return nil
 }
@@ -165,6 +174,10 @@ func (p *Protocol) handleTrackData(reader *bytes.Reader) error {
 		Int32("trackMeters", trackData.TrackMeters).
 		Msg("Track data recibida")
 
+	p.entryListMutex.Lock()
+	p.trackData = &trackData
+	p.entryListMutex.Unlock()
+
 	if p.OnTrackDataUpdate != nil {
 		p.OnTrackDataUpdate(trackData)
 	}
@@ -183,19 +196,41 @@ func (p *Protocol) handleRealtimeUpdate(reader *bytes.Reader) error {
 		p.OnRealtimeUpdate(update)
 	}
 
+	// Llega un realtime update por intervalo aunque no haya autos
+	p.notifyEntryListChanges(p.expireCars(time.Now())...)
+
 	return nil
 }
 
-func (p *Protocol) handleRealtimeCarUpdate(reader *bytes.Reader) error {
-	update, err := UnmarshalRealtimeCarUpdate(reader)
-	if err != nil {
+func (p *Protocol) handleRealtimeCarUpdate(data []byte) error {
+	update := AcquireCarUpdate()
+	defer ReleaseCarUpdate(update)
+
+	if err := DecodeRealtimeCarUpdate(data, update); err != nil {
 		p.logger.Error().Err(err).Msg("Error al deserializar realtime car update")
 		return err
 	}
 
-	p.entryListMutex.RLock()
+	now := time.Now()
+
+	p.entryListMutex.Lock()
 	carInfo, exists := p.entryListCars[update.CarIndex]
-	p.entryListMutex.RUnlock()
+	if exists {
+		p.lastSeen[update.CarIndex] = now
+	}
+
+	// En los cambios de piloto solo cambia el DriverIndex del car update
+	var swap EntryListChange
+	swapped := exists && len(carInfo.Drivers) == int(update.DriverCount) &&
+		update.DriverIndex != uint16(carInfo.CurrentDriverIndex) && int(update.DriverIndex) < len(carInfo.Drivers)
+	if swapped {
+		swap = p.swapDriverLocked(carInfo, update.DriverIndex, now)
+	}
+	p.entryListMutex.Unlock()
+
+	if swapped {
+		p.notifyEntryListChanges(swap)
+	}
 
 	if !exists || carInfo == nil || len(carInfo.Drivers) != int(update.DriverCount) {
 		if time.Since(p.lastEntryListRequest) > time.Second {
@@ -208,8 +243,11 @@ func (p *Protocol) handleRealtimeCarUpdate(reader *bytes.Reader) error {
 		return nil
 	}
 
+	if p.OnCarUpdate != nil {
+		p.OnCarUpdate(update)
+	}
 	if p.OnRealtimeCarUpdate != nil {
-		p.OnRealtimeCarUpdate(update)
+		p.OnRealtimeCarUpdate(update.RealtimeCarUpdate())
 	}
 
 	return nil
@@ -243,6 +281,31 @@ func (p *Protocol) GetCarInfo(carIndex uint16) (*CarInfo, bool) {
 	return carInfo, exists
 }
 
+// GetEntryList returns the cached entry list ordered by car index. Cars
+// whose details were not received yet only have CarIndex set.
+func (p *Protocol) GetEntryList() []CarInfo {
+	p.entryListMutex.RLock()
+	defer p.entryListMutex.RUnlock()
+
+	cars := make([]CarInfo, 0, len(p.entryListCars))
+	for _, carInfo := range p.entryListCars {
+		cars = append(cars, *carInfo)
+	}
+	sort.Slice(cars, func(i, j int) bool { return cars[i].CarIndex < cars[j].CarIndex })
+	return cars
+}
+
+// GetTrackData returns the last track data received
+func (p *Protocol) GetTrackData() (TrackData, bool) {
+	p.entryListMutex.RLock()
+	defer p.entryListMutex.RUnlock()
+
+	if p.trackData == nil {
+		return TrackData{}, false
+	}
+	return *p.trackData, true
+}
+
 func (p *Protocol) RequestConnection(displayName, connectionPassword string, msRealtimeUpdateInterval int32, commandPassword string) error {
 	data, err := MarshalRegistrationRequest(displayName, connectionPassword, msRealtimeUpdateInterval, commandPassword)
 	if err != nil {
@@ -334,3 +397,15 @@ func (p *Protocol) RequestHUDPage(hudPage string) error {
 
 	return p.sendFunc(data)
 }
+
+// SaveManualReplayHighlight asks ACC to save a replay highlight of the last
+// seconds, like the in-game key binding
+func (p *Protocol) SaveManualReplayHighlight() error {
+	data, err := MarshalSaveManualReplayHighlightRequest(p.connectionId)
+	if err != nil {
+		p.logger.Error().Err(err).Msg("Error al crear solicitud de highlight")
+		return err
+	}
+
+	return p.sendFunc(data)
+}
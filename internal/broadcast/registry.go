@@ -0,0 +1,287 @@
+package broadcast
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"RaceAll/internal/errors"
+	"RaceAll/internal/logger"
+	"RaceAll/internal/pubsub"
+)
+
+// pipelineBufferSize is the queue between a connection and its pipeline.
+// It holds a few realtime ticks of a full grid.
+const pipelineBufferSize = 256
+
+// Pipeline analyses the messages of a single connection. HandleMessage is
+// called from one goroutine per connection, in arrival order.
+type Pipeline interface {
+	HandleMessage(msg BroadcastMessage)
+}
+
+// PipelineFactory creates the pipeline of a new connection
+type PipelineFactory func(name string) Pipeline
+
+// ConnectionStatus is a snapshot of a registered connection
+type ConnectionStatus struct {
+	Name      string
+	Config    Config
+	IsRunning bool
+
+	// State is the last registration result received
+	State ConnectionState
+	// ClientState is the current state of the client connection
+	ClientState ClientState
+
+	Messages    uint64
+	LastMessage time.Time
+
+	// Dropped counts the messages evicted from the pipeline queue because
+	// the pipeline fell behind
+	Dropped uint64
+}
+
+// Connection is a named broadcast connection with its own service,
+// subscribers and pipeline
+type Connection struct {
+	name     string
+	service  *Service
+	pipeline Pipeline
+	registry *ConnectionRegistry
+	sub      *pubsub.Subscription[BroadcastMessage]
+	done     chan struct{}
+
+	state       ConnectionState
+	messages    uint64
+	lastMessage time.Time
+	mu          sync.RWMutex
+}
+
+// ConnectionRegistry holds named broadcast connections, e.g. one per ACC
+// server watched by a steward. Every message is published to the
+// registry subscribers tagged with its connection name.
+type ConnectionRegistry struct {
+	connections map[string]*Connection
+	factory     PipelineFactory
+	subscribers *pubsub.Hub[BroadcastMessage]
+	mu          sync.RWMutex
+}
+
+// NewConnectionRegistry creates an empty registry
+func NewConnectionRegistry() *ConnectionRegistry {
+	return &ConnectionRegistry{
+		connections: make(map[string]*Connection),
+		subscribers: pubsub.NewHub[BroadcastMessage](),
+	}
+}
+
+// SetPipelineFactory sets the factory used for connections added afterwards
+func (r *ConnectionRegistry) SetPipelineFactory(factory PipelineFactory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.factory = factory
+}
+
+// Add registers a connection. It is not started until Start is called on it
+// or on the registry.
+func (r *ConnectionRegistry) Add(name string, config Config) (*Connection, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.connections[name]; exists {
+		return nil, NewErrorWithContext("Add", errors.ErrConnectionExists, name)
+	}
+
+	config.Name = name
+	conn := &Connection{
+		name:     name,
+		service:  NewService(config),
+		registry: r,
+		done:     make(chan struct{}),
+	}
+	if r.factory != nil {
+		conn.pipeline = r.factory(name)
+	}
+
+	// A slow pipeline must not stall the UDP listener of the connection:
+	// its queue keeps the newest messages and counts the ones it evicts
+	conn.sub = conn.service.SubscribeWithOptions(pubsub.Options{
+		Policy:     pubsub.DropOldest,
+		BufferSize: pipelineBufferSize,
+	})
+	go conn.forward()
+
+	r.connections[name] = conn
+	return conn, nil
+}
+
+// Remove stops a connection and removes it from the registry
+func (r *ConnectionRegistry) Remove(name string) error {
+	r.mu.Lock()
+	conn, exists := r.connections[name]
+	if !exists {
+		r.mu.Unlock()
+		return NewErrorWithContext("Remove", errors.ErrConnectionUnknown, name)
+	}
+	delete(r.connections, name)
+	r.mu.Unlock()
+
+	conn.close()
+	return nil
+}
+
+// Get returns a connection by name
+func (r *ConnectionRegistry) Get(name string) (*Connection, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	conn, exists := r.connections[name]
+	return conn, exists
+}
+
+// Names returns the registered connection names in alphabetical order
+func (r *ConnectionRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.connections))
+	for name := range r.connections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Status returns a snapshot of every connection, ordered by name
+func (r *ConnectionRegistry) Status() []ConnectionStatus {
+	names := r.Names()
+	status := make([]ConnectionStatus, 0, len(names))
+
+	for _, name := range names {
+		if conn, ok := r.Get(name); ok {
+			status = append(status, conn.Status())
+		}
+	}
+	return status
+}
+
+// StartAll starts every connection. It returns the first error but still
+// tries to start the remaining connections.
+func (r *ConnectionRegistry) StartAll() error {
+	var first error
+	for _, name := range r.Names() {
+		if conn, ok := r.Get(name); ok {
+			if err := conn.Start(); err != nil && first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+// StopAll stops every connection without removing them
+func (r *ConnectionRegistry) StopAll() {
+	for _, name := range r.Names() {
+		if conn, ok := r.Get(name); ok {
+			conn.Stop()
+		}
+	}
+}
+
+// Close stops and removes every connection
+func (r *ConnectionRegistry) Close() {
+	for _, name := range r.Names() {
+		r.Remove(name)
+	}
+}
+
+// Subscribe returns a channel with the messages of every connection
+func (r *ConnectionRegistry) Subscribe() <-chan BroadcastMessage {
+	return r.subscribers.Subscribe(pubsub.DefaultOptions()).C()
+}
+
+// SubscribeWithOptions subscribes to every connection with a custom policy
+func (r *ConnectionRegistry) SubscribeWithOptions(options pubsub.Options) *pubsub.Subscription[BroadcastMessage] {
+	return r.subscribers.Subscribe(options)
+}
+
+func (r *ConnectionRegistry) Unsubscribe(ch <-chan BroadcastMessage) {
+	r.subscribers.Unsubscribe(ch)
+}
+
+// Name returns the connection name
+func (c *Connection) Name() string {
+	return c.name
+}
+
+// Service returns the broadcast service of the connection, to send commands
+// or subscribe to this connection only
+func (c *Connection) Service() *Service {
+	return c.service
+}
+
+// Pipeline returns the analysis pipeline of the connection (nil without factory)
+func (c *Connection) Pipeline() Pipeline {
+	return c.pipeline
+}
+
+func (c *Connection) Start() error {
+	return c.service.Start()
+}
+
+func (c *Connection) Stop() {
+	c.service.Stop()
+}
+
+func (c *Connection) IsRunning() bool {
+	return c.service.IsRunning()
+}
+
+// Status returns a snapshot of the connection
+func (c *Connection) Status() ConnectionStatus {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return ConnectionStatus{
+		Name:        c.name,
+		Config:      c.service.config,
+		IsRunning:   c.service.IsRunning(),
+		State:       c.state,
+		ClientState: c.service.State(),
+		Messages:    c.messages,
+		LastMessage: c.lastMessage,
+		Dropped:     c.sub.Stats().Dropped,
+	}
+}
+
+// forward feeds the pipeline and the registry subscribers until the
+// connection is removed
+func (c *Connection) forward() {
+	defer close(c.done)
+
+	for msg := range c.sub.C() {
+		c.mu.Lock()
+		c.messages++
+		c.lastMessage = time.Now()
+		if state, ok := msg.Payload.(ConnectionState); ok {
+			c.state = state
+		}
+		c.mu.Unlock()
+
+		if c.pipeline != nil {
+			c.pipeline.HandleMessage(msg)
+		}
+		c.registry.subscribers.Publish(msg)
+	}
+}
+
+func (c *Connection) close() {
+	c.service.Stop()
+	if err := c.service.StopCapture(); err != nil {
+		logger.Errorf("Failed to close capture of broadcast connection %s: %v", c.name, err)
+	}
+	c.service.Unsubscribe(c.sub.C())
+	<-c.done
+	logger.Infof("Broadcast connection %s removed", c.name)
+}
@@ -0,0 +1,616 @@
+package broadcast
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"RaceAll/internal/errors"
+	"RaceAll/internal/logger"
+	"RaceAll/internal/pubsub"
+)
+
+// DefaultRelayAddress is the default address downstream tools connect to,
+// next to the ACC broadcasting port
+const DefaultRelayAddress = "127.0.0.1:9001"
+
+// DefaultRelayClientTimeout is how long a downstream client may stay silent
+// before the relay drops it
+const DefaultRelayClientTimeout = 5 * time.Minute
+
+// RelayPolicy decides whether a command of a downstream client is forwarded
+// to ACC. It is only asked for clients registered with the relay command
+// password; commands of read-only clients are always denied.
+type RelayPolicy func(client RelayClient, command OutboundMessageType) bool
+
+// ForwardAllCommands forwards every command of non read-only clients
+func ForwardAllCommands(client RelayClient, command OutboundMessageType) bool {
+	return true
+}
+
+// DenyAllCommands keeps ACC under the control of RaceAll only
+func DenyAllCommands(client RelayClient, command OutboundMessageType) bool {
+	return false
+}
+
+// ForwardCommandsFrom forwards the commands of the clients registered with
+// one of the given display names
+func ForwardCommandsFrom(displayNames ...string) RelayPolicy {
+	allowed := make(map[string]bool, len(displayNames))
+	for _, name := range displayNames {
+		allowed[name] = true
+	}
+	return func(client RelayClient, command OutboundMessageType) bool {
+		return allowed[client.DisplayName]
+	}
+}
+
+// RelayConfig configures the relay server
+type RelayConfig struct {
+	// Address to listen on for downstream clients
+	Address string
+
+	// ConnectionPassword must be sent by downstream clients to register
+	ConnectionPassword string
+
+	// CommandPassword grants command access, like commandPassword in
+	// broadcasting.json. When empty every client is read-only.
+	CommandPassword string
+
+	// Policy filters the commands of clients with command access
+	// (nil forwards all of them)
+	Policy RelayPolicy
+
+	// ClientTimeout drops clients that sent nothing for that long, so a tool
+	// that crashed without unregistering frees its slot. Clients that only
+	// listen must send a request now and then, or they register again after
+	// their own read timeout. Zero uses DefaultRelayClientTimeout, negative
+	// disables it.
+	ClientTimeout time.Duration
+}
+
+// DefaultRelayConfig accepts read-only clients with the default password
+func DefaultRelayConfig() RelayConfig {
+	return RelayConfig{
+		Address:            DefaultRelayAddress,
+		ConnectionPassword: "asd",
+	}
+}
+
+// RelayClient describes a downstream client registered in the relay
+type RelayClient struct {
+	ConnectionId     int32
+	DisplayName      string
+	UpdateIntervalMS int32
+	IsReadonly       bool
+	Address          string
+}
+
+// RelayStats counts the traffic of the relay since it started
+type RelayStats struct {
+	Clients   int
+	Sent      uint64
+	Forwarded uint64
+	Denied    uint64
+}
+
+// Relay is a broadcasting protocol server that shares one upstream ACC
+// connection with other tools. It answers registrations itself, serves the
+// entry list and track data cached by the upstream protocol and fans out
+// realtime updates and events.
+type Relay struct {
+	upstream *Service
+	config   RelayConfig
+	conn     *net.UDPConn
+	sub      *pubsub.Subscription[BroadcastMessage]
+
+	clients          map[string]*relayClient
+	nextConnectionId int32
+	stats            RelayStats
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	mu     sync.Mutex
+}
+
+type relayClient struct {
+	info     RelayClient
+	addr     *net.UDPAddr
+	interval time.Duration
+
+	// lastCycle is when the last realtime cycle was sent; inCycle tells
+	// whether the car updates of the current cycle are sent to the client
+	lastCycle time.Time
+	inCycle   bool
+
+	// lastSeen is when the last datagram of the client arrived
+	lastSeen time.Time
+}
+
+// NewRelay creates a relay of an upstream service. Call Start to begin serving.
+func NewRelay(upstream *Service, config RelayConfig) *Relay {
+	if config.Address == "" {
+		config.Address = DefaultRelayAddress
+	}
+	if config.Policy == nil {
+		config.Policy = ForwardAllCommands
+	}
+	if config.ClientTimeout == 0 {
+		config.ClientTimeout = DefaultRelayClientTimeout
+	}
+
+	return &Relay{
+		upstream:         upstream,
+		config:           config,
+		clients:          make(map[string]*relayClient),
+		nextConnectionId: 1,
+	}
+}
+
+// Start opens the downstream socket and starts relaying the upstream messages
+func (r *Relay) Start() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conn != nil {
+		return NewError("Start", errors.ErrAlreadyConnected)
+	}
+
+	laddr, err := net.ResolveUDPAddr("udp", r.config.Address)
+	if err != nil {
+		return NewError("Start", fmt.Errorf("failed to resolve address: %w", err))
+	}
+
+	r.conn, err = net.ListenUDP("udp", laddr)
+	if err != nil {
+		return NewError("Start", fmt.Errorf("failed to listen: %w", err))
+	}
+
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+
+	// Realtime updates must reach every client in order, block briefly instead of dropping
+	r.sub = r.upstream.SubscribeWithOptions(pubsub.Options{
+		Policy:     pubsub.BlockWithTimeout,
+		BufferSize: pipelineBufferSize,
+	})
+
+	r.wg.Add(2)
+	go r.readLoop(r.conn)
+	go r.fanOut(r.sub)
+
+	if r.config.ClientTimeout > 0 {
+		r.wg.Add(1)
+		go r.expireLoop(r.ctx)
+	}
+
+	logger.Infof("Broadcast relay listening on %s", r.conn.LocalAddr())
+	return nil
+}
+
+// Stop closes the downstream socket. Registered clients are dropped, they
+// notice the relay is gone by their own read timeout.
+func (r *Relay) Stop() {
+	r.mu.Lock()
+	if r.conn == nil {
+		r.mu.Unlock()
+		return
+	}
+
+	r.cancel()
+	r.conn.Close()
+	r.conn = nil
+	r.upstream.Unsubscribe(r.sub.C())
+	r.clients = make(map[string]*relayClient)
+	r.mu.Unlock()
+
+	r.wg.Wait()
+	logger.Info("Broadcast relay stopped")
+}
+
+// Addr returns the address the relay listens on, in host:port form
+func (r *Relay) Addr() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conn == nil {
+		return ""
+	}
+	return r.conn.LocalAddr().String()
+}
+
+// Port returns the port the relay listens on
+func (r *Relay) Port() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.conn == nil {
+		return 0
+	}
+	return r.conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+// Clients returns the registered clients ordered by connection id
+func (r *Relay) Clients() []RelayClient {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	clients := make([]RelayClient, 0, len(r.clients))
+	for _, client := range r.clients {
+		clients = append(clients, client.info)
+	}
+	sort.Slice(clients, func(i, j int) bool { return clients[i].ConnectionId < clients[j].ConnectionId })
+	return clients
+}
+
+// Stats returns the traffic counters
+func (r *Relay) Stats() RelayStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stats := r.stats
+	stats.Clients = len(r.clients)
+	return stats
+}
+
+func (r *Relay) readLoop(conn *net.UDPConn) {
+	defer r.wg.Done()
+
+	buffer := make([]byte, ReadBufferSize)
+	failures := 0
+
+	for {
+		n, addr, err := conn.ReadFromUDP(buffer)
+		if err != nil {
+			if r.ctx.Err() != nil {
+				return
+			}
+
+			failures++
+			if failures == 1 {
+				logger.Warnf("Broadcast relay read error: %v", err)
+			}
+			select {
+			case <-r.ctx.Done():
+				return
+			case <-time.After(readErrorBackoff.Backoff(failures, nil)):
+			}
+			continue
+		}
+		failures = 0
+
+		if n > 0 {
+			data := make([]byte, n)
+			copy(data, buffer[:n])
+			r.touch(addr, time.Now())
+			r.handleMessage(data, addr)
+		}
+	}
+}
+
+// touch records that a registered client is alive
+func (r *Relay) touch(addr *net.UDPAddr, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if client, exists := r.clients[addr.String()]; exists {
+		client.lastSeen = now
+	}
+}
+
+// expireLoop drops the clients silent for longer than ClientTimeout
+func (r *Relay) expireLoop(ctx context.Context) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(max(r.config.ClientTimeout/4, 10*time.Millisecond))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			r.expireClients(now)
+		}
+	}
+}
+
+func (r *Relay) expireClients(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for key, client := range r.clients {
+		if now.Sub(client.lastSeen) > r.config.ClientTimeout {
+			delete(r.clients, key)
+			logger.Infof("Broadcast relay client timed out: %s (%s)", client.info.DisplayName, client.info.Address)
+		}
+	}
+}
+
+func (r *Relay) handleMessage(data []byte, addr *net.UDPAddr) {
+	reader := bytes.NewReader(data[1:])
+
+	switch OutboundMessageType(data[0]) {
+	case OutboundRegisterCommandApplication:
+		if request, err := UnmarshalRegistrationRequest(reader); err == nil {
+			r.register(request, addr)
+		}
+
+	case OutboundUnregisterCommandApplication:
+		if _, err := UnmarshalConnectionRequest(reader); err == nil {
+			r.unregister(addr)
+		}
+
+	case OutboundRequestEntryList:
+		if connectionId, err := UnmarshalConnectionRequest(reader); err == nil && r.isRegistered(addr, connectionId) {
+			r.sendEntryList(connectionId, addr)
+		}
+
+	case OutboundRequestTrackData:
+		if connectionId, err := UnmarshalConnectionRequest(reader); err == nil && r.isRegistered(addr, connectionId) {
+			// Without cached track data the client gets it when it arrives upstream
+			if trackData, ok := r.upstream.GetTrackData(); ok {
+				if data, err := MarshalTrackData(connectionId, trackData); err == nil {
+					r.send(data, addr)
+				}
+			}
+		}
+
+	case OutboundChangeFocus:
+		if request, err := UnmarshalFocusRequest(reader); err == nil {
+			r.command(addr, request.ConnectionId, OutboundChangeFocus, func() error {
+				switch {
+				case request.CarIndex != nil && request.CameraSet != nil && request.Camera != nil:
+					return r.upstream.SetFocusAndCamera(*request.CarIndex, *request.CameraSet, *request.Camera)
+				case request.CarIndex != nil:
+					return r.upstream.SetFocus(*request.CarIndex)
+				case request.CameraSet != nil && request.Camera != nil:
+					return r.upstream.SetCamera(*request.CameraSet, *request.Camera)
+				}
+				return nil
+			})
+		}
+
+	case OutboundInstantReplayRequest:
+		if request, err := UnmarshalInstantReplayRequest(reader); err == nil {
+			r.command(addr, request.ConnectionId, OutboundInstantReplayRequest, func() error {
+				return r.upstream.RequestInstantReplay(request.StartSessionTime, request.DurationMS,
+					request.InitialFocusedCarIndex, request.InitialCameraSet, request.InitialCamera)
+			})
+		}
+
+	case OutboundSaveManualReplayHighlight:
+		if connectionId, err := UnmarshalConnectionRequest(reader); err == nil {
+			r.command(addr, connectionId, OutboundSaveManualReplayHighlight, r.upstream.SaveManualReplayHighlight)
+		}
+
+	case OutboundChangeHUDPage:
+		if request, err := UnmarshalHUDPageRequest(reader); err == nil {
+			r.command(addr, request.ConnectionId, OutboundChangeHUDPage, func() error {
+				return r.upstream.RequestHUDPage(request.HUDPage)
+			})
+		}
+	}
+}
+
+// register answers a registration the same way ACC does
+func (r *Relay) register(request RegistrationRequest, addr *net.UDPAddr) {
+	state := ConnectionState{ConnectionId: -1}
+
+	switch {
+	case request.ProtocolVersion != BroadcastingProtocolVersion:
+		state.ErrorMsg = fmt.Sprintf("Protocol version mismatch, expected %d", BroadcastingProtocolVersion)
+	case request.ConnectionPassword != r.config.ConnectionPassword:
+		state.ErrorMsg = "Password incorrect"
+	default:
+		state.Success = true
+		state.IsReadonly = r.config.CommandPassword == "" || request.CommandPassword != r.config.CommandPassword
+	}
+
+	if state.Success {
+		r.mu.Lock()
+		state.ConnectionId = r.nextConnectionId
+		r.nextConnectionId++
+
+		// A client registering again from the same address replaces its old entry
+		r.clients[addr.String()] = &relayClient{
+			info: RelayClient{
+				ConnectionId:     state.ConnectionId,
+				DisplayName:      request.DisplayName,
+				UpdateIntervalMS: request.UpdateIntervalMS,
+				IsReadonly:       state.IsReadonly,
+				Address:          addr.String(),
+			},
+			addr:     addr,
+			interval: time.Duration(request.UpdateIntervalMS) * time.Millisecond,
+			lastSeen: time.Now(),
+		}
+		r.mu.Unlock()
+
+		logger.Infof("Broadcast relay client registered: %s (%s, readonly=%v)", request.DisplayName, addr, state.IsReadonly)
+	}
+
+	if data, err := MarshalRegistrationResult(state); err == nil {
+		r.send(data, addr)
+	}
+}
+
+func (r *Relay) unregister(addr *net.UDPAddr) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.clients, addr.String())
+}
+
+func (r *Relay) isRegistered(addr *net.UDPAddr, connectionId int32) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	client, exists := r.clients[addr.String()]
+	return exists && client.info.ConnectionId == connectionId
+}
+
+// command forwards a downstream command to ACC if the client may send it
+func (r *Relay) command(addr *net.UDPAddr, connectionId int32, messageType OutboundMessageType, forward func() error) {
+	r.mu.Lock()
+	client, exists := r.clients[addr.String()]
+	if !exists || client.info.ConnectionId != connectionId {
+		r.mu.Unlock()
+		return
+	}
+
+	info := client.info
+	allowed := !info.IsReadonly && r.config.Policy(info, messageType)
+	if allowed {
+		r.stats.Forwarded++
+	} else {
+		r.stats.Denied++
+	}
+	r.mu.Unlock()
+
+	if !allowed {
+		logger.Infof("Broadcast relay denied command %d from %s", messageType, info.DisplayName)
+		return
+	}
+
+	if err := forward(); err != nil {
+		logger.Errorf("Broadcast relay failed to forward command %d from %s: %v", messageType, info.DisplayName, err)
+	}
+}
+
+func (r *Relay) sendEntryList(connectionId int32, addr *net.UDPAddr) {
+	cars := r.upstream.GetEntryList()
+
+	carIndexes := make([]uint16, len(cars))
+	for i, car := range cars {
+		carIndexes[i] = car.CarIndex
+	}
+
+	data, err := MarshalEntryList(connectionId, carIndexes)
+	if err != nil {
+		return
+	}
+	r.send(data, addr)
+
+	for _, car := range cars {
+		// Cars without details yet are sent when they arrive upstream
+		if len(car.Drivers) == 0 {
+			continue
+		}
+		if data, err := MarshalEntryListCar(car); err == nil {
+			r.send(data, addr)
+		}
+	}
+}
+
+// fanOut relays the upstream messages to the downstream clients
+func (r *Relay) fanOut(sub *pubsub.Subscription[BroadcastMessage]) {
+	defer r.wg.Done()
+
+	for msg := range sub.C() {
+		switch payload := msg.Payload.(type) {
+		case TrackData:
+			// Track data carries the connection id of each client
+			for _, client := range r.snapshot() {
+				if data, err := MarshalTrackData(client.connectionId, payload); err == nil {
+					r.send(data, client.addr)
+				}
+			}
+
+		case CarInfo:
+			if data, err := MarshalEntryListCar(payload); err == nil {
+				r.sendAll(data, false)
+			}
+
+		case EntryListChange:
+			// A fresh entry list drops the car from the downstream caches
+			if payload.Type == CarLeft {
+				for _, client := range r.snapshot() {
+					r.sendEntryList(client.connectionId, client.addr)
+				}
+			}
+
+		case RealtimeUpdate:
+			r.startCycle(time.Now())
+			if data, err := MarshalRealtimeUpdate(payload); err == nil {
+				r.sendAll(data, true)
+			}
+
+		case CarUpdate:
+			if data, err := MarshalRealtimeCarUpdate(payload.RealtimeCarUpdate()); err == nil {
+				r.sendAll(data, true)
+			}
+
+		case BroadcastingEvent:
+			if data, err := MarshalBroadcastingEvent(payload); err == nil {
+				r.sendAll(data, false)
+			}
+		}
+	}
+}
+
+type relayTarget struct {
+	connectionId int32
+	addr         *net.UDPAddr
+}
+
+func (r *Relay) snapshot() []relayTarget {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	targets := make([]relayTarget, 0, len(r.clients))
+	for _, client := range r.clients {
+		targets = append(targets, relayTarget{client.info.ConnectionId, client.addr})
+	}
+	return targets
+}
+
+// startCycle decides which clients receive the realtime cycle starting now,
+// so each client gets updates no faster than the interval it asked for
+func (r *Relay) startCycle(now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, client := range r.clients {
+		client.inCycle = now.Sub(client.lastCycle) >= client.interval
+		if client.inCycle {
+			client.lastCycle = now
+		}
+	}
+}
+
+// sendAll sends a datagram to every client, or only to the clients in the
+// current realtime cycle
+func (r *Relay) sendAll(data []byte, realtime bool) {
+	r.mu.Lock()
+	addrs := make([]*net.UDPAddr, 0, len(r.clients))
+	for _, client := range r.clients {
+		if !realtime || client.inCycle {
+			addrs = append(addrs, client.addr)
+		}
+	}
+	r.mu.Unlock()
+
+	for _, addr := range addrs {
+		r.send(data, addr)
+	}
+}
+
+func (r *Relay) send(data []byte, addr *net.UDPAddr) {
+	r.mu.Lock()
+	conn := r.conn
+	r.mu.Unlock()
+
+	if conn == nil {
+		return
+	}
+
+	if _, err := conn.WriteToUDP(data, addr); err == nil {
+		r.mu.Lock()
+		r.stats.Sent++
+		r.mu.Unlock()
+	}
+}
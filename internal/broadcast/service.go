@@ -4,8 +4,12 @@ import (
 	"context"
 	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"RaceAll/internal/errors"
 	"RaceAll/internal/logger"
+	"RaceAll/internal/pubsub"
 )
 
 type Service struct {
@@ -13,25 +17,49 @@ type Service struct {
 	ctx         context.Context
 	cancel      context.CancelFunc
 	wg          sync.WaitGroup
-	isRunning   bool
+	isRunning   atomic.Bool
 	mu          sync.RWMutex
-	subscribers []chan BroadcastMessage
-	subMu       sync.RWMutex
+	subscribers *pubsub.Hub[BroadcastMessage]
+	typed       *typedHubs
+	commands    *CommandQueue
 	config      Config
+
+	// capture outlives the clients: Start attaches it to every new client
+	capture *CaptureWriter
+
+	// replay decodes the datagrams passed to ProcessMessage
+	replay *Protocol
 }
 
 type Config struct {
+	// Name identifies the connection in a ConnectionRegistry and tags its messages
+	Name            string
 	Host            string
 	Port            int
 	DisplayName     string
 	Password        string
 	CommandPassword string
 	UpdateMS        int32
+
+	// CarTimeout is how long a car may go without updates before it is
+	// reported as CarLeft; zero uses DefaultCarTimeout, negative disables it
+	CarTimeout time.Duration
+
+	// Commands controls the rate limit and retries of the command queue;
+	// fields left at zero use DefaultCommandQueueConfig
+	Commands CommandQueueConfig
+
+	// Reconnect controls the registration timeout and the backoff between
+	// attempts; fields left at zero use DefaultReconnectConfig
+	Reconnect ReconnectConfig
 }
 
 type BroadcastMessage struct {
 	Type    string
 	Payload interface{}
+
+	// Connection is the Name of the connection the message came from
+	Connection string
 }
 
 func DefaultConfig() Config {
@@ -42,6 +70,7 @@ func DefaultConfig() Config {
 		Password:        "asd",
 		CommandPassword: "",
 		UpdateMS:        100,
+		Reconnect:       DefaultReconnectConfig(),
 	}
 }
 
@@ -50,26 +79,28 @@ func NewService(config Config) *Service {
 		config.UpdateMS = 100
 	}
 
-	return &Service{
+	s := &Service{
 		config:      config,
-		subscribers: make([]chan BroadcastMessage, 0),
+		subscribers: pubsub.NewHub[BroadcastMessage](),
+		typed:       newTypedHubs(),
 	}
+	s.commands = NewCommandQueue(s, config.Commands)
+	return s
 }
 
 func (s *Service) Start() error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if s.isRunning {
+	if s.isRunning.Load() {
 		return nil
 	}
 
 	// Create client
-	address := fmt.Sprintf("%s:%d", s.config.Host, s.config.Port)
 	log := *logger.Get()
 
 	s.client = NewClient(
-		address,
+		s.address(),
 		s.config.DisplayName,
 		s.config.Password,
 		s.config.CommandPassword,
@@ -77,23 +108,40 @@ func (s *Service) Start() error {
 		log,
 	)
 
+	s.client.SetReconnectConfig(s.config.Reconnect)
+	if s.config.CarTimeout != 0 {
+		s.client.SetCarTimeout(s.config.CarTimeout)
+	}
+
+	// Attached before Run so the capture includes the registration
+	if s.capture != nil {
+		s.client.setCapture(s.capture)
+	}
+
 	// Set callbacks
 	s.setupCallbacks()
 
-	s.ctx, s.cancel = context.WithCancel(context.Background())
-	s.isRunning = true
+	ctx, cancel := context.WithCancel(context.Background())
+	s.ctx, s.cancel = ctx, cancel
+	s.isRunning.Store(true)
+	s.commands.Start()
 
-	// Connect in background
+	// Connect in background, registering again whenever the connection is lost
 	s.wg.Add(1)
 	go func() {
 		defer s.wg.Done()
 
-		err := s.client.ConnectAndListen()
+		// mu is not taken here: Stop holds it while waiting for this goroutine
+		err := s.client.Run(ctx)
 		if err != nil {
+			// Subscribers already got err as the final Lost StateChange
 			logger.Errorf("Broadcast client error: %v", err)
-			s.mu.Lock()
-			s.isRunning = false
-			s.mu.Unlock()
+
+			// The client gave up: release what Start acquired, Stop
+			// returns early once isRunning is false
+			s.commands.Stop()
+			cancel()
+			s.isRunning.Store(false)
 		}
 	}()
 
@@ -102,57 +150,105 @@ func (s *Service) Start() error {
 }
 
 func (s *Service) setupCallbacks() {
-	s.client.OnConnectionStateChanged = func(state ConnectionState) {
+	s.client.OnStateChanged = func(change StateChange) {
 		s.notifySubscribers(BroadcastMessage{
-			Type:    "ConnectionState",
-			Payload: state,
+			Type:    "StateChange",
+			Payload: change,
 		})
 	}
 
-	s.client.OnTrackDataUpdate = func(track TrackData) {
-		s.notifySubscribers(BroadcastMessage{
-			Type:    "TrackData",
-			Payload: track,
-		})
-	}
+	s.client.OnConnectionStateChanged = s.handleConnectionState
+	s.client.OnTrackDataUpdate = s.handleTrackData
+	s.client.OnEntrylistUpdate = s.handleEntryList
+	s.client.OnRealtimeUpdate = s.handleRealtimeUpdate
+	s.client.OnCarUpdate = s.handleCarUpdate
+	s.client.OnBroadcastingEvent = s.handleBroadcastingEvent
+	s.client.OnEntryListChange = s.handleEntryListChange
+}
 
-	s.client.OnEntrylistUpdate = func(car CarInfo) {
-		s.notifySubscribers(BroadcastMessage{
-			Type:    "EntryList",
-			Payload: car,
-		})
-	}
+func (s *Service) handleConnectionState(state ConnectionState) {
+	s.notifySubscribers(BroadcastMessage{
+		Type:    "ConnectionState",
+		Payload: state,
+	})
+}
 
-	s.client.OnRealtimeUpdate = func(update RealtimeUpdate) {
-		s.notifySubscribers(BroadcastMessage{
-			Type:    "RealtimeUpdate",
-			Payload: update,
-		})
-	}
+func (s *Service) handleTrackData(track TrackData) {
+	s.notifySubscribers(BroadcastMessage{
+		Type:    "TrackData",
+		Payload: track,
+	})
+}
 
-	s.client.OnRealtimeCarUpdate = func(update RealtimeCarUpdate) {
-		s.notifySubscribers(BroadcastMessage{
-			Type:    "RealtimeCarUpdate",
-			Payload: update,
-		})
-	}
+func (s *Service) handleEntryList(car CarInfo) {
+	s.notifySubscribers(BroadcastMessage{
+		Type:    "EntryList",
+		Payload: car,
+	})
+}
 
-	s.client.OnBroadcastingEvent = func(event BroadcastingEvent) {
-		s.notifySubscribers(BroadcastMessage{
-			Type:    "BroadcastingEvent",
-			Payload: event,
-		})
+func (s *Service) handleRealtimeUpdate(update RealtimeUpdate) {
+	s.commands.HandleRealtimeUpdate(update)
+	s.notifySubscribers(BroadcastMessage{
+		Type:    "RealtimeUpdate",
+		Payload: update,
+	})
+}
+
+// handleCarUpdate publishes a copy of the pooled update. CarUpdate has no
+// pointers, so the copy costs no allocations besides the Payload interface.
+func (s *Service) handleCarUpdate(update *CarUpdate) {
+	s.notifySubscribers(BroadcastMessage{
+		Type:    "RealtimeCarUpdate",
+		Payload: *update,
+	})
+}
+
+func (s *Service) handleBroadcastingEvent(event BroadcastingEvent) {
+	s.notifySubscribers(BroadcastMessage{
+		Type:    "BroadcastingEvent",
+		Payload: event,
+	})
+}
+
+func (s *Service) handleEntryListChange(change EntryListChange) {
+	s.notifySubscribers(BroadcastMessage{
+		Type:    "EntryListChange",
+		Payload: change,
+	})
+}
+
+// ProcessMessage handles an inbound datagram as if the client had received
+// it, e.g. to replay a capture to the subscribers without a connection.
+// Requests of the protocol, like the entry list, are discarded.
+func (s *Service) ProcessMessage(data []byte) error {
+	s.mu.Lock()
+	if s.replay == nil {
+		s.replay = NewProtocol("replay", func([]byte) error { return nil }, *logger.Get())
+		s.replay.OnConnectionStateChanged = s.handleConnectionState
+		s.replay.OnTrackDataUpdate = s.handleTrackData
+		s.replay.OnEntrylistUpdate = s.handleEntryList
+		s.replay.OnRealtimeUpdate = s.handleRealtimeUpdate
+		s.replay.OnCarUpdate = s.handleCarUpdate
+		s.replay.OnBroadcastingEvent = s.handleBroadcastingEvent
+		s.replay.OnEntryListChange = s.handleEntryListChange
 	}
+	replay := s.replay
+	s.mu.Unlock()
+
+	return replay.ProcessMessage(data)
 }
 
 func (s *Service) Stop() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if !s.isRunning {
+	if !s.isRunning.Load() {
 		return
 	}
 
+	s.commands.Stop()
+
 	if s.client != nil {
 		s.client.Disconnect()
 	}
@@ -160,49 +256,122 @@ func (s *Service) Stop() {
 	s.cancel()
 	s.wg.Wait()
 
-	s.isRunning = false
+	// The capture stays open for the next Start
+	if s.client != nil {
+		s.client.setCapture(nil)
+	}
+
+	s.isRunning.Store(false)
 	logger.Info("Broadcast service stopped")
 }
 
-func (s *Service) notifySubscribers(msg BroadcastMessage) {
-	s.subMu.RLock()
-	defer s.subMu.RUnlock()
-
-	for _, ch := range s.subscribers {
-		select {
-		case ch <- msg:
-		default:
-			// Skip if channel is full
-		}
+// StartCapture writes every datagram of the connection to a capture file.
+// The capture spans restarts of the service until StopCapture; started
+// before Start it also includes the registration.
+func (s *Service) StartCapture(path string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.capture != nil && !s.capture.isClosed() {
+		return NewError("StartCapture", errors.ErrCaptureActive)
+	}
+
+	capture, err := CreateCapture(path, s.address())
+	if err != nil {
+		return err
+	}
+
+	s.capture = capture
+	if s.client != nil && s.isRunning.Load() {
+		s.client.setCapture(capture)
 	}
+	logger.Infof("Capturing broadcast packets to %s", path)
+	return nil
+}
+
+// StopCapture finishes the current capture, if any
+func (s *Service) StopCapture() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.capture == nil {
+		return nil
+	}
+
+	if s.client != nil {
+		s.client.setCapture(nil)
+	}
+	err := s.capture.Close()
+	s.capture = nil
+	return err
+}
+
+// IsCapturing returns true while datagrams are being captured
+func (s *Service) IsCapturing() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.capture != nil && !s.capture.isClosed()
+}
+
+func (s *Service) address() string {
+	return fmt.Sprintf("%s:%d", s.config.Host, s.config.Port)
+}
+
+func (s *Service) notifySubscribers(msg BroadcastMessage) {
+	msg.Connection = s.config.Name
+	s.typed.publish(msg)
+	s.subscribers.Publish(msg)
 }
 
+// Subscribe returns a channel with the default policy: 10 buffered messages,
+// newer messages are dropped while it is full. Prefer the typed Subscribe*
+// methods, which filter by car, event type and session.
 func (s *Service) Subscribe() <-chan BroadcastMessage {
-	s.subMu.Lock()
-	defer s.subMu.Unlock()
+	return s.subscribers.Subscribe(pubsub.DefaultOptions()).C()
+}
 
-	ch := make(chan BroadcastMessage, 10)
-	s.subscribers = append(s.subscribers, ch)
-	return ch
+// SubscribeWithOptions subscribes with a custom backpressure policy and
+// buffer size. Pass sub.C() to Unsubscribe.
+func (s *Service) SubscribeWithOptions(options pubsub.Options) *pubsub.Subscription[BroadcastMessage] {
+	return s.subscribers.Subscribe(options)
 }
 
-func (s *Service) Unsubscribe(ch <-chan BroadcastMessage) {
-	s.subMu.Lock()
-	defer s.subMu.Unlock()
-
-	for i, sub := range s.subscribers {
-		if sub == ch {
-			s.subscribers = append(s.subscribers[:i], s.subscribers[i+1:]...)
-			close(sub)
-			break
-		}
+// SubscriptionStats returns the delivered/dropped counters of a subscriber
+func (s *Service) SubscriptionStats(ch <-chan BroadcastMessage) (pubsub.Stats, bool) {
+	sub, ok := s.subscribers.Lookup(ch)
+	if !ok {
+		return pubsub.Stats{}, false
 	}
+	return sub.Stats(), true
+}
+
+func (s *Service) Unsubscribe(ch <-chan BroadcastMessage) {
+	s.subscribers.Unsubscribe(ch)
 }
 
 func (s *Service) IsRunning() bool {
+	return s.isRunning.Load()
+}
+
+// State returns the state of the client connection (Disconnected if the
+// service was never started)
+func (s *Service) State() ClientState {
 	s.mu.RLock()
-	defer s.mu.RUnlock()
-	return s.isRunning
+	client := s.client
+	s.mu.RUnlock()
+
+	if client == nil {
+		return StateDisconnected
+	}
+	return client.State()
+}
+
+// Commands returns the command queue of the service. The SetFocus,
+// SetCamera, RequestHUDPage and RequestInstantReplay methods of the service
+// send right away; the queue rate-limits them and reports whether ACC
+// applied them.
+func (s *Service) Commands() *CommandQueue {
+	return s.commands
 }
 
 func (s *Service) SetFocus(carIndex uint16) error {
@@ -219,6 +388,13 @@ func (s *Service) SetCamera(cameraSet, camera string) error {
 	return s.client.SetCamera(cameraSet, camera)
 }
 
+func (s *Service) SetFocusAndCamera(carIndex uint16, cameraSet, camera string) error {
+	if s.client == nil {
+		return fmt.Errorf("not connected")
+	}
+	return s.client.SetFocusAndCamera(carIndex, cameraSet, camera)
+}
+
 func (s *Service) RequestInstantReplay(startTime, duration float32, initialFocusedCarIndex int32, cameraSet, camera string) error {
 	if s.client == nil {
 		return fmt.Errorf("not connected")
@@ -233,9 +409,32 @@ func (s *Service) RequestHUDPage(hudPage string) error {
 	return s.client.RequestHUDPage(hudPage)
 }
 
+func (s *Service) SaveManualReplayHighlight() error {
+	if s.client == nil {
+		return fmt.Errorf("not connected")
+	}
+	return s.client.SaveManualReplayHighlight()
+}
+
 func (s *Service) GetCarInfo(carIndex uint16) (*CarInfo, bool) {
 	if s.client == nil {
 		return nil, false
 	}
 	return s.client.GetCarInfo(carIndex)
 }
+
+// GetEntryList returns the entry list cached by the protocol
+func (s *Service) GetEntryList() []CarInfo {
+	if s.client == nil {
+		return nil
+	}
+	return s.client.GetEntryList()
+}
+
+// GetTrackData returns the track data cached by the protocol
+func (s *Service) GetTrackData() (TrackData, bool) {
+	if s.client == nil {
+		return TrackData{}, false
+	}
+	return s.client.GetTrackData()
+}
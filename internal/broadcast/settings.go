@@ -0,0 +1,226 @@
+package broadcast
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"unicode/utf16"
+
+	"RaceAll/internal/errors"
+	"RaceAll/internal/logger"
+)
+
+// BroadcastingSettingsFile is the location of broadcasting.json relative to
+// the user's home directory
+var BroadcastingSettingsFile = filepath.Join("Documents", "Assetto Corsa Competizione", "Config", "broadcasting.json")
+
+// BroadcastingSettings are the settings ACC reads from broadcasting.json.
+// ACC only reads the file at startup, so changes need a restart of the game.
+type BroadcastingSettings struct {
+	// UpdListenerPort is the port ACC listens on, 0 disables broadcasting
+	UpdListenerPort    int    `json:"updListenerPort"`
+	ConnectionPassword string `json:"connectionPassword"`
+	CommandPassword    string `json:"commandPassword"`
+
+	// fields keeps every key of the file so Save does not drop the ones
+	// RaceAll does not know about
+	fields map[string]json.RawMessage
+	// encoding is how the file was stored, Save writes it back the same way
+	encoding settingsEncoding
+}
+
+type settingsEncoding int
+
+const (
+	encodingUTF16LE settingsEncoding = iota
+	encodingUTF16LEBOM
+	encodingUTF8
+	encodingUTF8BOM
+)
+
+var (
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+)
+
+// DefaultBroadcastingSettingsPath returns the path of broadcasting.json in
+// the user's Documents folder
+func DefaultBroadcastingSettingsPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", NewError("DefaultBroadcastingSettingsPath", err)
+	}
+	return filepath.Join(home, BroadcastingSettingsFile), nil
+}
+
+// LoadBroadcastingSettings reads broadcasting.json. ACC writes it as
+// UTF-16LE; UTF-8 files, with or without BOM, are accepted as well.
+func LoadBroadcastingSettings(path string) (*BroadcastingSettings, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, NewError("LoadBroadcastingSettings", err)
+	}
+
+	text, encoding, err := decodeSettings(data)
+	if err != nil {
+		return nil, NewErrorWithContext("LoadBroadcastingSettings", err, path)
+	}
+
+	settings := &BroadcastingSettings{encoding: encoding}
+	if err := json.Unmarshal(text, &settings.fields); err != nil {
+		return nil, NewErrorWithContext("LoadBroadcastingSettings", fmt.Errorf("%w: %v", errors.ErrDecodingFailed, err), path)
+	}
+	if err := json.Unmarshal(text, settings); err != nil {
+		return nil, NewErrorWithContext("LoadBroadcastingSettings", fmt.Errorf("%w: %v", errors.ErrDecodingFailed, err), path)
+	}
+
+	return settings, nil
+}
+
+// Save writes the settings in the encoding the file was loaded with
+// (UTF-16LE for new settings), keeping the keys RaceAll does not use
+func (bs *BroadcastingSettings) Save(path string) error {
+	fields := make(map[string]json.RawMessage, len(bs.fields)+3)
+	for key, value := range bs.fields {
+		fields[key] = value
+	}
+
+	values := map[string]any{
+		"updListenerPort":    bs.UpdListenerPort,
+		"connectionPassword": bs.ConnectionPassword,
+		"commandPassword":    bs.CommandPassword,
+	}
+	for key, value := range values {
+		raw, err := json.Marshal(value)
+		if err != nil {
+			return NewError("SaveBroadcastingSettings", err)
+		}
+		fields[key] = raw
+	}
+
+	text, err := json.MarshalIndent(fields, "", "    ")
+	if err != nil {
+		return NewError("SaveBroadcastingSettings", fmt.Errorf("%w: %v", errors.ErrEncodingFailed, err))
+	}
+
+	if err := os.WriteFile(path, encodeSettings(text, bs.encoding), 0o644); err != nil {
+		return NewError("SaveBroadcastingSettings", err)
+	}
+	return nil
+}
+
+// IsEnabled returns false when ACC has broadcasting disabled (port 0)
+func (bs *BroadcastingSettings) IsEnabled() bool {
+	return bs.UpdListenerPort > 0
+}
+
+// Enable sets a listener port and a connection password when the file has
+// none, returning true if the settings changed
+func (bs *BroadcastingSettings) Enable(port int, connectionPassword string) bool {
+	changed := false
+	if bs.UpdListenerPort <= 0 && port > 0 {
+		bs.UpdListenerPort = port
+		changed = true
+	}
+	if bs.ConnectionPassword == "" && connectionPassword != "" {
+		bs.ConnectionPassword = connectionPassword
+		changed = true
+	}
+	return changed
+}
+
+// Apply copies the port and passwords into a service config. A disabled
+// port leaves the config port unchanged.
+func (bs *BroadcastingSettings) Apply(config *Config) {
+	if bs.IsEnabled() {
+		config.Port = bs.UpdListenerPort
+	}
+	config.Password = bs.ConnectionPassword
+	config.CommandPassword = bs.CommandPassword
+}
+
+// LoadConfig builds a service config from broadcasting.json (the default
+// location when path is empty). When the file disables broadcasting it logs
+// a warning and, with writeBack, enables it with the default port and
+// password; ACC must be restarted to pick the change up.
+func LoadConfig(path string, writeBack bool) (Config, error) {
+	config := DefaultConfig()
+
+	if path == "" {
+		var err error
+		if path, err = DefaultBroadcastingSettingsPath(); err != nil {
+			return config, err
+		}
+	}
+
+	settings, err := LoadBroadcastingSettings(path)
+	if err != nil {
+		return config, err
+	}
+
+	if !settings.IsEnabled() {
+		logger.Warnf("Broadcasting is disabled in %s (updListenerPort is 0)", path)
+
+		if writeBack && settings.Enable(config.Port, config.Password) {
+			if err := settings.Save(path); err != nil {
+				return config, err
+			}
+			logger.Warnf("Broadcasting enabled on port %d in %s, restart ACC to apply it", settings.UpdListenerPort, path)
+		}
+	}
+
+	settings.Apply(&config)
+	return config, nil
+}
+
+// decodeSettings converts the file contents to UTF-8 and detects how they
+// were encoded
+func decodeSettings(data []byte) ([]byte, settingsEncoding, error) {
+	switch {
+	case bytes.HasPrefix(data, bomUTF16LE):
+		text, err := decodeUTF16LE(data[len(bomUTF16LE):])
+		return text, encodingUTF16LEBOM, err
+	case bytes.HasPrefix(data, bomUTF8):
+		return data[len(bomUTF8):], encodingUTF8BOM, nil
+	case len(data) >= 2 && data[0] != 0 && data[1] == 0:
+		// ASCII JSON in UTF-16LE without BOM: every second byte is zero
+		text, err := decodeUTF16LE(data)
+		return text, encodingUTF16LE, err
+	default:
+		return data, encodingUTF8, nil
+	}
+}
+
+func decodeUTF16LE(data []byte) ([]byte, error) {
+	if len(data)%2 != 0 {
+		return nil, fmt.Errorf("%w: odd UTF-16 length %d", errors.ErrDecodingFailed, len(data))
+	}
+
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = binary.LittleEndian.Uint16(data[2*i:])
+	}
+	return []byte(string(utf16.Decode(units))), nil
+}
+
+func encodeSettings(text []byte, encoding settingsEncoding) []byte {
+	switch encoding {
+	case encodingUTF8:
+		return text
+	case encodingUTF8BOM:
+		return append(append([]byte{}, bomUTF8...), text...)
+	}
+
+	units := utf16.Encode([]rune(string(text)))
+	data := make([]byte, 0, 2*len(units)+len(bomUTF16LE))
+	if encoding == encodingUTF16LEBOM {
+		data = append(data, bomUTF16LE...)
+	}
+	for _, unit := range units {
+		data = binary.LittleEndian.AppendUint16(data, unit)
+	}
+	return data
+}
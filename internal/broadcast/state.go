@@ -0,0 +1,162 @@
+package broadcast
+
+import (
+	"math/rand"
+	"time"
+)
+
+// ClientState is the state of a broadcast client connection:
+//
+//	Disconnected -> Registering -> Connected/Readonly -> Lost -> Registering ...
+//
+// A client that is not running is Disconnected. Lost covers both a rejected
+// or timed out registration and a connection that stopped receiving data;
+// the client waits for the backoff delay and registers again.
+type ClientState int
+
+const (
+	StateDisconnected ClientState = iota
+	StateRegistering
+	StateConnected
+	StateReadonly
+	StateLost
+)
+
+func (s ClientState) String() string {
+	switch s {
+	case StateDisconnected:
+		return "Disconnected"
+	case StateRegistering:
+		return "Registering"
+	case StateConnected:
+		return "Connected"
+	case StateReadonly:
+		return "Readonly"
+	case StateLost:
+		return "Lost"
+	default:
+		return "Unknown"
+	}
+}
+
+// IsRegistered returns true for the states that receive session data
+func (s ClientState) IsRegistered() bool {
+	return s == StateConnected || s == StateReadonly
+}
+
+// StateChange is published every time the client changes state
+type StateChange struct {
+	From ClientState
+	To   ClientState
+
+	// Reason explains the transition, e.g. the ErrorMsg of a rejected
+	// registration or why the connection is readonly
+	Reason string
+	Err    error
+
+	// Attempt is the registration attempt, starting at 1 and reset after a
+	// successful registration
+	Attempt int
+
+	// RetryIn is the backoff delay before the next attempt (Lost only)
+	RetryIn time.Duration
+
+	Time time.Time
+}
+
+// ReconnectConfig controls registration timeouts and retries
+type ReconnectConfig struct {
+	// RegistrationTimeout is how long to wait for the registration result
+	RegistrationTimeout time.Duration
+
+	// The delay before attempt n is InitialBackoff * Multiplier^(n-1),
+	// capped at MaxBackoff and randomized by +/- Jitter (0..1)
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+	Jitter         float64
+
+	// MaxAttempts stops retrying after that many failed attempts in a row
+	// (0 retries until the client is stopped)
+	MaxAttempts int
+}
+
+// DefaultReconnectConfig retries forever, from 500ms up to 30s between attempts
+func DefaultReconnectConfig() ReconnectConfig {
+	return ReconnectConfig{
+		RegistrationTimeout: 3 * time.Second,
+		InitialBackoff:      500 * time.Millisecond,
+		MaxBackoff:          30 * time.Second,
+		Multiplier:          2,
+		Jitter:              0.2,
+	}
+}
+
+// readErrorBackoff spaces out UDP reads after socket errors. Errors can
+// repeat, e.g. WSAECONNRESET on Windows after sending to a peer that is gone,
+// so read loops back off instead of spinning.
+var readErrorBackoff = ReconnectConfig{InitialBackoff: 10 * time.Millisecond, MaxBackoff: time.Second}
+
+// withDefaults fills the fields left at zero
+func (rc ReconnectConfig) withDefaults() ReconnectConfig {
+	defaults := DefaultReconnectConfig()
+	if rc.RegistrationTimeout <= 0 {
+		rc.RegistrationTimeout = defaults.RegistrationTimeout
+	}
+	if rc.InitialBackoff <= 0 {
+		rc.InitialBackoff = defaults.InitialBackoff
+	}
+	if rc.MaxBackoff < rc.InitialBackoff {
+		rc.MaxBackoff = max(defaults.MaxBackoff, rc.InitialBackoff)
+	}
+	if rc.Multiplier < 1 {
+		rc.Multiplier = defaults.Multiplier
+	}
+	if rc.Jitter < 0 {
+		rc.Jitter = 0
+	}
+	if rc.Jitter > 1 {
+		rc.Jitter = 1
+	}
+	return rc
+}
+
+// Backoff returns the delay before the next attempt after the given number
+// of failed attempts. random returns values in [0, 1), nil uses math/rand.
+func (rc ReconnectConfig) Backoff(failures int, random func() float64) time.Duration {
+	rc = rc.withDefaults()
+	if failures < 1 {
+		failures = 1
+	}
+
+	delay := float64(rc.InitialBackoff)
+	for i := 1; i < failures && delay < float64(rc.MaxBackoff); i++ {
+		delay *= rc.Multiplier
+	}
+	if delay > float64(rc.MaxBackoff) {
+		delay = float64(rc.MaxBackoff)
+	}
+
+	if rc.Jitter > 0 {
+		if random == nil {
+			random = rand.Float64
+		}
+		delay *= 1 + rc.Jitter*(2*random()-1)
+	}
+	return time.Duration(delay)
+}
+
+// registrationReason describes a registration result for a StateChange
+func registrationReason(state ConnectionState) string {
+	if state.ErrorMsg != "" {
+		return state.ErrorMsg
+	}
+	switch {
+	case !state.Success:
+		return "registration rejected"
+	case state.IsReadonly:
+		return "readonly: command password missing or rejected"
+	default:
+		return ""
+	}
+}
@@ -276,3 +276,62 @@ type ConnectionState struct {
 	// ErrorMsg is the error message (if any)
 	ErrorMsg string
 }
+
+// RegistrationRequest is the registration sent by a broadcasting client
+type RegistrationRequest struct {
+	// ProtocolVersion is the broadcasting protocol version of the client
+	ProtocolVersion byte
+
+	// DisplayName is the name shown for the client in ACC
+	DisplayName string
+
+	// ConnectionPassword must match the password in broadcasting.json
+	ConnectionPassword string
+
+	// UpdateIntervalMS is the requested realtime update interval
+	UpdateIntervalMS int32
+
+	// CommandPassword must match commandPassword to send commands
+	CommandPassword string
+}
+
+// FocusRequest is a focus and/or camera change sent by a client
+type FocusRequest struct {
+	// ConnectionId is the identifier of the sending connection
+	ConnectionId int32
+
+	// CarIndex is the car to focus (nil to keep the current one)
+	CarIndex *uint16
+
+	// CameraSet and Camera are the camera to switch to (nil to keep the current one)
+	CameraSet *string
+	Camera    *string
+}
+
+// InstantReplayRequest is an instant replay request sent by a client
+type InstantReplayRequest struct {
+	// ConnectionId is the identifier of the sending connection
+	ConnectionId int32
+
+	// StartSessionTime is the session time the replay starts at, in milliseconds
+	StartSessionTime float32
+
+	// DurationMS is the length of the replay
+	DurationMS float32
+
+	// InitialFocusedCarIndex is the car focused when the replay starts (-1 to keep the current one)
+	InitialFocusedCarIndex int32
+
+	// InitialCameraSet and InitialCamera are the camera used when the replay starts
+	InitialCameraSet string
+	InitialCamera    string
+}
+
+// HUDPageRequest is a HUD page change sent by a client
+type HUDPageRequest struct {
+	// ConnectionId is the identifier of the sending connection
+	ConnectionId int32
+
+	// HUDPage is the page to show
+	HUDPage string
+}
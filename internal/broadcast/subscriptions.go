@@ -0,0 +1,168 @@
+package broadcast
+
+import (
+	"context"
+	"slices"
+	"sync/atomic"
+
+	"RaceAll/internal/pubsub"
+)
+
+// Filter selects the messages delivered to a typed subscription. Empty
+// fields match everything.
+type Filter struct {
+	// CarIndexes keeps the car updates, entry list cars and events of these cars
+	CarIndexes []uint16
+
+	// SessionTypes keeps the messages received while one of these sessions
+	// is running, as reported by the last RealtimeUpdate
+	SessionTypes []RaceSessionType
+
+	// Options is the backpressure policy of the subscription (the zero
+	// value uses pubsub.DefaultOptions)
+	Options pubsub.Options
+}
+
+func (f Filter) matchesCar(carIndex uint16) bool {
+	return len(f.CarIndexes) == 0 || slices.Contains(f.CarIndexes, carIndex)
+}
+
+func (f Filter) matchesSession(sessionType RaceSessionType, known bool) bool {
+	if len(f.SessionTypes) == 0 {
+		return true
+	}
+	return known && slices.Contains(f.SessionTypes, sessionType)
+}
+
+// typedHubs fans out each message type to its typed subscribers, so
+// consumers only get the messages they asked for instead of type-switching
+// on BroadcastMessage
+type typedHubs struct {
+	states          *pubsub.Hub[StateChange]
+	trackData       *pubsub.Hub[TrackData]
+	entryList       *pubsub.Hub[CarInfo]
+	entryChanges    *pubsub.Hub[EntryListChange]
+	realtimeUpdates *pubsub.Hub[RealtimeUpdate]
+	carUpdates      *pubsub.Hub[CarUpdate]
+	events          *pubsub.Hub[BroadcastingEvent]
+
+	// session is the type of the running session, -1 until the first
+	// RealtimeUpdate
+	session atomic.Int32
+}
+
+func newTypedHubs() *typedHubs {
+	hubs := &typedHubs{
+		states:          pubsub.NewHub[StateChange](),
+		trackData:       pubsub.NewHub[TrackData](),
+		entryList:       pubsub.NewHub[CarInfo](),
+		entryChanges:    pubsub.NewHub[EntryListChange](),
+		realtimeUpdates: pubsub.NewHub[RealtimeUpdate](),
+		carUpdates:      pubsub.NewHub[CarUpdate](),
+		events:          pubsub.NewHub[BroadcastingEvent](),
+	}
+	hubs.session.Store(-1)
+	return hubs
+}
+
+func (h *typedHubs) currentSession() (RaceSessionType, bool) {
+	session := h.session.Load()
+	if session < 0 {
+		return 0, false
+	}
+	return RaceSessionType(session), true
+}
+
+func (h *typedHubs) inSession(filter Filter) bool {
+	session, known := h.currentSession()
+	return filter.matchesSession(session, known)
+}
+
+// publish sends a message to the typed subscribers of its payload
+func (h *typedHubs) publish(msg BroadcastMessage) {
+	switch payload := msg.Payload.(type) {
+	case StateChange:
+		h.states.Publish(payload)
+	case TrackData:
+		h.trackData.Publish(payload)
+	case CarInfo:
+		h.entryList.Publish(payload)
+	case EntryListChange:
+		h.entryChanges.Publish(payload)
+	case RealtimeUpdate:
+		h.session.Store(int32(payload.SessionType))
+		h.realtimeUpdates.Publish(payload)
+	case CarUpdate:
+		h.carUpdates.Publish(payload)
+	case BroadcastingEvent:
+		h.events.Publish(payload)
+	}
+}
+
+// SubscribeStateChanges returns the client state changes until ctx is cancelled
+func (s *Service) SubscribeStateChanges(ctx context.Context, options pubsub.Options) <-chan StateChange {
+	return s.typed.states.SubscribeContext(ctx, options, nil).C()
+}
+
+// SubscribeTrackData returns the track data received until ctx is cancelled
+func (s *Service) SubscribeTrackData(ctx context.Context, options pubsub.Options) <-chan TrackData {
+	return s.typed.trackData.SubscribeContext(ctx, options, nil).C()
+}
+
+// SubscribeEntryList returns the entry list cars matching filter until ctx
+// is cancelled
+func (s *Service) SubscribeEntryList(ctx context.Context, filter Filter) <-chan CarInfo {
+	return s.typed.entryList.SubscribeContext(ctx, filter.Options, func(car CarInfo) bool {
+		return filter.matchesCar(car.CarIndex) && s.typed.inSession(filter)
+	}).C()
+}
+
+// SubscribeEntryListChanges returns the cars joining, leaving or changing
+// that match filter until ctx is cancelled
+func (s *Service) SubscribeEntryListChanges(ctx context.Context, filter Filter) <-chan EntryListChange {
+	return s.typed.entryChanges.SubscribeContext(ctx, filter.Options, func(change EntryListChange) bool {
+		return filter.matchesCar(change.CarIndex) && s.typed.inSession(filter)
+	}).C()
+}
+
+// SubscribeRealtimeUpdates returns the session updates matching filter
+// until ctx is cancelled. CarIndexes is ignored.
+func (s *Service) SubscribeRealtimeUpdates(ctx context.Context, filter Filter) <-chan RealtimeUpdate {
+	return s.typed.realtimeUpdates.SubscribeContext(ctx, filter.Options, func(update RealtimeUpdate) bool {
+		return filter.matchesSession(update.SessionType, true)
+	}).C()
+}
+
+// SubscribeRealtimeCarUpdates returns the car updates matching filter until
+// ctx is cancelled
+func (s *Service) SubscribeRealtimeCarUpdates(ctx context.Context, filter Filter) <-chan CarUpdate {
+	return s.typed.carUpdates.SubscribeContext(ctx, filter.Options, func(update CarUpdate) bool {
+		return filter.matchesCar(update.CarIndex) && s.typed.inSession(filter)
+	}).C()
+}
+
+// SubscribeEvents returns the broadcasting events of the given types (all
+// types if none) matching filter until ctx is cancelled. Session-wide
+// events (green flag, session over) are not filtered by car.
+func (s *Service) SubscribeEvents(ctx context.Context, filter Filter, types ...BroadcastingEventType) <-chan BroadcastingEvent {
+	types = slices.Clone(types)
+	return s.typed.events.SubscribeContext(ctx, filter.Options, func(event BroadcastingEvent) bool {
+		if len(types) > 0 && !slices.Contains(types, event.Type) {
+			return false
+		}
+		if isCarEvent(event.Type) && !filter.matchesCar(uint16(event.CarId)) {
+			return false
+		}
+		return s.typed.inSession(filter)
+	}).C()
+}
+
+// isCarEvent tells whether the CarId of an event type refers to a car
+func isCarEvent(eventType BroadcastingEventType) bool {
+	switch eventType {
+	case BroadcastingEventTypeNone, BroadcastingEventTypeGreenFlag, BroadcastingEventTypeSessionOver:
+		return false
+	default:
+		return true
+	}
+}
@@ -7,29 +7,31 @@ import (
 )
 
 func UnmarshalRegistrationResult(r io.Reader) (ConnectionState, error) {
+	mr := newMessageReader(r)
+
 	var state ConnectionState
 
-	connectionId, err := readInt32(r)
+	connectionId, err := readInt32(mr)
 	if err != nil {
-		return state, NewError("UnmarshalRegistrationResult", fmt.Errorf("failed to read connectionId: %w", err))
+		return state, NewError("UnmarshalRegistrationResult", mr.fail("connectionId", err))
 	}
 	state.ConnectionId = connectionId
 
-	success, err := readUint8(r)
+	success, err := readUint8(mr)
 	if err != nil {
-		return state, NewError("UnmarshalRegistrationResult", fmt.Errorf("failed to read success: %w", err))
+		return state, NewError("UnmarshalRegistrationResult", mr.fail("success", err))
 	}
 	state.Success = success > 0
 
-	isReadonly, err := readUint8(r)
+	isReadonly, err := readUint8(mr)
 	if err != nil {
-		return state, NewError("UnmarshalRegistrationResult", fmt.Errorf("failed to read isReadonly: %w", err))
+		return state, NewError("UnmarshalRegistrationResult", mr.fail("isReadonly", err))
 	}
 	state.IsReadonly = isReadonly == 0
 
-	errMsg, err := readString(r)
+	errMsg, err := readString(mr)
 	if err != nil {
-		return state, NewError("UnmarshalRegistrationResult", fmt.Errorf("failed to read errorMsg: %w", err))
+		return state, NewError("UnmarshalRegistrationResult", mr.fail("errorMsg", err))
 	}
 	state.ErrorMsg = errMsg
 
@@ -37,31 +39,32 @@ func UnmarshalRegistrationResult(r io.Reader) (ConnectionState, error) {
 }
 
 func UnmarshalEntryList(r io.Reader) (int32, []uint16, error) {
-	connectionId, err := readInt32(r)
+	mr := newMessageReader(r)
+
+	connectionId, err := readInt32(mr)
 	if err != nil {
-		return 0, nil, NewError("UnmarshalEntryList", fmt.Errorf("failed to read connectionId: %w", err))
+		return 0, nil, NewError("UnmarshalEntryList", mr.fail("connectionId", err))
 	}
 
-	carCount, err := readUint16(r)
+	carCount, err := readUint16(mr)
 	if err != nil {
-		return connectionId, nil, NewError("UnmarshalEntryList", fmt.Errorf("failed to read carCount: %w", err))
+		return connectionId, nil, NewError("UnmarshalEntryList", mr.fail("carCount", err))
 	}
 
-	if carCount > 200 {
-		return connectionId, nil, NewError("UnmarshalEntryList",
-			NewValidationError("carCount", carCount, "exceeds maximum of 200"))
+	if err := mr.checkCount("carCount", int(carCount), MaxEntryListCars, minCarIndexSize); err != nil {
+		return connectionId, nil, NewError("UnmarshalEntryList", err)
 	}
 
 	carIndexes := make([]uint16, carCount)
 	for i := uint16(0); i < carCount; i++ {
-		carIndex, err := readUint16(r)
+		carIndex, err := readUint16(mr)
 		if err != nil {
 			return connectionId, nil, NewError("UnmarshalEntryList",
-				fmt.Errorf("failed to read carIndex at position %d: %w", i, err))
+				mr.fail(fmt.Sprintf("carIndexes[%d]", i), err))
 		}
 
 		if err := ValidateCarIndex(carIndex); err != nil {
-			return connectionId, nil, NewError("UnmarshalEntryList", err)
+			return connectionId, nil, NewError("UnmarshalEntryList", mr.fail(fmt.Sprintf("carIndexes[%d]", i), err))
 		}
 
 		carIndexes[i] = carIndex
@@ -71,110 +74,111 @@ func UnmarshalEntryList(r io.Reader) (int32, []uint16, error) {
 }
 
 func UnmarshalEntryListCar(r io.Reader) (CarInfo, error) {
+	mr := newMessageReader(r)
+
 	var car CarInfo
 
-	carIndex, err := readUint16(r)
+	carIndex, err := readUint16(mr)
 	if err != nil {
-		return car, NewError("UnmarshalEntryListCar", fmt.Errorf("failed to read carIndex: %w", err))
+		return car, NewError("UnmarshalEntryListCar", mr.fail("carIndex", err))
 	}
 
 	if err := ValidateCarIndex(carIndex); err != nil {
-		return car, NewError("UnmarshalEntryListCar", err)
+		return car, NewError("UnmarshalEntryListCar", mr.fail("carIndex", err))
 	}
 	car.CarIndex = carIndex
 
-	carModelType, err := readUint8(r)
+	carModelType, err := readUint8(mr)
 	if err != nil {
-		return car, NewError("UnmarshalEntryListCar", fmt.Errorf("failed to read carModelType: %w", err))
+		return car, NewError("UnmarshalEntryListCar", mr.fail("carModelType", err))
 	}
 	car.CarModelType = carModelType
 
-	teamName, err := readString(r)
+	teamName, err := readString(mr)
 	if err != nil {
-		return car, NewError("UnmarshalEntryListCar", fmt.Errorf("failed to read teamName: %w", err))
+		return car, NewError("UnmarshalEntryListCar", mr.fail("teamName", err))
 	}
 	car.TeamName = teamName
 
-	raceNumber, err := readInt32(r)
+	raceNumber, err := readInt32(mr)
 	if err != nil {
-		return car, NewError("UnmarshalEntryListCar", fmt.Errorf("failed to read raceNumber: %w", err))
+		return car, NewError("UnmarshalEntryListCar", mr.fail("raceNumber", err))
 	}
 	car.RaceNumber = raceNumber
 
-	cupCategory, err := readUint8(r)
+	cupCategory, err := readUint8(mr)
 	if err != nil {
-		return car, NewError("UnmarshalEntryListCar", fmt.Errorf("failed to read cupCategory: %w", err))
+		return car, NewError("UnmarshalEntryListCar", mr.fail("cupCategory", err))
 	}
 
 	if cupCategory > 4 {
 		return car, NewError("UnmarshalEntryListCar",
-			NewValidationError("cupCategory", cupCategory, "must be between 0 and 4"))
+			mr.fail("cupCategory", NewValidationError("cupCategory", cupCategory, "must be between 0 and 4")))
 	}
 	car.CupCategory = cupCategory
 
-	currentDriverIndex, err := readUint8(r)
+	currentDriverIndex, err := readUint8(mr)
 	if err != nil {
-		return car, NewError("UnmarshalEntryListCar", fmt.Errorf("failed to read currentDriverIndex: %w", err))
+		return car, NewError("UnmarshalEntryListCar", mr.fail("currentDriverIndex", err))
 	}
 	car.CurrentDriverIndex = currentDriverIndex
 
-	nationality, err := readUint16(r)
+	nationality, err := readUint16(mr)
 	if err != nil {
-		return car, NewError("UnmarshalEntryListCar", fmt.Errorf("failed to read nationality: %w", err))
+		return car, NewError("UnmarshalEntryListCar", mr.fail("nationality", err))
 	}
 	car.Nationality = NationalityEnum(nationality)
 
-	driverCount, err := readUint8(r)
+	driverCount, err := readUint8(mr)
 	if err != nil {
-		return car, NewError("UnmarshalEntryListCar", fmt.Errorf("failed to read driverCount: %w", err))
+		return car, NewError("UnmarshalEntryListCar", mr.fail("driverCount", err))
 	}
 
-	if driverCount > 10 {
-		return car, NewError("UnmarshalEntryListCar",
-			NewValidationError("driverCount", driverCount, "exceeds maximum of 10"))
+	if err := mr.checkCount("driverCount", int(driverCount), MaxDrivers, minDriverSize); err != nil {
+		return car, NewError("UnmarshalEntryListCar", err)
 	}
 
 	if currentDriverIndex >= driverCount {
 		return car, NewError("UnmarshalEntryListCar",
-			NewValidationError("currentDriverIndex", currentDriverIndex,
-				fmt.Sprintf("must be less than driverCount (%d)", driverCount)))
+			mr.fail("driverCount", NewValidationError("currentDriverIndex", currentDriverIndex,
+				fmt.Sprintf("must be less than driverCount (%d)", driverCount))))
 	}
 
 	car.Drivers = make([]DriverInfo, driverCount)
 	for i := uint8(0); i < driverCount; i++ {
-		firstName, err := readString(r)
+		firstName, err := readString(mr)
 		if err != nil {
 			return car, NewError("UnmarshalEntryListCar",
-				fmt.Errorf("failed to read driver firstName at index %d: %w", i, err))
+				mr.fail(fmt.Sprintf("drivers[%d].firstName", i), err))
 		}
 
-		lastName, err := readString(r)
+		lastName, err := readString(mr)
 		if err != nil {
 			return car, NewError("UnmarshalEntryListCar",
-				fmt.Errorf("failed to read driver lastName at index %d: %w", i, err))
+				mr.fail(fmt.Sprintf("drivers[%d].lastName", i), err))
 		}
 
-		shortName, err := readString(r)
+		shortName, err := readString(mr)
 		if err != nil {
 			return car, NewError("UnmarshalEntryListCar",
-				fmt.Errorf("failed to read driver shortName at index %d: %w", i, err))
+				mr.fail(fmt.Sprintf("drivers[%d].shortName", i), err))
 		}
 
-		category, err := readUint8(r)
+		category, err := readUint8(mr)
 		if err != nil {
 			return car, NewError("UnmarshalEntryListCar",
-				fmt.Errorf("failed to read driver category at index %d: %w", i, err))
+				mr.fail(fmt.Sprintf("drivers[%d].category", i), err))
 		}
 
 		if err := ValidateDriverCategory(DriverCategory(category)); err != nil {
 			return car, NewError("UnmarshalEntryListCar",
-				fmt.Errorf("invalid driver category at index %d: %w", i, err))
+				mr.fail(fmt.Sprintf("drivers[%d].category", i), err))
 		}
 
-		driverNationality, err := readUint16(r)
+		driverNationality, err := readUint16(mr)
 		if err != nil {
 			return car, NewError("UnmarshalEntryListCar",
-				fmt.Errorf("failed to read driver nationality at index %d: %w", i, err))
+				mr.fail(fmt.Sprintf("drivers[%d].nationality", i), err))
 		}
 
 		car.Drivers[i] = DriverInfo{
@@ -190,72 +194,72 @@ func UnmarshalEntryListCar(r io.Reader) (CarInfo, error) {
 }
 
 func UnmarshalTrackData(r io.Reader) (int32, TrackData, error) {
+	mr := newMessageReader(r)
+
 	var trackData TrackData
 
-	connectionId, err := readInt32(r)
+	connectionId, err := readInt32(mr)
 	if err != nil {
-		return 0, trackData, NewError("UnmarshalTrackData", fmt.Errorf("failed to read connectionId: %w", err))
+		return 0, trackData, NewError("UnmarshalTrackData", mr.fail("connectionId", err))
 	}
 
-	trackName, err := readString(r)
+	trackName, err := readString(mr)
 	if err != nil {
-		return connectionId, trackData, NewError("UnmarshalTrackData", fmt.Errorf("failed to read trackName: %w", err))
+		return connectionId, trackData, NewError("UnmarshalTrackData", mr.fail("trackName", err))
 	}
 	trackData.TrackName = trackName
 
-	trackId, err := readInt32(r)
+	trackId, err := readInt32(mr)
 	if err != nil {
-		return connectionId, trackData, NewError("UnmarshalTrackData", fmt.Errorf("failed to read trackId: %w", err))
+		return connectionId, trackData, NewError("UnmarshalTrackData", mr.fail("trackId", err))
 	}
 	trackData.TrackId = trackId
 
-	trackMeters, err := readInt32(r)
+	trackMeters, err := readInt32(mr)
 	if err != nil {
-		return connectionId, trackData, NewError("UnmarshalTrackData", fmt.Errorf("failed to read trackMeters: %w", err))
+		return connectionId, trackData, NewError("UnmarshalTrackData", mr.fail("trackMeters", err))
 	}
 
 	if trackMeters < 1000 || trackMeters > 25000 {
 		return connectionId, trackData, NewError("UnmarshalTrackData",
-			NewValidationError("trackMeters", trackMeters, "must be between 1000 and 25000"))
+			mr.fail("trackMeters", NewValidationError("trackMeters", trackMeters, "must be between 1000 and 25000")))
 	}
 	trackData.TrackMeters = trackMeters
 
 	trackData.CameraSets = make(map[string][]string)
 
-	cameraSetCount, err := readUint8(r)
+	cameraSetCount, err := readUint8(mr)
 	if err != nil {
-		return connectionId, trackData, NewError("UnmarshalTrackData", fmt.Errorf("failed to read cameraSetCount: %w", err))
+		return connectionId, trackData, NewError("UnmarshalTrackData", mr.fail("cameraSetCount", err))
 	}
 
-	if cameraSetCount > 50 {
-		return connectionId, trackData, NewError("UnmarshalTrackData",
-			NewValidationError("cameraSetCount", cameraSetCount, "exceeds maximum of 50"))
+	if err := mr.checkCount("cameraSetCount", int(cameraSetCount), MaxCameraSets, minCameraSetSize); err != nil {
+		return connectionId, trackData, NewError("UnmarshalTrackData", err)
 	}
 
 	for i := uint8(0); i < cameraSetCount; i++ {
-		cameraSetName, err := readString(r)
+		cameraSetName, err := readString(mr)
 		if err != nil {
 			return connectionId, trackData, NewError("UnmarshalTrackData",
-				fmt.Errorf("failed to read cameraSetName at index %d: %w", i, err))
+				mr.fail(fmt.Sprintf("cameraSets[%d].name", i), err))
 		}
 
-		cameraCount, err := readUint8(r)
+		cameraCount, err := readUint8(mr)
 		if err != nil {
 			return connectionId, trackData, NewError("UnmarshalTrackData",
-				fmt.Errorf("failed to read cameraCount at index %d: %w", i, err))
+				mr.fail(fmt.Sprintf("cameraSets[%d].cameraCount", i), err))
 		}
 
-		if cameraCount > 100 {
-			return connectionId, trackData, NewError("UnmarshalTrackData",
-				NewValidationError("cameraCount", cameraCount, "exceeds maximum of 100"))
+		if err := mr.checkCount(fmt.Sprintf("cameraSets[%d].cameraCount", i), int(cameraCount), MaxCamerasPerSet, minStringSize); err != nil {
+			return connectionId, trackData, NewError("UnmarshalTrackData", err)
 		}
 
 		cameras := make([]string, cameraCount)
 		for j := uint8(0); j < cameraCount; j++ {
-			cameraName, err := readString(r)
+			cameraName, err := readString(mr)
 			if err != nil {
 				return connectionId, trackData, NewError("UnmarshalTrackData",
-					fmt.Errorf("failed to read cameraName at set %d, camera %d: %w", i, j, err))
+					mr.fail(fmt.Sprintf("cameraSets[%d].cameras[%d]", i, j), err))
 			}
 			cameras[j] = cameraName
 		}
@@ -263,22 +267,21 @@ func UnmarshalTrackData(r io.Reader) (int32, TrackData, error) {
 		trackData.CameraSets[cameraSetName] = cameras
 	}
 
-	hudPageCount, err := readUint8(r)
+	hudPageCount, err := readUint8(mr)
 	if err != nil {
-		return connectionId, trackData, NewError("UnmarshalTrackData", fmt.Errorf("failed to read hudPageCount: %w", err))
+		return connectionId, trackData, NewError("UnmarshalTrackData", mr.fail("hudPageCount", err))
 	}
 
-	if hudPageCount > 50 {
-		return connectionId, trackData, NewError("UnmarshalTrackData",
-			NewValidationError("hudPageCount", hudPageCount, "exceeds maximum of 50"))
+	if err := mr.checkCount("hudPageCount", int(hudPageCount), MaxHUDPages, minStringSize); err != nil {
+		return connectionId, trackData, NewError("UnmarshalTrackData", err)
 	}
 
 	trackData.HUDPages = make([]string, hudPageCount)
 	for i := uint8(0); i < hudPageCount; i++ {
-		hudPage, err := readString(r)
+		hudPage, err := readString(mr)
 		if err != nil {
 			return connectionId, trackData, NewError("UnmarshalTrackData",
-				fmt.Errorf("failed to read hudPage at index %d: %w", i, err))
+				mr.fail(fmt.Sprintf("hudPages[%d]", i), err))
 		}
 		trackData.HUDPages[i] = hudPage
 	}
@@ -287,127 +290,129 @@ func UnmarshalTrackData(r io.Reader) (int32, TrackData, error) {
 }
 
 func UnmarshalRealtimeUpdate(r io.Reader) (RealtimeUpdate, error) {
+	mr := newMessageReader(r)
+
 	var update RealtimeUpdate
 
-	eventIndex, err := readUint16(r)
+	eventIndex, err := readUint16(mr)
 	if err != nil {
-		return update, NewError("UnmarshalRealtimeUpdate", fmt.Errorf("failed to read eventIndex: %w", err))
+		return update, NewError("UnmarshalRealtimeUpdate", mr.fail("eventIndex", err))
 	}
 	update.EventIndex = eventIndex
 
-	sessionIndex, err := readUint16(r)
+	sessionIndex, err := readUint16(mr)
 	if err != nil {
-		return update, NewError("UnmarshalRealtimeUpdate", fmt.Errorf("failed to read sessionIndex: %w", err))
+		return update, NewError("UnmarshalRealtimeUpdate", mr.fail("sessionIndex", err))
 	}
 	update.SessionIndex = sessionIndex
 
-	sessionType, err := readUint8(r)
+	sessionType, err := readUint8(mr)
 	if err != nil {
-		return update, NewError("UnmarshalRealtimeUpdate", fmt.Errorf("failed to read sessionType: %w", err))
+		return update, NewError("UnmarshalRealtimeUpdate", mr.fail("sessionType", err))
 	}
 	update.SessionType = RaceSessionType(sessionType)
 
-	phase, err := readUint8(r)
+	phase, err := readUint8(mr)
 	if err != nil {
-		return update, NewError("UnmarshalRealtimeUpdate", fmt.Errorf("failed to read phase: %w", err))
+		return update, NewError("UnmarshalRealtimeUpdate", mr.fail("phase", err))
 	}
 	update.Phase = SessionPhase(phase)
 
-	sessionTime, err := readFloat32(r)
+	sessionTime, err := readFloat32(mr)
 	if err != nil {
-		return update, NewError("UnmarshalRealtimeUpdate", fmt.Errorf("failed to read sessionTime: %w", err))
+		return update, NewError("UnmarshalRealtimeUpdate", mr.fail("sessionTime", err))
 	}
 	update.SessionTime = time.Duration(sessionTime) * time.Millisecond
 
-	sessionEndTime, err := readFloat32(r)
+	sessionEndTime, err := readFloat32(mr)
 	if err != nil {
-		return update, NewError("UnmarshalRealtimeUpdate", fmt.Errorf("failed to read sessionEndTime: %w", err))
+		return update, NewError("UnmarshalRealtimeUpdate", mr.fail("sessionEndTime", err))
 	}
 	update.SessionEndTime = time.Duration(sessionEndTime) * time.Millisecond
 
-	focusedCarIndex, err := readInt32(r)
+	focusedCarIndex, err := readInt32(mr)
 	if err != nil {
-		return update, NewError("UnmarshalRealtimeUpdate", fmt.Errorf("failed to read focusedCarIndex: %w", err))
+		return update, NewError("UnmarshalRealtimeUpdate", mr.fail("focusedCarIndex", err))
 	}
 	update.FocusedCarIndex = focusedCarIndex
 
-	activeCameraSet, err := readString(r)
+	activeCameraSet, err := readString(mr)
 	if err != nil {
-		return update, NewError("UnmarshalRealtimeUpdate", fmt.Errorf("failed to read activeCameraSet: %w", err))
+		return update, NewError("UnmarshalRealtimeUpdate", mr.fail("activeCameraSet", err))
 	}
 	update.ActiveCameraSet = activeCameraSet
 
-	activeCamera, err := readString(r)
+	activeCamera, err := readString(mr)
 	if err != nil {
-		return update, NewError("UnmarshalRealtimeUpdate", fmt.Errorf("failed to read activeCamera: %w", err))
+		return update, NewError("UnmarshalRealtimeUpdate", mr.fail("activeCamera", err))
 	}
 	update.ActiveCamera = activeCamera
 
-	currentHudPage, err := readString(r)
+	currentHudPage, err := readString(mr)
 	if err != nil {
-		return update, NewError("UnmarshalRealtimeUpdate", fmt.Errorf("failed to read currentHudPage: %w", err))
+		return update, NewError("UnmarshalRealtimeUpdate", mr.fail("currentHudPage", err))
 	}
 	update.CurrentHudPage = currentHudPage
 
-	isReplayPlaying, err := readUint8(r)
+	isReplayPlaying, err := readUint8(mr)
 	if err != nil {
-		return update, NewError("UnmarshalRealtimeUpdate", fmt.Errorf("failed to read isReplayPlaying: %w", err))
+		return update, NewError("UnmarshalRealtimeUpdate", mr.fail("isReplayPlaying", err))
 	}
 	update.IsReplayPlaying = isReplayPlaying > 0
 
 	if update.IsReplayPlaying {
-		replaySessionTime, err := readFloat32(r)
+		replaySessionTime, err := readFloat32(mr)
 		if err != nil {
-			return update, NewError("UnmarshalRealtimeUpdate", fmt.Errorf("failed to read replaySessionTime: %w", err))
+			return update, NewError("UnmarshalRealtimeUpdate", mr.fail("replaySessionTime", err))
 		}
 		update.ReplaySessionTime = replaySessionTime
 
-		replayRemainingTime, err := readFloat32(r)
+		replayRemainingTime, err := readFloat32(mr)
 		if err != nil {
-			return update, NewError("UnmarshalRealtimeUpdate", fmt.Errorf("failed to read replayRemainingTime: %w", err))
+			return update, NewError("UnmarshalRealtimeUpdate", mr.fail("replayRemainingTime", err))
 		}
 		update.ReplayRemainingTime = replayRemainingTime
 	}
 
-	timeOfDay, err := readFloat32(r)
+	timeOfDay, err := readFloat32(mr)
 	if err != nil {
-		return update, NewError("UnmarshalRealtimeUpdate", fmt.Errorf("failed to read timeOfDay: %w", err))
+		return update, NewError("UnmarshalRealtimeUpdate", mr.fail("timeOfDay", err))
 	}
 	update.TimeOfDay = time.Duration(timeOfDay) * time.Millisecond
 
-	ambientTemp, err := readUint8(r)
+	ambientTemp, err := readUint8(mr)
 	if err != nil {
-		return update, NewError("UnmarshalRealtimeUpdate", fmt.Errorf("failed to read ambientTemp: %w", err))
+		return update, NewError("UnmarshalRealtimeUpdate", mr.fail("ambientTemp", err))
 	}
 	update.AmbientTemp = ambientTemp
 
-	trackTemp, err := readUint8(r)
+	trackTemp, err := readUint8(mr)
 	if err != nil {
-		return update, NewError("UnmarshalRealtimeUpdate", fmt.Errorf("failed to read trackTemp: %w", err))
+		return update, NewError("UnmarshalRealtimeUpdate", mr.fail("trackTemp", err))
 	}
 	update.TrackTemp = trackTemp
 
-	clouds, err := readUint8(r)
+	clouds, err := readUint8(mr)
 	if err != nil {
-		return update, NewError("UnmarshalRealtimeUpdate", fmt.Errorf("failed to read clouds: %w", err))
+		return update, NewError("UnmarshalRealtimeUpdate", mr.fail("clouds", err))
 	}
 	update.Clouds = float32(clouds) / 10.0
 
-	rainLevel, err := readUint8(r)
+	rainLevel, err := readUint8(mr)
 	if err != nil {
-		return update, NewError("UnmarshalRealtimeUpdate", fmt.Errorf("failed to read rainLevel: %w", err))
+		return update, NewError("UnmarshalRealtimeUpdate", mr.fail("rainLevel", err))
 	}
 	update.RainLevel = float32(rainLevel) / 10.0
 
-	wetness, err := readUint8(r)
+	wetness, err := readUint8(mr)
 	if err != nil {
-		return update, NewError("UnmarshalRealtimeUpdate", fmt.Errorf("failed to read wetness: %w", err))
+		return update, NewError("UnmarshalRealtimeUpdate", mr.fail("wetness", err))
 	}
 	update.Wetness = float32(wetness) / 10.0
 
-	bestSessionLap, err := readLap(r)
+	bestSessionLap, err := readLap(mr)
 	if err != nil {
-		return update, NewError("UnmarshalRealtimeUpdate", fmt.Errorf("failed to read bestSessionLap: %w", err))
+		return update, NewError("UnmarshalRealtimeUpdate", mr.fail("bestSessionLap", err))
 	}
 	update.BestSessionLap = bestSessionLap
 
@@ -415,116 +420,118 @@ func UnmarshalRealtimeUpdate(r io.Reader) (RealtimeUpdate, error) {
 }
 
 func UnmarshalRealtimeCarUpdate(r io.Reader) (RealtimeCarUpdate, error) {
+	mr := newMessageReader(r)
+
 	var update RealtimeCarUpdate
 
-	carIndex, err := readUint16(r)
+	carIndex, err := readUint16(mr)
 	if err != nil {
-		return update, NewError("UnmarshalRealtimeCarUpdate", fmt.Errorf("failed to read carIndex: %w", err))
+		return update, NewError("UnmarshalRealtimeCarUpdate", mr.fail("carIndex", err))
 	}
 	if err := ValidateCarIndex(carIndex); err != nil {
-		return update, NewError("UnmarshalRealtimeCarUpdate", err)
+		return update, NewError("UnmarshalRealtimeCarUpdate", mr.fail("carIndex", err))
 	}
 	update.CarIndex = carIndex
 
-	driverIndex, err := readUint16(r)
+	driverIndex, err := readUint16(mr)
 	if err != nil {
-		return update, NewError("UnmarshalRealtimeCarUpdate", fmt.Errorf("failed to read driverIndex: %w", err))
+		return update, NewError("UnmarshalRealtimeCarUpdate", mr.fail("driverIndex", err))
 	}
 	update.DriverIndex = driverIndex
 
-	driverCount, err := readUint8(r)
+	driverCount, err := readUint8(mr)
 	if err != nil {
-		return update, NewError("UnmarshalRealtimeCarUpdate", fmt.Errorf("failed to read driverCount: %w", err))
+		return update, NewError("UnmarshalRealtimeCarUpdate", mr.fail("driverCount", err))
 	}
 	update.DriverCount = driverCount
 
-	gear, err := readUint8(r)
+	gear, err := readUint8(mr)
 	if err != nil {
-		return update, NewError("UnmarshalRealtimeCarUpdate", fmt.Errorf("failed to read gear: %w", err))
+		return update, NewError("UnmarshalRealtimeCarUpdate", mr.fail("gear", err))
 	}
 	update.Gear = int8(gear) - 2
 
-	worldPosX, err := readFloat32(r)
+	worldPosX, err := readFloat32(mr)
 	if err != nil {
-		return update, NewError("UnmarshalRealtimeCarUpdate", fmt.Errorf("failed to read worldPosX: %w", err))
+		return update, NewError("UnmarshalRealtimeCarUpdate", mr.fail("worldPosX", err))
 	}
 	update.WorldPosX = worldPosX
 
-	worldPosY, err := readFloat32(r)
+	worldPosY, err := readFloat32(mr)
 	if err != nil {
-		return update, NewError("UnmarshalRealtimeCarUpdate", fmt.Errorf("failed to read worldPosY: %w", err))
+		return update, NewError("UnmarshalRealtimeCarUpdate", mr.fail("worldPosY", err))
 	}
 	update.WorldPosY = worldPosY
 
-	heading, err := readFloat32(r)
+	heading, err := readFloat32(mr)
 	if err != nil {
-		return update, NewError("UnmarshalRealtimeCarUpdate", fmt.Errorf("failed to read heading: %w", err))
+		return update, NewError("UnmarshalRealtimeCarUpdate", mr.fail("heading", err))
 	}
 	update.Heading = heading
 
-	carLocation, err := readUint8(r)
+	carLocation, err := readUint8(mr)
 	if err != nil {
-		return update, NewError("UnmarshalRealtimeCarUpdate", fmt.Errorf("failed to read carLocation: %w", err))
+		return update, NewError("UnmarshalRealtimeCarUpdate", mr.fail("carLocation", err))
 	}
 	update.CarLocation = CarLocationEnum(carLocation)
 
-	kmh, err := readUint16(r)
+	kmh, err := readUint16(mr)
 	if err != nil {
-		return update, NewError("UnmarshalRealtimeCarUpdate", fmt.Errorf("failed to read kmh: %w", err))
+		return update, NewError("UnmarshalRealtimeCarUpdate", mr.fail("kmh", err))
 	}
 	update.Kmh = kmh
 
-	position, err := readUint16(r)
+	position, err := readUint16(mr)
 	if err != nil {
-		return update, NewError("UnmarshalRealtimeCarUpdate", fmt.Errorf("failed to read position: %w", err))
+		return update, NewError("UnmarshalRealtimeCarUpdate", mr.fail("position", err))
 	}
 	update.Position = position
 
-	cupPosition, err := readUint16(r)
+	cupPosition, err := readUint16(mr)
 	if err != nil {
-		return update, NewError("UnmarshalRealtimeCarUpdate", fmt.Errorf("failed to read cupPosition: %w", err))
+		return update, NewError("UnmarshalRealtimeCarUpdate", mr.fail("cupPosition", err))
 	}
 	update.CupPosition = cupPosition
 
-	trackPosition, err := readUint16(r)
+	trackPosition, err := readUint16(mr)
 	if err != nil {
-		return update, NewError("UnmarshalRealtimeCarUpdate", fmt.Errorf("failed to read trackPosition: %w", err))
+		return update, NewError("UnmarshalRealtimeCarUpdate", mr.fail("trackPosition", err))
 	}
 	update.TrackPosition = trackPosition
 
-	splinePosition, err := readFloat32(r)
+	splinePosition, err := readFloat32(mr)
 	if err != nil {
-		return update, NewError("UnmarshalRealtimeCarUpdate", fmt.Errorf("failed to read splinePosition: %w", err))
+		return update, NewError("UnmarshalRealtimeCarUpdate", mr.fail("splinePosition", err))
 	}
 	update.SplinePosition = splinePosition
 
-	laps, err := readUint16(r)
+	laps, err := readUint16(mr)
 	if err != nil {
-		return update, NewError("UnmarshalRealtimeCarUpdate", fmt.Errorf("failed to read laps: %w", err))
+		return update, NewError("UnmarshalRealtimeCarUpdate", mr.fail("laps", err))
 	}
 	update.Laps = laps
 
-	delta, err := readInt32(r)
+	delta, err := readInt32(mr)
 	if err != nil {
-		return update, NewError("UnmarshalRealtimeCarUpdate", fmt.Errorf("failed to read delta: %w", err))
+		return update, NewError("UnmarshalRealtimeCarUpdate", mr.fail("delta", err))
 	}
 	update.Delta = delta
 
-	bestSessionLap, err := readLap(r)
+	bestSessionLap, err := readLap(mr)
 	if err != nil {
-		return update, NewError("UnmarshalRealtimeCarUpdate", fmt.Errorf("failed to read bestSessionLap: %w", err))
+		return update, NewError("UnmarshalRealtimeCarUpdate", mr.fail("bestSessionLap", err))
 	}
 	update.BestSessionLap = bestSessionLap
 
-	lastLap, err := readLap(r)
+	lastLap, err := readLap(mr)
 	if err != nil {
-		return update, NewError("UnmarshalRealtimeCarUpdate", fmt.Errorf("failed to read lastLap: %w", err))
+		return update, NewError("UnmarshalRealtimeCarUpdate", mr.fail("lastLap", err))
 	}
 	update.LastLap = lastLap
 
-	currentLap, err := readLap(r)
+	currentLap, err := readLap(mr)
 	if err != nil {
-		return update, NewError("UnmarshalRealtimeCarUpdate", fmt.Errorf("failed to read currentLap: %w", err))
+		return update, NewError("UnmarshalRealtimeCarUpdate", mr.fail("currentLap", err))
 	}
 	update.CurrentLap = currentLap
 
@@ -532,31 +539,192 @@ func UnmarshalRealtimeCarUpdate(r io.Reader) (RealtimeCarUpdate, error) {
 }
 
 func UnmarshalBroadcastingEvent(r io.Reader) (BroadcastingEvent, error) {
+	mr := newMessageReader(r)
+
 	var event BroadcastingEvent
 
-	eventType, err := readUint8(r)
+	eventType, err := readUint8(mr)
 	if err != nil {
-		return event, NewError("UnmarshalBroadcastingEvent", fmt.Errorf("failed to read eventType: %w", err))
+		return event, NewError("UnmarshalBroadcastingEvent", mr.fail("eventType", err))
 	}
 	event.Type = BroadcastingEventType(eventType)
 
-	msg, err := readString(r)
+	msg, err := readString(mr)
 	if err != nil {
-		return event, NewError("UnmarshalBroadcastingEvent", fmt.Errorf("failed to read msg: %w", err))
+		return event, NewError("UnmarshalBroadcastingEvent", mr.fail("msg", err))
 	}
 	event.Msg = msg
 
-	timeMs, err := readInt32(r)
+	timeMs, err := readInt32(mr)
 	if err != nil {
-		return event, NewError("UnmarshalBroadcastingEvent", fmt.Errorf("failed to read timeMs: %w", err))
+		return event, NewError("UnmarshalBroadcastingEvent", mr.fail("timeMs", err))
 	}
 	event.TimeMs = timeMs
 
-	carId, err := readInt32(r)
+	carId, err := readInt32(mr)
 	if err != nil {
-		return event, NewError("UnmarshalBroadcastingEvent", fmt.Errorf("failed to read carId: %w", err))
+		return event, NewError("UnmarshalBroadcastingEvent", mr.fail("carId", err))
 	}
 	event.CarId = carId
 
 	return event, nil
 }
+
+// Outbound messages, as received by ACC. Used by the mock server.
+
+func UnmarshalRegistrationRequest(r io.Reader) (RegistrationRequest, error) {
+	mr := newMessageReader(r)
+
+	var request RegistrationRequest
+
+	protocolVersion, err := readUint8(mr)
+	if err != nil {
+		return request, NewError("UnmarshalRegistrationRequest", mr.fail("protocolVersion", err))
+	}
+	request.ProtocolVersion = protocolVersion
+
+	displayName, err := readString(mr)
+	if err != nil {
+		return request, NewError("UnmarshalRegistrationRequest", mr.fail("displayName", err))
+	}
+	request.DisplayName = displayName
+
+	connectionPassword, err := readString(mr)
+	if err != nil {
+		return request, NewError("UnmarshalRegistrationRequest", mr.fail("connectionPassword", err))
+	}
+	request.ConnectionPassword = connectionPassword
+
+	updateInterval, err := readInt32(mr)
+	if err != nil {
+		return request, NewError("UnmarshalRegistrationRequest", mr.fail("updateInterval", err))
+	}
+	request.UpdateIntervalMS = updateInterval
+
+	commandPassword, err := readString(mr)
+	if err != nil {
+		return request, NewError("UnmarshalRegistrationRequest", mr.fail("commandPassword", err))
+	}
+	request.CommandPassword = commandPassword
+
+	return request, nil
+}
+
+// UnmarshalConnectionRequest reads the body of requests that only carry the
+// connection id (unregister, entry list, track data and highlight requests)
+func UnmarshalConnectionRequest(r io.Reader) (int32, error) {
+	mr := newMessageReader(r)
+
+	connectionId, err := readInt32(mr)
+	if err != nil {
+		return 0, NewError("UnmarshalConnectionRequest", mr.fail("connectionId", err))
+	}
+	return connectionId, nil
+}
+
+func UnmarshalFocusRequest(r io.Reader) (FocusRequest, error) {
+	mr := newMessageReader(r)
+
+	var request FocusRequest
+
+	connectionId, err := readInt32(mr)
+	if err != nil {
+		return request, NewError("UnmarshalFocusRequest", mr.fail("connectionId", err))
+	}
+	request.ConnectionId = connectionId
+
+	hasCarIndex, err := readUint8(mr)
+	if err != nil {
+		return request, NewError("UnmarshalFocusRequest", mr.fail("carIndexFlag", err))
+	}
+	if hasCarIndex > 0 {
+		carIndex, err := readUint16(mr)
+		if err != nil {
+			return request, NewError("UnmarshalFocusRequest", mr.fail("carIndex", err))
+		}
+		request.CarIndex = &carIndex
+	}
+
+	hasCamera, err := readUint8(mr)
+	if err != nil {
+		return request, NewError("UnmarshalFocusRequest", mr.fail("cameraFlag", err))
+	}
+	if hasCamera > 0 {
+		cameraSet, err := readString(mr)
+		if err != nil {
+			return request, NewError("UnmarshalFocusRequest", mr.fail("cameraSet", err))
+		}
+		camera, err := readString(mr)
+		if err != nil {
+			return request, NewError("UnmarshalFocusRequest", mr.fail("camera", err))
+		}
+		request.CameraSet = &cameraSet
+		request.Camera = &camera
+	}
+
+	return request, nil
+}
+
+func UnmarshalInstantReplayRequest(r io.Reader) (InstantReplayRequest, error) {
+	mr := newMessageReader(r)
+
+	var request InstantReplayRequest
+
+	connectionId, err := readInt32(mr)
+	if err != nil {
+		return request, NewError("UnmarshalInstantReplayRequest", mr.fail("connectionId", err))
+	}
+	request.ConnectionId = connectionId
+
+	startSessionTime, err := readFloat32(mr)
+	if err != nil {
+		return request, NewError("UnmarshalInstantReplayRequest", mr.fail("startSessionTime", err))
+	}
+	request.StartSessionTime = startSessionTime
+
+	duration, err := readFloat32(mr)
+	if err != nil {
+		return request, NewError("UnmarshalInstantReplayRequest", mr.fail("duration", err))
+	}
+	request.DurationMS = duration
+
+	focusedCarIndex, err := readInt32(mr)
+	if err != nil {
+		return request, NewError("UnmarshalInstantReplayRequest", mr.fail("initialFocusedCarIndex", err))
+	}
+	request.InitialFocusedCarIndex = focusedCarIndex
+
+	cameraSet, err := readString(mr)
+	if err != nil {
+		return request, NewError("UnmarshalInstantReplayRequest", mr.fail("initialCameraSet", err))
+	}
+	request.InitialCameraSet = cameraSet
+
+	camera, err := readString(mr)
+	if err != nil {
+		return request, NewError("UnmarshalInstantReplayRequest", mr.fail("initialCamera", err))
+	}
+	request.InitialCamera = camera
+
+	return request, nil
+}
+
+func UnmarshalHUDPageRequest(r io.Reader) (HUDPageRequest, error) {
+	mr := newMessageReader(r)
+
+	var request HUDPageRequest
+
+	connectionId, err := readInt32(mr)
+	if err != nil {
+		return request, NewError("UnmarshalHUDPageRequest", mr.fail("connectionId", err))
+	}
+	request.ConnectionId = connectionId
+
+	hudPage, err := readString(mr)
+	if err != nil {
+		return request, NewError("UnmarshalHUDPageRequest", mr.fail("hudPage", err))
+	}
+	request.HUDPage = hudPage
+
+	return request, nil
+}
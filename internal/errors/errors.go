@@ -12,6 +12,12 @@ var (
 	ErrConnectionTimeout = errors.New("connection timeout")
 	ErrAlreadyConnected  = errors.New("already connected")
 	ErrNotConnected      = errors.New("not connected")
+	ErrConnectionExists  = errors.New("connection already exists")
+	ErrConnectionUnknown = errors.New("unknown connection")
+
+	// Common registration errors
+	ErrRegistrationRejected = errors.New("registration rejected")
+	ErrRegistrationTimeout  = errors.New("registration timeout")
 
 	// Common protocol errors
 	ErrProtocolNotInitialized = errors.New("protocol not initialized")
@@ -36,16 +42,36 @@ var (
 	ErrInvalidDriverCategory = errors.New("invalid driver category")
 
 	// Common shared memory errors
-	ErrSharedMemoryNotFound = errors.New("shared memory not found")
-	ErrSharedMemoryAccess   = errors.New("shared memory access denied")
-	ErrSharedMemoryMap      = errors.New("failed to map shared memory")
-	ErrSharedMemoryUnmap    = errors.New("failed to unmap shared memory")
+	ErrSharedMemoryNotFound     = errors.New("shared memory not found")
+	ErrSharedMemoryAccess       = errors.New("shared memory access denied")
+	ErrSharedMemoryMap          = errors.New("failed to map shared memory")
+	ErrSharedMemoryUnmap        = errors.New("failed to unmap shared memory")
+	ErrSharedMemoryTornRead     = errors.New("shared memory page changed during read")
+	ErrSharedMemoryIncompatible = errors.New("incompatible shared memory layout")
+
+	// Common recording errors
+	ErrInvalidRecording     = errors.New("invalid recording file")
+	ErrUnsupportedRecording = errors.New("unsupported recording format version")
+	ErrRecordingClosed      = errors.New("recording is closed")
+	ErrCaptureActive        = errors.New("capture already in progress")
+
+	// Common steward review errors
+	ErrReviewEntryUnknown = errors.New("unknown review entry")
+	ErrInvalidReviewState = errors.New("invalid review status")
+
+	// Common broadcast command errors
+	ErrCommandSuperseded   = errors.New("command superseded by a newer command")
+	ErrCommandNotConfirmed = errors.New("command not confirmed by ACC")
+	ErrCommandQueueClosed  = errors.New("command queue closed")
 
 	// Common I/O errors
 	ErrReadFailed     = errors.New("read operation failed")
 	ErrWriteFailed    = errors.New("write operation failed")
 	ErrEncodingFailed = errors.New("encoding failed")
 	ErrDecodingFailed = errors.New("decoding failed")
+
+	// Common binary decoding errors
+	ErrLengthOutOfRange = errors.New("length exceeds remaining data")
 )
 
 type AppError struct {
@@ -113,6 +139,34 @@ func NewValidationError(module, field string, value any, rule string) error {
 	}
 }
 
+// DecodeError reports the field of a binary message that could not be
+// decoded and its byte offset in the message body
+type DecodeError struct {
+	Field  string // Field that failed to decode
+	Offset int    // Offset of the field
+	Err    error  // Underlying error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("failed to decode %s at offset %d: %v", e.Field, e.Offset, e.Err)
+}
+
+// Unwrap allows errors.Is and errors.As to work correctly
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+func NewDecodeError(field string, offset int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &DecodeError{
+		Field:  field,
+		Offset: offset,
+		Err:    err,
+	}
+}
+
 func WrapError(module, op string, err error) error {
 	if err == nil {
 		return nil
@@ -140,9 +194,19 @@ func IsValidationError(err error) bool {
 	return errors.As(err, &validationErr)
 }
 
+func IsDecodeError(err error) bool {
+	var decodeErr *DecodeError
+	return errors.As(err, &decodeErr)
+}
+
 func IsTimeoutError(err error) bool {
 	return errors.Is(err, ErrTimeout) ||
 		errors.Is(err, ErrReadTimeout) ||
 		errors.Is(err, ErrWriteTimeout) ||
-		errors.Is(err, ErrConnectionTimeout)
+		errors.Is(err, ErrConnectionTimeout) ||
+		errors.Is(err, ErrRegistrationTimeout)
+}
+
+func IsIncompatibleError(err error) bool {
+	return errors.Is(err, ErrSharedMemoryIncompatible)
 }
@@ -0,0 +1,312 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Policy decides what happens when a subscriber's buffer is full
+type Policy int
+
+const (
+	// DropNewest discards the message being published (default)
+	DropNewest Policy = iota
+	// DropOldest evicts the oldest buffered message to make room
+	DropOldest
+	// LatestOnly keeps a single pending message, replaced by each publish
+	LatestOnly
+	// BlockWithTimeout waits up to Options.Timeout for room before dropping.
+	// The wait happens on a goroutine of the subscription so Publish never
+	// blocks; one more message can queue behind the waiting one, further
+	// ones are dropped.
+	BlockWithTimeout
+)
+
+func (p Policy) String() string {
+	switch p {
+	case DropNewest:
+		return "DropNewest"
+	case DropOldest:
+		return "DropOldest"
+	case LatestOnly:
+		return "LatestOnly"
+	case BlockWithTimeout:
+		return "BlockWithTimeout"
+	default:
+		return "Unknown"
+	}
+}
+
+const (
+	DefaultBufferSize = 10
+	DefaultTimeout    = 100 * time.Millisecond
+)
+
+// Options configures a single subscription
+type Options struct {
+	Policy     Policy
+	BufferSize int
+	// Timeout is only used by BlockWithTimeout
+	Timeout time.Duration
+}
+
+// DefaultOptions drops new messages once 10 are pending
+func DefaultOptions() Options {
+	return Options{
+		Policy:     DropNewest,
+		BufferSize: DefaultBufferSize,
+		Timeout:    DefaultTimeout,
+	}
+}
+
+// Stats counts what happened to the messages published to a subscription
+type Stats struct {
+	// Delivered messages reached the subscriber's buffer and were not evicted
+	Delivered uint64
+	// Dropped messages were discarded or evicted by the policy
+	Dropped uint64
+}
+
+// Subscription is one subscriber of a Hub
+type Subscription[T any] struct {
+	ch      chan T
+	options Options
+	filter  func(T) bool
+
+	sendMu    sync.Mutex
+	delivered atomic.Uint64
+	dropped   atomic.Uint64
+
+	// done is closed by Unsubscribe
+	done chan struct{}
+
+	// BlockWithTimeout only: messages waiting for room in ch, how many of
+	// them the delivery goroutine still holds, and when it exits
+	waiting chan T
+	pending atomic.Int32
+	stopped chan struct{}
+}
+
+// C returns the channel messages are delivered on
+func (s *Subscription[T]) C() <-chan T {
+	return s.ch
+}
+
+// Options returns the effective options of the subscription
+func (s *Subscription[T]) Options() Options {
+	return s.options
+}
+
+// Stats returns the delivered and dropped counters
+func (s *Subscription[T]) Stats() Stats {
+	return Stats{
+		Delivered: s.delivered.Load(),
+		Dropped:   s.dropped.Load(),
+	}
+}
+
+func (s *Subscription[T]) send(msg T) {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+
+	// A message still waiting for room goes first
+	if s.options.Policy == BlockWithTimeout && s.pending.Load() > 0 {
+		s.wait(msg)
+		return
+	}
+
+	select {
+	case s.ch <- msg:
+		s.delivered.Add(1)
+		return
+	default:
+	}
+
+	switch s.options.Policy {
+	case DropOldest, LatestOnly:
+		for {
+			select {
+			case s.ch <- msg:
+				s.delivered.Add(1)
+				return
+			default:
+			}
+			select {
+			case <-s.ch:
+				s.delivered.Add(^uint64(0))
+				s.dropped.Add(1)
+			default:
+			}
+		}
+
+	case BlockWithTimeout:
+		s.wait(msg)
+
+	default:
+		s.dropped.Add(1)
+	}
+}
+
+// wait hands msg to the delivery goroutine, or drops it if another message
+// is already waiting
+func (s *Subscription[T]) wait(msg T) {
+	s.pending.Add(1)
+	select {
+	case s.waiting <- msg:
+	default:
+		s.pending.Add(-1)
+		s.dropped.Add(1)
+	}
+}
+
+// deliver moves waiting messages to ch, dropping each one that finds no room
+// within the timeout. It owns ch and closes it on Unsubscribe.
+func (s *Subscription[T]) deliver() {
+	defer close(s.stopped)
+	defer close(s.ch)
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case msg := <-s.waiting:
+			timer := time.NewTimer(s.options.Timeout)
+			select {
+			case s.ch <- msg:
+				s.delivered.Add(1)
+			case <-timer.C:
+				s.dropped.Add(1)
+			case <-s.done:
+				timer.Stop()
+				return
+			}
+			timer.Stop()
+			s.pending.Add(-1)
+		}
+	}
+}
+
+// close ends the subscription and closes ch
+func (s *Subscription[T]) close() {
+	close(s.done)
+	if s.stopped != nil {
+		<-s.stopped
+		return
+	}
+	close(s.ch)
+}
+
+// Hub fans messages out to subscribers, applying each one's policy
+type Hub[T any] struct {
+	mu   sync.RWMutex
+	subs []*Subscription[T]
+}
+
+// NewHub creates a hub without subscribers
+func NewHub[T any]() *Hub[T] {
+	return &Hub[T]{subs: make([]*Subscription[T], 0)}
+}
+
+// Subscribe adds a subscriber. Invalid options fall back to the defaults;
+// LatestOnly always uses a buffer of one.
+func (h *Hub[T]) Subscribe(options Options) *Subscription[T] {
+	return h.SubscribeFiltered(options, nil)
+}
+
+// SubscribeFiltered adds a subscriber that only receives the messages
+// accepted by filter (nil accepts all). Rejected messages are not counted
+// as delivered or dropped.
+func (h *Hub[T]) SubscribeFiltered(options Options, filter func(T) bool) *Subscription[T] {
+	defaults := DefaultOptions()
+	if options.BufferSize <= 0 {
+		options.BufferSize = defaults.BufferSize
+	}
+	if options.Policy == LatestOnly {
+		options.BufferSize = 1
+	}
+	if options.Policy == BlockWithTimeout && options.Timeout <= 0 {
+		options.Timeout = defaults.Timeout
+	}
+
+	sub := &Subscription[T]{
+		ch:      make(chan T, options.BufferSize),
+		options: options,
+		filter:  filter,
+		done:    make(chan struct{}),
+	}
+	if options.Policy == BlockWithTimeout {
+		sub.waiting = make(chan T, 1)
+		sub.stopped = make(chan struct{})
+		go sub.deliver()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.subs = append(h.subs, sub)
+	return sub
+}
+
+// SubscribeContext adds a filtered subscriber that is unsubscribed, closing
+// its channel, when ctx is cancelled
+func (h *Hub[T]) SubscribeContext(ctx context.Context, options Options, filter func(T) bool) *Subscription[T] {
+	sub := h.SubscribeFiltered(options, filter)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			h.Unsubscribe(sub.ch)
+		case <-sub.done:
+		}
+	}()
+
+	return sub
+}
+
+// Unsubscribe removes the subscriber owning ch and closes its channel
+func (h *Hub[T]) Unsubscribe(ch <-chan T) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i, sub := range h.subs {
+		if sub.ch == ch {
+			h.subs = append(h.subs[:i], h.subs[i+1:]...)
+			sub.close()
+			break
+		}
+	}
+}
+
+// Lookup returns the subscription owning ch
+func (h *Hub[T]) Lookup(ch <-chan T) (*Subscription[T], bool) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, sub := range h.subs {
+		if sub.ch == ch {
+			return sub, true
+		}
+	}
+	return nil, false
+}
+
+// Publish delivers msg to every subscriber
+func (h *Hub[T]) Publish(msg T) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, sub := range h.subs {
+		if sub.filter != nil && !sub.filter(msg) {
+			continue
+		}
+		sub.send(msg)
+	}
+}
+
+// Len returns the number of subscribers
+func (h *Hub[T]) Len() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subs)
+}
@@ -0,0 +1,171 @@
+package sharedmemory
+
+import (
+	"fmt"
+	"unsafe"
+
+	"RaceAll/internal/errors"
+)
+
+// Game identifies which game's page layout a reader expects
+type Game int
+
+const (
+	// GameAuto detects the game from the SMVersion of the static page
+	GameAuto Game = iota
+	// GameACC is Assetto Corsa Competizione
+	GameACC
+	// GameAC is the original Assetto Corsa
+	GameAC
+)
+
+func (g Game) String() string {
+	switch g {
+	case GameAuto:
+		return "Auto"
+	case GameACC:
+		return "ACC"
+	case GameAC:
+		return "AC"
+	default:
+		return "Unknown"
+	}
+}
+
+// Layout describes the size of each page for a given shared memory version
+type Layout struct {
+	Game         Game
+	SMVersion    string
+	PhysicsSize  int
+	GraphicsSize int
+	StaticSize   int
+}
+
+// AC1 pages are shorter than ACC's. Physics and Static are a prefix of the
+// ACC layout; Graphics diverges after NormalizedCarPosition (see acGraphics).
+var (
+	acPhysicsPageSize  = int(unsafe.Offsetof(Physics{}.P2PActivations))
+	acGraphicsPageSize = int(unsafe.Sizeof(acGraphics{}))
+	acStaticPageSize   = int(unsafe.Offsetof(Static{}.IsOnline))
+)
+
+// knownLayouts lists the SM versions whose layout matches our structs
+var knownLayouts = []Layout{
+	{GameACC, "1.9", PhysicsPageFileSize, GraphicsPageFileSize, StaticPageFileSize},
+	{GameACC, "1.8", PhysicsPageFileSize, GraphicsPageFileSize, StaticPageFileSize},
+	{GameAC, "1.7", acPhysicsPageSize, acGraphicsPageSize, acStaticPageSize},
+}
+
+// KnownLayouts returns the supported shared memory versions
+func KnownLayouts() []Layout {
+	layouts := make([]Layout, len(knownLayouts))
+	copy(layouts, knownLayouts)
+	return layouts
+}
+
+// defaultLayout is used while the game has not filled the static page yet
+func defaultLayout(game Game) Layout {
+	for _, layout := range knownLayouts {
+		if layout.Game == game {
+			layout.SMVersion = ""
+			return layout
+		}
+	}
+	return defaultLayout(GameACC)
+}
+
+// ResolveLayout picks the layout for the SMVersion found in the static page.
+// An empty version means the game is still loading and the default layout of
+// the requested game is assumed. An unknown version, or one that belongs to
+// a different game than requested, is reported as ErrSharedMemoryIncompatible.
+func ResolveLayout(game Game, smVersion string) (Layout, error) {
+	if smVersion == "" {
+		return defaultLayout(game), nil
+	}
+
+	for _, layout := range knownLayouts {
+		if layout.SMVersion != smVersion {
+			continue
+		}
+		if game != GameAuto && layout.Game != game {
+			return Layout{}, NewErrorWithContext("ResolveLayout", errors.ErrSharedMemoryIncompatible,
+				fmt.Sprintf("SM version %s is %s, expected %s", smVersion, layout.Game, game))
+		}
+		return layout, nil
+	}
+
+	return Layout{}, NewErrorWithContext("ResolveLayout", errors.ErrSharedMemoryIncompatible,
+		fmt.Sprintf("unknown SM version %q", smVersion))
+}
+
+// acGraphics is the AC1 graphics page (SM 1.7)
+type acGraphics struct {
+	PacketId              int32
+	Status                ACStatus
+	Session               ACSessionType
+	CurrentTime           [15]uint16
+	LastTime              [15]uint16
+	BestTime              [15]uint16
+	Split                 [15]uint16
+	CompletedLaps         int32
+	Position              int32
+	ICurrentTime          int32
+	ILastTime             int32
+	IBestTime             int32
+	SessionTimeLeft       float32
+	DistanceTraveled      float32
+	IsInPit               int32
+	CurrentSectorIndex    int32
+	LastSectorTime        int32
+	NumberOfLaps          int32
+	TyreCompound          [33]uint16
+	ReplayTimeMultiplier  float32
+	NormalizedCarPosition float32
+	CarCoordinates        [3]float32
+	PenaltyTime           float32
+	Flag                  ACFlagType
+	IdealLineOn           int32
+	IsInPitLane           int32
+	SurfaceGrip           float32
+	MandatoryPitDone      int32
+	WindSpeed             float32
+	WindDirection         float32
+}
+
+// toGraphics maps the AC1 page onto the ACC struct. AC1 only exposes the
+// player's car, which becomes the single active car.
+func (g *acGraphics) toGraphics() *Graphics {
+	return &Graphics{
+		PacketId:              g.PacketId,
+		Status:                g.Status,
+		Session:               g.Session,
+		CurrentTime:           g.CurrentTime,
+		LastTime:              g.LastTime,
+		BestTime:              g.BestTime,
+		Split:                 g.Split,
+		CompletedLaps:         g.CompletedLaps,
+		Position:              g.Position,
+		ICurrentTime:          g.ICurrentTime,
+		ILastTime:             g.ILastTime,
+		IBestTime:             g.IBestTime,
+		SessionTimeLeft:       g.SessionTimeLeft,
+		DistanceTraveled:      g.DistanceTraveled,
+		IsInPit:               g.IsInPit,
+		CurrentSectorIndex:    g.CurrentSectorIndex,
+		LastSectorTime:        g.LastSectorTime,
+		NumberOfLaps:          g.NumberOfLaps,
+		TyreCompound:          g.TyreCompound,
+		ReplayTimeMultiplier:  g.ReplayTimeMultiplier,
+		NormalizedCarPosition: g.NormalizedCarPosition,
+		ActiveCars:            1,
+		CarCoordinates:        [60][3]float32{g.CarCoordinates},
+		PenaltyTime:           g.PenaltyTime,
+		Flag:                  g.Flag,
+		IdealLineOn:           g.IdealLineOn,
+		IsInPitLane:           g.IsInPitLane,
+		SurfaceGrip:           g.SurfaceGrip,
+		MandatoryPitDone:      g.MandatoryPitDone,
+		WindSpeed:             g.WindSpeed,
+		WindDirection:         g.WindDirection,
+	}
+}
@@ -0,0 +1,71 @@
+//go:build linux
+
+package sharedmemory
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"RaceAll/internal/errors"
+)
+
+// DefaultPageLocation is where Proton/Wine bridges expose the ACC pages
+const DefaultPageLocation = "/dev/shm"
+
+// mappedPage is an mmap of a file-backed page
+type mappedPage struct {
+	data []byte
+}
+
+func openPage(location, name string, size int) (*mappedPage, error) {
+	file, err := os.Open(filepath.Join(location, name))
+	if err != nil {
+		return nil, errors.ErrSharedMemoryNotFound
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil || info.Size() < int64(size) {
+		return nil, errors.ErrSharedMemoryMap
+	}
+
+	data, err := syscall.Mmap(int(file.Fd()), 0, size, syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, errors.ErrSharedMemoryMap
+	}
+
+	return &mappedPage{data: data}, nil
+}
+
+// createPage creates (or resizes) the page file and maps it read-write
+func createPage(location, name string, size int) (*mappedPage, error) {
+	file, err := os.OpenFile(filepath.Join(location, name), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, errors.ErrSharedMemoryAccess
+	}
+	defer file.Close()
+
+	if err := file.Truncate(int64(size)); err != nil {
+		return nil, errors.ErrSharedMemoryMap
+	}
+
+	data, err := syscall.Mmap(int(file.Fd()), 0, size, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, errors.ErrSharedMemoryMap
+	}
+
+	return &mappedPage{data: data}, nil
+}
+
+// removePage deletes a page file created by createPage
+func removePage(location, name string) {
+	os.Remove(filepath.Join(location, name))
+}
+
+func (p *mappedPage) close() {
+	if p.data != nil {
+		syscall.Munmap(p.data)
+		p.data = nil
+	}
+}
@@ -0,0 +1,27 @@
+//go:build !windows && !linux
+
+package sharedmemory
+
+import (
+	"RaceAll/internal/errors"
+)
+
+// DefaultPageLocation is unused on platforms without a mapping backend
+const DefaultPageLocation = ""
+
+// mappedPage is never created on platforms without a mapping backend
+type mappedPage struct {
+	data []byte
+}
+
+func openPage(location, name string, size int) (*mappedPage, error) {
+	return nil, errors.ErrSharedMemoryNotFound
+}
+
+func createPage(location, name string, size int) (*mappedPage, error) {
+	return nil, errors.ErrSharedMemoryMap
+}
+
+func removePage(location, name string) {}
+
+func (p *mappedPage) close() {}
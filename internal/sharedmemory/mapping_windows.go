@@ -0,0 +1,157 @@
+//go:build windows
+
+package sharedmemory
+
+import (
+	"syscall"
+	"unsafe"
+
+	"RaceAll/internal/errors"
+)
+
+// DefaultPageLocation is the namespace ACC creates its file mappings in
+const DefaultPageLocation = "Local\\"
+
+var (
+	kernel32              = syscall.NewLazyDLL("kernel32.dll")
+	procOpenFileMapping   = kernel32.NewProc("OpenFileMappingW")
+	procCreateFileMapping = kernel32.NewProc("CreateFileMappingW")
+	procMapViewOfFile     = kernel32.NewProc("MapViewOfFile")
+	procUnmapViewOfFile   = kernel32.NewProc("UnmapViewOfFile")
+	procCloseHandle       = kernel32.NewProc("CloseHandle")
+)
+
+const (
+	FILE_MAP_WRITE = 0x0002
+	FILE_MAP_READ  = 0x0004
+	PAGE_READWRITE = 0x04
+
+	INVALID_HANDLE_VALUE = ^uintptr(0)
+)
+
+// mappedPage is a view of a named Windows file mapping
+type mappedPage struct {
+	handle syscall.Handle
+	addr   uintptr
+	data   []byte
+}
+
+func openPage(location, name string, size int) (*mappedPage, error) {
+	handle, err := openFileMapping(location + name)
+	if err != nil {
+		return nil, errors.ErrSharedMemoryNotFound
+	}
+
+	addr, err := mapViewOfFile(handle, FILE_MAP_READ, size)
+	if err != nil {
+		closeHandle(handle)
+		return nil, errors.ErrSharedMemoryMap
+	}
+
+	return &mappedPage{
+		handle: handle,
+		addr:   addr,
+		data:   unsafe.Slice((*byte)(unsafe.Pointer(addr)), size),
+	}, nil
+}
+
+// createPage creates a pagefile-backed named mapping and maps it read-write.
+// The mapping lives until the last handle to it is closed.
+func createPage(location, name string, size int) (*mappedPage, error) {
+	handle, err := createFileMapping(location+name, size)
+	if err != nil {
+		return nil, err
+	}
+
+	addr, err := mapViewOfFile(handle, FILE_MAP_WRITE, size)
+	if err != nil {
+		closeHandle(handle)
+		return nil, errors.ErrSharedMemoryMap
+	}
+
+	return &mappedPage{
+		handle: handle,
+		addr:   addr,
+		data:   unsafe.Slice((*byte)(unsafe.Pointer(addr)), size),
+	}, nil
+}
+
+// removePage is a no-op, closing the handle already removes the mapping
+func removePage(location, name string) {}
+
+func (p *mappedPage) close() {
+	if p.addr != 0 {
+		unmapViewOfFile(p.addr)
+		p.addr = 0
+	}
+	if p.handle != 0 {
+		closeHandle(p.handle)
+		p.handle = 0
+	}
+	p.data = nil
+}
+
+func openFileMapping(name string) (syscall.Handle, error) {
+	namePtr, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return 0, err
+	}
+
+	handle, _, err := procOpenFileMapping.Call(
+		uintptr(FILE_MAP_READ),
+		0,
+		uintptr(unsafe.Pointer(namePtr)),
+	)
+
+	if handle == 0 {
+		return 0, errors.ErrSharedMemoryAccess
+	}
+
+	return syscall.Handle(handle), nil
+}
+
+func createFileMapping(name string, size int) (syscall.Handle, error) {
+	namePtr, err := syscall.UTF16PtrFromString(name)
+	if err != nil {
+		return 0, err
+	}
+
+	handle, _, _ := procCreateFileMapping.Call(
+		INVALID_HANDLE_VALUE,
+		0,
+		uintptr(PAGE_READWRITE),
+		0,
+		uintptr(size),
+		uintptr(unsafe.Pointer(namePtr)),
+	)
+
+	if handle == 0 {
+		return 0, errors.ErrSharedMemoryAccess
+	}
+
+	return syscall.Handle(handle), nil
+}
+
+func mapViewOfFile(handle syscall.Handle, access uint32, size int) (uintptr, error) {
+	addr, _, err := procMapViewOfFile.Call(
+		uintptr(handle),
+		uintptr(access),
+		0,
+		0,
+		uintptr(size),
+	)
+
+	if addr == 0 {
+		return 0, err
+	}
+
+	return addr, nil
+}
+
+func unmapViewOfFile(addr uintptr) {
+	procUnmapViewOfFile.Call(addr)
+}
+
+func closeHandle(handle syscall.Handle) {
+	procCloseHandle.Call(uintptr(handle))
+}
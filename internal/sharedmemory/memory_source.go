@@ -0,0 +1,104 @@
+package sharedmemory
+
+import (
+	"sync"
+
+	"RaceAll/internal/errors"
+)
+
+// MemorySource is an in-memory Source, useful for tests and tooling
+// that want to push pages directly into a Service
+type MemorySource struct {
+	mu        sync.RWMutex
+	connected bool
+
+	physics  Physics
+	graphics Graphics
+	static   Static
+}
+
+var _ Source = (*MemorySource)(nil)
+
+// NewMemorySource creates an empty in-memory source
+func NewMemorySource() *MemorySource {
+	return &MemorySource{}
+}
+
+func (m *MemorySource) Connect() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.connected = true
+	return nil
+}
+
+func (m *MemorySource) Disconnect() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.connected = false
+}
+
+func (m *MemorySource) IsConnected() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.connected
+}
+
+// SetPhysics replaces the physics page
+func (m *MemorySource) SetPhysics(physics Physics) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.physics = physics
+}
+
+// SetGraphics replaces the graphics page
+func (m *MemorySource) SetGraphics(graphics Graphics) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.graphics = graphics
+}
+
+// SetStatic replaces the static page
+func (m *MemorySource) SetStatic(static Static) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.static = static
+}
+
+// ReadPhysics returns a copy of the current physics page
+func (m *MemorySource) ReadPhysics() (*Physics, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if !m.connected {
+		return nil, NewError("ReadPhysics", errors.ErrNotConnected)
+	}
+
+	physics := m.physics
+	return &physics, nil
+}
+
+// ReadGraphics returns a copy of the current graphics page
+func (m *MemorySource) ReadGraphics() (*Graphics, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if !m.connected {
+		return nil, NewError("ReadGraphics", errors.ErrNotConnected)
+	}
+
+	graphics := m.graphics
+	return &graphics, nil
+}
+
+// ReadStatic returns a copy of the current static page
+func (m *MemorySource) ReadStatic() (*Static, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if !m.connected {
+		return nil, NewError("ReadStatic", errors.ErrNotConnected)
+	}
+
+	static := m.static
+	return &static, nil
+}
@@ -0,0 +1,566 @@
+package sharedmemory
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"RaceAll/internal/errors"
+)
+
+// Player reads a recording back. It can be iterated frame by frame with
+// Next, or used as a Source so a Service replays it through Subscribe at
+// any speed or step by step.
+type Player struct {
+	mu     sync.Mutex
+	rs     io.ReadSeeker
+	closer io.Closer
+
+	header RecordingHeader
+	index  []recordingIndexEntry
+
+	// Frames of the currently decoded block
+	blockIdx int
+	frames   []RecordedFrame
+	frameIdx int
+
+	// Playback clock for the Source implementation
+	connected bool
+	current   *RecordedFrame
+	pending   *RecordedFrame
+	finished  bool
+	speed     float64
+	paused    bool
+	position  time.Duration
+	wallStart time.Time
+}
+
+var _ Source = (*Player)(nil)
+
+// NewPlayer opens a recording from any seekable reader
+func NewPlayer(rs io.ReadSeeker) (*Player, error) {
+	p := &Player{rs: rs, speed: 1, blockIdx: -1}
+	if c, ok := rs.(io.Closer); ok {
+		p.closer = c
+	}
+
+	header, err := readRecordingHeader(rs)
+	if err != nil {
+		return nil, NewError("NewPlayer", err)
+	}
+	p.header = header
+
+	dataStart, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, NewError("NewPlayer", err)
+	}
+
+	if err := p.loadIndex(dataStart); err != nil {
+		return nil, NewError("NewPlayer", err)
+	}
+
+	return p, nil
+}
+
+// OpenRecording opens a recording file
+func OpenRecording(path string) (*Player, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, NewError("OpenRecording", err)
+	}
+
+	p, err := NewPlayer(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+// Header returns the recording header
+func (p *Player) Header() RecordingHeader {
+	return p.header
+}
+
+// Duration returns the offset of the last recorded frame
+func (p *Player) Duration() time.Duration {
+	if len(p.index) == 0 {
+		return 0
+	}
+	return time.Duration(p.index[len(p.index)-1].LastOffset)
+}
+
+// FrameCount returns the number of frames in the recording
+func (p *Player) FrameCount() int {
+	count := 0
+	for _, entry := range p.index {
+		count += int(entry.FrameCount)
+	}
+	return count
+}
+
+// Next returns the next frame, or io.EOF at the end of the recording
+func (p *Player) Next() (*RecordedFrame, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.next()
+}
+
+// Seek positions the player on the last frame at or before offset, so the
+// following Next returns that frame
+func (p *Player) Seek(offset time.Duration) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.seek(offset); err != nil {
+		return NewError("Seek", err)
+	}
+
+	if p.connected {
+		p.position = offset
+		p.wallStart = time.Now()
+		p.current = nil
+		p.pending = nil
+		p.finished = false
+		p.advance()
+	}
+	return nil
+}
+
+// Close releases the underlying file
+func (p *Player) Close() error {
+	if p.closer != nil {
+		return p.closer.Close()
+	}
+	return nil
+}
+
+// SetSpeed sets the playback rate (1 is real time, 4 is four times faster)
+func (p *Player) SetSpeed(speed float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if speed <= 0 {
+		speed = 1
+	}
+	p.position = p.clock()
+	p.wallStart = time.Now()
+	p.speed = speed
+}
+
+// Pause freezes the playback clock
+func (p *Player) Pause() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.paused {
+		p.position = p.clock()
+		p.paused = true
+	}
+}
+
+// Resume restarts the playback clock after Pause or Step
+func (p *Player) Resume() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.paused {
+		p.wallStart = time.Now()
+		p.paused = false
+	}
+}
+
+// Step pauses playback and advances exactly one frame
+func (p *Player) Step() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.paused {
+		p.position = p.clock()
+		p.paused = true
+	}
+	p.advance()
+	if p.pending != nil {
+		p.position = p.pending.Offset
+		p.advance()
+	}
+}
+
+// Position returns the offset of the frame currently served as a Source
+func (p *Player) Position() time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.current == nil {
+		return 0
+	}
+	return p.current.Offset
+}
+
+// Finished returns true once playback has served the last frame
+func (p *Player) Finished() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.finished
+}
+
+// Connect starts playback from the beginning of the recording
+func (p *Player) Connect() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.connected {
+		return nil
+	}
+
+	if err := p.seek(0); err != nil {
+		return NewError("Connect", err)
+	}
+
+	p.connected = true
+	p.current = nil
+	p.pending = nil
+	p.finished = false
+	p.position = 0
+	p.wallStart = time.Now()
+	p.advance()
+
+	if p.current == nil {
+		p.connected = false
+		return NewError("Connect", errors.ErrInvalidRecording)
+	}
+	return nil
+}
+
+func (p *Player) Disconnect() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.connected = false
+}
+
+func (p *Player) IsConnected() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.connected
+}
+
+// ReadPhysics returns the physics page at the playback position. It is the
+// only read that moves playback forward, so the Graphics and Static read
+// right after it belong to the same frame.
+func (p *Player) ReadPhysics() (*Physics, error) {
+	data, err := p.currentData("ReadPhysics", true)
+	if err != nil {
+		return nil, err
+	}
+	return data.Physics, nil
+}
+
+// ReadGraphics returns the graphics page at the playback position
+func (p *Player) ReadGraphics() (*Graphics, error) {
+	data, err := p.currentData("ReadGraphics", false)
+	if err != nil {
+		return nil, err
+	}
+	if data.Graphics == nil {
+		return &Graphics{}, nil
+	}
+	return data.Graphics, nil
+}
+
+// ReadStatic returns the static page at the playback position
+func (p *Player) ReadStatic() (*Static, error) {
+	data, err := p.currentData("ReadStatic", false)
+	if err != nil {
+		return nil, err
+	}
+	if data.Static == nil {
+		return &Static{}, nil
+	}
+	return data.Static, nil
+}
+
+func (p *Player) currentData(op string, advance bool) (TelemetryData, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.connected || p.current == nil {
+		return TelemetryData{}, NewError(op, errors.ErrNotConnected)
+	}
+
+	if advance {
+		p.advance()
+	}
+	return p.current.Data, nil
+}
+
+// clock returns the playback position derived from wall time
+func (p *Player) clock() time.Duration {
+	if p.paused {
+		return p.position
+	}
+	elapsed := time.Since(p.wallStart)
+	return p.position + time.Duration(float64(elapsed)*p.speed)
+}
+
+// advance moves current forward to the last frame not later than the clock
+func (p *Player) advance() {
+	if p.current == nil {
+		frame, err := p.next()
+		if err != nil {
+			p.finished = true
+			return
+		}
+		p.current = frame
+	}
+
+	target := p.clock()
+	for {
+		if p.pending == nil {
+			frame, err := p.next()
+			if err != nil {
+				p.finished = true
+				return
+			}
+			p.pending = frame
+		}
+
+		if p.pending.Offset > target {
+			return
+		}
+		p.current = p.pending
+		p.pending = nil
+	}
+}
+
+func (p *Player) next() (*RecordedFrame, error) {
+	for p.frameIdx >= len(p.frames) {
+		if p.blockIdx+1 >= len(p.index) {
+			return nil, io.EOF
+		}
+		if err := p.loadBlock(p.blockIdx + 1); err != nil {
+			return nil, err
+		}
+	}
+
+	frame := p.frames[p.frameIdx]
+	p.frameIdx++
+	return &frame, nil
+}
+
+func (p *Player) seek(offset time.Duration) error {
+	if len(p.index) == 0 {
+		return errors.ErrInvalidRecording
+	}
+
+	target := offset.Nanoseconds()
+	block := sort.Search(len(p.index), func(i int) bool {
+		return p.index[i].FirstOffset > target
+	}) - 1
+	if block < 0 {
+		block = 0
+	}
+
+	if err := p.loadBlock(block); err != nil {
+		return err
+	}
+
+	for p.frameIdx+1 < len(p.frames) && p.frames[p.frameIdx+1].Offset <= offset {
+		p.frameIdx++
+	}
+	return nil
+}
+
+func (p *Player) loadBlock(i int) error {
+	entry := p.index[i]
+	if entry.FrameCount > recordingBlockFrames {
+		return errors.ErrInvalidRecording
+	}
+	if _, err := p.rs.Seek(entry.FileOffset, io.SeekStart); err != nil {
+		return err
+	}
+
+	_, compressed, err := readRecordingBlock(p.rs)
+	if err != nil {
+		return err
+	}
+
+	raw, err := io.ReadAll(io.LimitReader(flate.NewReader(bytes.NewReader(compressed)), int64(maxRecordingBlockRawSize)+1))
+	if err != nil || len(raw) > maxRecordingBlockRawSize {
+		return errors.ErrInvalidRecording
+	}
+
+	// Sequence numbers continue across blocks
+	var sequence uint64
+	for _, prev := range p.index[:i] {
+		sequence += uint64(prev.FrameCount)
+	}
+
+	frames := make([]RecordedFrame, 0, entry.FrameCount)
+	r := bytes.NewReader(raw)
+	var state TelemetryData
+	for n := uint32(0); n < entry.FrameCount; n++ {
+		frame, err := decodeRecordedFrame(r, state)
+		if err != nil {
+			return errors.ErrInvalidRecording
+		}
+		sequence++
+		frame.Data.Sequence = sequence
+		state = frame.Data
+		frames = append(frames, frame)
+	}
+
+	p.blockIdx = i
+	p.frames = frames
+	p.frameIdx = 0
+	return nil
+}
+
+func decodeRecordedFrame(r io.Reader, prev TelemetryData) (RecordedFrame, error) {
+	var frame RecordedFrame
+
+	var offset int64
+	if err := binary.Read(r, binary.LittleEndian, &offset); err != nil {
+		return frame, err
+	}
+	frame.Offset = time.Duration(offset)
+
+	var flags uint8
+	if err := binary.Read(r, binary.LittleEndian, &flags); err != nil {
+		return frame, err
+	}
+
+	frame.Data = TelemetryData{
+		Graphics: prev.Graphics,
+		Static:   prev.Static,
+	}
+
+	physics := &Physics{}
+	if err := binary.Read(r, binary.LittleEndian, physics); err != nil {
+		return frame, err
+	}
+	frame.Data.Physics = physics
+
+	if flags&frameGraphics != 0 {
+		graphics := &Graphics{}
+		if err := binary.Read(r, binary.LittleEndian, graphics); err != nil {
+			return frame, err
+		}
+		frame.Data.Graphics = graphics
+	}
+
+	if flags&frameStatic != 0 {
+		static := &Static{}
+		if err := binary.Read(r, binary.LittleEndian, static); err != nil {
+			return frame, err
+		}
+		frame.Data.Static = static
+	}
+
+	return frame, nil
+}
+
+// loadIndex reads the index from the footer, or rebuilds it by scanning the
+// blocks when the recording was not closed cleanly
+func (p *Player) loadIndex(dataStart int64) error {
+	end, err := p.rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	if end-dataStart >= recordingFooterSize {
+		if index, ok := p.readFooterIndex(end); ok {
+			p.index = index
+			return nil
+		}
+	}
+
+	if _, err := p.rs.Seek(dataStart, io.SeekStart); err != nil {
+		return err
+	}
+
+	offset := dataStart
+	for {
+		entry, compressed, err := readRecordingBlock(p.rs)
+		if err != nil {
+			// Index tag, EOF or a truncated trailing block ends the scan
+			break
+		}
+		entry.FileOffset = offset
+		p.index = append(p.index, entry)
+		offset += recordingBlockHeaderSize + int64(len(compressed))
+	}
+
+	return nil
+}
+
+func (p *Player) readFooterIndex(end int64) ([]recordingIndexEntry, bool) {
+	if _, err := p.rs.Seek(end-recordingFooterSize, io.SeekStart); err != nil {
+		return nil, false
+	}
+
+	var indexOffset int64
+	if err := binary.Read(p.rs, binary.LittleEndian, &indexOffset); err != nil {
+		return nil, false
+	}
+	magic := make([]byte, len(recordingIndexMagic))
+	if _, err := io.ReadFull(p.rs, magic); err != nil || string(magic) != recordingIndexMagic {
+		return nil, false
+	}
+
+	if _, err := p.rs.Seek(indexOffset, io.SeekStart); err != nil {
+		return nil, false
+	}
+
+	var tag [1]byte
+	var count uint32
+	if _, err := io.ReadFull(p.rs, tag[:]); err != nil || tag[0] != recordingIndexTag {
+		return nil, false
+	}
+	if err := binary.Read(p.rs, binary.LittleEndian, &count); err != nil {
+		return nil, false
+	}
+	if int64(count)*recordingIndexEntrySize > end-indexOffset {
+		return nil, false
+	}
+
+	index := make([]recordingIndexEntry, count)
+	if err := binary.Read(p.rs, binary.LittleEndian, index); err != nil {
+		return nil, false
+	}
+	return index, true
+}
+
+func readRecordingBlock(r io.Reader) (recordingIndexEntry, []byte, error) {
+	var entry recordingIndexEntry
+
+	var tag [1]byte
+	if _, err := io.ReadFull(r, tag[:]); err != nil {
+		return entry, nil, err
+	}
+	if tag[0] != recordingBlockTag {
+		return entry, nil, errors.ErrInvalidRecording
+	}
+
+	var compressedLen uint32
+	for _, v := range []any{&entry.FrameCount, &entry.FirstOffset, &entry.LastOffset, &compressedLen} {
+		if err := binary.Read(r, binary.LittleEndian, v); err != nil {
+			return entry, nil, err
+		}
+	}
+
+	if entry.FrameCount == 0 || entry.FrameCount > recordingBlockFrames || uint64(compressedLen) > uint64(maxRecordingBlockSize) {
+		return entry, nil, errors.ErrInvalidRecording
+	}
+
+	compressed := make([]byte, compressedLen)
+	if _, err := io.ReadFull(r, compressed); err != nil {
+		return entry, nil, err
+	}
+
+	return entry, compressed, nil
+}
@@ -1,7 +1,9 @@
 package sharedmemory
 
 import (
-	"syscall"
+	"math"
+	"runtime"
+	"sync/atomic"
 	"unsafe"
 
 	"RaceAll/internal/errors"
@@ -9,6 +11,14 @@ import (
 
 const moduleName = "shared-memory"
 
+// MaxSnapshotRetries is how many times a page copy is retried while the
+// game is writing it before the read is reported as torn
+const MaxSnapshotRetries = 5
+
+// packetInProgress is the PacketId SharedMemoryWriter stores while a page
+// body is being written; the game never uses it
+const packetInProgress = math.MinInt32
+
 func NewError(op string, err error) error {
 	return errors.NewError(moduleName, op, err)
 }
@@ -17,178 +27,175 @@ func NewErrorWithContext(op string, err error, ctx string) error {
 	return errors.NewErrorWithContext(moduleName, op, err, ctx)
 }
 
-var (
-	kernel32            = syscall.NewLazyDLL("kernel32.dll")
-	procOpenFileMapping = kernel32.NewProc("OpenFileMappingW")
-	procMapViewOfFile   = kernel32.NewProc("MapViewOfFile")
-	procUnmapViewOfFile = kernel32.NewProc("UnmapViewOfFile")
-	procCloseHandle     = kernel32.NewProc("CloseHandle")
-)
-
-const (
-	FILE_MAP_READ = 0x0004
-)
-
+// SharedMemoryReader maps the three ACC pages through the platform backend
+// (named file mappings on Windows, file-backed mmaps on Linux)
 type SharedMemoryReader struct {
-	physicsHandle  syscall.Handle
-	graphicsHandle syscall.Handle
-	staticHandle   syscall.Handle
+	location string
+	game     Game
+	layout   Layout
 
-	physicsAddr  uintptr
-	graphicsAddr uintptr
-	staticAddr   uintptr
+	physics  *mappedPage
+	graphics *mappedPage
+	static   *mappedPage
 }
 
+// NewSharedMemoryReader creates a reader for the platform default page location
 func NewSharedMemoryReader() *SharedMemoryReader {
-	return &SharedMemoryReader{}
+	return NewSharedMemoryReaderAt(DefaultPageLocation)
 }
 
-func (r *SharedMemoryReader) Connect() error {
-	var err error
+// NewSharedMemoryReaderAt creates a reader for a custom page location.
+// On Windows this is the mapping namespace prefix, on Linux the directory
+// holding the acpmf_* files.
+func NewSharedMemoryReaderAt(location string) *SharedMemoryReader {
+	return &SharedMemoryReader{location: location, game: GameAuto}
+}
 
-	// Open Physics shared memory
-	r.physicsHandle, err = openFileMapping(AccSharedMemoryName)
-	if err != nil {
-		return NewError("Connect", errors.ErrSharedMemoryNotFound)
-	}
+// SetGame selects the expected game (GameAuto by default). It applies on
+// the next Connect.
+func (r *SharedMemoryReader) SetGame(game Game) {
+	r.game = game
+}
 
-	r.physicsAddr, err = mapViewOfFile(r.physicsHandle, PhysicsPageFileSize)
+// Layout returns the page layout detected by the last successful Connect
+func (r *SharedMemoryReader) Layout() Layout {
+	return r.layout
+}
+
+// Connect detects the shared memory version and maps the three pages with
+// the sizes of that layout. An unsupported version fails with
+// ErrSharedMemoryIncompatible instead of reading misaligned data.
+func (r *SharedMemoryReader) Connect() error {
+	layout, err := r.detectLayout()
 	if err != nil {
-		r.Disconnect()
-		return NewError("Connect", errors.ErrSharedMemoryMap)
+		return err
 	}
+	r.layout = layout
 
-	// Open Graphics shared memory
-	r.graphicsHandle, err = openFileMapping(AccGraphicsMemoryName)
+	r.physics, err = openPage(r.location, PhysicsPageName, layout.PhysicsSize)
 	if err != nil {
 		r.Disconnect()
-		return NewError("Connect", errors.ErrSharedMemoryNotFound)
+		return NewErrorWithContext("Connect", err, PhysicsPageName)
 	}
 
-	r.graphicsAddr, err = mapViewOfFile(r.graphicsHandle, GraphicsPageFileSize)
+	r.graphics, err = openPage(r.location, GraphicsPageName, layout.GraphicsSize)
 	if err != nil {
 		r.Disconnect()
-		return NewError("Connect", errors.ErrSharedMemoryMap)
+		return NewErrorWithContext("Connect", err, GraphicsPageName)
 	}
 
-	// Open Static shared memory
-	r.staticHandle, err = openFileMapping(AccStaticMemoryName)
+	r.static, err = openPage(r.location, StaticPageName, layout.StaticSize)
 	if err != nil {
 		r.Disconnect()
-		return NewError("Connect", errors.ErrSharedMemoryNotFound)
+		return NewErrorWithContext("Connect", err, StaticPageName)
 	}
 
-	r.staticAddr, err = mapViewOfFile(r.staticHandle, StaticPageFileSize)
+	return nil
+}
+
+// detectLayout maps the part of the static page every game shares to read
+// its SMVersion before the pages are mapped with their real sizes
+func (r *SharedMemoryReader) detectLayout() (Layout, error) {
+	page, err := openPage(r.location, StaticPageName, acStaticPageSize)
 	if err != nil {
-		r.Disconnect()
-		return NewError("Connect", errors.ErrSharedMemoryMap)
+		return Layout{}, NewErrorWithContext("Connect", err, StaticPageName)
 	}
+	defer page.close()
 
-	return nil
+	static := &Static{}
+	copy(unsafe.Slice((*byte)(unsafe.Pointer(static)), acStaticPageSize), page.data)
+
+	return ResolveLayout(r.game, static.GetSMVersion())
 }
 
 // Disconnect closes all shared memory handles
 func (r *SharedMemoryReader) Disconnect() {
-	if r.physicsAddr != 0 {
-		unmapViewOfFile(r.physicsAddr)
-		r.physicsAddr = 0
-	}
-	if r.physicsHandle != 0 {
-		closeHandle(r.physicsHandle)
-		r.physicsHandle = 0
+	if r.physics != nil {
+		r.physics.close()
+		r.physics = nil
 	}
 
-	if r.graphicsAddr != 0 {
-		unmapViewOfFile(r.graphicsAddr)
-		r.graphicsAddr = 0
-	}
-	if r.graphicsHandle != 0 {
-		closeHandle(r.graphicsHandle)
-		r.graphicsHandle = 0
+	if r.graphics != nil {
+		r.graphics.close()
+		r.graphics = nil
 	}
 
-	if r.staticAddr != 0 {
-		unmapViewOfFile(r.staticAddr)
-		r.staticAddr = 0
-	}
-	if r.staticHandle != 0 {
-		closeHandle(r.staticHandle)
-		r.staticHandle = 0
+	if r.static != nil {
+		r.static.close()
+		r.static = nil
 	}
 }
 
+// ReadPhysics returns an owned copy of the physics page. Fields missing from
+// the detected layout (AC1) are left zero.
 func (r *SharedMemoryReader) ReadPhysics() (*Physics, error) {
-	if r.physicsAddr == 0 {
+	if r.physics == nil {
 		return nil, NewError("ReadPhysics", errors.ErrNotConnected)
 	}
 
-	physics := (*Physics)(unsafe.Pointer(r.physicsAddr))
+	physics := &Physics{}
+	if !snapshotPage(r.physics.data, unsafe.Pointer(physics), r.layout.PhysicsSize) {
+		return nil, NewError("ReadPhysics", errors.ErrSharedMemoryTornRead)
+	}
 	return physics, nil
 }
 
+// ReadGraphics returns an owned copy of the graphics page
 func (r *SharedMemoryReader) ReadGraphics() (*Graphics, error) {
-	if r.graphicsAddr == 0 {
+	if r.graphics == nil {
 		return nil, NewError("ReadGraphics", errors.ErrNotConnected)
 	}
 
-	graphics := (*Graphics)(unsafe.Pointer(r.graphicsAddr))
+	if r.layout.Game == GameAC {
+		page := &acGraphics{}
+		if !snapshotPage(r.graphics.data, unsafe.Pointer(page), r.layout.GraphicsSize) {
+			return nil, NewError("ReadGraphics", errors.ErrSharedMemoryTornRead)
+		}
+		return page.toGraphics(), nil
+	}
+
+	graphics := &Graphics{}
+	if !snapshotPage(r.graphics.data, unsafe.Pointer(graphics), r.layout.GraphicsSize) {
+		return nil, NewError("ReadGraphics", errors.ErrSharedMemoryTornRead)
+	}
 	return graphics, nil
 }
 
+// ReadStatic returns an owned copy of the static page
 func (r *SharedMemoryReader) ReadStatic() (*Static, error) {
-	if r.staticAddr == 0 {
+	if r.static == nil {
 		return nil, NewError("ReadStatic", errors.ErrNotConnected)
 	}
 
-	static := (*Static)(unsafe.Pointer(r.staticAddr))
+	static := &Static{}
+	copy(unsafe.Slice((*byte)(unsafe.Pointer(static)), r.layout.StaticSize), r.static.data)
 	return static, nil
 }
 
-// IsConnected returns true if all shared memory handles are connected
+// IsConnected returns true if all shared memory pages are mapped
 func (r *SharedMemoryReader) IsConnected() bool {
-	return r.physicsHandle != 0 && r.graphicsHandle != 0 && r.staticHandle != 0
-}
-
-
-func openFileMapping(name string) (syscall.Handle, error) {
-	namePtr, err := syscall.UTF16PtrFromString(name)
-	if err != nil {
-		return 0, err
-	}
-
-	handle, _, err := procOpenFileMapping.Call(
-		uintptr(FILE_MAP_READ),
-		0,
-		uintptr(unsafe.Pointer(namePtr)),
-	)
-
-	if handle == 0 {
-		return 0, errors.ErrSharedMemoryAccess
-	}
-
-	return syscall.Handle(handle), nil
-}
-
-func mapViewOfFile(handle syscall.Handle, size int) (uintptr, error) {
-	addr, _, err := procMapViewOfFile.Call(
-		uintptr(handle),
-		uintptr(FILE_MAP_READ),
-		0,
-		0,
-		uintptr(size),
-	)
-
-	if addr == 0 {
-		return 0, err
-	}
-
-	return addr, nil
-}
-
-func unmapViewOfFile(addr uintptr) {
-	procUnmapViewOfFile.Call(addr)
+	return r.physics != nil && r.graphics != nil && r.static != nil
 }
 
-func closeHandle(handle syscall.Handle) {
-	procCloseHandle.Call(uintptr(handle))
+// snapshotPage copies size bytes of a page that starts with a PacketId into
+// dst. The PacketId is checked before and after the copy so a frame the game
+// was still writing is discarded and copied again; a page SharedMemoryWriter
+// is writing is marked with packetInProgress and is never copied.
+func snapshotPage(page []byte, dst unsafe.Pointer, size int) bool {
+	packetID := (*int32)(unsafe.Pointer(&page[0]))
+	out := unsafe.Slice((*byte)(dst), size)
+
+	for i := 0; i < MaxSnapshotRetries; i++ {
+		before := atomic.LoadInt32(packetID)
+		if before == packetInProgress {
+			runtime.Gosched()
+			continue
+		}
+		copy(out, page[:size])
+		if atomic.LoadInt32(packetID) == before {
+			return true
+		}
+	}
+
+	return false
 }
@@ -0,0 +1,222 @@
+package sharedmemory
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"RaceAll/internal/errors"
+)
+
+// Recorder writes TelemetryData frames to a compressed, seekable recording
+type Recorder struct {
+	mu     sync.Mutex
+	out    countingWriter
+	closer io.Closer
+	closed bool
+
+	header        RecordingHeader
+	headerWritten bool
+
+	block      bytes.Buffer
+	blockCount uint32
+	blockFirst int64
+	blockLast  int64
+	index      []recordingIndexEntry
+
+	lastGraphicsID int32
+	lastStatic     Static
+}
+
+// NewRecorder creates a recorder writing to w. The header is written with
+// the first frame, taking SM version, car and track from its Static page.
+func NewRecorder(w io.Writer) *Recorder {
+	r := &Recorder{out: countingWriter{w: bufio.NewWriter(w)}}
+	if c, ok := w.(io.Closer); ok {
+		r.closer = c
+	}
+	return r
+}
+
+// CreateRecording creates (or truncates) a recording file
+func CreateRecording(path string) (*Recorder, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, NewError("CreateRecording", err)
+	}
+	return NewRecorder(file), nil
+}
+
+// WriteFrame records a frame stamped with the current time
+func (r *Recorder) WriteFrame(data TelemetryData) error {
+	return r.WriteFrameAt(data, time.Now())
+}
+
+// WriteFrameAt records a frame stamped with the given time
+func (r *Recorder) WriteFrameAt(data TelemetryData, at time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return NewError("WriteFrame", errors.ErrRecordingClosed)
+	}
+	if data.Physics == nil {
+		return NewError("WriteFrame", errors.ErrInvalidData)
+	}
+
+	if !r.headerWritten {
+		r.header = headerFromStatic(data.Static, at)
+		if err := writeRecordingHeader(&r.out, r.header); err != nil {
+			return NewError("WriteFrame", err)
+		}
+		r.headerWritten = true
+	}
+
+	offset := at.Sub(r.header.StartTime).Nanoseconds()
+	keyframe := r.blockCount == 0
+	if keyframe {
+		r.blockFirst = offset
+	}
+
+	flags := framePhysics
+	if data.Graphics != nil && (keyframe || data.Graphics.PacketId != r.lastGraphicsID) {
+		flags |= frameGraphics
+	}
+	if data.Static != nil && (keyframe || *data.Static != r.lastStatic) {
+		flags |= frameStatic
+	}
+
+	binary.Write(&r.block, binary.LittleEndian, offset)
+	r.block.WriteByte(flags)
+	binary.Write(&r.block, binary.LittleEndian, data.Physics)
+	if flags&frameGraphics != 0 {
+		binary.Write(&r.block, binary.LittleEndian, data.Graphics)
+		r.lastGraphicsID = data.Graphics.PacketId
+	}
+	if flags&frameStatic != 0 {
+		binary.Write(&r.block, binary.LittleEndian, data.Static)
+		r.lastStatic = *data.Static
+	}
+
+	r.blockCount++
+	r.blockLast = offset
+
+	if r.blockCount >= recordingBlockFrames {
+		if err := r.flushBlock(); err != nil {
+			return NewError("WriteFrame", err)
+		}
+	}
+
+	return nil
+}
+
+// Header returns the header written to the recording (zero until the first frame)
+func (r *Recorder) Header() RecordingHeader {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.header
+}
+
+// Close flushes pending frames, writes the index and closes the underlying file
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+
+	if err := r.finish(); err != nil {
+		if r.closer != nil {
+			r.closer.Close()
+		}
+		return NewError("Close", err)
+	}
+
+	if r.closer != nil {
+		if err := r.closer.Close(); err != nil {
+			return NewError("Close", err)
+		}
+	}
+	return nil
+}
+
+func (r *Recorder) finish() error {
+	if !r.headerWritten {
+		return r.out.w.Flush()
+	}
+
+	if err := r.flushBlock(); err != nil {
+		return err
+	}
+
+	indexOffset := r.out.n
+	r.out.Write([]byte{recordingIndexTag})
+	binary.Write(&r.out, binary.LittleEndian, uint32(len(r.index)))
+	for _, entry := range r.index {
+		binary.Write(&r.out, binary.LittleEndian, entry)
+	}
+	binary.Write(&r.out, binary.LittleEndian, indexOffset)
+	if _, err := io.WriteString(&r.out, recordingIndexMagic); err != nil {
+		return err
+	}
+
+	return r.out.w.Flush()
+}
+
+func (r *Recorder) flushBlock() error {
+	if r.blockCount == 0 {
+		return nil
+	}
+
+	var compressed bytes.Buffer
+	fw, err := flate.NewWriter(&compressed, flate.DefaultCompression)
+	if err != nil {
+		return err
+	}
+	if _, err := fw.Write(r.block.Bytes()); err != nil {
+		return err
+	}
+	if err := fw.Close(); err != nil {
+		return err
+	}
+
+	entry := recordingIndexEntry{
+		FileOffset:  r.out.n,
+		FirstOffset: r.blockFirst,
+		LastOffset:  r.blockLast,
+		FrameCount:  r.blockCount,
+	}
+
+	r.out.Write([]byte{recordingBlockTag})
+	binary.Write(&r.out, binary.LittleEndian, entry.FrameCount)
+	binary.Write(&r.out, binary.LittleEndian, entry.FirstOffset)
+	binary.Write(&r.out, binary.LittleEndian, entry.LastOffset)
+	binary.Write(&r.out, binary.LittleEndian, uint32(compressed.Len()))
+	if _, err := r.out.Write(compressed.Bytes()); err != nil {
+		return err
+	}
+
+	r.index = append(r.index, entry)
+	r.block.Reset()
+	r.blockCount = 0
+	return nil
+}
+
+// countingWriter tracks the file offset of everything written for the index
+type countingWriter struct {
+	w *bufio.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
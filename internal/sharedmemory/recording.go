@@ -0,0 +1,157 @@
+package sharedmemory
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+
+	"RaceAll/internal/errors"
+)
+
+// Recording file layout (little endian):
+//
+//	header:  magic "RASMREC\x00", format version, SM/AC version, car, track, start time
+//	blocks:  'B', frame count, first/last offset, compressed length, flate(frames)
+//	index:   'I', entry count, {file offset, first/last offset, frame count}...
+//	footer:  index offset, magic "RASMIDX\x00"
+//
+// The first frame of every block carries all three pages so blocks can be
+// decoded independently; later frames only carry the pages that changed.
+// A recording without index (e.g. after a crash) is still readable, the
+// player rebuilds the index by scanning the blocks.
+const (
+	recordingMagic         = "RASMREC\x00"
+	recordingIndexMagic    = "RASMIDX\x00"
+	RecordingFormatVersion = 1
+
+	recordingBlockTag = 'B'
+	recordingIndexTag = 'I'
+
+	// recordingBlockFrames is the number of frames compressed together
+	recordingBlockFrames = 512
+
+	recordingFooterSize      = 16
+	recordingBlockHeaderSize = 1 + 4 + 8 + 8 + 4
+	recordingIndexEntrySize  = 8 + 8 + 8 + 4
+
+	// maxRecordingBlockRawSize is a full block of frames carrying all three
+	// pages, the largest block the recorder writes
+	maxRecordingBlockRawSize = recordingBlockFrames * (8 + 1 + PhysicsPageFileSize + GraphicsPageFileSize + StaticPageFileSize)
+	// maxRecordingBlockSize bounds the compressed length read back for a
+	// block; flate adds at most a few bytes per stored 64 KiB chunk
+	maxRecordingBlockSize = maxRecordingBlockRawSize * 65 / 64
+)
+
+const (
+	framePhysics uint8 = 1 << iota
+	frameGraphics
+	frameStatic
+)
+
+// RecordingHeader describes the session a recording was taken from
+type RecordingHeader struct {
+	FormatVersion uint16
+	SMVersion     string
+	ACVersion     string
+	CarModel      string
+	Track         string
+	StartTime     time.Time
+}
+
+// RecordedFrame is a frame read back from a recording
+type RecordedFrame struct {
+	// Offset is the time since the start of the recording
+	Offset time.Duration
+	Data   TelemetryData
+}
+
+type recordingIndexEntry struct {
+	FileOffset  int64
+	FirstOffset int64
+	LastOffset  int64
+	FrameCount  uint32
+}
+
+func headerFromStatic(static *Static, start time.Time) RecordingHeader {
+	header := RecordingHeader{
+		FormatVersion: RecordingFormatVersion,
+		StartTime:     start,
+	}
+	if static != nil {
+		header.SMVersion = static.GetSMVersion()
+		header.ACVersion = static.GetACVersion()
+		header.CarModel = static.GetCarModel()
+		header.Track = static.GetTrack()
+	}
+	return header
+}
+
+func writeRecordingHeader(w io.Writer, header RecordingHeader) error {
+	if _, err := io.WriteString(w, recordingMagic); err != nil {
+		return err
+	}
+	if err := binary.Write(w, binary.LittleEndian, header.FormatVersion); err != nil {
+		return err
+	}
+	for _, s := range []string{header.SMVersion, header.ACVersion, header.CarModel, header.Track} {
+		if err := writeRecordingString(w, s); err != nil {
+			return err
+		}
+	}
+	return binary.Write(w, binary.LittleEndian, header.StartTime.UnixNano())
+}
+
+func readRecordingHeader(r io.Reader) (RecordingHeader, error) {
+	var header RecordingHeader
+
+	magic := make([]byte, len(recordingMagic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return header, err
+	}
+	if string(magic) != recordingMagic {
+		return header, errors.ErrInvalidRecording
+	}
+
+	if err := binary.Read(r, binary.LittleEndian, &header.FormatVersion); err != nil {
+		return header, err
+	}
+	if header.FormatVersion != RecordingFormatVersion {
+		return header, errors.ErrUnsupportedRecording
+	}
+
+	for _, s := range []*string{&header.SMVersion, &header.ACVersion, &header.CarModel, &header.Track} {
+		value, err := readRecordingString(r)
+		if err != nil {
+			return header, err
+		}
+		*s = value
+	}
+
+	var startNanos int64
+	if err := binary.Read(r, binary.LittleEndian, &startNanos); err != nil {
+		return header, err
+	}
+	header.StartTime = time.Unix(0, startNanos)
+
+	return header, nil
+}
+
+func writeRecordingString(w io.Writer, s string) error {
+	if err := binary.Write(w, binary.LittleEndian, uint16(len(s))); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, s)
+	return err
+}
+
+func readRecordingString(r io.Reader) (string, error) {
+	var length uint16
+	if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
+		return "", err
+	}
+	buf := make([]byte, length)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
@@ -3,41 +3,104 @@ package sharedmemory
 import (
 	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"RaceAll/internal/errors"
 	"RaceAll/internal/logger"
+	"RaceAll/internal/pubsub"
 )
 
 type Service struct {
-	reader               *SharedMemoryReader
+	reader               Source
 	ctx                  context.Context
 	cancel               context.CancelFunc
 	wg                   sync.WaitGroup
 	isRunning            bool
 	mu                   sync.RWMutex
-	subscribers          []chan TelemetryData
-	subMu                sync.RWMutex
-	reconnectEnabled     bool
-	reconnectDelay       time.Duration
+	subscribers          *pubsub.Hub[TelemetryData]
+	reconnectEnabled     atomic.Bool
+	reconnectDelay       atomic.Int64
 	consecutiveErrors    int
 	maxConsecutiveErrors int
+	sequence             uint64
+	recorder             *Recorder
+	recMu                sync.Mutex
+	poll                 atomic.Pointer[PollConfig]
+	stats                serviceStats
 }
 
+// PollConfig controls how often each page is read. Physics is polled at
+// PhysicsRate and only published when its PacketId advances; Graphics and
+// Static change less often and are re-read at their own intervals.
+type PollConfig struct {
+	// PhysicsRate is the physics poll frequency in Hz (max MaxPhysicsPollRate)
+	PhysicsRate      int
+	GraphicsInterval time.Duration
+	StaticInterval   time.Duration
+}
+
+// MaxPhysicsPollRate is the fastest supported physics poll rate in Hz
+const MaxPhysicsPollRate = 333
+
+// DefaultPollConfig polls physics at the full ACC physics rate
+func DefaultPollConfig() PollConfig {
+	return PollConfig{
+		PhysicsRate:      MaxPhysicsPollRate,
+		GraphicsInterval: 16 * time.Millisecond,
+		StaticInterval:   time.Second,
+	}
+}
+
+func (c PollConfig) physicsPeriod() time.Duration {
+	return time.Second / time.Duration(c.PhysicsRate)
+}
+
+// Stats counts what the read loop observed since the service was created
+type Stats struct {
+	// Published is the number of frames sent to subscribers
+	Published uint64
+	// Duplicates counts polls that saw a physics PacketId already read
+	Duplicates uint64
+	// Skipped counts physics packets the game produced between two polls
+	Skipped uint64
+}
+
+type serviceStats struct {
+	published  atomic.Uint64
+	duplicates atomic.Uint64
+	skipped    atomic.Uint64
+}
+
+// TelemetryData is one frame of the three pages. The pages are owned copies
+// shared by every subscriber, so they must be treated as read-only.
 type TelemetryData struct {
 	Physics  *Physics
 	Graphics *Graphics
 	Static   *Static
+
+	// Sequence increases by one for every frame published to subscribers;
+	// it is zero for frames returned by GetLatestData
+	Sequence uint64
 }
 
+// NewService creates a service reading the live ACC shared memory
 func NewService() *Service {
-	return &Service{
-		reader:               NewSharedMemoryReader(),
-		subscribers:          make([]chan TelemetryData, 0),
-		reconnectEnabled:     true,
-		reconnectDelay:       5 * time.Second,
+	return NewServiceWithSource(NewSharedMemoryReader())
+}
+
+// NewServiceWithSource creates a service reading from any Source backend
+func NewServiceWithSource(source Source) *Service {
+	s := &Service{
+		reader:               source,
+		subscribers:          pubsub.NewHub[TelemetryData](),
 		maxConsecutiveErrors: 10,
 	}
+	s.reconnectEnabled.Store(true)
+	s.reconnectDelay.Store(int64(5 * time.Second))
+	poll := DefaultPollConfig()
+	s.poll.Store(&poll)
+	return s
 }
 
 func (s *Service) Start() error {
@@ -51,6 +114,8 @@ func (s *Service) Start() error {
 	s.ctx, s.cancel = context.WithCancel(context.Background())
 	s.isRunning = true
 
+	// Los loops leen la configuración de forma atómica y nunca toman mu:
+	// Stop lo mantiene tomado mientras espera a que terminen
 	s.wg.Add(1)
 	go s.connectionLoop()
 
@@ -69,8 +134,15 @@ func (s *Service) connectionLoop() {
 			// Intentar conectar
 			err := s.reader.Connect()
 			if err != nil {
-				logger.Warnf("Failed to connect to shared memory: %v. Retrying in %v...", err, s.reconnectDelay)
-				time.Sleep(s.reconnectDelay)
+				reconnectDelay := time.Duration(s.reconnectDelay.Load())
+				if errors.IsIncompatibleError(err) {
+					logger.Errorf("Unsupported shared memory version: %v. Retrying in %v...", err, reconnectDelay)
+				} else {
+					logger.Warnf("Failed to connect to shared memory: %v. Retrying in %v...", err, reconnectDelay)
+				}
+				if !s.sleep(reconnectDelay) {
+					return
+				}
 				continue
 			}
 
@@ -78,21 +150,33 @@ func (s *Service) connectionLoop() {
 			s.consecutiveErrors = 0
 
 			// Iniciar el loop de lectura
-			s.readLoop()
+			s.readLoop(*s.poll.Load())
 
 			// Si llegamos aquí, el readLoop terminó (desconexión)
 			s.reader.Disconnect()
 
-			if !s.reconnectEnabled {
+			if !s.reconnectEnabled.Load() {
 				return
 			}
 
 			logger.Warn("Shared memory connection lost. Attempting to reconnect...")
-			time.Sleep(s.reconnectDelay)
+			if !s.sleep(time.Duration(s.reconnectDelay.Load())) {
+				return
+			}
 		}
 	}
 }
 
+// sleep waits for the given delay, returning false if the service was stopped
+func (s *Service) sleep(delay time.Duration) bool {
+	select {
+	case <-s.ctx.Done():
+		return false
+	case <-time.After(delay):
+		return true
+	}
+}
+
 func (s *Service) Stop() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -106,95 +190,192 @@ func (s *Service) Stop() {
 	s.reader.Disconnect()
 	s.isRunning = false
 
+	if err := s.StopRecording(); err != nil {
+		logger.Warnf("Failed to finish recording: %v", err)
+	}
+
 	logger.Info("Shared Memory service stopped")
 }
 
-func (s *Service) readLoop() {
-	ticker := time.NewTicker(16 * time.Millisecond) // ~60Hz
+func (s *Service) readLoop(poll PollConfig) {
+	ticker := time.NewTicker(poll.physicsPeriod())
 	defer ticker.Stop()
 
+	var (
+		graphics         *Graphics
+		static           *Static
+		lastGraphicsRead time.Time
+		lastStaticRead   time.Time
+		lastPhysicsID    int32
+		lastGraphicsID   int32
+		havePacket       bool
+	)
+
 	for {
 		select {
 		case <-s.ctx.Done():
 			return
-		case <-ticker.C:
+		case now := <-ticker.C:
 			physics, err := s.reader.ReadPhysics()
 			if err != nil {
-				s.consecutiveErrors++
-				if s.consecutiveErrors >= s.maxConsecutiveErrors {
-					logger.Errorf("Too many consecutive read errors, reconnecting...")
+				if s.readFailed() {
 					return
 				}
 				continue
 			}
 
-			graphics, err := s.reader.ReadGraphics()
-			if err != nil {
-				s.consecutiveErrors++
-				if s.consecutiveErrors >= s.maxConsecutiveErrors {
-					logger.Errorf("Too many consecutive read errors, reconnecting...")
-					return
+			graphicsUpdated := false
+			if graphics == nil || now.Sub(lastGraphicsRead) >= poll.GraphicsInterval {
+				g, err := s.reader.ReadGraphics()
+				if err != nil {
+					if s.readFailed() {
+						return
+					}
+					continue
 				}
-				continue
+				graphicsUpdated = graphics == nil || g.PacketId != lastGraphicsID
+				graphics, lastGraphicsRead, lastGraphicsID = g, now, g.PacketId
 			}
 
-			static, err := s.reader.ReadStatic()
-			if err != nil {
-				s.consecutiveErrors++
-				if s.consecutiveErrors >= s.maxConsecutiveErrors {
-					logger.Errorf("Too many consecutive read errors, reconnecting...")
-					return
+			if static == nil || now.Sub(lastStaticRead) >= poll.StaticInterval {
+				st, err := s.reader.ReadStatic()
+				if err != nil {
+					if s.readFailed() {
+						return
+					}
+					continue
 				}
-				continue
+				static, lastStaticRead = st, now
 			}
 
 			// Reset error counter on successful read
 			s.consecutiveErrors = 0
 
+			physicsUpdated := !havePacket || physics.PacketId != lastPhysicsID
+			if havePacket {
+				switch {
+				case physics.PacketId == lastPhysicsID:
+					s.stats.duplicates.Add(1)
+				case physics.PacketId > lastPhysicsID+1:
+					s.stats.skipped.Add(uint64(physics.PacketId - lastPhysicsID - 1))
+				}
+			}
+			lastPhysicsID, havePacket = physics.PacketId, true
+
+			// Only publish new frames; graphics keeps flowing while physics
+			// is frozen (pause, menus)
+			if !physicsUpdated && !graphicsUpdated {
+				continue
+			}
+
+			s.sequence++
+			s.stats.published.Add(1)
+
 			data := TelemetryData{
 				Physics:  physics,
 				Graphics: graphics,
 				Static:   static,
+				Sequence: s.sequence,
 			}
 
 			s.notifySubscribers(data)
+			s.recordFrame(data)
 		}
 	}
 }
 
-func (s *Service) notifySubscribers(data TelemetryData) {
-	s.subMu.RLock()
-	defer s.subMu.RUnlock()
+// readFailed counts a read error and reports whether the loop should reconnect
+func (s *Service) readFailed() bool {
+	s.consecutiveErrors++
+	if s.consecutiveErrors >= s.maxConsecutiveErrors {
+		logger.Errorf("Too many consecutive read errors, reconnecting...")
+		return true
+	}
+	return false
+}
 
-	for _, ch := range s.subscribers {
-		select {
-		case ch <- data:
-		default:
-			// Skip if channel is full
-		}
+func (s *Service) recordFrame(data TelemetryData) {
+	s.recMu.Lock()
+	defer s.recMu.Unlock()
+
+	if s.recorder == nil {
+		return
+	}
+
+	if err := s.recorder.WriteFrame(data); err != nil {
+		logger.Errorf("Failed to record frame, stopping recording: %v", err)
+		s.recorder.Close()
+		s.recorder = nil
+	}
+}
+
+// StartRecording records every frame published by the read loop to path
+func (s *Service) StartRecording(path string) error {
+	s.recMu.Lock()
+	defer s.recMu.Unlock()
+
+	if s.recorder != nil {
+		return NewError("StartRecording", errors.ErrAlreadyConnected)
+	}
+
+	recorder, err := CreateRecording(path)
+	if err != nil {
+		return err
 	}
+
+	s.recorder = recorder
+	logger.Infof("Recording shared memory to %s", path)
+	return nil
+}
+
+// StopRecording finishes the current recording, if any
+func (s *Service) StopRecording() error {
+	s.recMu.Lock()
+	defer s.recMu.Unlock()
+
+	if s.recorder == nil {
+		return nil
+	}
+
+	err := s.recorder.Close()
+	s.recorder = nil
+	return err
+}
+
+// IsRecording returns true while frames are being recorded
+func (s *Service) IsRecording() bool {
+	s.recMu.Lock()
+	defer s.recMu.Unlock()
+	return s.recorder != nil
 }
 
+func (s *Service) notifySubscribers(data TelemetryData) {
+	s.subscribers.Publish(data)
+}
+
+// Subscribe returns a channel with the default policy: 10 buffered messages,
+// newer messages are dropped while it is full
 func (s *Service) Subscribe() <-chan TelemetryData {
-	s.subMu.Lock()
-	defer s.subMu.Unlock()
+	return s.subscribers.Subscribe(pubsub.DefaultOptions()).C()
+}
 
-	ch := make(chan TelemetryData, 10)
-	s.subscribers = append(s.subscribers, ch)
-	return ch
+// SubscribeWithOptions subscribes with a custom backpressure policy and
+// buffer size. Pass sub.C() to Unsubscribe.
+func (s *Service) SubscribeWithOptions(options pubsub.Options) *pubsub.Subscription[TelemetryData] {
+	return s.subscribers.Subscribe(options)
 }
 
-func (s *Service) Unsubscribe(ch <-chan TelemetryData) {
-	s.subMu.Lock()
-	defer s.subMu.Unlock()
-
-	for i, sub := range s.subscribers {
-		if sub == ch {
-			s.subscribers = append(s.subscribers[:i], s.subscribers[i+1:]...)
-			close(sub)
-			break
-		}
+// SubscriptionStats returns the delivered/dropped counters of a subscriber
+func (s *Service) SubscriptionStats(ch <-chan TelemetryData) (pubsub.Stats, bool) {
+	sub, ok := s.subscribers.Lookup(ch)
+	if !ok {
+		return pubsub.Stats{}, false
 	}
+	return sub.Stats(), true
+}
+
+func (s *Service) Unsubscribe(ch <-chan TelemetryData) {
+	s.subscribers.Unsubscribe(ch)
 }
 
 func (s *Service) IsRunning() bool {
@@ -237,14 +418,36 @@ func (s *Service) IsConnected() bool {
 
 // SetReconnectEnabled enables or disables automatic reconnection
 func (s *Service) SetReconnectEnabled(enabled bool) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.reconnectEnabled = enabled
+	s.reconnectEnabled.Store(enabled)
 }
 
 // SetReconnectDelay sets the delay between reconnection attempts
 func (s *Service) SetReconnectDelay(delay time.Duration) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.reconnectDelay = delay
+	s.reconnectDelay.Store(int64(delay))
+}
+
+// SetPollConfig changes the poll rates; it applies on the next (re)connection.
+// Rates outside the supported range are clamped.
+func (s *Service) SetPollConfig(config PollConfig) {
+	defaults := DefaultPollConfig()
+	if config.PhysicsRate <= 0 || config.PhysicsRate > MaxPhysicsPollRate {
+		config.PhysicsRate = defaults.PhysicsRate
+	}
+	if config.GraphicsInterval <= 0 {
+		config.GraphicsInterval = defaults.GraphicsInterval
+	}
+	if config.StaticInterval <= 0 {
+		config.StaticInterval = defaults.StaticInterval
+	}
+
+	s.poll.Store(&config)
+}
+
+// Stats returns the read loop frame counters
+func (s *Service) Stats() Stats {
+	return Stats{
+		Published:  s.stats.published.Load(),
+		Duplicates: s.stats.duplicates.Load(),
+		Skipped:    s.stats.skipped.Load(),
+	}
 }
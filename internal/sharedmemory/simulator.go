@@ -0,0 +1,510 @@
+package sharedmemory
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"RaceAll/internal/errors"
+)
+
+// Scenario configures the synthetic car driven by a Simulator
+type Scenario struct {
+	Track       string
+	CarModel    string
+	Session     ACSessionType
+	TrackLength float32 // meters
+	LapTime     time.Duration
+	SectorCount int
+	MaxRpm      int32
+
+	MaxFuel   float32
+	StartFuel float32
+	// FuelPerLap is the consumption per lap (0 runs without using fuel)
+	FuelPerLap float32
+
+	// TyreWearPerLap is the fraction of tread lost per lap
+	TyreWearPerLap float32
+
+	// PitEveryLaps makes the car pit after every N completed laps (0 disables)
+	PitEveryLaps    int
+	PitStopDuration time.Duration
+
+	// RainStartLap starts rain once this many laps are completed (0 keeps it dry)
+	RainStartLap int
+
+	// PhysicsRate is the physics step frequency in Hz
+	PhysicsRate int
+
+	// TimeScale runs the simulation against the wall clock (1 is real time).
+	// Zero disables the clock, the simulation then only moves through Advance.
+	TimeScale float64
+}
+
+// DefaultScenario returns a GT3 car lapping Monza
+func DefaultScenario() Scenario {
+	return Scenario{
+		Track:           "monza",
+		CarModel:        "ferrari_296_gt3",
+		Session:         ACRace,
+		TrackLength:     5793,
+		LapTime:         108 * time.Second,
+		SectorCount:     3,
+		MaxRpm:          8000,
+		MaxFuel:         120,
+		StartFuel:       60,
+		FuelPerLap:      3.1,
+		TyreWearPerLap:  0.0008,
+		PitEveryLaps:    15,
+		PitStopDuration: 30 * time.Second,
+		PhysicsRate:     100,
+		TimeScale:       1,
+	}
+}
+
+const (
+	// Fraction of the lap after a stop that is still driven in the pit lane
+	simPitExitFraction = 0.04
+
+	// Lap time penalty and grip once the track is wet
+	simRainLapFactor = 1.08
+	simRainGrip      = 0.82
+
+	simSMVersion = "1.9"
+	simACVersion = "1.9"
+)
+
+// Simulator is a Source producing Physics and Graphics frames of a car
+// lapping a track. For a given Scenario the frames are a pure function of
+// the simulated time, so tests using Advance are fully deterministic.
+type Simulator struct {
+	mu        sync.Mutex
+	scenario  Scenario
+	connected bool
+
+	step      time.Duration
+	simTime   time.Duration
+	wallStart time.Time
+	wallBase  time.Duration
+
+	// Car state
+	lapProgress   float64
+	lapElapsed    time.Duration
+	lastSpeed     float32
+	pitRemaining  time.Duration
+	exitingPit    bool
+	bestLapMS     int32
+	rainLevel     float32
+	physicsPacket int32
+	stepCount     int
+
+	physics  Physics
+	graphics Graphics
+	static   Static
+}
+
+var _ Source = (*Simulator)(nil)
+
+// NewSimulator creates a simulator for the given scenario
+func NewSimulator(scenario Scenario) *Simulator {
+	if scenario.PhysicsRate <= 0 {
+		scenario.PhysicsRate = 100
+	}
+	if scenario.SectorCount <= 0 {
+		scenario.SectorCount = 3
+	}
+	if scenario.LapTime <= 0 {
+		scenario.LapTime = 100 * time.Second
+	}
+	if scenario.TrackLength <= 0 {
+		scenario.TrackLength = 5000
+	}
+	if scenario.FuelPerLap < 0 {
+		scenario.FuelPerLap = 0
+	}
+
+	s := &Simulator{
+		scenario: scenario,
+		step:     time.Second / time.Duration(scenario.PhysicsRate),
+	}
+	s.reset()
+	return s
+}
+
+// Scenario returns the scenario the simulator runs
+func (s *Simulator) Scenario() Scenario {
+	return s.scenario
+}
+
+// Connect starts the simulation clock
+func (s *Simulator) Connect() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.connected {
+		s.connected = true
+		s.wallStart = time.Now()
+		s.wallBase = s.simTime
+	}
+	return nil
+}
+
+func (s *Simulator) Disconnect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.connected = false
+}
+
+func (s *Simulator) IsConnected() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connected
+}
+
+// Advance moves the simulation forward by d regardless of the clock
+func (s *Simulator) Advance(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.runUntil(s.simTime + d)
+	s.wallBase += d
+}
+
+// SimTime returns the simulated time since the start
+func (s *Simulator) SimTime() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.simTime
+}
+
+func (s *Simulator) ReadPhysics() (*Physics, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.connected {
+		return nil, NewError("ReadPhysics", errors.ErrNotConnected)
+	}
+
+	s.syncClock()
+	physics := s.physics
+	return &physics, nil
+}
+
+func (s *Simulator) ReadGraphics() (*Graphics, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.connected {
+		return nil, NewError("ReadGraphics", errors.ErrNotConnected)
+	}
+
+	graphics := s.graphics
+	return &graphics, nil
+}
+
+func (s *Simulator) ReadStatic() (*Static, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.connected {
+		return nil, NewError("ReadStatic", errors.ErrNotConnected)
+	}
+
+	static := s.static
+	return &static, nil
+}
+
+func (s *Simulator) syncClock() {
+	if s.scenario.TimeScale <= 0 {
+		return
+	}
+	elapsed := time.Duration(float64(time.Since(s.wallStart)) * s.scenario.TimeScale)
+	s.runUntil(s.wallBase + elapsed)
+}
+
+func (s *Simulator) runUntil(target time.Duration) {
+	for s.simTime+s.step <= target {
+		s.simTime += s.step
+		s.tick(s.step)
+	}
+}
+
+func (s *Simulator) reset() {
+	sc := s.scenario
+
+	s.static = Static{
+		NumberOfSessions:  1,
+		NumCars:           1,
+		SectorCount:       int32(sc.SectorCount),
+		MaxRpm:            sc.MaxRpm,
+		MaxFuel:           sc.MaxFuel,
+		TrackSPlineLength: sc.TrackLength,
+		PitWindowStart:    -1,
+		PitWindowEnd:      -1,
+	}
+	PutUTF16(s.static.SMVersion[:], simSMVersion)
+	PutUTF16(s.static.ACVersion[:], simACVersion)
+	PutUTF16(s.static.CarModel[:], sc.CarModel)
+	PutUTF16(s.static.Track[:], sc.Track)
+	PutUTF16(s.static.PlayerName[:], "Synthetic")
+	PutUTF16(s.static.PlayerSurname[:], "Driver")
+	PutUTF16(s.static.PlayerNick[:], "SYN")
+	PutUTF16(s.static.DryTyresName[:], "DHE")
+	PutUTF16(s.static.WetTyresName[:], "WH")
+
+	s.physics = Physics{
+		Fuel:            sc.StartFuel,
+		IgnitionOn:      1,
+		IsEngineRunning: 1,
+		AirTemp:         22,
+		RoadTemp:        30,
+		BrakeBias:       0.56,
+		CurrentMaxRpm:   sc.MaxRpm,
+	}
+	s.physics.WheelsPressure = [4]float32{27.6, 27.6, 27.6, 27.6}
+	s.physics.TyreCoreTemperature = [4]float32{70, 70, 70, 70}
+	s.physics.PadLife = [4]float32{29, 29, 29, 29}
+	s.physics.DiscLife = [4]float32{32, 32, 32, 32}
+
+	s.graphics = Graphics{
+		Status:          ACLive,
+		Session:         sc.Session,
+		Position:        1,
+		ActiveCars:      1,
+		SurfaceGrip:     1,
+		FuelXLap:        sc.FuelPerLap,
+		TrackGripStatus: 2,
+	}
+	PutUTF16(s.graphics.TyreCompound[:], "dry_compound")
+	PutUTF16(s.graphics.TrackStatus[:], "OPTIMUM")
+}
+
+// lapTime returns the current target lap time, slower in the wet
+func (s *Simulator) lapTime() time.Duration {
+	if s.rainLevel > 0 {
+		return time.Duration(float64(s.scenario.LapTime) * simRainLapFactor)
+	}
+	return s.scenario.LapTime
+}
+
+func (s *Simulator) tick(dt time.Duration) {
+	sc := s.scenario
+	p := &s.physics
+	g := &s.graphics
+	seconds := float32(dt.Seconds())
+
+	s.stepCount++
+	s.physicsPacket++
+	p.PacketId = s.physicsPacket
+
+	s.lapElapsed += dt
+
+	var speedKmh float32
+	if s.pitRemaining > 0 {
+		// Stationary in the pit box
+		s.pitRemaining -= dt
+		g.IsInPit = 1
+		g.IsInPitLane = 1
+		if s.pitRemaining <= 0 {
+			s.finishPitStop()
+		}
+	} else {
+		progressStep := dt.Seconds() / s.lapTime().Seconds()
+		s.lapProgress += progressStep
+
+		// Average speed over the lap with three braking zones
+		avg := float64(sc.TrackLength) / s.lapTime().Seconds() * 3.6
+		speedKmh = float32(avg * (1 + 0.3*math.Cos(2*math.Pi*3*s.lapProgress)))
+
+		if s.exitingPit && s.lapProgress >= simPitExitFraction {
+			s.exitingPit = false
+		}
+		if s.exitingPit {
+			speedKmh = 80
+		}
+		g.IsInPitLane = boolToInt32(s.exitingPit)
+		g.IsInPit = 0
+
+		wear := sc.TyreWearPerLap * float32(progressStep)
+		for i := range p.TyreWear {
+			p.TyreWear[i] += wear
+		}
+		p.Fuel -= sc.FuelPerLap * float32(progressStep)
+		if p.Fuel < 0 {
+			p.Fuel = 0
+		}
+		g.UsedFuel += sc.FuelPerLap * float32(progressStep)
+
+		if s.lapProgress >= 1 {
+			s.completeLap()
+		}
+	}
+
+	// Driver inputs, gearbox and engine from the speed profile
+	accel := (speedKmh - s.lastSpeed) / seconds
+	s.lastSpeed = speedKmh
+	p.SpeedKmh = speedKmh
+	p.Gas, p.Brake = 0, 0
+	switch {
+	case accel > 1:
+		p.Gas = 1
+	case accel < -1:
+		p.Brake = float32(math.Min(1, float64(-accel)/60))
+	default:
+		p.Gas = 0.6
+	}
+
+	gear := int32(speedKmh/45) + 1
+	if gear > 6 {
+		gear = 6
+	}
+	if speedKmh == 0 {
+		gear = 0
+	}
+	p.Gear = gear + 1 // ACC: 0 reverse, 1 neutral
+	gearFraction := (speedKmh - float32(gear-1)*45) / 45
+	p.Rpms = int32(float32(sc.MaxRpm) * (0.55 + 0.4*clamp32(gearFraction, 0, 1)))
+	if speedKmh == 0 {
+		p.Rpms = int32(float32(sc.MaxRpm) * 0.15)
+	}
+	p.PitLimiterOn = g.IsInPitLane
+
+	// Tyre and brake temperatures follow load with some inertia
+	load := speedKmh / 250
+	for i := 0; i < 4; i++ {
+		target := 70 + 25*load - s.rainLevel*15
+		p.TyreCoreTemperature[i] += (target - p.TyreCoreTemperature[i]) * 0.2 * seconds
+		p.TyreTemp[i] = p.TyreCoreTemperature[i]
+		p.TyreTempI[i] = p.TyreCoreTemperature[i] + 3
+		p.TyreTempM[i] = p.TyreCoreTemperature[i] + 1
+		p.TyreTempO[i] = p.TyreCoreTemperature[i] - 1
+		p.WheelsPressure[i] = 27.6 + (p.TyreCoreTemperature[i]-70)*0.045
+
+		brakeTarget := 250 + 500*p.Brake
+		p.BrakeTemp[i] += (brakeTarget - p.BrakeTemp[i]) * 0.5 * seconds
+	}
+
+	// Rain arrives after RainStartLap laps and builds up lap by lap
+	if sc.RainStartLap > 0 && int(g.CompletedLaps) >= sc.RainStartLap {
+		laps := float32(int(g.CompletedLaps)-sc.RainStartLap) + float32(s.lapProgress)
+		s.rainLevel = clamp32(0.2+laps*0.2, 0, 1)
+	}
+	s.updateWeather()
+
+	// Lap timing and position
+	g.NormalizedCarPosition = float32(s.lapProgress)
+	g.DistanceTraveled = float32(float64(g.CompletedLaps)+s.lapProgress) * sc.TrackLength
+	g.CurrentSectorIndex = int32(s.lapProgress * float64(sc.SectorCount))
+	if g.CurrentSectorIndex >= int32(sc.SectorCount) {
+		g.CurrentSectorIndex = int32(sc.SectorCount - 1)
+	}
+	g.ICurrentTime = int32(s.lapElapsed.Milliseconds())
+	PutUTF16(g.CurrentTime[:], formatLapTime(g.ICurrentTime))
+	g.FuelEstimatedLaps = 0
+	if sc.FuelPerLap > 0 {
+		g.FuelEstimatedLaps = p.Fuel / sc.FuelPerLap
+	}
+
+	radius := float64(sc.TrackLength) / (2 * math.Pi)
+	angle := 2 * math.Pi * s.lapProgress
+	g.CarCoordinates[0] = [3]float32{float32(radius * math.Cos(angle)), 0, float32(radius * math.Sin(angle))}
+	p.Heading = float32(angle + math.Pi/2)
+
+	// Graphics is refreshed at roughly 60 Hz like the game
+	if interval := sc.PhysicsRate / 60; interval <= 1 || s.stepCount%interval == 0 {
+		g.PacketId++
+	}
+}
+
+func (s *Simulator) completeLap() {
+	g := &s.graphics
+	lapMS := int32(s.lapElapsed.Milliseconds())
+
+	s.lapProgress -= 1
+	s.lapElapsed = 0
+	g.CompletedLaps++
+	g.ILastTime = lapMS
+	PutUTF16(g.LastTime[:], formatLapTime(lapMS))
+	g.LastSectorTime = lapMS / int32(s.scenario.SectorCount)
+
+	if s.bestLapMS == 0 || lapMS < s.bestLapMS {
+		s.bestLapMS = lapMS
+		g.IBestTime = lapMS
+		PutUTF16(g.BestTime[:], formatLapTime(lapMS))
+	}
+
+	if s.scenario.PitEveryLaps > 0 && int(g.CompletedLaps)%s.scenario.PitEveryLaps == 0 {
+		s.pitRemaining = s.scenario.PitStopDuration
+		if s.pitRemaining <= 0 {
+			s.finishPitStop()
+		}
+	}
+}
+
+func (s *Simulator) finishPitStop() {
+	p := &s.physics
+	g := &s.graphics
+
+	s.pitRemaining = 0
+	p.Fuel = s.scenario.StartFuel
+	if p.Fuel > s.scenario.MaxFuel && s.scenario.MaxFuel > 0 {
+		p.Fuel = s.scenario.MaxFuel
+	}
+	p.TyreWear = [4]float32{}
+	g.IsInPit = 0
+	g.MandatoryPitDone = 1
+	s.exitingPit = true
+	g.CurrentTyreSet++
+	if s.rainLevel > 0.3 {
+		g.RainTyres = 1
+		PutUTF16(g.TyreCompound[:], "wet_compound")
+	}
+}
+
+func (s *Simulator) updateWeather() {
+	g := &s.graphics
+
+	switch {
+	case s.rainLevel == 0:
+		g.RainIntensity = 0
+		g.TrackGripStatus = 2
+		g.SurfaceGrip = 1
+		PutUTF16(g.TrackStatus[:], "OPTIMUM")
+	case s.rainLevel < 0.5:
+		g.RainIntensity = 2
+		g.TrackGripStatus = 4
+		g.SurfaceGrip = 1 - (1-simRainGrip)*s.rainLevel*2
+		PutUTF16(g.TrackStatus[:], "DAMP")
+	default:
+		g.RainIntensity = 3
+		g.TrackGripStatus = 5
+		g.SurfaceGrip = simRainGrip
+		PutUTF16(g.TrackStatus[:], "WET")
+	}
+	g.RainIntensityIn10min = g.RainIntensity
+	g.RainIntensityIn30min = g.RainIntensity
+	g.WiperLV = boolToInt32(s.rainLevel > 0)
+	g.RainLights = g.WiperLV
+}
+
+func formatLapTime(ms int32) string {
+	return fmt.Sprintf("%d:%02d.%03d", ms/60000, (ms/1000)%60, ms%1000)
+}
+
+func boolToInt32(b bool) int32 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func clamp32(v, lo, hi float32) float32 {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
@@ -0,0 +1,15 @@
+package sharedmemory
+
+// Source provides the three ACC shared memory pages to the Service.
+// SharedMemoryReader is the live implementation; MemorySource and other
+// backends let the pipeline run without the game.
+type Source interface {
+	Connect() error
+	Disconnect()
+	IsConnected() bool
+	ReadPhysics() (*Physics, error)
+	ReadGraphics() (*Graphics, error)
+	ReadStatic() (*Static, error)
+}
+
+var _ Source = (*SharedMemoryReader)(nil)
@@ -5,10 +5,15 @@ import (
 )
 
 const (
+	// Page names shared by every backend
+	PhysicsPageName  = "acpmf_physics"
+	GraphicsPageName = "acpmf_graphics"
+	StaticPageName   = "acpmf_static"
+
 	// Shared memory names
-	AccSharedMemoryName   = "Local\\acpmf_physics"
-	AccGraphicsMemoryName = "Local\\acpmf_graphics"
-	AccStaticMemoryName   = "Local\\acpmf_static"
+	AccSharedMemoryName   = "Local\\" + PhysicsPageName
+	AccGraphicsMemoryName = "Local\\" + GraphicsPageName
+	AccStaticMemoryName   = "Local\\" + StaticPageName
 
 	// Page file sizes
 	PhysicsPageFileSize  = int(unsafe.Sizeof(Physics{}))
@@ -88,3 +88,15 @@ func (s *Static) GetDryTyresName() string {
 func (s *Static) GetWetTyresName() string {
 	return UTF16ToString(s.WetTyresName[:])
 }
+
+// PutUTF16 writes s into a fixed UTF16 array, null terminated and truncated to fit
+func PutUTF16(dst []uint16, s string) {
+	encoded := utf16.Encode([]rune(s))
+	if len(encoded) >= len(dst) {
+		encoded = encoded[:len(dst)-1]
+	}
+	n := copy(dst, encoded)
+	for i := n; i < len(dst); i++ {
+		dst[i] = 0
+	}
+}
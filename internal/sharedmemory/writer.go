@@ -0,0 +1,188 @@
+package sharedmemory
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+
+	"RaceAll/internal/errors"
+)
+
+// SharedMemoryWriter creates the three acpmf_* pages with the ACC layout and
+// fills them, so any tool reading the game's shared memory can consume
+// recordings, simulator output or other TelemetryData streams
+type SharedMemoryWriter struct {
+	mu       sync.Mutex
+	location string
+
+	physics  *mappedPage
+	graphics *mappedPage
+	static   *mappedPage
+}
+
+// NewSharedMemoryWriter creates a writer for the platform default page location
+func NewSharedMemoryWriter() *SharedMemoryWriter {
+	return NewSharedMemoryWriterAt(DefaultPageLocation)
+}
+
+// NewSharedMemoryWriterAt creates a writer for a custom page location, with
+// the same meaning as in NewSharedMemoryReaderAt
+func NewSharedMemoryWriterAt(location string) *SharedMemoryWriter {
+	return &SharedMemoryWriter{location: location}
+}
+
+// Open creates the pages. Existing Linux page files are resized and reused.
+func (w *SharedMemoryWriter) Open() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.physics != nil {
+		return NewError("Open", errors.ErrAlreadyConnected)
+	}
+
+	var err error
+
+	w.physics, err = createPage(w.location, PhysicsPageName, PhysicsPageFileSize)
+	if err != nil {
+		w.close()
+		return NewErrorWithContext("Open", err, PhysicsPageName)
+	}
+
+	w.graphics, err = createPage(w.location, GraphicsPageName, GraphicsPageFileSize)
+	if err != nil {
+		w.close()
+		return NewErrorWithContext("Open", err, GraphicsPageName)
+	}
+
+	w.static, err = createPage(w.location, StaticPageName, StaticPageFileSize)
+	if err != nil {
+		w.close()
+		return NewErrorWithContext("Open", err, StaticPageName)
+	}
+
+	return nil
+}
+
+// Close unmaps the pages and removes them, like the game does on exit
+func (w *SharedMemoryWriter) Close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.close()
+}
+
+func (w *SharedMemoryWriter) close() {
+	if w.physics != nil {
+		w.physics.close()
+		w.physics = nil
+		removePage(w.location, PhysicsPageName)
+	}
+
+	if w.graphics != nil {
+		w.graphics.close()
+		w.graphics = nil
+		removePage(w.location, GraphicsPageName)
+	}
+
+	if w.static != nil {
+		w.static.close()
+		w.static = nil
+		removePage(w.location, StaticPageName)
+	}
+}
+
+// IsOpen returns true while the pages are mapped
+func (w *SharedMemoryWriter) IsOpen() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.physics != nil
+}
+
+// WritePhysics publishes a physics page
+func (w *SharedMemoryWriter) WritePhysics(physics *Physics) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.physics == nil {
+		return NewError("WritePhysics", errors.ErrNotConnected)
+	}
+	publishPage(w.physics.data, unsafe.Pointer(physics), PhysicsPageFileSize)
+	return nil
+}
+
+// WriteGraphics publishes a graphics page
+func (w *SharedMemoryWriter) WriteGraphics(graphics *Graphics) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.graphics == nil {
+		return NewError("WriteGraphics", errors.ErrNotConnected)
+	}
+	publishPage(w.graphics.data, unsafe.Pointer(graphics), GraphicsPageFileSize)
+	return nil
+}
+
+// WriteStatic publishes a static page
+func (w *SharedMemoryWriter) WriteStatic(static *Static) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.static == nil {
+		return NewError("WriteStatic", errors.ErrNotConnected)
+	}
+	copy(w.static.data, unsafe.Slice((*byte)(unsafe.Pointer(static)), StaticPageFileSize))
+	return nil
+}
+
+// WriteFrame publishes the pages present in data. Static is written first so
+// readers that connect mid-stream see the session before the first packet.
+func (w *SharedMemoryWriter) WriteFrame(data TelemetryData) error {
+	if data.Static != nil {
+		if err := w.WriteStatic(data.Static); err != nil {
+			return err
+		}
+	}
+	if data.Graphics != nil {
+		if err := w.WriteGraphics(data.Graphics); err != nil {
+			return err
+		}
+	}
+	if data.Physics != nil {
+		if err := w.WritePhysics(data.Physics); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run writes every frame received on frames until the channel is closed or
+// ctx is cancelled. Frames usually come from Service.Subscribe on a Player
+// or Simulator source.
+func (w *SharedMemoryWriter) Run(ctx context.Context, frames <-chan TelemetryData) error {
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case data, ok := <-frames:
+			if !ok {
+				return nil
+			}
+			if err := w.WriteFrame(data); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// publishPage copies a page that starts with a PacketId as a seqlock: the
+// PacketId is set to packetInProgress before the body is written and to the
+// frame's PacketId after, so snapshotPage discards any copy that overlaps the
+// write. Frames must carry a new PacketId for a reader to tell them apart.
+func publishPage(page []byte, src unsafe.Pointer, size int) {
+	packetID := (*int32)(unsafe.Pointer(&page[0]))
+	in := unsafe.Slice((*byte)(src), size)
+
+	atomic.StoreInt32(packetID, packetInProgress)
+	copy(page[4:size], in[4:])
+	atomic.StoreInt32(packetID, *(*int32)(src))
+}
@@ -0,0 +1,316 @@
+package acc_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"RaceAll/internal/acc/director"
+	"RaceAll/internal/acc/incidents"
+	"RaceAll/internal/acc/leaderboard"
+	"RaceAll/internal/broadcast"
+)
+
+// fakeController guarda los cambios de foco y cámara enviados
+type fakeController struct {
+	shots []director.Shot
+	err   error
+}
+
+func (f *fakeController) SetFocusAndCamera(carIndex uint16, cameraSet, camera string) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.shots = append(f.shots, director.Shot{CarIndex: carIndex, CameraSet: cameraSet, Camera: camera})
+	return nil
+}
+
+// grid crea un leaderboard con los autos en orden e intervalos en ms
+func grid(intervals ...int32) []leaderboard.DriverPosition {
+	positions := make([]leaderboard.DriverPosition, len(intervals))
+	for i, interval := range intervals {
+		positions[i] = leaderboard.DriverPosition{
+			Position:   i + 1,
+			CarIndex:   uint16(i + 1),
+			IntervalMS: interval,
+			Location:   broadcast.CarLocationTrack,
+		}
+	}
+	return positions
+}
+
+func newTestDirector() (*director.Director, *fakeController) {
+	d := director.NewDirector()
+	controller := &fakeController{}
+	d.SetController(controller)
+	d.UpdateTrackData(&broadcast.TrackData{
+		CameraSets: map[string][]string{
+			"set1":    {"Camera1", "Camera2"},
+			"Helicam": {"Helicam"},
+			"pitlane": {"CameraPit1"},
+		},
+	})
+	return d, controller
+}
+
+func TestDirector_FollowsLeaderThenBattle(t *testing.T) {
+	d, controller := newTestDirector()
+
+	// Sin batallas se sigue al líder
+	shot, ok := d.Update(grid(0, 3000, 4000), time.Minute)
+	if !ok || shot.CarIndex != 1 || shot.Reason != director.ReasonLeader || shot.CameraSet != "set1" {
+		t.Fatalf("first shot = %+v, %v, want leader on set1", shot, ok)
+	}
+
+	// La batalla tiene más prioridad pero se respeta el tiempo mínimo
+	if _, ok := d.Update(grid(0, 3000, 500), time.Minute+2*time.Second); ok {
+		t.Error("Update() changed shot before MinDwell")
+	}
+
+	shot, ok = d.Update(grid(0, 3000, 500), time.Minute+6*time.Second)
+	if !ok || shot.CarIndex != 3 || shot.Reason != director.ReasonBattle {
+		t.Fatalf("shot after MinDwell = %+v, %v, want battle for car 3", shot, ok)
+	}
+
+	if len(controller.shots) != 2 || controller.shots[1].CarIndex != 3 {
+		t.Errorf("controller shots = %+v", controller.shots)
+	}
+}
+
+func TestDirector_PriorityAndMaxDwell(t *testing.T) {
+	d, _ := newTestDirector()
+
+	shot, _ := d.Update(grid(0, 500, 3000), time.Minute)
+	if shot.CarIndex != 2 || shot.Reason != director.ReasonBattle {
+		t.Fatalf("first shot = %+v, want battle for car 2", shot)
+	}
+
+	// Mientras dure la batalla el líder (menor prioridad) espera a MaxDwell
+	if _, ok := d.Update(grid(0, 500, 3000), time.Minute+8*time.Second); ok {
+		t.Error("lower priority candidate replaced the battle before MaxDwell")
+	}
+
+	shot, ok := d.Update(grid(0, 500, 3000), time.Minute+30*time.Second)
+	if !ok || shot.CarIndex != 1 {
+		t.Errorf("shot after MaxDwell = %+v, %v, want leader", shot, ok)
+	}
+
+	// El líder tiene menos prioridad: tras MinDwell se vuelve a la batalla
+	shot, ok = d.Update(grid(0, 500, 3000), time.Minute+36*time.Second)
+	if !ok || shot.CarIndex != 2 {
+		t.Errorf("shot after leader = %+v, %v, want battle for car 2", shot, ok)
+	}
+}
+
+func TestDirector_IncidentsAndPositionChanges(t *testing.T) {
+	d, _ := newTestDirector()
+	d.Update(grid(0, 3000, 4000), time.Minute)
+
+	// Un incidente se muestra con la cámara preferida para incidentes
+	d.HandleIncident(incidents.Incident{CarIndex: 2})
+	shot, ok := d.Update(grid(0, 3000, 4000), time.Minute+5*time.Second)
+	if !ok || shot.CarIndex != 2 || shot.Reason != director.ReasonIncident || shot.CameraSet != "Helicam" {
+		t.Fatalf("incident shot = %+v, %v", shot, ok)
+	}
+
+	// El auto 3 adelanta al 2; el incidente sigue siendo más prioritario
+	passed := grid(0, 3000, 4000)
+	passed[1].CarIndex, passed[2].CarIndex = 3, 2
+	if _, ok := d.Update(passed, time.Minute+8*time.Second); ok {
+		t.Error("position change replaced an incident")
+	}
+
+	candidates := d.Candidates()
+	if len(candidates) < 2 || candidates[0].Reason != director.ReasonIncident || candidates[1].Reason != director.ReasonPositionChange {
+		t.Errorf("Candidates() = %+v", candidates)
+	}
+
+	// El incidente expira tras EventWindow y pasa a mostrarse el adelantamiento
+	shot, ok = d.Update(passed, time.Minute+12*time.Second)
+	if !ok || shot.CarIndex != 3 || shot.Reason != director.ReasonPositionChange {
+		t.Errorf("shot after incident expired = %+v, %v, want position change of car 3", shot, ok)
+	}
+}
+
+func TestDirector_PitStop(t *testing.T) {
+	d, _ := newTestDirector()
+	d.Update(grid(0, 3000, 4000), time.Minute)
+
+	pit := grid(0, 3000, 4000)
+	pit[2].Location = broadcast.CarLocationPitEntry
+	shot, ok := d.Update(pit, time.Minute+6*time.Second)
+	if !ok || shot.CarIndex != 3 || shot.Reason != director.ReasonPitStop || shot.CameraSet != "pitlane" {
+		t.Errorf("pit shot = %+v, %v", shot, ok)
+	}
+}
+
+func TestDirector_CameraRotation(t *testing.T) {
+	d, _ := newTestDirector()
+
+	first, _ := d.Update(grid(0, 3000), time.Minute)
+	if _, ok := d.Update(grid(0, 3000), time.Minute+5*time.Second); ok {
+		t.Error("camera changed before CameraDwell")
+	}
+
+	next, ok := d.Update(grid(0, 3000), time.Minute+11*time.Second)
+	if !ok || next.CarIndex != first.CarIndex || next.Camera == first.Camera {
+		t.Errorf("camera rotation = %+v -> %+v, %v", first, next, ok)
+	}
+}
+
+func TestDirector_ManualOverrideLock(t *testing.T) {
+	d, controller := newTestDirector()
+	d.Update(grid(0, 3000), time.Minute)
+
+	if err := d.Override(2, "Helicam", "Helicam"); err != nil {
+		t.Fatalf("Override() error = %v", err)
+	}
+	if !d.IsLocked() {
+		t.Error("Override() should lock the director")
+	}
+
+	// Bloqueado: ni siquiera un incidente cambia el plano
+	d.Update(grid(0, 3000), 2*time.Minute)
+	d.HandleIncident(incidents.Incident{CarIndex: 1})
+	if _, ok := d.Update(grid(0, 3000), 2*time.Minute+time.Second); ok {
+		t.Error("Update() changed shot while locked")
+	}
+
+	shot, _ := d.CurrentShot()
+	if shot.Reason != director.ReasonManual || shot.CarIndex != 2 {
+		t.Errorf("CurrentShot() = %+v, want manual shot on car 2", shot)
+	}
+
+	d.Unlock()
+	shot, ok := d.Update(grid(0, 3000), 2*time.Minute+2*time.Second)
+	if !ok || shot.CarIndex != 1 || shot.Reason != director.ReasonIncident {
+		t.Errorf("shot after Unlock = %+v, %v, want incident of car 1", shot, ok)
+	}
+
+	if len(controller.shots) != 3 {
+		t.Errorf("controller received %d shots, want 3", len(controller.shots))
+	}
+}
+
+func TestDirector_ControllerError(t *testing.T) {
+	d, controller := newTestDirector()
+	controller.err = errors.New("not connected")
+
+	if _, ok := d.Update(grid(0), time.Minute); ok {
+		t.Error("Update() reported a shot the controller rejected")
+	}
+	if _, ok := d.CurrentShot(); ok {
+		t.Error("CurrentShot() should stay empty after a failed change")
+	}
+
+	// Sin controller el director no decide
+	d.SetController(nil)
+	if _, ok := d.Update(grid(0), 2*time.Minute); ok {
+		t.Error("Update() without controller should not change shot")
+	}
+}
+
+// overrideController simula un Override del operador mientras el director
+// envía su plano, que ACC rechaza. El Override espera a que termine el envío.
+type overrideController struct {
+	director *director.Director
+	calls    int
+	done     chan error
+}
+
+func (c *overrideController) SetFocusAndCamera(carIndex uint16, cameraSet, camera string) error {
+	c.calls++
+	if c.calls == 1 {
+		go func() { c.done <- c.director.Override(2, "Helicam", "Helicam") }()
+		return errors.New("not connected")
+	}
+	return nil
+}
+
+func TestDirector_ControllerErrorKeepsOverride(t *testing.T) {
+	d, _ := newTestDirector()
+	controller := &overrideController{director: d, done: make(chan error, 1)}
+	d.SetController(controller)
+
+	if _, ok := d.Update(grid(0, 3000), time.Minute); ok {
+		t.Error("Update() reported a shot the controller rejected")
+	}
+	if err := <-controller.done; err != nil {
+		t.Fatalf("Override() error = %v", err)
+	}
+
+	shot, ok := d.CurrentShot()
+	if !ok || shot.Reason != director.ReasonManual || shot.CarIndex != 2 {
+		t.Errorf("CurrentShot() = %+v, %v, want the manual shot on car 2", shot, ok)
+	}
+	if !d.IsLocked() {
+		t.Error("IsLocked() = false after Override")
+	}
+}
+
+// slowController registra cada plano cuando ACC termina de recibirlo; el
+// primer envío espera a release
+type slowController struct {
+	mu      sync.Mutex
+	shots   []director.Shot
+	entered chan struct{}
+	release chan struct{}
+	first   bool
+}
+
+func (c *slowController) SetFocusAndCamera(carIndex uint16, cameraSet, camera string) error {
+	c.mu.Lock()
+	first := !c.first
+	c.first = true
+	c.mu.Unlock()
+
+	if first {
+		close(c.entered)
+		<-c.release
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.shots = append(c.shots, director.Shot{CarIndex: carIndex, CameraSet: cameraSet, Camera: camera})
+	return nil
+}
+
+func TestDirector_OverrideDuringAutomaticSend(t *testing.T) {
+	d, _ := newTestDirector()
+	controller := &slowController{entered: make(chan struct{}), release: make(chan struct{})}
+	d.SetController(controller)
+
+	updated := make(chan struct{})
+	go func() {
+		d.Update(grid(0, 3000), time.Minute)
+		close(updated)
+	}()
+	<-controller.entered
+
+	overridden := make(chan error, 1)
+	go func() { overridden <- d.Override(2, "Helicam", "Helicam") }()
+
+	// El Override no puede llegar a ACC antes que el plano en curso
+	select {
+	case err := <-overridden:
+		t.Fatalf("Override() returned while the director's shot was in flight (error = %v)", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(controller.release)
+	<-updated
+	if err := <-overridden; err != nil {
+		t.Fatalf("Override() error = %v", err)
+	}
+
+	controller.mu.Lock()
+	last := controller.shots[len(controller.shots)-1]
+	controller.mu.Unlock()
+	if last.CarIndex != 2 || last.CameraSet != "Helicam" {
+		t.Errorf("last shot sent to ACC = %+v, want the manual shot on car 2", last)
+	}
+	if shot, _ := d.CurrentShot(); shot.Reason != director.ReasonManual || !d.IsLocked() {
+		t.Errorf("CurrentShot() = %+v, locked %v; want the manual shot", shot, d.IsLocked())
+	}
+}
@@ -0,0 +1,131 @@
+package acc_test
+
+import (
+	"testing"
+	"time"
+
+	"RaceAll/internal/acc"
+	"RaceAll/internal/acc/cars"
+	"RaceAll/internal/acc/events"
+	"RaceAll/internal/acc/tracks"
+	"RaceAll/internal/sharedmemory"
+)
+
+func TestEventDetector_Transitions(t *testing.T) {
+	base := sharedmemory.Graphics{
+		PacketId:   1,
+		Status:     sharedmemory.ACLive,
+		IsValidLap: 1,
+	}
+
+	tests := []struct {
+		name   string
+		change func(g *sharedmemory.Graphics)
+		want   []events.EventType
+	}{
+		{"No change", func(g *sharedmemory.Graphics) {}, nil},
+		{"Lap completed", func(g *sharedmemory.Graphics) {
+			g.CompletedLaps = 1
+			g.ILastTime = 108000
+		}, []events.EventType{events.EventLapCompleted}},
+		{"Sector changed", func(g *sharedmemory.Graphics) { g.CurrentSectorIndex = 1 }, []events.EventType{events.EventSectorChanged}},
+		{"Pit lane entered", func(g *sharedmemory.Graphics) { g.IsInPitLane = 1 }, []events.EventType{events.EventPitLaneEntered}},
+		{"Pit box entered", func(g *sharedmemory.Graphics) { g.IsInPit = 1 }, []events.EventType{events.EventPitBoxEntered}},
+		{"Flag changed", func(g *sharedmemory.Graphics) { g.Flag = sharedmemory.ACBlueFlag }, []events.EventType{events.EventFlagChanged}},
+		{"Status changed", func(g *sharedmemory.Graphics) { g.Status = sharedmemory.ACPause }, []events.EventType{events.EventStatusChanged}},
+		{"Session changed resets laps", func(g *sharedmemory.Graphics) {
+			g.SessionIndex = 1
+			g.CompletedLaps = 3
+		}, []events.EventType{events.EventSessionChanged}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			detector := events.NewEventDetector()
+			first := base
+			detector.Update(nil, &first)
+
+			next := base
+			next.PacketId = 2
+			tt.change(&next)
+
+			got := detector.Update(&sharedmemory.Physics{Fuel: 50}, &next)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Update() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i].Type != tt.want[i] {
+					t.Errorf("event %d = %v, want %v", i, got[i].Type, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestEventDetector_PitExit(t *testing.T) {
+	detector := events.NewEventDetector()
+	detector.Update(nil, &sharedmemory.Graphics{PacketId: 1, IsInPit: 1, IsInPitLane: 1})
+
+	got := detector.Update(nil, &sharedmemory.Graphics{PacketId: 2})
+	if !events.HasEvent(got, events.EventPitBoxExited) || !events.HasEvent(got, events.EventPitLaneExited) {
+		t.Errorf("Update() = %v, want pit box and pit lane exits", got)
+	}
+}
+
+func TestEventDetector_Simulator(t *testing.T) {
+	scenario := sharedmemory.DefaultScenario()
+	sim := sharedmemory.NewSimulator(scenario)
+	sim.Connect()
+
+	detector := events.NewEventDetector()
+	var laps, pitEntries int
+	detector.OnEvent(func(e events.Event) {
+		switch e.Type {
+		case events.EventLapCompleted:
+			laps++
+			if e.LapTimeMs <= 0 {
+				t.Errorf("lap %d without lap time", e.Current)
+			}
+		case events.EventPitLaneEntered:
+			pitEntries++
+		}
+	})
+
+	for i := 0; i < 16*int(scenario.LapTime/time.Second); i++ {
+		sim.Advance(time.Second)
+		physics, _ := sim.ReadPhysics()
+		graphics, _ := sim.ReadGraphics()
+		detector.Update(physics, graphics)
+	}
+
+	if laps < 15 {
+		t.Errorf("detected %d laps, want at least 15", laps)
+	}
+	if pitEntries != 1 {
+		t.Errorf("detected %d pit entries, want 1", pitEntries)
+	}
+}
+
+func TestDataManager_FuelPerLapFromSharedMemory(t *testing.T) {
+	scenario := sharedmemory.DefaultScenario()
+	scenario.FuelPerLap = 2.5
+	sim := sharedmemory.NewSimulator(scenario)
+	sim.Connect()
+
+	dm := acc.NewDataManager()
+	dm.Initialize(cars.CarModel(0), 0, tracks.TrackID(0))
+
+	for i := 0; i < 3*int(sim.Scenario().LapTime/time.Second); i++ {
+		sim.Advance(time.Second)
+		physics, _ := sim.ReadPhysics()
+		graphics, _ := sim.ReadGraphics()
+		static, _ := sim.ReadStatic()
+		dm.UpdateFromSharedMemory(physics, graphics, static)
+	}
+
+	// Without completed laps the calculator falls back to 3 l/lap
+	laps := dm.GetFuelCalculator().CalculateLapsWithFuel(25)
+	if laps < 9.5 || laps > 10.5 {
+		t.Errorf("CalculateLapsWithFuel(25) = %.2f, want ~10 from 2.5 l/lap", laps)
+	}
+}
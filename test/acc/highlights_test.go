@@ -0,0 +1,189 @@
+package acc_test
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"RaceAll/internal/acc/highlights"
+	"RaceAll/internal/broadcast"
+)
+
+// fakeSaver cuenta los comandos SaveManualReplayHighlight enviados
+type fakeSaver struct {
+	calls int
+	err   error
+}
+
+func (f *fakeSaver) SaveManualReplayHighlight() error {
+	f.calls++
+	return f.err
+}
+
+func accident(timeMs int32, carID int32) *broadcast.BroadcastingEvent {
+	return &broadcast.BroadcastingEvent{
+		Type:   broadcast.BroadcastingEventTypeAccident,
+		Msg:    "Accident",
+		TimeMs: timeMs,
+		CarId:  carID,
+	}
+}
+
+func TestHighlightManager_AutoSaveSelectedEvents(t *testing.T) {
+	hm := highlights.NewHighlightManager()
+	saver := &fakeSaver{}
+	hm.SetSaver(saver)
+
+	car := &broadcast.CarInfo{
+		CarIndex:   7,
+		RaceNumber: 88,
+		Drivers:    []broadcast.DriverInfo{{FirstName: "Jules", LastName: "Gounon"}},
+	}
+
+	var notified []highlights.Highlight
+	hm.OnHighlight(func(h highlights.Highlight) { notified = append(notified, h) })
+
+	h, ok := hm.HandleEvent(accident(600000, 7), car)
+	if !ok || !h.Saved || h.SessionTime != 10*time.Minute || h.RaceNumber != 88 || h.DriverName != "Jules Gounon" {
+		t.Errorf("HandleEvent(accident) = %+v, %v", h, ok)
+	}
+
+	// Las vueltas completadas no están seleccionadas por defecto
+	if _, ok := hm.HandleEvent(&broadcast.BroadcastingEvent{Type: broadcast.BroadcastingEventTypeLapCompleted}, nil); ok {
+		t.Error("LapCompleted should not create a highlight by default")
+	}
+
+	if _, ok := hm.HandleEvent(&broadcast.BroadcastingEvent{Type: broadcast.BroadcastingEventTypeBestSessionLap, TimeMs: 700000, CarId: 3}, nil); !ok {
+		t.Error("BestSessionLap should create a highlight by default")
+	}
+
+	if saver.calls != 2 || len(notified) != 2 || len(hm.GetHighlights()) != 2 {
+		t.Errorf("calls = %d, notified = %d, index = %d, want 2", saver.calls, len(notified), len(hm.GetHighlights()))
+	}
+
+	hm.SetAutoSaveEvents(broadcast.BroadcastingEventTypeLapCompleted)
+	if _, ok := hm.HandleEvent(accident(800000, 7), car); ok {
+		t.Error("accidents should not create a highlight after SetAutoSaveEvents(LapCompleted)")
+	}
+}
+
+func TestHighlightManager_MinInterval(t *testing.T) {
+	hm := highlights.NewHighlightManager()
+	saver := &fakeSaver{}
+	hm.SetSaver(saver)
+	hm.SetMinInterval(3 * time.Second)
+
+	// Accidente múltiple: tres eventos en un segundo, un único highlight en ACC
+	first, _ := hm.HandleEvent(accident(100000, 1), nil)
+	second, _ := hm.HandleEvent(accident(100500, 2), nil)
+	third, _ := hm.HandleEvent(accident(101000, 3), nil)
+	later, _ := hm.HandleEvent(accident(104000, 4), nil)
+
+	if !first.Saved || second.Saved || third.Saved || !later.Saved {
+		t.Errorf("Saved = %v %v %v %v, want true false false true", first.Saved, second.Saved, third.Saved, later.Saved)
+	}
+	if saver.calls != 2 {
+		t.Errorf("saver calls = %d, want 2", saver.calls)
+	}
+
+	// Todos los autos quedan en el índice
+	if got := hm.GetHighlightsForCar(2); len(got) != 1 || got[0].ID != second.ID {
+		t.Errorf("GetHighlightsForCar(2) = %+v", got)
+	}
+}
+
+func TestHighlightManager_Bookmark(t *testing.T) {
+	hm := highlights.NewHighlightManager()
+	hm.UpdateSessionTime(95 * time.Second)
+
+	// Sin saver solo se actualiza el índice
+	h, err := hm.Bookmark(-1, "start")
+	if err != nil || h.Saved || !h.Manual || h.SessionTime != 95*time.Second {
+		t.Errorf("Bookmark() without saver = %+v, %v", h, err)
+	}
+
+	saver := &fakeSaver{err: errors.New("not connected")}
+	hm.SetSaver(saver)
+	h, err = hm.Bookmark(12, "overtake")
+	if err == nil || h.Saved {
+		t.Errorf("Bookmark() with failing saver = %+v, %v", h, err)
+	}
+	if len(hm.GetHighlights()) != 2 {
+		t.Errorf("index has %d entries, want 2", len(hm.GetHighlights()))
+	}
+}
+
+func TestHighlightManager_IndexPersistence(t *testing.T) {
+	hm := highlights.NewHighlightManager()
+	hm.SetSaver(&fakeSaver{})
+	hm.HandleEvent(accident(60000, 5), &broadcast.CarInfo{RaceNumber: 32})
+	hm.Bookmark(5, "replay this")
+
+	path := filepath.Join(t.TempDir(), "highlights.json")
+	if err := hm.SaveIndexFile(path); err != nil {
+		t.Fatalf("SaveIndexFile() error = %v", err)
+	}
+
+	loaded := highlights.NewHighlightManager()
+	if err := loaded.LoadIndexFile(path); err != nil {
+		t.Fatalf("LoadIndexFile() error = %v", err)
+	}
+
+	got := loaded.GetHighlights()
+	want := hm.GetHighlights()
+	if len(got) != len(want) {
+		t.Fatalf("loaded %d highlights, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].ID != want[i].ID || got[i].SessionTime != want[i].SessionTime ||
+			got[i].CarIndex != want[i].CarIndex || got[i].Note != want[i].Note || got[i].Saved != want[i].Saved {
+			t.Errorf("highlight %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	// Los IDs continúan después de los cargados
+	h, _ := loaded.Bookmark(1, "")
+	if h.ID != 3 {
+		t.Errorf("next ID = %d, want 3", h.ID)
+	}
+
+	if err := loaded.LoadIndex(bytes.NewBufferString("not json")); err == nil {
+		t.Error("LoadIndex() should fail on invalid JSON")
+	}
+}
+
+// indexSaver lee el índice mientras se envía el highlight
+type indexSaver struct {
+	hm    *highlights.HighlightManager
+	err   error
+	index int
+}
+
+func (s *indexSaver) SaveManualReplayHighlight() error {
+	s.index = len(s.hm.GetHighlights())
+	return s.err
+}
+
+func TestHighlightManager_SaveOutsideLock(t *testing.T) {
+	hm := highlights.NewHighlightManager()
+	saver := &indexSaver{hm: hm, err: errors.New("not connected")}
+	hm.SetSaver(saver)
+
+	// El envío falla: la entrada queda en el índice sin guardar
+	first, ok := hm.HandleEvent(accident(100000, 1), nil)
+	if !ok || first.Saved || saver.index != 1 {
+		t.Errorf("HandleEvent() with failing saver = %+v, index %d", first, saver.index)
+	}
+
+	// Un fallo no cuenta para el intervalo mínimo
+	saver.err = nil
+	second, _ := hm.HandleEvent(accident(100500, 2), nil)
+	if !second.Saved {
+		t.Error("HandleEvent() after a failed save should save again")
+	}
+	if got := hm.GetHighlightsForCar(2); len(got) != 1 || !got[0].Saved {
+		t.Errorf("GetHighlightsForCar(2) = %+v, want the saved entry", got)
+	}
+}
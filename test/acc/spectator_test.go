@@ -0,0 +1,143 @@
+package acc_test
+
+import (
+	"testing"
+
+	"RaceAll/internal/acc"
+	"RaceAll/internal/broadcast"
+	"RaceAll/internal/sharedmemory"
+)
+
+// noLap es una vuelta sin tiempos, como la decodifica el protocolo
+func noLap() broadcast.Lap {
+	invalid := broadcast.InvalidSectorTime
+	return broadcast.Lap{LaptimeMS: broadcast.InvalidLapTime, Splits: [3]int32{invalid, invalid, invalid}}
+}
+
+// feedBroadcast envía al pipeline un ciclo de broadcast con el auto enfocado
+func feedBroadcast(pipeline *acc.BroadcastPipeline, config broadcast.MockServerConfig, focused int32) {
+	for i, car := range config.Cars {
+		pipeline.HandleMessage(broadcast.BroadcastMessage{Payload: broadcast.CarUpdate{
+			CarIndex:       car.Info.CarIndex,
+			DriverCount:    uint8(len(car.Info.Drivers)),
+			Position:       uint16(i + 1),
+			CarLocation:    broadcast.CarLocationTrack,
+			BestSessionLap: noLap(),
+			LastLap:        noLap(),
+			CurrentLap:     noLap(),
+		}})
+	}
+
+	update := config.Session
+	update.FocusedCarIndex = focused
+	pipeline.HandleMessage(broadcast.BroadcastMessage{Payload: update})
+}
+
+func TestDataManager_SpectatorFollowsFocusedCar(t *testing.T) {
+	config := broadcast.DefaultMockServerConfig()
+	pipeline := acc.NewBroadcastPipeline()
+	dm := pipeline.DataManager()
+
+	if !dm.IsSpectator() {
+		t.Fatal("broadcast pipeline is not in spectator mode")
+	}
+
+	// Antes de los datos del circuito nada falla
+	if dm.GetLapData() != nil {
+		t.Error("GetLapData() before Initialize")
+	}
+	dm.GetFuelData(50)
+	dm.GetTyresData()
+
+	pipeline.HandleMessage(broadcast.BroadcastMessage{Payload: config.Track})
+	for _, car := range config.Cars {
+		pipeline.HandleMessage(broadcast.BroadcastMessage{Payload: car.Info})
+	}
+
+	focused := config.Cars[1].Info
+	feedBroadcast(pipeline, config, int32(focused.CarIndex))
+
+	if got := dm.GetCarInfo().Model; got != focused.CarModelType {
+		t.Errorf("car model = %d, want %d of the focused car", got, focused.CarModelType)
+	}
+	if player := dm.GetLeaderboardTracker().GetPlayerPosition(); player == nil || player.CarIndex != focused.CarIndex {
+		t.Errorf("player position = %+v, want focused car %d", player, focused.CarIndex)
+	}
+
+	// El foco cambia a otro auto
+	other := config.Cars[2].Info
+	feedBroadcast(pipeline, config, int32(other.CarIndex))
+	if player := dm.GetLeaderboardTracker().GetPlayerPosition(); player == nil || player.CarIndex != other.CarIndex {
+		t.Errorf("player position = %+v, want focused car %d", player, other.CarIndex)
+	}
+}
+
+func TestDataManager_SpectatorIgnoresSharedMemory(t *testing.T) {
+	config := broadcast.DefaultMockServerConfig()
+	pipeline := acc.NewBroadcastPipeline()
+	dm := pipeline.DataManager()
+
+	pipeline.HandleMessage(broadcast.BroadcastMessage{Payload: config.Track})
+	feedBroadcast(pipeline, config, 1)
+
+	// Datos del juego local, que puede estar en otra sesión
+	dm.UpdateFromSharedMemory(&sharedmemory.Physics{Fuel: 40}, &sharedmemory.Graphics{}, &sharedmemory.Static{})
+
+	if dm.HasSharedMemory() {
+		t.Error("HasSharedMemory() = true in spectator mode")
+	}
+	if fuel := dm.GetFuelData(40); fuel.CurrentFuel != 0 || fuel.MaxFuel != 0 {
+		t.Errorf("GetFuelData() = %+v, want empty", fuel)
+	}
+
+	// Fuera del modo espectador shared memory vuelve a usarse
+	dm.SetSpectator(false)
+	dm.UpdateFromSharedMemory(&sharedmemory.Physics{Fuel: 40}, &sharedmemory.Graphics{}, &sharedmemory.Static{})
+	if !dm.HasSharedMemory() || dm.GetFuelData(40).CurrentFuel != 40 {
+		t.Errorf("GetFuelData() = %+v with shared memory", dm.GetFuelData(40))
+	}
+}
+
+func TestDataManager_SpectatorKeepsLapsPerCar(t *testing.T) {
+	config := broadcast.DefaultMockServerConfig()
+	pipeline := acc.NewBroadcastPipeline()
+	dm := pipeline.DataManager()
+
+	pipeline.HandleMessage(broadcast.BroadcastMessage{Payload: config.Track})
+	for _, car := range config.Cars {
+		pipeline.HandleMessage(broadcast.BroadcastMessage{Payload: car.Info})
+	}
+
+	first := config.Cars[1].Info.CarIndex
+	second := config.Cars[2].Info.CarIndex
+
+	// Una vuelta del primer auto mientras está enfocado
+	lastLap := noLap()
+	lastLap.LaptimeMS = 90000
+	lastLap.CarIndex = first
+	pipeline.HandleMessage(broadcast.BroadcastMessage{Payload: broadcast.CarUpdate{
+		CarIndex:       first,
+		CarLocation:    broadcast.CarLocationTrack,
+		BestSessionLap: noLap(),
+		LastLap:        lastLap,
+		CurrentLap:     noLap(),
+	}})
+	update := config.Session
+	update.FocusedCarIndex = int32(first)
+	pipeline.HandleMessage(broadcast.BroadcastMessage{Payload: update})
+
+	laps := dm.GetLapTracker().GetLapCount()
+	if laps == 0 {
+		t.Fatal("no laps recorded for the focused car")
+	}
+
+	// El director corta a otro auto y vuelve
+	feedBroadcast(pipeline, config, int32(second))
+	if got := dm.GetLapTracker().GetLapCount(); got != 0 {
+		t.Errorf("second car laps = %d, want 0", got)
+	}
+	feedBroadcast(pipeline, config, int32(first))
+	if got := dm.GetLapTracker().GetLapCount(); got != laps {
+		t.Errorf("first car laps after refocus = %d, want %d", got, laps)
+	}
+}
@@ -0,0 +1,197 @@
+package acc_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"RaceAll/internal/acc/incidents"
+	"RaceAll/internal/acc/stewards"
+	raceerrors "RaceAll/internal/errors"
+)
+
+// replayRequest es un instant replay pedido por la cola
+type replayRequest struct {
+	startTime float32
+	duration  float32
+	carIndex  int32
+	cameraSet string
+	camera    string
+}
+
+// fakeReplayer guarda los instant replays pedidos
+type fakeReplayer struct {
+	requests []replayRequest
+	err      error
+}
+
+func (f *fakeReplayer) RequestInstantReplay(startTime, duration float32, carIndex int32, cameraSet, camera string) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.requests = append(f.requests, replayRequest{startTime, duration, carIndex, cameraSet, camera})
+	return nil
+}
+
+func newTestIncident(sessionTime time.Duration, carIndex uint16, involved ...uint16) incidents.Incident {
+	return incidents.Incident{
+		Type:         incidents.IncidentTypeCollision,
+		SessionTime:  sessionTime,
+		CarIndex:     carIndex,
+		Message:      "Contact",
+		InvolvedCars: involved,
+	}
+}
+
+func TestReviewQueue_AddResolvesInvolvedCars(t *testing.T) {
+	queue := stewards.NewReviewQueue()
+	queue.SetCarResolver(func(carIndex uint16) (stewards.InvolvedCar, bool) {
+		return stewards.InvolvedCar{CarIndex: carIndex, RaceNumber: int32(carIndex) * 10, DriverName: "Driver"}, true
+	})
+
+	entry := queue.Add(newTestIncident(time.Minute, 1, 1, 2))
+
+	if entry.ID != 1 || entry.Status != stewards.StatusPending {
+		t.Fatalf("Entrada inesperada: id=%d status=%s", entry.ID, entry.Status)
+	}
+	// El auto principal no se repite aunque venga en InvolvedCars
+	if len(entry.InvolvedCars) != 2 {
+		t.Fatalf("Se esperaban 2 autos implicados, hay %d", len(entry.InvolvedCars))
+	}
+	if entry.InvolvedCars[1].RaceNumber != 20 {
+		t.Errorf("El auto 2 no se completó con la lista de entrada: %+v", entry.InvolvedCars[1])
+	}
+
+	if err := queue.AddInvolvedCar(entry.ID, 3); err != nil {
+		t.Fatalf("AddInvolvedCar: %v", err)
+	}
+	if err := queue.RemoveInvolvedCar(entry.ID, 1); err != nil {
+		t.Fatalf("RemoveInvolvedCar: %v", err)
+	}
+
+	got, _ := queue.Get(entry.ID)
+	if len(got.InvolvedCars) != 2 || got.InvolvedCars[0].CarIndex != 2 || got.InvolvedCars[1].CarIndex != 3 {
+		t.Errorf("Autos implicados inesperados: %+v", got.InvolvedCars)
+	}
+}
+
+func TestReviewQueue_OpenRequestsReplay(t *testing.T) {
+	queue := stewards.NewReviewQueue()
+
+	entry := queue.Add(newTestIncident(90*time.Second, 7))
+
+	// Sin conexión no se puede abrir el replay
+	if err := queue.Open(entry.ID); !errors.Is(err, raceerrors.ErrNotConnected) {
+		t.Fatalf("Se esperaba ErrNotConnected, se obtuvo %v", err)
+	}
+
+	replayer := &fakeReplayer{}
+	queue.SetReplayRequester(replayer)
+	queue.SetReplayConfig(stewards.ReplayConfig{
+		PreRoll:   5 * time.Second,
+		Duration:  10 * time.Second,
+		CameraSet: "set1",
+		Camera:    "Camera1",
+	})
+
+	if err := queue.Open(entry.ID); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	want := replayRequest{startTime: 85000, duration: 10000, carIndex: 7, cameraSet: "set1", camera: "Camera1"}
+	if len(replayer.requests) != 1 || replayer.requests[0] != want {
+		t.Fatalf("Replay inesperado: %+v", replayer.requests)
+	}
+
+	// Un incidente al inicio de la sesión no pide tiempos negativos
+	early := queue.Add(newTestIncident(2*time.Second, 3))
+	if err := queue.Open(early.ID); err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if replayer.requests[1].startTime != 0 {
+		t.Errorf("Se esperaba inicio 0, se obtuvo %v", replayer.requests[1].startTime)
+	}
+
+	got, _ := queue.Get(entry.ID)
+	if got.Replays != 1 {
+		t.Errorf("Se esperaba 1 replay, hay %d", got.Replays)
+	}
+
+	if err := queue.Open(99); !errors.Is(err, raceerrors.ErrReviewEntryUnknown) {
+		t.Errorf("Se esperaba ErrReviewEntryUnknown, se obtuvo %v", err)
+	}
+}
+
+func TestReviewQueue_DecideAndReport(t *testing.T) {
+	queue := stewards.NewReviewQueue()
+
+	first := queue.Add(newTestIncident(time.Minute, 1, 2))
+	second := queue.Add(newTestIncident(2*time.Minute, 3))
+	queue.Add(newTestIncident(3*time.Minute, 4))
+
+	if err := queue.Decide(first.ID, stewards.StatusPenalty, "Drive through"); err != nil {
+		t.Fatalf("Decide: %v", err)
+	}
+	if err := queue.AddNote(first.ID, "Divebomb at turn 1"); err != nil {
+		t.Fatalf("AddNote: %v", err)
+	}
+	// La sanción solo se guarda con StatusPenalty
+	if err := queue.Decide(second.ID, stewards.StatusNoAction, "ignored"); err != nil {
+		t.Fatalf("Decide: %v", err)
+	}
+	if err := queue.Decide(second.ID, stewards.Status(42), ""); !errors.Is(err, raceerrors.ErrInvalidReviewState) {
+		t.Errorf("Se esperaba ErrInvalidReviewState, se obtuvo %v", err)
+	}
+
+	if pending := queue.Pending(); len(pending) != 1 {
+		t.Fatalf("Se esperaba 1 pendiente, hay %d", len(pending))
+	}
+
+	got, _ := queue.Get(second.ID)
+	if got.Penalty != "" || got.DecidedAt.IsZero() {
+		t.Errorf("Decisión inesperada: %+v", got)
+	}
+
+	var text bytes.Buffer
+	if err := queue.WriteReport(&text); err != nil {
+		t.Fatalf("WriteReport: %v", err)
+	}
+	for _, want := range []string{"Incidents: 3", "#1 [Penalty] 1:00.000", "Penalty: Drive through", "Note: Divebomb at turn 1", "#2 [NoAction]"} {
+		if !strings.Contains(text.String(), want) {
+			t.Errorf("El reporte no contiene %q:\n%s", want, text.String())
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "stewards.json")
+	if err := queue.SaveReportFile(path); err != nil {
+		t.Fatalf("SaveReportFile: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+
+	var report struct {
+		Summary map[string]int
+		Entries []struct {
+			ID      int
+			Status  string
+			Penalty string
+		}
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("El reporte JSON no es válido: %v", err)
+	}
+	if report.Summary["Penalty"] != 1 || report.Summary["NoAction"] != 1 || report.Summary["Pending"] != 1 {
+		t.Errorf("Resumen inesperado: %v", report.Summary)
+	}
+	if len(report.Entries) != 3 || report.Entries[0].Status != "Penalty" || report.Entries[0].Penalty != "Drive through" {
+		t.Errorf("Entradas inesperadas: %+v", report.Entries)
+	}
+}
@@ -0,0 +1,137 @@
+package acc_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"RaceAll/internal/acc/world"
+	"RaceAll/internal/broadcast"
+	"RaceAll/internal/sharedmemory"
+)
+
+// frame coloca autos en el plano XZ: positions[i] = {x, z} del CarID i+1
+func frame(packetID int32, positions ...[2]float32) *sharedmemory.Graphics {
+	g := &sharedmemory.Graphics{PacketId: packetID, ActiveCars: int32(len(positions)), PlayerCarID: 1}
+	for i, p := range positions {
+		g.CarID[i] = int32(i + 1)
+		g.CarCoordinates[i] = [3]float32{p[0], 0, p[1]}
+	}
+	return g
+}
+
+func approx(a, b float32) bool {
+	return math.Abs(float64(a-b)) < 0.01
+}
+
+func TestWorldModel_DistanceBearingVelocity(t *testing.T) {
+	wm := world.NewWorldModel()
+	start := time.Now()
+
+	// Auto 1 avanza por +Z a 50 m/s, auto 2 está 30 m por delante y 10 m a +X a 40 m/s
+	wm.UpdateAt(frame(1, [2]float32{0, 0}, [2]float32{10, 30}), start)
+	wm.UpdateAt(frame(2, [2]float32{0, 5}, [2]float32{10, 34}), start.Add(100*time.Millisecond))
+
+	distance, ok := wm.Distance(1, 2)
+	if !ok || !approx(distance, float32(math.Hypot(10, 29))) {
+		t.Errorf("Distance() = %v, %v", distance, ok)
+	}
+
+	bearing, _ := wm.Bearing(1, 2)
+	want := float32(math.Atan2(10, 29) * 180 / math.Pi)
+	if !approx(bearing, want) {
+		t.Errorf("Bearing() = %v, want %v", bearing, want)
+	}
+
+	rel, _ := wm.RelativeVelocity(1, 2)
+	if !approx(rel.Z, -10) || !approx(rel.X, 0) {
+		t.Errorf("RelativeVelocity() = %+v, want Z -10", rel)
+	}
+
+	player, ok := wm.GetPlayerCar()
+	if !ok || player.CarID != 1 || !approx(player.Speed(), 50) {
+		t.Errorf("GetPlayerCar() = %+v, %v", player, ok)
+	}
+
+	if history := wm.GetHistory(1); len(history) != 2 || history[1].Position.Z != 5 {
+		t.Errorf("GetHistory() = %+v", history)
+	}
+}
+
+func TestWorldModel_Nearest(t *testing.T) {
+	wm := world.NewWorldModel()
+	wm.UpdateAt(frame(1,
+		[2]float32{0, 0},
+		[2]float32{0, 100},
+		[2]float32{0, -20},
+		[2]float32{5, 0},
+	), time.Now())
+
+	nearest := wm.Nearest(1, 2)
+	if len(nearest) != 2 || nearest[0].CarID != 4 || nearest[1].CarID != 3 {
+		t.Errorf("Nearest() = %+v, want cars 4 and 3", nearest)
+	}
+
+	if within := wm.Within(1, 50); len(within) != 2 {
+		t.Errorf("Within(50) returned %d cars, want 2", len(within))
+	}
+
+	// Un auto que desaparece del frame se elimina
+	wm.UpdateAt(frame(2, [2]float32{0, 0}), time.Now())
+	if len(wm.GetCars()) != 1 {
+		t.Errorf("GetCars() = %d cars, want 1", len(wm.GetCars()))
+	}
+}
+
+func TestWorldModel_CarIndexFromBroadcast(t *testing.T) {
+	wm := world.NewWorldModel()
+	wm.UpdateAt(frame(1, [2]float32{0, 0}, [2]float32{200, 300}), time.Now())
+
+	if index, confirmed := wm.CarIndex(2); confirmed || index != 2 {
+		t.Errorf("CarIndex() before broadcast = %d, %v; want 2, false", index, confirmed)
+	}
+
+	wm.UpdateFromBroadcast(&broadcast.CarUpdate{CarIndex: 17, WorldPosX: 201, WorldPosY: 298})
+
+	if index, confirmed := wm.CarIndex(2); !confirmed || index != 17 {
+		t.Errorf("CarIndex() = %d, %v; want 17, true", index, confirmed)
+	}
+	if car, _ := wm.GetCar(2); car.CarIndex != 17 {
+		t.Errorf("GetCar().CarIndex = %d, want 17", car.CarIndex)
+	}
+
+	// Demasiado lejos de cualquier auto: no se asocia
+	wm.UpdateFromBroadcast(&broadcast.CarUpdate{CarIndex: 30, WorldPosX: 1000, WorldPosY: 1000})
+	if _, confirmed := wm.CarIndex(30); confirmed {
+		t.Error("CarIndex(30) associated without a nearby car")
+	}
+}
+
+func TestWorldModel_CarIndexNotInherited(t *testing.T) {
+	wm := world.NewWorldModel()
+	now := time.Now()
+	wm.UpdateAt(frame(1, [2]float32{0, 0}, [2]float32{200, 300}), now)
+	wm.UpdateFromBroadcast(&broadcast.CarUpdate{CarIndex: 17, WorldPosX: 200, WorldPosY: 300})
+
+	// El CarIndex 17 aparece ahora junto al CarID 1: el CarID 2 pierde la asociación
+	wm.UpdateFromBroadcast(&broadcast.CarUpdate{CarIndex: 17, WorldPosX: 1, WorldPosY: 1})
+	if index, confirmed := wm.CarIndex(1); !confirmed || index != 17 {
+		t.Errorf("CarIndex(1) = %d, %v; want 17, true", index, confirmed)
+	}
+	if index, confirmed := wm.CarIndex(2); confirmed || index != 2 {
+		t.Errorf("CarIndex(2) = %d, %v; want 2, false", index, confirmed)
+	}
+	if car, _ := wm.GetCar(2); car.CarIndex != 2 {
+		t.Errorf("GetCar(2).CarIndex = %d, want 2", car.CarIndex)
+	}
+
+	// El CarID 1 sale y vuelve a aparecer: no hereda el CarIndex anterior
+	wm.UpdateAt(frame(2), now.Add(time.Second))
+	wm.UpdateAt(frame(3, [2]float32{500, 500}), now.Add(2*time.Second))
+	if index, confirmed := wm.CarIndex(1); confirmed || index != 1 {
+		t.Errorf("CarIndex(1) after rejoin = %d, %v; want 1, false", index, confirmed)
+	}
+	if car, _ := wm.GetCar(1); car.CarIndex != 1 {
+		t.Errorf("GetCar(1).CarIndex after rejoin = %d, want 1", car.CarIndex)
+	}
+}
@@ -0,0 +1,192 @@
+package broadcast_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"RaceAll/internal/broadcast"
+
+	"github.com/rs/zerolog"
+)
+
+func TestCapture_RoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+
+	writer, err := broadcast.NewCaptureWriter(&buf, "127.0.0.1:9000")
+	if err != nil {
+		t.Fatalf("NewCaptureWriter() error = %v", err)
+	}
+
+	datagrams := []struct {
+		direction broadcast.CaptureDirection
+		data      []byte
+	}{
+		{broadcast.CaptureOutbound, []byte{1, 4, 0, 0}},
+		{broadcast.CaptureInbound, []byte{1, 2, 3}},
+		{broadcast.CaptureInbound, []byte{}},
+	}
+	for _, d := range datagrams {
+		if err := writer.WriteDatagram(d.direction, d.data); err != nil {
+			t.Fatalf("WriteDatagram() error = %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	if err := writer.WriteDatagram(broadcast.CaptureInbound, []byte{1}); err == nil {
+		t.Error("WriteDatagram() after Close() should fail")
+	}
+
+	reader, err := broadcast.NewCaptureReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("NewCaptureReader() error = %v", err)
+	}
+	if reader.Header().Address != "127.0.0.1:9000" {
+		t.Errorf("Address = %q", reader.Header().Address)
+	}
+
+	var last time.Duration
+	for i, want := range datagrams {
+		got, err := reader.Next()
+		if err != nil {
+			t.Fatalf("Next() #%d error = %v", i, err)
+		}
+		if got.Direction != want.direction || !bytes.Equal(got.Data, want.data) {
+			t.Errorf("datagram #%d = %v %v, want %v %v", i, got.Direction, got.Data, want.direction, want.data)
+		}
+		if got.Offset < last {
+			t.Errorf("datagram #%d offset %v before %v", i, got.Offset, last)
+		}
+		last = got.Offset
+	}
+
+	if _, err := reader.Next(); err != io.EOF {
+		t.Errorf("Next() at end error = %v, want io.EOF", err)
+	}
+}
+
+func TestCapture_TruncatedEndsAtLastRecord(t *testing.T) {
+	var buf bytes.Buffer
+
+	writer, _ := broadcast.NewCaptureWriter(&buf, "")
+	writer.WriteDatagram(broadcast.CaptureInbound, []byte{7, 0, 0, 0})
+	writer.WriteDatagram(broadcast.CaptureInbound, []byte{7, 1, 1, 1})
+	writer.Close()
+
+	data := buf.Bytes()[:buf.Len()-2]
+	reader, err := broadcast.NewCaptureReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("NewCaptureReader() error = %v", err)
+	}
+
+	if _, err := reader.Next(); err != nil {
+		t.Fatalf("Next() error = %v", err)
+	}
+	if _, err := reader.Next(); err != io.EOF {
+		t.Errorf("Next() on truncated record error = %v, want io.EOF", err)
+	}
+}
+
+func TestCapture_InvalidHeader(t *testing.T) {
+	if _, err := broadcast.NewCaptureReader(bytes.NewReader([]byte("not a capture"))); err == nil {
+		t.Error("NewCaptureReader() should reject an invalid file")
+	}
+}
+
+func TestReplayer_DrivesProtocol(t *testing.T) {
+	var buf bytes.Buffer
+	writer, _ := broadcast.NewCaptureWriter(&buf, "127.0.0.1:9000")
+
+	registration, _ := broadcast.MarshalRegistrationRequest("RaceAll", "asd", 100, "")
+	result, _ := broadcast.MarshalRegistrationResult(broadcast.ConnectionState{ConnectionId: 3, Success: true})
+	track, _ := broadcast.MarshalTrackData(3, broadcast.TrackData{TrackName: "Imola", TrackId: 5, TrackMeters: 4959})
+	event, _ := broadcast.MarshalBroadcastingEvent(broadcast.BroadcastingEvent{Type: broadcast.BroadcastingEventTypeGreenFlag})
+
+	writer.WriteDatagram(broadcast.CaptureOutbound, registration)
+	writer.WriteDatagram(broadcast.CaptureInbound, result)
+	writer.WriteDatagram(broadcast.CaptureInbound, track)
+	writer.WriteDatagram(broadcast.CaptureInbound, []byte{3, 1})
+	writer.WriteDatagram(broadcast.CaptureInbound, event)
+	writer.Close()
+
+	reader, err := broadcast.NewCaptureReader(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("NewCaptureReader() error = %v", err)
+	}
+
+	var sent int
+	protocol := broadcast.NewProtocol("replay", func([]byte) error { sent++; return nil }, zerolog.Nop())
+
+	var states []broadcast.ConnectionState
+	var tracks []broadcast.TrackData
+	var events []broadcast.BroadcastingEvent
+	protocol.OnConnectionStateChanged = func(s broadcast.ConnectionState) { states = append(states, s) }
+	protocol.OnTrackDataUpdate = func(td broadcast.TrackData) { tracks = append(tracks, td) }
+	protocol.OnBroadcastingEvent = func(e broadcast.BroadcastingEvent) { events = append(events, e) }
+
+	replayer := broadcast.NewReplayer(reader, protocol)
+	replayer.SetSpeed(0)
+	if err := replayer.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if len(states) != 1 || states[0].ConnectionId != 3 {
+		t.Errorf("states = %+v", states)
+	}
+	if len(tracks) != 1 || tracks[0].TrackName != "Imola" {
+		t.Errorf("tracks = %+v", tracks)
+	}
+	if len(events) != 1 {
+		t.Errorf("events = %+v", events)
+	}
+
+	stats := replayer.Stats()
+	if stats.Inbound != 4 || stats.Outbound != 1 || stats.Errors != 1 {
+		t.Errorf("Stats() = %+v, want 4 inbound, 1 outbound, 1 error", stats)
+	}
+	// The replay answers the registration by requesting entry list and track data, without network
+	if sent != 2 {
+		t.Errorf("protocol sent %d requests, want 2", sent)
+	}
+}
+
+func TestReplayer_OriginalPace(t *testing.T) {
+	var buf bytes.Buffer
+	writer, _ := broadcast.NewCaptureWriter(&buf, "")
+	writer.WriteDatagram(broadcast.CaptureInbound, []byte{0})
+	time.Sleep(60 * time.Millisecond)
+	writer.WriteDatagram(broadcast.CaptureInbound, []byte{0})
+	writer.Close()
+
+	run := func(speed float64) time.Duration {
+		reader, _ := broadcast.NewCaptureReader(bytes.NewReader(buf.Bytes()))
+		replayer := broadcast.NewReplayer(reader, broadcast.NewProtocol("replay", func([]byte) error { return nil }, zerolog.Nop()))
+		replayer.SetSpeed(speed)
+
+		start := time.Now()
+		if err := replayer.Run(context.Background()); err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		return time.Since(start)
+	}
+
+	if elapsed := run(1); elapsed < 50*time.Millisecond {
+		t.Errorf("replay at speed 1 took %v, want at least the captured 60ms", elapsed)
+	}
+	if elapsed := run(0); elapsed > 30*time.Millisecond {
+		t.Errorf("replay at speed 0 took %v, want no pacing", elapsed)
+	}
+
+	// Cancelling stops the wait
+	reader, _ := broadcast.NewCaptureReader(bytes.NewReader(buf.Bytes()))
+	replayer := broadcast.NewReplayer(reader, broadcast.NewProtocol("replay", func([]byte) error { return nil }, zerolog.Nop()))
+	replayer.SetSpeed(0.01)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := replayer.Run(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Run() with cancelled context error = %v", err)
+	}
+}
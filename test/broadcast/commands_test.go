@@ -0,0 +1,238 @@
+package broadcast_test
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"RaceAll/internal/broadcast"
+	raceerrors "RaceAll/internal/errors"
+)
+
+// fakeCommander records the commands sent by a queue
+type fakeCommander struct {
+	mu     sync.Mutex
+	sent   []broadcast.Command
+	sentAt []time.Time
+	err    error
+}
+
+func (f *fakeCommander) record(command broadcast.Command) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.sent = append(f.sent, command)
+	f.sentAt = append(f.sentAt, time.Now())
+	return f.err
+}
+
+func (f *fakeCommander) commands() ([]broadcast.Command, []time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]broadcast.Command(nil), f.sent...), append([]time.Time(nil), f.sentAt...)
+}
+
+func (f *fakeCommander) SetFocus(carIndex uint16) error {
+	return f.record(broadcast.Command{Type: broadcast.CommandFocus, CarIndex: carIndex})
+}
+
+func (f *fakeCommander) SetCamera(cameraSet, camera string) error {
+	return f.record(broadcast.Command{Type: broadcast.CommandCamera, CameraSet: cameraSet, Camera: camera})
+}
+
+func (f *fakeCommander) SetFocusAndCamera(carIndex uint16, cameraSet, camera string) error {
+	return f.record(broadcast.Command{Type: broadcast.CommandFocusAndCamera, CarIndex: carIndex, CameraSet: cameraSet, Camera: camera})
+}
+
+func (f *fakeCommander) RequestInstantReplay(startSessionTime, durationMS float32, initialFocusedCarIndex int32, initialCameraSet, initialCamera string) error {
+	return f.record(broadcast.Command{Type: broadcast.CommandInstantReplay, StartSessionTime: startSessionTime, DurationMS: durationMS})
+}
+
+func (f *fakeCommander) RequestHUDPage(hudPage string) error {
+	return f.record(broadcast.Command{Type: broadcast.CommandHUDPage, HUDPage: hudPage})
+}
+
+// waitSent waits until the commander received n commands
+func waitSent(t *testing.T, commander *fakeCommander, n int) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if sent, _ := commander.commands(); len(sent) >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timeout waiting for %d commands", n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func waitResult(t *testing.T, results <-chan broadcast.CommandResult) broadcast.CommandResult {
+	t.Helper()
+
+	select {
+	case result := <-results:
+		return result
+	case <-time.After(2 * time.Second):
+		t.Fatal("timeout waiting for command result")
+		return broadcast.CommandResult{}
+	}
+}
+
+func TestCommandQueue_CoalescesAndConfirms(t *testing.T) {
+	commander := &fakeCommander{}
+	queue := broadcast.NewCommandQueue(commander, broadcast.CommandQueueConfig{MinInterval: 5 * time.Millisecond})
+
+	// Queued before Start: the last command overrides the other three
+	focus1 := queue.Submit(broadcast.Command{Type: broadcast.CommandFocus, CarIndex: 1})
+	focus2 := queue.Submit(broadcast.Command{Type: broadcast.CommandFocus, CarIndex: 2})
+	camera := queue.Submit(broadcast.Command{Type: broadcast.CommandCamera, CameraSet: "set1", Camera: "Camera1"})
+	both := queue.Submit(broadcast.Command{Type: broadcast.CommandFocusAndCamera, CarIndex: 3, CameraSet: "Helicam", Camera: "Helicam"})
+
+	for _, results := range []<-chan broadcast.CommandResult{focus1, focus2, camera} {
+		if result := waitResult(t, results); !errors.Is(result.Err, raceerrors.ErrCommandSuperseded) || result.Attempts != 0 {
+			t.Errorf("result = %+v, want superseded before sending", result)
+		}
+	}
+
+	queue.Start()
+	defer queue.Stop()
+
+	waitSent(t, commander, 1)
+
+	// An update without the change does not confirm it
+	queue.HandleRealtimeUpdate(broadcast.RealtimeUpdate{FocusedCarIndex: 3, ActiveCameraSet: "set1", ActiveCamera: "Camera1"})
+	queue.HandleRealtimeUpdate(broadcast.RealtimeUpdate{FocusedCarIndex: 3, ActiveCameraSet: "Helicam", ActiveCamera: "Helicam"})
+
+	result := waitResult(t, both)
+	if result.Err != nil || result.Attempts != 1 {
+		t.Errorf("result = %+v, want confirmed after one attempt", result)
+	}
+
+	sent, _ := commander.commands()
+	if len(sent) != 1 || sent[0].Type != broadcast.CommandFocusAndCamera || sent[0].CarIndex != 3 {
+		t.Errorf("sent = %+v, want only the focus and camera change", sent)
+	}
+	if queue.Pending() != 0 {
+		t.Errorf("Pending() = %d, want 0", queue.Pending())
+	}
+}
+
+func TestCommandQueue_RetriesUntilTimeout(t *testing.T) {
+	commander := &fakeCommander{}
+	queue := broadcast.NewCommandQueue(commander, broadcast.CommandQueueConfig{
+		MinInterval:    5 * time.Millisecond,
+		ConfirmTimeout: 20 * time.Millisecond,
+		MaxAttempts:    2,
+	})
+	queue.Start()
+	defer queue.Stop()
+
+	result := waitResult(t, queue.Submit(broadcast.Command{Type: broadcast.CommandHUDPage, HUDPage: "Broadcasting"}))
+	if !errors.Is(result.Err, raceerrors.ErrCommandNotConfirmed) || result.Attempts != 2 {
+		t.Errorf("result = %+v, want not confirmed after 2 attempts", result)
+	}
+
+	if sent, _ := commander.commands(); len(sent) != 2 {
+		t.Errorf("sent %d times, want 2", len(sent))
+	}
+}
+
+func TestCommandQueue_RateLimit(t *testing.T) {
+	commander := &fakeCommander{}
+	queue := broadcast.NewCommandQueue(commander, broadcast.CommandQueueConfig{MinInterval: 50 * time.Millisecond})
+	queue.Start()
+	defer queue.Stop()
+
+	// Different targets, none of them is superseded
+	queue.Submit(broadcast.Command{Type: broadcast.CommandHUDPage, HUDPage: "Basic HUD"})
+	queue.Submit(broadcast.Command{Type: broadcast.CommandFocus, CarIndex: 2})
+	queue.Submit(broadcast.Command{Type: broadcast.CommandCamera, CameraSet: "set1", Camera: "Camera1"})
+
+	waitSent(t, commander, 3)
+
+	_, sentAt := commander.commands()
+	for i := 1; i < len(sentAt); i++ {
+		if gap := sentAt[i].Sub(sentAt[i-1]); gap < 45*time.Millisecond {
+			t.Errorf("commands %d and %d sent %v apart, want at least 50ms", i-1, i, gap)
+		}
+	}
+}
+
+func TestCommandQueue_SendErrorAndStop(t *testing.T) {
+	commander := &fakeCommander{err: raceerrors.ErrNotConnected}
+	queue := broadcast.NewCommandQueue(commander, broadcast.CommandQueueConfig{MinInterval: 5 * time.Millisecond})
+	queue.Start()
+
+	result := waitResult(t, queue.Submit(broadcast.Command{Type: broadcast.CommandFocus, CarIndex: 1}))
+	if !errors.Is(result.Err, raceerrors.ErrNotConnected) {
+		t.Errorf("result = %+v, want send error", result)
+	}
+
+	commander.mu.Lock()
+	commander.err = nil
+	commander.mu.Unlock()
+
+	results := queue.Submit(broadcast.Command{Type: broadcast.CommandFocus, CarIndex: 2})
+	waitSent(t, commander, 2)
+	queue.Stop()
+
+	if result := waitResult(t, results); !errors.Is(result.Err, raceerrors.ErrCommandQueueClosed) {
+		t.Errorf("result = %+v, want queue closed", result)
+	}
+}
+
+func TestCommandQueue_SubmitAfterStop(t *testing.T) {
+	commander := &fakeCommander{}
+	queue := broadcast.NewCommandQueue(commander, broadcast.CommandQueueConfig{MinInterval: 5 * time.Millisecond})
+	queue.Start()
+	queue.Stop()
+
+	result := waitResult(t, queue.Submit(broadcast.Command{Type: broadcast.CommandFocus, CarIndex: 1}))
+	if !errors.Is(result.Err, raceerrors.ErrCommandQueueClosed) || result.Attempts != 0 {
+		t.Errorf("result = %+v, want queue closed before sending", result)
+	}
+	if queue.Pending() != 0 {
+		t.Errorf("Pending() = %d, want 0", queue.Pending())
+	}
+
+	// Start accepts commands again
+	queue.Start()
+	defer queue.Stop()
+
+	results := queue.Submit(broadcast.Command{Type: broadcast.CommandFocus, CarIndex: 2})
+	waitSent(t, commander, 1)
+	queue.HandleRealtimeUpdate(broadcast.RealtimeUpdate{FocusedCarIndex: 2})
+	if result := waitResult(t, results); result.Err != nil {
+		t.Errorf("result = %+v, want confirmed after Start", result)
+	}
+}
+
+func TestCommandQueue_InstantReplayConfirmedWhenStarted(t *testing.T) {
+	commander := &fakeCommander{}
+	queue := broadcast.NewCommandQueue(commander, broadcast.CommandQueueConfig{MinInterval: 5 * time.Millisecond})
+	queue.Start()
+	defer queue.Stop()
+
+	// A replay is already playing when the command is sent
+	queue.HandleRealtimeUpdate(broadcast.RealtimeUpdate{IsReplayPlaying: true})
+
+	results := queue.Submit(broadcast.Command{Type: broadcast.CommandInstantReplay, StartSessionTime: 1000, DurationMS: 5000})
+	waitSent(t, commander, 1)
+
+	queue.HandleRealtimeUpdate(broadcast.RealtimeUpdate{IsReplayPlaying: true})
+	select {
+	case result := <-results:
+		t.Fatalf("result = %+v, want no confirmation while the previous replay plays", result)
+	default:
+	}
+
+	// The previous replay ends and the requested one starts
+	queue.HandleRealtimeUpdate(broadcast.RealtimeUpdate{IsReplayPlaying: false})
+	queue.HandleRealtimeUpdate(broadcast.RealtimeUpdate{IsReplayPlaying: true})
+	if result := waitResult(t, results); result.Err != nil || result.Attempts != 1 {
+		t.Errorf("result = %+v, want confirmed after one attempt", result)
+	}
+}
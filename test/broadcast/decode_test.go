@@ -0,0 +1,288 @@
+package broadcast_test
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"testing"
+
+	"RaceAll/internal/broadcast"
+
+	"github.com/rs/zerolog"
+)
+
+func sampleCarUpdate() broadcast.RealtimeCarUpdate {
+	lastLap := sampleLap()
+	lastLap.IsInvalid = true
+	lastLap.Type = broadcast.LapTypeInlap
+
+	return broadcast.RealtimeCarUpdate{
+		CarIndex:       1,
+		DriverIndex:    1,
+		DriverCount:    2,
+		Gear:           5,
+		WorldPosX:      -120.5,
+		WorldPosY:      340.25,
+		Heading:        1.57,
+		CarLocation:    broadcast.CarLocationTrack,
+		Kmh:            243,
+		Position:       3,
+		CupPosition:    2,
+		TrackPosition:  4,
+		SplinePosition: 0.731,
+		Laps:           17,
+		Delta:          -312,
+		BestSessionLap: sampleLap(),
+		LastLap:        lastLap,
+		CurrentLap:     broadcast.LapInfo{CarIndex: 1, DriverIndex: 1, Type: broadcast.LapTypeRegular},
+	}
+}
+
+func carUpdateBody(t testing.TB, update broadcast.RealtimeCarUpdate) []byte {
+	t.Helper()
+
+	data, err := broadcast.MarshalRealtimeCarUpdate(update)
+	if err != nil {
+		t.Fatalf("MarshalRealtimeCarUpdate() error = %v", err)
+	}
+	return data[1:]
+}
+
+func TestDecodeRealtimeCarUpdate_MatchesUnmarshal(t *testing.T) {
+	data := carUpdateBody(t, sampleCarUpdate())
+
+	want, err := broadcast.UnmarshalRealtimeCarUpdate(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("UnmarshalRealtimeCarUpdate() error = %v", err)
+	}
+
+	var update broadcast.CarUpdate
+	if err := broadcast.DecodeRealtimeCarUpdate(data, &update); err != nil {
+		t.Fatalf("DecodeRealtimeCarUpdate() error = %v", err)
+	}
+
+	if got := update.RealtimeCarUpdate(); !reflect.DeepEqual(got, want) {
+		t.Errorf("DecodeRealtimeCarUpdate() = %+v, want %+v", got, want)
+	}
+	if update.CurrentLap.HasLapTime() || update.CurrentLap.HasSplit(0) {
+		t.Errorf("current lap without times = %+v", update.CurrentLap)
+	}
+	if !update.BestSessionLap.HasSplit(1) || update.BestSessionLap.HasSplit(2) {
+		t.Errorf("best lap splits = %v", update.BestSessionLap.Splits)
+	}
+}
+
+func TestDecodeRealtimeCarUpdate_Allocations(t *testing.T) {
+	data := carUpdateBody(t, sampleCarUpdate())
+	update := broadcast.AcquireCarUpdate()
+	defer broadcast.ReleaseCarUpdate(update)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := broadcast.DecodeRealtimeCarUpdate(data, update); err != nil {
+			t.Fatalf("DecodeRealtimeCarUpdate() error = %v", err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("DecodeRealtimeCarUpdate allocates %.0f times, want 0", allocs)
+	}
+}
+
+// newDecodeProtocol crea un protocolo con el auto 1 en la entry list
+func newDecodeProtocol(t testing.TB) *broadcast.Protocol {
+	t.Helper()
+
+	protocol := broadcast.NewProtocol("bench", func([]byte) error { return nil }, zerolog.Nop())
+
+	car := broadcast.DefaultMockServerConfig().Cars[0].Info
+	data, err := broadcast.MarshalEntryListCar(car)
+	if err != nil {
+		t.Fatalf("MarshalEntryListCar() error = %v", err)
+	}
+	if err := protocol.ProcessMessage(data); err != nil {
+		t.Fatalf("ProcessMessage() error = %v", err)
+	}
+	return protocol
+}
+
+func TestProcessMessage_CarUpdateAllocations(t *testing.T) {
+	protocol := newDecodeProtocol(t)
+
+	var received int
+	protocol.OnCarUpdate = func(update *broadcast.CarUpdate) {
+		received++
+	}
+
+	data, err := broadcast.MarshalRealtimeCarUpdate(sampleCarUpdate())
+	if err != nil {
+		t.Fatalf("MarshalRealtimeCarUpdate() error = %v", err)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := protocol.ProcessMessage(data); err != nil {
+			t.Fatalf("ProcessMessage() error = %v", err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("ProcessMessage allocates %.0f times per car update, want 0", allocs)
+	}
+	if received == 0 {
+		t.Error("OnCarUpdate not called")
+	}
+}
+
+// FuzzDecodeRealtimeCarUpdate checks that the cursor decoder and the reader
+// decoder agree on every input
+func FuzzDecodeRealtimeCarUpdate(f *testing.F) {
+	f.Add(carUpdateBody(f, sampleCarUpdate()))
+	f.Add([]byte{})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		want, wantErr := broadcast.UnmarshalRealtimeCarUpdate(bytes.NewReader(data))
+
+		var update broadcast.CarUpdate
+		err := broadcast.DecodeRealtimeCarUpdate(data, &update)
+
+		if (err == nil) != (wantErr == nil) {
+			t.Fatalf("DecodeRealtimeCarUpdate() error = %v, UnmarshalRealtimeCarUpdate() error = %v", err, wantErr)
+		}
+		if err != nil {
+			return
+		}
+
+		// Compared encoded, NaN positions are not DeepEqual to themselves
+		got, _ := broadcast.MarshalRealtimeCarUpdate(update.RealtimeCarUpdate())
+		expected, _ := broadcast.MarshalRealtimeCarUpdate(want)
+		if !bytes.Equal(got, expected) {
+			t.Fatalf("DecodeRealtimeCarUpdate() = %+v, want %+v", update.RealtimeCarUpdate(), want)
+		}
+	})
+}
+
+func BenchmarkUnmarshalRealtimeCarUpdate(b *testing.B) {
+	data := carUpdateBody(b, sampleCarUpdate())
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := broadcast.UnmarshalRealtimeCarUpdate(bytes.NewReader(data)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeRealtimeCarUpdate(b *testing.B) {
+	data := carUpdateBody(b, sampleCarUpdate())
+	update := broadcast.AcquireCarUpdate()
+	defer broadcast.ReleaseCarUpdate(update)
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if err := broadcast.DecodeRealtimeCarUpdate(data, update); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkProcessMessage_CarUpdate(b *testing.B) {
+	data, err := broadcast.MarshalRealtimeCarUpdate(sampleCarUpdate())
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("OnCarUpdate", func(b *testing.B) {
+		protocol := newDecodeProtocol(b)
+		protocol.OnCarUpdate = func(*broadcast.CarUpdate) {}
+		b.ReportAllocs()
+
+		for i := 0; i < b.N; i++ {
+			if err := protocol.ProcessMessage(data); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("OnRealtimeCarUpdate", func(b *testing.B) {
+		protocol := newDecodeProtocol(b)
+		protocol.OnRealtimeCarUpdate = func(broadcast.RealtimeCarUpdate) {}
+		b.ReportAllocs()
+
+		for i := 0; i < b.N; i++ {
+			if err := protocol.ProcessMessage(data); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// newDecodeService crea un servicio sin conexión con el auto 1 en la entry list
+func newDecodeService(t testing.TB) *broadcast.Service {
+	t.Helper()
+
+	service := broadcast.NewService(broadcast.DefaultConfig())
+
+	car := broadcast.DefaultMockServerConfig().Cars[0].Info
+	data, err := broadcast.MarshalEntryListCar(car)
+	if err != nil {
+		t.Fatalf("MarshalEntryListCar() error = %v", err)
+	}
+	if err := service.ProcessMessage(data); err != nil {
+		t.Fatalf("ProcessMessage() error = %v", err)
+	}
+	return service
+}
+
+func TestService_CarUpdateAllocations(t *testing.T) {
+	service := newDecodeService(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	messages := service.Subscribe()
+	carUpdates := service.SubscribeRealtimeCarUpdates(ctx, broadcast.Filter{})
+
+	data, err := broadcast.MarshalRealtimeCarUpdate(sampleCarUpdate())
+	if err != nil {
+		t.Fatalf("MarshalRealtimeCarUpdate() error = %v", err)
+	}
+
+	// Solo el Payload de BroadcastMessage asigna: las vueltas no tienen punteros
+	allocs := testing.AllocsPerRun(100, func() {
+		if err := service.ProcessMessage(data); err != nil {
+			t.Fatalf("ProcessMessage() error = %v", err)
+		}
+	})
+	if allocs > 1 {
+		t.Errorf("Service.ProcessMessage allocates %.0f times per car update, want at most 1", allocs)
+	}
+
+	msg := <-messages
+	for msg.Type != "RealtimeCarUpdate" {
+		msg = <-messages
+	}
+	if update, ok := msg.Payload.(broadcast.CarUpdate); !ok || update.CarIndex != 1 {
+		t.Errorf("Payload = %#v, want the CarUpdate of car 1", msg.Payload)
+	}
+	want := sampleCarUpdate()
+	if update := <-carUpdates; update.LastLap.GetLapTimeMS() != want.LastLap.GetLapTimeMS() {
+		t.Errorf("LastLap.GetLapTimeMS() = %d, want %d", update.LastLap.GetLapTimeMS(), want.LastLap.GetLapTimeMS())
+	}
+}
+
+func BenchmarkService_CarUpdate(b *testing.B) {
+	service := newDecodeService(b)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	service.Subscribe()
+	service.SubscribeRealtimeCarUpdates(ctx, broadcast.Filter{})
+
+	data, err := broadcast.MarshalRealtimeCarUpdate(sampleCarUpdate())
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		if err := service.ProcessMessage(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
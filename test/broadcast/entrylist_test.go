@@ -0,0 +1,164 @@
+package broadcast_test
+
+import (
+	"testing"
+	"time"
+
+	"RaceAll/internal/broadcast"
+
+	"github.com/rs/zerolog"
+)
+
+// entryListHarness feeds messages to a protocol and records its entry list changes
+type entryListHarness struct {
+	t        *testing.T
+	protocol *broadcast.Protocol
+	changes  []broadcast.EntryListChange
+}
+
+func newEntryListHarness(t *testing.T) *entryListHarness {
+	h := &entryListHarness{
+		t:        t,
+		protocol: broadcast.NewProtocol("test", func([]byte) error { return nil }, zerolog.Nop()),
+	}
+	h.protocol.OnEntryListChange = func(change broadcast.EntryListChange) {
+		h.changes = append(h.changes, change)
+	}
+	return h
+}
+
+func (h *entryListHarness) process(data []byte, err error) {
+	h.t.Helper()
+
+	if err != nil {
+		h.t.Fatalf("marshal: %v", err)
+	}
+	if err := h.protocol.ProcessMessage(data); err != nil {
+		h.t.Fatalf("ProcessMessage() error = %v", err)
+	}
+}
+
+func (h *entryListHarness) entryList(cars ...broadcast.CarInfo) {
+	h.t.Helper()
+
+	carIndexes := make([]uint16, len(cars))
+	for i, car := range cars {
+		carIndexes[i] = car.CarIndex
+	}
+	h.process(broadcast.MarshalEntryList(1, carIndexes))
+	for _, car := range cars {
+		h.process(broadcast.MarshalEntryListCar(car))
+	}
+}
+
+// take returns the changes recorded since the last call
+func (h *entryListHarness) take() []broadcast.EntryListChange {
+	changes := h.changes
+	h.changes = nil
+	return changes
+}
+
+func TestProtocol_EntryListChanges(t *testing.T) {
+	h := newEntryListHarness(t)
+
+	config := broadcast.DefaultMockServerConfig()
+	car1, car2, car3 := config.Cars[0].Info, config.Cars[1].Info, config.Cars[2].Info
+
+	h.entryList(car1, car2, car3)
+	if changes := h.take(); len(changes) != 3 || changes[0].Type != broadcast.CarJoined || changes[0].CarIndex != car1.CarIndex {
+		t.Fatalf("changes = %+v, want 3 CarJoined", changes)
+	}
+
+	// The same entry list again changes nothing
+	h.entryList(car1, car2, car3)
+	if changes := h.take(); len(changes) != 0 {
+		t.Errorf("changes = %+v, want none", changes)
+	}
+
+	renamed := car3
+	renamed.TeamName = "Renamed Team"
+	h.process(broadcast.MarshalEntryListCar(renamed))
+	if changes := h.take(); len(changes) != 1 || changes[0].Type != broadcast.CarInfoChanged || changes[0].Previous.TeamName != car3.TeamName {
+		t.Errorf("changes = %+v, want CarInfoChanged", changes)
+	}
+
+	// Car 2 disconnects: the next entry list does not have it
+	h.process(broadcast.MarshalEntryList(1, []uint16{car1.CarIndex, car3.CarIndex}))
+	changes := h.take()
+	if len(changes) != 1 || changes[0].Type != broadcast.CarLeft || changes[0].CarIndex != car2.CarIndex || changes[0].TimedOut {
+		t.Fatalf("changes = %+v, want CarLeft of car 2", changes)
+	}
+	if changes[0].Car.TeamName != car2.TeamName {
+		t.Errorf("CarLeft car = %+v, want last known data", changes[0].Car)
+	}
+
+	entryList := h.protocol.GetEntryList()
+	if len(entryList) != 2 || len(entryList[0].Drivers) == 0 || len(entryList[1].Drivers) == 0 {
+		t.Errorf("entry list = %+v, want cars 1 and 3 with details", entryList)
+	}
+}
+
+func TestProtocol_DriverSwapFromCarUpdate(t *testing.T) {
+	h := newEntryListHarness(t)
+
+	car := broadcast.DefaultMockServerConfig().Cars[0].Info
+	h.entryList(car)
+	h.take()
+
+	update := broadcast.RealtimeCarUpdate{
+		CarIndex:    car.CarIndex,
+		DriverIndex: uint16(car.CurrentDriverIndex) + 1,
+		DriverCount: uint8(len(car.Drivers)),
+	}
+	h.process(broadcast.MarshalRealtimeCarUpdate(update))
+
+	changes := h.take()
+	if len(changes) != 1 || changes[0].Type != broadcast.DriverSwapped {
+		t.Fatalf("changes = %+v, want DriverSwapped", changes)
+	}
+	if changes[0].Previous.CurrentDriverIndex != car.CurrentDriverIndex || changes[0].Car.CurrentDriverIndex != byte(update.DriverIndex) {
+		t.Errorf("swap from %d to %d", changes[0].Previous.CurrentDriverIndex, changes[0].Car.CurrentDriverIndex)
+	}
+
+	info, ok := h.protocol.GetCarInfo(car.CarIndex)
+	if !ok || info.CurrentDriverIndex != byte(update.DriverIndex) {
+		t.Errorf("GetCarInfo() = %+v, want current driver %d", info, update.DriverIndex)
+	}
+
+	// Later updates of the same driver are not a swap
+	h.process(broadcast.MarshalRealtimeCarUpdate(update))
+	if changes := h.take(); len(changes) != 0 {
+		t.Errorf("changes = %+v, want none", changes)
+	}
+}
+
+func TestProtocol_CarTimeout(t *testing.T) {
+	h := newEntryListHarness(t)
+	h.protocol.SetCarTimeout(20 * time.Millisecond)
+
+	config := broadcast.DefaultMockServerConfig()
+	car1, car2 := config.Cars[0].Info, config.Cars[1].Info
+	h.entryList(car1, car2)
+	h.take()
+
+	time.Sleep(30 * time.Millisecond)
+
+	// Only car 1 keeps sending updates
+	h.process(broadcast.MarshalRealtimeCarUpdate(broadcast.RealtimeCarUpdate{
+		CarIndex:    car1.CarIndex,
+		DriverIndex: uint16(car1.CurrentDriverIndex),
+		DriverCount: uint8(len(car1.Drivers)),
+	}))
+	h.process(broadcast.MarshalRealtimeUpdate(config.Session))
+
+	changes := h.take()
+	if len(changes) != 1 || changes[0].Type != broadcast.CarLeft || changes[0].CarIndex != car2.CarIndex || !changes[0].TimedOut {
+		t.Fatalf("changes = %+v, want timed out CarLeft of car 2", changes)
+	}
+	if _, ok := h.protocol.GetCarInfo(car2.CarIndex); ok {
+		t.Error("timed out car still in the entry list")
+	}
+	if _, ok := h.protocol.GetCarInfo(car1.CarIndex); !ok {
+		t.Error("car 1 removed while sending updates")
+	}
+}
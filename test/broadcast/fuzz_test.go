@@ -0,0 +1,281 @@
+package broadcast_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"RaceAll/internal/broadcast"
+	raceerrors "RaceAll/internal/errors"
+
+	"github.com/rs/zerolog"
+)
+
+// inboundSeeds returns one valid message of each inbound type, type byte included
+func inboundSeeds(t testing.TB) [][]byte {
+	t.Helper()
+
+	config := broadcast.DefaultMockServerConfig()
+	car := config.Cars[0].Info
+
+	update := config.Session
+	update.SessionTime = 5 * time.Minute
+	update.IsReplayPlaying = true
+	update.BestSessionLap = sampleLap()
+
+	carUpdate := broadcast.RealtimeCarUpdate{
+		CarIndex:       car.CarIndex,
+		DriverCount:    uint8(len(car.Drivers)),
+		Gear:           4,
+		CarLocation:    broadcast.CarLocationTrack,
+		Position:       1,
+		SplinePosition: 0.5,
+		BestSessionLap: sampleLap(),
+		LastLap:        sampleLap(),
+		CurrentLap:     sampleLap(),
+	}
+
+	var seeds [][]byte
+	add := func(data []byte, err error) {
+		if err != nil {
+			t.Fatalf("marshal seed: %v", err)
+		}
+		seeds = append(seeds, data)
+	}
+
+	add(broadcast.MarshalRegistrationResult(broadcast.ConnectionState{ConnectionId: 1, Success: true, ErrorMsg: "ok"}))
+	add(broadcast.MarshalEntryList(1, []uint16{1, 2, 3}))
+	add(broadcast.MarshalEntryListCar(car))
+	add(broadcast.MarshalTrackData(1, config.Track))
+	add(broadcast.MarshalRealtimeUpdate(update))
+	add(broadcast.MarshalRealtimeCarUpdate(carUpdate))
+	add(broadcast.MarshalBroadcastingEvent(broadcast.BroadcastingEvent{Type: broadcast.BroadcastingEventTypeAccident, Msg: "Accident", TimeMs: 1000, CarId: 1}))
+	return seeds
+}
+
+// outboundSeeds returns one valid request of each outbound type, type byte included
+func outboundSeeds(t testing.TB) [][]byte {
+	t.Helper()
+
+	carIndex := uint16(2)
+	cameraSet, camera := "set1", "Camera1"
+
+	var seeds [][]byte
+	add := func(data []byte, err error) {
+		if err != nil {
+			t.Fatalf("marshal seed: %v", err)
+		}
+		seeds = append(seeds, data)
+	}
+
+	add(broadcast.MarshalRegistrationRequest("RaceAll", "asd", 100, "cmd"))
+	add(broadcast.MarshalEntryListRequest(1))
+	add(broadcast.MarshalSetFocusRequest(1, &carIndex, &cameraSet, &camera))
+	add(broadcast.MarshalInstantReplayRequest(1, 1000, 5000, 2, cameraSet, camera))
+	add(broadcast.MarshalHUDPageRequest(1, "Basic HUD"))
+	return seeds
+}
+
+// fuzzUnmarshal feeds message bodies to an Unmarshal function. Any input may
+// fail, but never panic and always with a typed decode error.
+func fuzzUnmarshal(f *testing.F, seeds [][]byte, unmarshal func(io.Reader) error) {
+	for _, seed := range seeds {
+		f.Add(seed[1:])
+	}
+	f.Add([]byte{})
+	f.Add([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := unmarshal(bytes.NewReader(data)); err != nil && !raceerrors.IsDecodeError(err) {
+			t.Fatalf("untyped error: %v", err)
+		}
+	})
+}
+
+func FuzzUnmarshalRegistrationResult(f *testing.F) {
+	fuzzUnmarshal(f, inboundSeeds(f), func(r io.Reader) error {
+		_, err := broadcast.UnmarshalRegistrationResult(r)
+		return err
+	})
+}
+
+func FuzzUnmarshalEntryList(f *testing.F) {
+	fuzzUnmarshal(f, inboundSeeds(f), func(r io.Reader) error {
+		_, _, err := broadcast.UnmarshalEntryList(r)
+		return err
+	})
+}
+
+func FuzzUnmarshalEntryListCar(f *testing.F) {
+	fuzzUnmarshal(f, inboundSeeds(f), func(r io.Reader) error {
+		_, err := broadcast.UnmarshalEntryListCar(r)
+		return err
+	})
+}
+
+func FuzzUnmarshalTrackData(f *testing.F) {
+	fuzzUnmarshal(f, inboundSeeds(f), func(r io.Reader) error {
+		_, _, err := broadcast.UnmarshalTrackData(r)
+		return err
+	})
+}
+
+func FuzzUnmarshalRealtimeUpdate(f *testing.F) {
+	fuzzUnmarshal(f, inboundSeeds(f), func(r io.Reader) error {
+		_, err := broadcast.UnmarshalRealtimeUpdate(r)
+		return err
+	})
+}
+
+func FuzzUnmarshalRealtimeCarUpdate(f *testing.F) {
+	fuzzUnmarshal(f, inboundSeeds(f), func(r io.Reader) error {
+		_, err := broadcast.UnmarshalRealtimeCarUpdate(r)
+		return err
+	})
+}
+
+func FuzzUnmarshalBroadcastingEvent(f *testing.F) {
+	fuzzUnmarshal(f, inboundSeeds(f), func(r io.Reader) error {
+		_, err := broadcast.UnmarshalBroadcastingEvent(r)
+		return err
+	})
+}
+
+func FuzzUnmarshalRegistrationRequest(f *testing.F) {
+	fuzzUnmarshal(f, outboundSeeds(f), func(r io.Reader) error {
+		_, err := broadcast.UnmarshalRegistrationRequest(r)
+		return err
+	})
+}
+
+func FuzzUnmarshalConnectionRequest(f *testing.F) {
+	fuzzUnmarshal(f, outboundSeeds(f), func(r io.Reader) error {
+		_, err := broadcast.UnmarshalConnectionRequest(r)
+		return err
+	})
+}
+
+func FuzzUnmarshalFocusRequest(f *testing.F) {
+	fuzzUnmarshal(f, outboundSeeds(f), func(r io.Reader) error {
+		_, err := broadcast.UnmarshalFocusRequest(r)
+		return err
+	})
+}
+
+func FuzzUnmarshalInstantReplayRequest(f *testing.F) {
+	fuzzUnmarshal(f, outboundSeeds(f), func(r io.Reader) error {
+		_, err := broadcast.UnmarshalInstantReplayRequest(r)
+		return err
+	})
+}
+
+func FuzzUnmarshalHUDPageRequest(f *testing.F) {
+	fuzzUnmarshal(f, outboundSeeds(f), func(r io.Reader) error {
+		_, err := broadcast.UnmarshalHUDPageRequest(r)
+		return err
+	})
+}
+
+func FuzzProcessMessage(f *testing.F) {
+	for _, seed := range inboundSeeds(f) {
+		f.Add(seed)
+	}
+	f.Add([]byte{})
+	f.Add([]byte{0xff})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		protocol := broadcast.NewProtocol("fuzz", func([]byte) error { return nil }, zerolog.Nop())
+		protocol.OnEntrylistUpdate = func(broadcast.CarInfo) {}
+		protocol.OnRealtimeCarUpdate = func(broadcast.RealtimeCarUpdate) {}
+
+		if err := protocol.ProcessMessage(data); err != nil && !raceerrors.IsDecodeError(err) {
+			t.Fatalf("untyped error: %v", err)
+		}
+		protocol.GetEntryList()
+	})
+}
+
+func TestUnmarshal_DecodeErrors(t *testing.T) {
+	le := binary.LittleEndian
+
+	tests := []struct {
+		name       string
+		data       []byte
+		unmarshal  func(io.Reader) error
+		wantField  string
+		wantOffset int
+		wantErr    error
+	}{
+		{
+			name: "Entry list count larger than data",
+			// connectionId, carCount = 200, a single car index
+			data: le.AppendUint16(le.AppendUint16(le.AppendUint32(nil, 1), 200), 1),
+			unmarshal: func(r io.Reader) error {
+				_, _, err := broadcast.UnmarshalEntryList(r)
+				return err
+			},
+			wantField:  "carCount",
+			wantOffset: 4,
+			wantErr:    raceerrors.ErrLengthOutOfRange,
+		},
+		{
+			name: "String length larger than data",
+			// connectionId, hudPage length = 65535, three bytes
+			data: append(le.AppendUint16(le.AppendUint32(nil, 1), 0xffff), 'a', 'b', 'c'),
+			unmarshal: func(r io.Reader) error {
+				_, err := broadcast.UnmarshalHUDPageRequest(r)
+				return err
+			},
+			wantField:  "hudPage",
+			wantOffset: 4,
+			wantErr:    raceerrors.ErrLengthOutOfRange,
+		},
+		{
+			name: "Truncated event",
+			// eventType, empty msg, timeMs, half of carId
+			data: append(le.AppendUint32(append([]byte{4}, 0, 0), 1000), 1, 0),
+			unmarshal: func(r io.Reader) error {
+				_, err := broadcast.UnmarshalBroadcastingEvent(r)
+				return err
+			},
+			wantField:  "carId",
+			wantOffset: 7,
+			wantErr:    io.ErrUnexpectedEOF,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.unmarshal(bytes.NewReader(tt.data))
+
+			var decodeErr *raceerrors.DecodeError
+			if !errors.As(err, &decodeErr) {
+				t.Fatalf("error = %v, want DecodeError", err)
+			}
+			if decodeErr.Field != tt.wantField || decodeErr.Offset != tt.wantOffset {
+				t.Errorf("field %q at %d, want %q at %d", decodeErr.Field, decodeErr.Offset, tt.wantField, tt.wantOffset)
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUnmarshalTrackData_CountLimits(t *testing.T) {
+	track := broadcast.DefaultMockServerConfig().Track
+	track.HUDPages = make([]string, broadcast.MaxHUDPages+1)
+
+	data, err := broadcast.MarshalTrackData(1, track)
+	if err != nil {
+		t.Fatalf("MarshalTrackData() error = %v", err)
+	}
+
+	_, _, err = broadcast.UnmarshalTrackData(body(t, data, broadcast.InboundTrackData))
+	if !raceerrors.IsDecodeError(err) || !raceerrors.IsValidationError(err) {
+		t.Errorf("error = %v, want decode and validation error", err)
+	}
+}
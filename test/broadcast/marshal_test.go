@@ -0,0 +1,346 @@
+package broadcast_test
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"RaceAll/internal/broadcast"
+)
+
+func int32Ptr(v int32) *int32 {
+	return &v
+}
+
+// body checks the message type byte and returns a reader over the rest
+func body(t *testing.T, data []byte, expected broadcast.InboundMessageType) *bytes.Reader {
+	t.Helper()
+	if len(data) == 0 {
+		t.Fatal("empty message")
+	}
+	if broadcast.InboundMessageType(data[0]) != expected {
+		t.Fatalf("message type = %d, want %d", data[0], expected)
+	}
+	return bytes.NewReader(data[1:])
+}
+
+func sampleLap() broadcast.LapInfo {
+	return broadcast.LapInfo{
+		LaptimeMS:      int32Ptr(107345),
+		Splits:         [3]*int32{int32Ptr(35000), int32Ptr(36000), nil},
+		CarIndex:       11,
+		DriverIndex:    1,
+		IsValidForBest: true,
+		Type:           broadcast.LapTypeRegular,
+	}
+}
+
+func TestMarshalRegistrationResult_RoundTrip(t *testing.T) {
+	tests := []broadcast.ConnectionState{
+		{ConnectionId: 7, Success: true, IsReadonly: false},
+		{ConnectionId: 8, Success: true, IsReadonly: true},
+		{ConnectionId: -1, Success: false, IsReadonly: true, ErrorMsg: "Password incorrect"},
+	}
+
+	for _, want := range tests {
+		data, err := broadcast.MarshalRegistrationResult(want)
+		if err != nil {
+			t.Fatalf("MarshalRegistrationResult() error = %v", err)
+		}
+
+		got, err := broadcast.UnmarshalRegistrationResult(body(t, data, broadcast.InboundRegistrationResult))
+		if err != nil {
+			t.Fatalf("UnmarshalRegistrationResult() error = %v", err)
+		}
+		if got != want {
+			t.Errorf("round trip = %+v, want %+v", got, want)
+		}
+	}
+}
+
+func TestMarshalRealtimeUpdate_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		update broadcast.RealtimeUpdate
+	}{
+		{
+			name: "Live",
+			update: broadcast.RealtimeUpdate{
+				EventIndex:      2,
+				SessionIndex:    1,
+				SessionType:     broadcast.RaceSessionTypeRace,
+				Phase:           broadcast.SessionPhaseSession,
+				SessionTime:     754 * time.Second,
+				SessionEndTime:  46 * time.Minute,
+				FocusedCarIndex: 12,
+				ActiveCameraSet: "Onboard",
+				ActiveCamera:    "Onboard1",
+				CurrentHudPage:  "Broadcasting",
+				TimeOfDay:       14 * time.Hour,
+				AmbientTemp:     22,
+				TrackTemp:       30,
+				Clouds:          0.3,
+				RainLevel:       0.1,
+				Wetness:         0.2,
+				BestSessionLap:  sampleLap(),
+			},
+		},
+		{
+			name: "Replay",
+			update: broadcast.RealtimeUpdate{
+				SessionType:         broadcast.RaceSessionTypeQualifying,
+				Phase:               broadcast.SessionPhaseSession,
+				IsReplayPlaying:     true,
+				ReplaySessionTime:   120000,
+				ReplayRemainingTime: 8000,
+				BestSessionLap:      broadcast.LapInfo{Type: broadcast.LapTypeRegular},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := broadcast.MarshalRealtimeUpdate(tt.update)
+			if err != nil {
+				t.Fatalf("MarshalRealtimeUpdate() error = %v", err)
+			}
+
+			reader := body(t, data, broadcast.InboundRealtimeUpdate)
+			got, err := broadcast.UnmarshalRealtimeUpdate(reader)
+			if err != nil {
+				t.Fatalf("UnmarshalRealtimeUpdate() error = %v", err)
+			}
+			if reader.Len() != 0 {
+				t.Errorf("%d bytes left unread", reader.Len())
+			}
+			if !reflect.DeepEqual(got, tt.update) {
+				t.Errorf("round trip = %+v, want %+v", got, tt.update)
+			}
+		})
+	}
+}
+
+func TestMarshalRealtimeCarUpdate_RoundTrip(t *testing.T) {
+	outlap := sampleLap()
+	outlap.Type = broadcast.LapTypeOutlap
+	outlap.IsInvalid = true
+	outlap.IsValidForBest = false
+
+	want := broadcast.RealtimeCarUpdate{
+		CarIndex:       11,
+		DriverIndex:    1,
+		DriverCount:    2,
+		Gear:           -1,
+		WorldPosX:      -512.5,
+		WorldPosY:      231.25,
+		Heading:        1.57,
+		CarLocation:    broadcast.CarLocationPitlane,
+		Kmh:            80,
+		Position:       3,
+		CupPosition:    1,
+		TrackPosition:  5,
+		SplinePosition: 0.95,
+		Laps:           12,
+		Delta:          -350,
+		BestSessionLap: sampleLap(),
+		LastLap:        outlap,
+		CurrentLap:     broadcast.LapInfo{CarIndex: 11, Type: broadcast.LapTypeInlap},
+	}
+
+	data, err := broadcast.MarshalRealtimeCarUpdate(want)
+	if err != nil {
+		t.Fatalf("MarshalRealtimeCarUpdate() error = %v", err)
+	}
+
+	reader := body(t, data, broadcast.InboundRealtimeCarUpdate)
+	got, err := broadcast.UnmarshalRealtimeCarUpdate(reader)
+	if err != nil {
+		t.Fatalf("UnmarshalRealtimeCarUpdate() error = %v", err)
+	}
+	if reader.Len() != 0 {
+		t.Errorf("%d bytes left unread", reader.Len())
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip = %+v, want %+v", got, want)
+	}
+}
+
+func TestMarshalEntryList_RoundTrip(t *testing.T) {
+	want := []uint16{11, 12, 13}
+
+	data, err := broadcast.MarshalEntryList(4, want)
+	if err != nil {
+		t.Fatalf("MarshalEntryList() error = %v", err)
+	}
+
+	connectionId, got, err := broadcast.UnmarshalEntryList(body(t, data, broadcast.InboundEntryList))
+	if err != nil {
+		t.Fatalf("UnmarshalEntryList() error = %v", err)
+	}
+	if connectionId != 4 {
+		t.Errorf("connectionId = %d, want 4", connectionId)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("carIndexes = %v, want %v", got, want)
+	}
+}
+
+func TestMarshalEntryListCar_RoundTrip(t *testing.T) {
+	want := broadcast.CarInfo{
+		CarIndex:           11,
+		CarModelType:       25,
+		TeamName:           "Mercedes-AMG Team",
+		RaceNumber:         88,
+		CupCategory:        1,
+		CurrentDriverIndex: 1,
+		Nationality:        broadcast.NationalityGermany,
+		Drivers: []broadcast.DriverInfo{
+			{FirstName: "Raffaele", LastName: "Marciello", ShortName: "MAR", Category: broadcast.DriverCategoryPlatinum, Nationality: broadcast.NationalityItaly},
+			{FirstName: "Jules", LastName: "Gounon", ShortName: "GOU", Category: broadcast.DriverCategoryGold, Nationality: broadcast.NationalityFrance},
+		},
+	}
+
+	data, err := broadcast.MarshalEntryListCar(want)
+	if err != nil {
+		t.Fatalf("MarshalEntryListCar() error = %v", err)
+	}
+
+	got, err := broadcast.UnmarshalEntryListCar(body(t, data, broadcast.InboundEntryListCar))
+	if err != nil {
+		t.Fatalf("UnmarshalEntryListCar() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip = %+v, want %+v", got, want)
+	}
+}
+
+func TestMarshalTrackData_RoundTrip(t *testing.T) {
+	want := broadcast.TrackData{
+		TrackName:   "Spa-Francorchamps",
+		TrackId:     7,
+		TrackMeters: 7004,
+		CameraSets: map[string][]string{
+			"set1":    {"CameraPit1", "Camera1"},
+			"Onboard": {"Onboard0", "Onboard1"},
+			"Helicam": {"Helicam"},
+		},
+		HUDPages: []string{"Blank", "Basic HUD", "Broadcasting"},
+	}
+
+	data, err := broadcast.MarshalTrackData(3, want)
+	if err != nil {
+		t.Fatalf("MarshalTrackData() error = %v", err)
+	}
+
+	connectionId, got, err := broadcast.UnmarshalTrackData(body(t, data, broadcast.InboundTrackData))
+	if err != nil {
+		t.Fatalf("UnmarshalTrackData() error = %v", err)
+	}
+	if connectionId != 3 {
+		t.Errorf("connectionId = %d, want 3", connectionId)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip = %+v, want %+v", got, want)
+	}
+
+	// Camera sets are written in a stable order
+	again, _ := broadcast.MarshalTrackData(3, want)
+	if !bytes.Equal(data, again) {
+		t.Error("MarshalTrackData() output is not deterministic")
+	}
+}
+
+func TestMarshalBroadcastingEvent_RoundTrip(t *testing.T) {
+	want := broadcast.BroadcastingEvent{
+		Type:   broadcast.BroadcastingEventTypeAccident,
+		Msg:    "Contact between #88 and #51",
+		TimeMs: 754000,
+		CarId:  11,
+	}
+
+	data, err := broadcast.MarshalBroadcastingEvent(want)
+	if err != nil {
+		t.Fatalf("MarshalBroadcastingEvent() error = %v", err)
+	}
+
+	got, err := broadcast.UnmarshalBroadcastingEvent(body(t, data, broadcast.InboundBroadcastingEvent))
+	if err != nil {
+		t.Fatalf("UnmarshalBroadcastingEvent() error = %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip = %+v, want %+v", got, want)
+	}
+}
+
+func TestUnmarshalRegistrationRequest(t *testing.T) {
+	data, err := broadcast.MarshalRegistrationRequest("RaceAll", "asd", 250, "cmd")
+	if err != nil {
+		t.Fatalf("MarshalRegistrationRequest() error = %v", err)
+	}
+	if broadcast.OutboundMessageType(data[0]) != broadcast.OutboundRegisterCommandApplication {
+		t.Fatalf("message type = %d", data[0])
+	}
+
+	got, err := broadcast.UnmarshalRegistrationRequest(bytes.NewReader(data[1:]))
+	if err != nil {
+		t.Fatalf("UnmarshalRegistrationRequest() error = %v", err)
+	}
+
+	want := broadcast.RegistrationRequest{
+		ProtocolVersion:    broadcast.BroadcastingProtocolVersion,
+		DisplayName:        "RaceAll",
+		ConnectionPassword: "asd",
+		UpdateIntervalMS:   250,
+		CommandPassword:    "cmd",
+	}
+	if got != want {
+		t.Errorf("UnmarshalRegistrationRequest() = %+v, want %+v", got, want)
+	}
+}
+
+func TestUnmarshalFocusRequest(t *testing.T) {
+	carIndex := uint16(12)
+	cameraSet, camera := "Onboard", "Onboard1"
+
+	data, err := broadcast.MarshalSetFocusRequest(5, &carIndex, &cameraSet, &camera)
+	if err != nil {
+		t.Fatalf("MarshalSetFocusRequest() error = %v", err)
+	}
+
+	got, err := broadcast.UnmarshalFocusRequest(bytes.NewReader(data[1:]))
+	if err != nil {
+		t.Fatalf("UnmarshalFocusRequest() error = %v", err)
+	}
+	if got.ConnectionId != 5 || got.CarIndex == nil || *got.CarIndex != carIndex {
+		t.Errorf("UnmarshalFocusRequest() = %+v", got)
+	}
+	if got.CameraSet == nil || *got.CameraSet != cameraSet || got.Camera == nil || *got.Camera != camera {
+		t.Errorf("camera = %v/%v, want %s/%s", got.CameraSet, got.Camera, cameraSet, camera)
+	}
+
+	// Camera only
+	data, _ = broadcast.MarshalSetFocusRequest(5, nil, &cameraSet, &camera)
+	got, err = broadcast.UnmarshalFocusRequest(bytes.NewReader(data[1:]))
+	if err != nil {
+		t.Fatalf("UnmarshalFocusRequest() error = %v", err)
+	}
+	if got.CarIndex != nil {
+		t.Errorf("CarIndex = %d, want nil", *got.CarIndex)
+	}
+}
+
+func TestMarshalSaveManualReplayHighlightRequest(t *testing.T) {
+	data, err := broadcast.MarshalSaveManualReplayHighlightRequest(6)
+	if err != nil {
+		t.Fatalf("MarshalSaveManualReplayHighlightRequest() error = %v", err)
+	}
+	if broadcast.OutboundMessageType(data[0]) != broadcast.OutboundSaveManualReplayHighlight {
+		t.Fatalf("message type = %d, want %d", data[0], broadcast.OutboundSaveManualReplayHighlight)
+	}
+
+	connectionId, err := broadcast.UnmarshalConnectionRequest(bytes.NewReader(data[1:]))
+	if err != nil || connectionId != 6 {
+		t.Errorf("UnmarshalConnectionRequest() = %d, %v, want 6", connectionId, err)
+	}
+}
@@ -0,0 +1,133 @@
+package broadcast_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"RaceAll/internal/broadcast"
+)
+
+// utf16le encodes a string the way ACC writes its config files
+func utf16le(s string) []byte {
+	var data []byte
+	for _, unit := range utf16.Encode([]rune(s)) {
+		data = binary.LittleEndian.AppendUint16(data, unit)
+	}
+	return data
+}
+
+func writeSettings(t *testing.T, data []byte) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "broadcasting.json")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	return path
+}
+
+func TestLoadBroadcastingSettings_Encodings(t *testing.T) {
+	const text = `{
+    "updListenerPort": 9232,
+    "connectionPassword": "secret",
+    "commandPassword": "cmd"
+}`
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"UTF-16LE", utf16le(text)},
+		{"UTF-16LE with BOM", append([]byte{0xFF, 0xFE}, utf16le(text)...)},
+		{"UTF-8", []byte(text)},
+		{"UTF-8 with BOM", append([]byte{0xEF, 0xBB, 0xBF}, text...)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings, err := broadcast.LoadBroadcastingSettings(writeSettings(t, tt.data))
+			if err != nil {
+				t.Fatalf("LoadBroadcastingSettings() error = %v", err)
+			}
+
+			if settings.UpdListenerPort != 9232 || settings.ConnectionPassword != "secret" || settings.CommandPassword != "cmd" {
+				t.Errorf("settings = %+v", *settings)
+			}
+
+			config := broadcast.DefaultConfig()
+			settings.Apply(&config)
+			if config.Port != 9232 || config.Password != "secret" || config.CommandPassword != "cmd" {
+				t.Errorf("config = %+v", config)
+			}
+		})
+	}
+}
+
+func TestLoadBroadcastingSettings_Invalid(t *testing.T) {
+	if _, err := broadcast.LoadBroadcastingSettings(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("expected error for a missing file")
+	}
+	if _, err := broadcast.LoadBroadcastingSettings(writeSettings(t, utf16le(`{"updListenerPort": `))); err == nil {
+		t.Error("expected error for truncated JSON")
+	}
+}
+
+func TestLoadConfig_DisabledWriteBack(t *testing.T) {
+	original := append([]byte{0xFF, 0xFE}, utf16le(`{"updListenerPort": 0, "connectionPassword": "", "commandPassword": "", "spectatorOnly": 1}`)...)
+
+	// Without write-back the file is left alone and the default port is kept
+	path := writeSettings(t, original)
+	config, err := broadcast.LoadConfig(path, false)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if config.Port != broadcast.DefaultConfig().Port {
+		t.Errorf("Port = %d, want default", config.Port)
+	}
+	if data, _ := os.ReadFile(path); !bytes.Equal(data, original) {
+		t.Error("file changed without write-back")
+	}
+
+	config, err = broadcast.LoadConfig(path, true)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if config.Port != 9000 || config.Password != "asd" {
+		t.Errorf("config = %+v, want port 9000 and password asd", config)
+	}
+
+	// The file keeps its encoding and unknown keys
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile() error = %v", err)
+	}
+	if !bytes.HasPrefix(data, []byte{0xFF, 0xFE}) {
+		t.Error("UTF-16LE BOM lost on save")
+	}
+	if !bytes.Contains(data, utf16le(`"spectatorOnly": 1`)) {
+		t.Error("unknown key lost on save")
+	}
+
+	settings, err := broadcast.LoadBroadcastingSettings(path)
+	if err != nil {
+		t.Fatalf("LoadBroadcastingSettings() error = %v", err)
+	}
+	if !settings.IsEnabled() || settings.UpdListenerPort != 9000 || settings.ConnectionPassword != "asd" {
+		t.Errorf("saved settings = %+v", *settings)
+	}
+}
+
+func TestDefaultBroadcastingSettingsPath(t *testing.T) {
+	path, err := broadcast.DefaultBroadcastingSettingsPath()
+	if err != nil {
+		t.Skipf("no home directory: %v", err)
+	}
+	if !strings.HasSuffix(path, filepath.Join("Assetto Corsa Competizione", "Config", "broadcasting.json")) {
+		t.Errorf("path = %q", path)
+	}
+}
@@ -0,0 +1,76 @@
+package broadcast_test
+
+import (
+	"testing"
+	"time"
+
+	"RaceAll/internal/broadcast"
+)
+
+func TestReconnectConfig_Backoff(t *testing.T) {
+	config := broadcast.ReconnectConfig{
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Multiplier:     2,
+	}
+
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{0, 100 * time.Millisecond},
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{50, time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := config.Backoff(tt.failures, nil); got != tt.want {
+			t.Errorf("Backoff(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+func TestReconnectConfig_BackoffJitter(t *testing.T) {
+	config := broadcast.ReconnectConfig{
+		InitialBackoff: time.Second,
+		MaxBackoff:     time.Second,
+		Jitter:         0.25,
+	}
+
+	if got := config.Backoff(1, func() float64 { return 0 }); got != 750*time.Millisecond {
+		t.Errorf("Backoff() with lowest jitter = %v, want 750ms", got)
+	}
+	if got := config.Backoff(1, func() float64 { return 0.5 }); got != time.Second {
+		t.Errorf("Backoff() with centered jitter = %v, want 1s", got)
+	}
+
+	for i := 0; i < 100; i++ {
+		got := config.Backoff(3, nil)
+		if got < 750*time.Millisecond || got > 1250*time.Millisecond {
+			t.Fatalf("Backoff() = %v, want within 25%% of 1s", got)
+		}
+	}
+}
+
+func TestClientState_String(t *testing.T) {
+	states := map[broadcast.ClientState]string{
+		broadcast.StateDisconnected: "Disconnected",
+		broadcast.StateRegistering:  "Registering",
+		broadcast.StateConnected:    "Connected",
+		broadcast.StateReadonly:     "Readonly",
+		broadcast.StateLost:         "Lost",
+	}
+	for state, want := range states {
+		if got := state.String(); got != want {
+			t.Errorf("String() = %q, want %q", got, want)
+		}
+	}
+
+	if !broadcast.StateReadonly.IsRegistered() || broadcast.StateLost.IsRegistered() {
+		t.Error("IsRegistered() should be true only for Connected and Readonly")
+	}
+}
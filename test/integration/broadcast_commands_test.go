@@ -0,0 +1,57 @@
+package integration_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"RaceAll/internal/broadcast"
+	raceerrors "RaceAll/internal/errors"
+)
+
+func TestCommandQueue_ConfirmedByMockServer(t *testing.T) {
+	serverConfig := broadcast.DefaultMockServerConfig()
+	server := startMockServer(t, serverConfig)
+
+	// Con la contraseña de comandos el servidor aplica los comandos
+	config := broadcast.DefaultConfig()
+	config.Port = server.Port()
+	config.UpdateMS = 20
+	config.CommandPassword = serverConfig.CommandPassword
+	config.Commands = broadcast.CommandQueueConfig{MinInterval: 20 * time.Millisecond, ConfirmTimeout: 300 * time.Millisecond, MaxAttempts: 2}
+	service := broadcast.NewService(config)
+	if err := service.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	t.Cleanup(service.Stop)
+
+	waitFor(t, 2*time.Second, "conexión registrada", func() bool {
+		return service.State() == broadcast.StateConnected
+	})
+
+	queue := service.Commands()
+	focus := queue.Submit(broadcast.Command{Type: broadcast.CommandFocusAndCamera, CarIndex: 3, CameraSet: "Helicam", Camera: "Helicam"})
+	hud := queue.Submit(broadcast.Command{Type: broadcast.CommandHUDPage, HUDPage: "Broadcasting"})
+
+	for _, results := range []<-chan broadcast.CommandResult{focus, hud} {
+		select {
+		case result := <-results:
+			if result.Err != nil {
+				t.Errorf("%s error = %v", result.Command.Type, result.Err)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatal("timeout esperando la confirmación")
+		}
+	}
+
+	session := server.Session()
+	if session.FocusedCarIndex != 3 || session.ActiveCamera != "Helicam" || session.CurrentHudPage != "Broadcasting" {
+		t.Errorf("session = %+v", session)
+	}
+
+	// Un comando que el servidor no aplica falla tras los reintentos
+	result := <-queue.Submit(broadcast.Command{Type: broadcast.CommandInstantReplay, StartSessionTime: 1000, DurationMS: 5000})
+	if !errors.Is(result.Err, raceerrors.ErrCommandNotConfirmed) || result.Attempts != 2 {
+		t.Errorf("replay result = %+v, want not confirmed", result)
+	}
+}
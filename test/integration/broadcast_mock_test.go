@@ -0,0 +1,576 @@
+package integration_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"RaceAll/internal/acc"
+	"RaceAll/internal/broadcast"
+	raerrors "RaceAll/internal/errors"
+	"RaceAll/internal/logger"
+	"RaceAll/internal/sharedmemory"
+)
+
+// startMockServer arranca un servidor ACC simulado y lo detiene al terminar el test
+func startMockServer(t *testing.T, config broadcast.MockServerConfig) *broadcast.MockServer {
+	t.Helper()
+
+	logger.Init(logger.Config{
+		Level:  logger.LevelWarn,
+		Pretty: true,
+		Output: os.Stdout,
+	})
+
+	server := broadcast.NewMockServer(config)
+	if err := server.Start(); err != nil {
+		t.Fatalf("MockServer.Start() error = %v", err)
+	}
+	t.Cleanup(server.Stop)
+	return server
+}
+
+// waitFor espera hasta que cond sea verdadera o se agote el tiempo
+func waitFor(t *testing.T, timeout time.Duration, what string, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timeout waiting for %s", what)
+}
+
+// mockSession guarda lo recibido por un cliente conectado al servidor simulado
+type mockSession struct {
+	mu         sync.Mutex
+	state      *broadcast.ConnectionState
+	track      *broadcast.TrackData
+	cars       map[uint16]broadcast.CarInfo
+	carUpdates map[uint16]broadcast.RealtimeCarUpdate
+	realtime   *broadcast.RealtimeUpdate
+	events     []broadcast.BroadcastingEvent
+}
+
+func connectMockClient(t *testing.T, server *broadcast.MockServer, password, commandPassword string) (*broadcast.Client, *mockSession) {
+	t.Helper()
+	return connectClient(t, server.Addr(), "Mock Test Client", password, commandPassword)
+}
+
+// connectClient conecta un cliente a cualquier servidor de broadcast (simulado o relay)
+func connectClient(t *testing.T, address, displayName, password, commandPassword string) (*broadcast.Client, *mockSession) {
+	t.Helper()
+
+	session := &mockSession{
+		cars:       make(map[uint16]broadcast.CarInfo),
+		carUpdates: make(map[uint16]broadcast.RealtimeCarUpdate),
+	}
+
+	client := broadcast.NewClient(address, displayName, password, commandPassword, 20, *logger.Get())
+
+	client.OnConnectionStateChanged = func(state broadcast.ConnectionState) {
+		session.mu.Lock()
+		defer session.mu.Unlock()
+		session.state = &state
+	}
+	client.OnTrackDataUpdate = func(track broadcast.TrackData) {
+		session.mu.Lock()
+		defer session.mu.Unlock()
+		session.track = &track
+	}
+	client.OnEntrylistUpdate = func(car broadcast.CarInfo) {
+		session.mu.Lock()
+		defer session.mu.Unlock()
+		session.cars[car.CarIndex] = car
+	}
+	client.OnRealtimeUpdate = func(update broadcast.RealtimeUpdate) {
+		session.mu.Lock()
+		defer session.mu.Unlock()
+		session.realtime = &update
+	}
+	client.OnRealtimeCarUpdate = func(update broadcast.RealtimeCarUpdate) {
+		session.mu.Lock()
+		defer session.mu.Unlock()
+		session.carUpdates[update.CarIndex] = update
+	}
+	client.OnBroadcastingEvent = func(event broadcast.BroadcastingEvent) {
+		session.mu.Lock()
+		defer session.mu.Unlock()
+		session.events = append(session.events, event)
+	}
+
+	client.SetTimeout(2 * time.Second)
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+
+	go client.Listen()
+	t.Cleanup(func() { client.Disconnect() })
+
+	return client, session
+}
+
+func (s *mockSession) read(fn func(s *mockSession) bool) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fn(s)
+}
+
+func TestMockServer_ClientSession(t *testing.T) {
+	config := broadcast.DefaultMockServerConfig()
+	server := startMockServer(t, config)
+	_, session := connectMockClient(t, server, config.ConnectionPassword, "")
+
+	waitFor(t, 2*time.Second, "registration", func() bool {
+		return session.read(func(s *mockSession) bool { return s.state != nil })
+	})
+	session.read(func(s *mockSession) bool {
+		if !s.state.Success || !s.state.IsReadonly || s.state.ConnectionId != 1 {
+			t.Errorf("state = %+v, want success, read-only, connectionId 1", *s.state)
+		}
+		return true
+	})
+
+	waitFor(t, 2*time.Second, "track data and entry list", func() bool {
+		return session.read(func(s *mockSession) bool {
+			return s.track != nil && len(s.cars) == len(config.Cars)
+		})
+	})
+
+	// Las actualizaciones de autos solo se aceptan con el entry list completo
+	waitFor(t, 2*time.Second, "car updates", func() bool {
+		return session.read(func(s *mockSession) bool {
+			return s.realtime != nil && len(s.carUpdates) == len(config.Cars)
+		})
+	})
+
+	session.read(func(s *mockSession) bool {
+		if s.track.TrackName != config.Track.TrackName || len(s.track.CameraSets) != len(config.Track.CameraSets) {
+			t.Errorf("track = %+v", *s.track)
+		}
+		if s.realtime.FocusedCarIndex != config.Session.FocusedCarIndex {
+			t.Errorf("FocusedCarIndex = %d, want %d", s.realtime.FocusedCarIndex, config.Session.FocusedCarIndex)
+		}
+
+		positions := make(map[uint16]bool)
+		for _, car := range config.Cars {
+			update := s.carUpdates[car.Info.CarIndex]
+			if int(update.DriverCount) != len(car.Info.Drivers) {
+				t.Errorf("car %d DriverCount = %d, want %d", car.Info.CarIndex, update.DriverCount, len(car.Info.Drivers))
+			}
+			positions[update.Position] = true
+		}
+		// El auto con mayor spline inicial va primero
+		if s.carUpdates[config.Cars[0].Info.CarIndex].Position != 1 {
+			t.Errorf("leader position = %d, want 1", s.carUpdates[config.Cars[0].Info.CarIndex].Position)
+		}
+		if len(positions) != len(config.Cars) {
+			t.Errorf("positions are not unique: %v", positions)
+		}
+		return true
+	})
+
+	if clients := server.Clients(); len(clients) != 1 || clients[0].DisplayName != "Mock Test Client" {
+		t.Errorf("Clients() = %+v", clients)
+	}
+
+	event := broadcast.BroadcastingEvent{Type: broadcast.BroadcastingEventTypeAccident, Msg: "Contact", CarId: 2}
+	if err := server.SendEvent(event); err != nil {
+		t.Fatalf("SendEvent() error = %v", err)
+	}
+	waitFor(t, 2*time.Second, "broadcasting event", func() bool {
+		return session.read(func(s *mockSession) bool {
+			return len(s.events) == 1 && s.events[0].Type == broadcast.BroadcastingEventTypeAccident
+		})
+	})
+}
+
+func TestMockServer_WrongPassword(t *testing.T) {
+	server := startMockServer(t, broadcast.DefaultMockServerConfig())
+	_, session := connectMockClient(t, server, "wrong", "")
+
+	waitFor(t, 2*time.Second, "registration", func() bool {
+		return session.read(func(s *mockSession) bool { return s.state != nil })
+	})
+	session.read(func(s *mockSession) bool {
+		if s.state.Success || s.state.ErrorMsg == "" {
+			t.Errorf("state = %+v, want failure with an error message", *s.state)
+		}
+		return true
+	})
+
+	if clients := server.Clients(); len(clients) != 0 {
+		t.Errorf("Clients() = %+v, want none", clients)
+	}
+}
+
+func TestMockServer_Commands(t *testing.T) {
+	config := broadcast.DefaultMockServerConfig()
+	server := startMockServer(t, config)
+	client, session := connectMockClient(t, server, config.ConnectionPassword, config.CommandPassword)
+
+	waitFor(t, 2*time.Second, "registration", func() bool {
+		return session.read(func(s *mockSession) bool { return s.state != nil && s.state.Success })
+	})
+
+	if err := client.SetFocusAndCamera(3, "Onboard", "Onboard1"); err != nil {
+		t.Fatalf("SetFocusAndCamera() error = %v", err)
+	}
+	if err := client.RequestHUDPage("TrackMap"); err != nil {
+		t.Fatalf("RequestHUDPage() error = %v", err)
+	}
+	if err := client.SaveManualReplayHighlight(); err != nil {
+		t.Fatalf("SaveManualReplayHighlight() error = %v", err)
+	}
+
+	// El siguiente RealtimeUpdate confirma los comandos
+	waitFor(t, 2*time.Second, "commands applied", func() bool {
+		return session.read(func(s *mockSession) bool {
+			return s.realtime != nil &&
+				s.realtime.FocusedCarIndex == 3 &&
+				s.realtime.ActiveCameraSet == "Onboard" &&
+				s.realtime.ActiveCamera == "Onboard1" &&
+				s.realtime.CurrentHudPage == "TrackMap"
+		})
+	})
+
+	// Un cliente de solo lectura no puede cambiar el foco
+	readonly, readonlySession := connectMockClient(t, server, config.ConnectionPassword, "")
+	waitFor(t, 2*time.Second, "read-only registration", func() bool {
+		return readonlySession.read(func(s *mockSession) bool { return s.state != nil && s.state.Success })
+	})
+	if err := readonly.SetFocus(1); err != nil {
+		t.Fatalf("SetFocus() error = %v", err)
+	}
+
+	waitFor(t, 2*time.Second, "read-only command", func() bool {
+		return len(server.Commands()) == 4
+	})
+
+	commands := server.Commands()
+	if commands[2].Type != broadcast.OutboundSaveManualReplayHighlight || !commands[2].Applied {
+		t.Errorf("command 2 = %+v, want an applied SaveManualReplayHighlight", commands[2])
+	}
+	if !commands[0].Applied || !commands[1].Applied || commands[3].Applied {
+		t.Errorf("Applied = %v %v %v, want true true false", commands[0].Applied, commands[1].Applied, commands[3].Applied)
+	}
+	if server.Session().FocusedCarIndex != 3 {
+		t.Errorf("FocusedCarIndex = %d, want 3", server.Session().FocusedCarIndex)
+	}
+}
+
+func TestMockServer_ConnectionManager(t *testing.T) {
+	server := startMockServer(t, broadcast.DefaultMockServerConfig())
+
+	// Shared memory en memoria con el juego en marcha
+	src := sharedmemory.NewMemorySource()
+	src.SetPhysics(sharedmemory.Physics{PacketId: 1})
+	src.SetGraphics(sharedmemory.Graphics{PacketId: 1, Status: sharedmemory.ACLive})
+
+	smService := sharedmemory.NewServiceWithSource(src)
+	if err := smService.Start(); err != nil {
+		t.Fatalf("sharedmemory.Service.Start() error = %v", err)
+	}
+	defer smService.Stop()
+
+	waitFor(t, 2*time.Second, "shared memory connection", smService.IsConnected)
+
+	config := broadcast.DefaultConfig()
+	config.Port = server.Port()
+	config.UpdateMS = 20
+
+	manager := broadcast.NewConnectionManager(config, smService)
+	if err := manager.Start(); err != nil {
+		t.Fatalf("ConnectionManager.Start() error = %v", err)
+	}
+	defer manager.Stop()
+
+	messages := manager.GetService().Subscribe()
+
+	received := make(map[string]bool)
+	timeout := time.After(3 * time.Second)
+	for !received["ConnectionState"] || !received["RealtimeCarUpdate"] {
+		select {
+		case msg := <-messages:
+			received[msg.Type] = true
+		case <-timeout:
+			t.Fatalf("timeout waiting for broadcast messages, received %v", received)
+		}
+	}
+
+	if !manager.IsConnected() {
+		t.Error("IsConnected() = false while the game is live")
+	}
+
+	// Juego cerrado: PacketId a 0 y estado OFF
+	src.SetPhysics(sharedmemory.Physics{PacketId: 0})
+	src.SetGraphics(sharedmemory.Graphics{PacketId: 2, Status: sharedmemory.ACOff})
+
+	waitFor(t, 2*time.Second, "broadcast disconnect", func() bool { return !manager.IsConnected() })
+}
+
+func TestMockServer_ConnectionManagerSpectator(t *testing.T) {
+	server := startMockServer(t, broadcast.DefaultMockServerConfig())
+
+	// Shared memory con el juego cerrado en esta PC
+	src := sharedmemory.NewMemorySource()
+	src.SetPhysics(sharedmemory.Physics{PacketId: 0})
+	src.SetGraphics(sharedmemory.Graphics{PacketId: 1, Status: sharedmemory.ACOff})
+
+	smService := sharedmemory.NewServiceWithSource(src)
+	if err := smService.Start(); err != nil {
+		t.Fatalf("sharedmemory.Service.Start() error = %v", err)
+	}
+	defer smService.Stop()
+
+	waitFor(t, 2*time.Second, "shared memory connection", smService.IsConnected)
+
+	config := broadcast.DefaultConfig()
+	config.Port = server.Port()
+	config.UpdateMS = 20
+
+	manager := broadcast.NewConnectionManager(config, smService)
+	if err := manager.Start(); err != nil {
+		t.Fatalf("ConnectionManager.Start() error = %v", err)
+	}
+	defer manager.Stop()
+
+	// En modo local no se conecta sin el juego
+	time.Sleep(300 * time.Millisecond)
+	if manager.IsConnected() {
+		t.Fatal("IsConnected() = true with the game closed")
+	}
+
+	// El espectador se conecta al servidor sin mirar shared memory
+	if err := manager.SetMode(broadcast.ModeSpectator); err != nil {
+		t.Fatalf("SetMode() error = %v", err)
+	}
+	waitFor(t, 3*time.Second, "spectator connection", manager.IsConnected)
+
+	// De vuelta en modo local, el juego cerrado desconecta el broadcast
+	if err := manager.SetMode(broadcast.ModeLocal); err != nil {
+		t.Fatalf("SetMode() error = %v", err)
+	}
+	waitFor(t, 2*time.Second, "broadcast disconnect", func() bool { return !manager.IsConnected() })
+
+	// Sin shared memory solo hay modo espectador
+	spectator := broadcast.NewConnectionManager(config, nil)
+	if spectator.Mode() != broadcast.ModeSpectator {
+		t.Errorf("Mode() = %s without shared memory, want Spectator", spectator.Mode())
+	}
+	if err := spectator.SetMode(broadcast.ModeLocal); err == nil {
+		t.Error("SetMode(ModeLocal) without shared memory did not fail")
+	}
+}
+
+func TestConnectionManager_ModeReachesPipeline(t *testing.T) {
+	smService := sharedmemory.NewServiceWithSource(sharedmemory.NewMemorySource())
+	manager := broadcast.NewConnectionManager(broadcast.DefaultConfig(), smService)
+
+	pipeline := acc.NewBroadcastPipeline()
+	manager.SetPipeline(pipeline)
+	defer manager.SetPipeline(nil)
+
+	dm := pipeline.DataManager()
+	pipeline.HandleMessage(broadcast.BroadcastMessage{Payload: broadcast.DefaultMockServerConfig().Track})
+
+	updateSharedMemory := func() {
+		dm.UpdateFromSharedMemory(&sharedmemory.Physics{Fuel: 40}, &sharedmemory.Graphics{}, &sharedmemory.Static{})
+	}
+
+	// En modo local el DataManager usa shared memory
+	if dm.IsSpectator() {
+		t.Fatal("IsSpectator() = true with the manager in ModeLocal")
+	}
+	updateSharedMemory()
+	if !dm.HasSharedMemory() {
+		t.Error("HasSharedMemory() = false in ModeLocal")
+	}
+
+	// El modo espectador del gestor llega al DataManager, que ignora shared memory
+	if err := manager.SetMode(broadcast.ModeSpectator); err != nil {
+		t.Fatalf("SetMode() error = %v", err)
+	}
+	if !dm.IsSpectator() {
+		t.Fatal("IsSpectator() = false after SetMode(ModeSpectator)")
+	}
+	updateSharedMemory()
+	if dm.HasSharedMemory() {
+		t.Error("HasSharedMemory() = true in ModeSpectator")
+	}
+
+	if err := manager.SetMode(broadcast.ModeLocal); err != nil {
+		t.Fatalf("SetMode() error = %v", err)
+	}
+	updateSharedMemory()
+	if dm.IsSpectator() || !dm.HasSharedMemory() {
+		t.Errorf("IsSpectator() = %v, HasSharedMemory() = %v back in ModeLocal", dm.IsSpectator(), dm.HasSharedMemory())
+	}
+
+	// Un gestor sin shared memory arranca el pipeline en modo espectador
+	spectator := broadcast.NewConnectionManager(broadcast.DefaultConfig(), nil)
+	other := acc.NewBroadcastPipeline()
+	other.SetSpectator(false)
+	spectator.SetPipeline(other)
+	defer spectator.SetPipeline(nil)
+	if !other.DataManager().IsSpectator() {
+		t.Error("IsSpectator() = false with a manager without shared memory")
+	}
+}
+
+func TestMockServer_CaptureAndReplay(t *testing.T) {
+	config := broadcast.DefaultMockServerConfig()
+	server := startMockServer(t, config)
+
+	path := filepath.Join(t.TempDir(), "session.cap")
+
+	client := broadcast.NewClient(server.Addr(), "Capture Test", config.ConnectionPassword, "", 20, *logger.Get())
+	client.SetTimeout(2 * time.Second)
+
+	// La captura empieza antes de Connect para incluir el registro
+	if err := client.StartCapture(path); err != nil {
+		t.Fatalf("StartCapture() error = %v", err)
+	}
+	if err := client.StartCapture(path); !errors.Is(err, raerrors.ErrCaptureActive) {
+		t.Errorf("second StartCapture() error = %v, want ErrCaptureActive", err)
+	}
+
+	var mu sync.Mutex
+	liveCars := make(map[uint16]bool)
+	client.OnRealtimeCarUpdate = func(update broadcast.RealtimeCarUpdate) {
+		mu.Lock()
+		defer mu.Unlock()
+		liveCars[update.CarIndex] = true
+	}
+
+	if err := client.Connect(); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	go client.Listen()
+
+	waitFor(t, 2*time.Second, "car updates", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(liveCars) == len(config.Cars)
+	})
+
+	client.Disconnect()
+	if err := client.StopCapture(); err != nil {
+		t.Fatalf("StopCapture() error = %v", err)
+	}
+
+	capture, err := broadcast.OpenCapture(path)
+	if err != nil {
+		t.Fatalf("OpenCapture() error = %v", err)
+	}
+	defer capture.Close()
+
+	if capture.Header().Address != server.Addr() {
+		t.Errorf("capture address = %q, want %q", capture.Header().Address, server.Addr())
+	}
+
+	// Reproducir la captura sin red reconstruye la misma sesión
+	protocol := broadcast.NewProtocol("replay", func([]byte) error { return nil }, *logger.Get())
+
+	var track *broadcast.TrackData
+	replayCars := make(map[uint16]bool)
+	protocol.OnTrackDataUpdate = func(td broadcast.TrackData) { track = &td }
+	protocol.OnRealtimeCarUpdate = func(update broadcast.RealtimeCarUpdate) { replayCars[update.CarIndex] = true }
+
+	var first broadcast.CaptureDirection = 255
+	replayer := broadcast.NewReplayer(capture, protocol)
+	replayer.OnDatagram = func(d broadcast.CapturedDatagram) {
+		if first == 255 {
+			first = d.Direction
+		}
+	}
+	replayer.SetSpeed(10)
+
+	if err := replayer.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if first != broadcast.CaptureOutbound {
+		t.Errorf("first datagram direction = %v, want the outbound registration", first)
+	}
+	if track == nil || track.TrackName != config.Track.TrackName {
+		t.Errorf("replayed track = %+v", track)
+	}
+	if len(replayCars) != len(config.Cars) {
+		t.Errorf("replayed %d cars, want %d", len(replayCars), len(config.Cars))
+	}
+
+	stats := replayer.Stats()
+	if stats.Inbound == 0 || stats.Outbound == 0 || stats.Errors != 0 {
+		t.Errorf("Stats() = %+v", stats)
+	}
+}
+
+func TestMockServer_ConnectionManagerCapture(t *testing.T) {
+	config := broadcast.DefaultMockServerConfig()
+	server := startMockServer(t, config)
+
+	serviceConfig := broadcast.DefaultConfig()
+	serviceConfig.Port = server.Port()
+	serviceConfig.UpdateMS = 20
+
+	path := filepath.Join(t.TempDir(), "session.cap")
+	manager := broadcast.NewConnectionManager(serviceConfig, nil)
+
+	// La captura empieza antes de Start para incluir el primer registro
+	if err := manager.StartCapture(path); err != nil {
+		t.Fatalf("StartCapture() error = %v", err)
+	}
+	if err := manager.StartCapture(path); !errors.Is(err, raerrors.ErrCaptureActive) {
+		t.Errorf("second StartCapture() error = %v, want ErrCaptureActive", err)
+	}
+	if err := manager.Start(); err != nil {
+		t.Fatalf("ConnectionManager.Start() error = %v", err)
+	}
+	waitFor(t, 3*time.Second, "spectator connection", manager.IsConnected)
+
+	// El servicio se detiene y el gestor lo vuelve a arrancar con un cliente nuevo
+	manager.GetService().Stop()
+	waitFor(t, 3*time.Second, "reconnection", manager.IsConnected)
+	if !manager.IsCapturing() {
+		t.Error("IsCapturing() = false after the service restarted")
+	}
+
+	// Stop cierra la captura
+	manager.Stop()
+	if manager.IsCapturing() {
+		t.Error("IsCapturing() = true after Stop")
+	}
+
+	capture, err := broadcast.OpenCapture(path)
+	if err != nil {
+		t.Fatalf("OpenCapture() error = %v", err)
+	}
+	defer capture.Close()
+
+	registrations := 0
+	for {
+		datagram, err := capture.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		if datagram.Direction == broadcast.CaptureOutbound &&
+			broadcast.OutboundMessageType(datagram.Data[0]) == broadcast.OutboundRegisterCommandApplication {
+			registrations++
+		}
+	}
+	if registrations < 2 {
+		t.Errorf("captured %d registrations, want one per client (2)", registrations)
+	}
+}
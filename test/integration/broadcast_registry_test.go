@@ -0,0 +1,155 @@
+package integration_test
+
+import (
+	"testing"
+	"time"
+
+	"RaceAll/internal/acc"
+	"RaceAll/internal/broadcast"
+)
+
+func TestConnectionRegistry_MultipleServers(t *testing.T) {
+	// Dos servidores ACC con campos distintos
+	configA := broadcast.DefaultMockServerConfig()
+	configB := broadcast.DefaultMockServerConfig()
+	configB.Track.TrackName = "Spa-Francorchamps"
+	configB.Track.TrackId = 7
+	configB.Track.TrackMeters = 7004
+	configB.Cars = configB.Cars[:2]
+
+	serverA := startMockServer(t, configA)
+	serverB := startMockServer(t, configB)
+
+	registry := broadcast.NewConnectionRegistry()
+	registry.SetPipelineFactory(acc.NewBroadcastPipelineFactory())
+	defer registry.Close()
+
+	messages := registry.Subscribe()
+
+	for name, server := range map[string]*broadcast.MockServer{"monza": serverA, "spa": serverB} {
+		config := broadcast.DefaultConfig()
+		config.Port = server.Port()
+		config.UpdateMS = 20
+		if _, err := registry.Add(name, config); err != nil {
+			t.Fatalf("Add(%s) error = %v", name, err)
+		}
+	}
+
+	if _, err := registry.Add("monza", broadcast.DefaultConfig()); err == nil {
+		t.Error("Add() with a duplicate name should fail")
+	}
+
+	if err := registry.StartAll(); err != nil {
+		t.Fatalf("StartAll() error = %v", err)
+	}
+
+	// Cada mensaje llega etiquetado con su conexión
+	tracks := make(map[string]string)
+	timeout := time.After(3 * time.Second)
+	for len(tracks) < 2 {
+		select {
+		case msg := <-messages:
+			if track, ok := msg.Payload.(broadcast.TrackData); ok {
+				tracks[msg.Connection] = track.TrackName
+			}
+		case <-timeout:
+			t.Fatalf("timeout waiting for track data, got %v", tracks)
+		}
+	}
+
+	if tracks["monza"] != configA.Track.TrackName || tracks["spa"] != configB.Track.TrackName {
+		t.Errorf("tracks by connection = %v", tracks)
+	}
+
+	waitFor(t, 3*time.Second, "messages on both connections", func() bool {
+		for _, status := range registry.Status() {
+			if !status.IsRunning || !status.State.Success || status.Messages < 20 {
+				return false
+			}
+		}
+		return true
+	})
+
+	monza, _ := registry.Get("monza")
+	spa, _ := registry.Get("spa")
+
+	// Parar una conexión no afecta a la otra
+	monza.Stop()
+	if monza.IsRunning() || !spa.IsRunning() {
+		t.Errorf("IsRunning() after stopping monza = %v/%v, want false/true", monza.IsRunning(), spa.IsRunning())
+	}
+
+	if err := registry.Remove("spa"); err != nil {
+		t.Fatalf("Remove() error = %v", err)
+	}
+	if err := registry.Remove("spa"); err == nil {
+		t.Error("Remove() of an unknown connection should fail")
+	}
+	if names := registry.Names(); len(names) != 1 || names[0] != "monza" {
+		t.Errorf("Names() = %v, want [monza]", names)
+	}
+
+	// Cada conexión tiene su propio pipeline de análisis; Close espera a
+	// que terminen de procesar antes de leerlos
+	registry.Close()
+	monzaData := monza.Pipeline().(*acc.BroadcastPipeline).DataManager()
+	spaData := spa.Pipeline().(*acc.BroadcastPipeline).DataManager()
+
+	if got := len(monzaData.GetLeaderboardData()); got != len(configA.Cars) {
+		t.Errorf("monza leaderboard has %d cars, want %d", got, len(configA.Cars))
+	}
+	if got := len(spaData.GetLeaderboardData()); got != len(configB.Cars) {
+		t.Errorf("spa leaderboard has %d cars, want %d", got, len(configB.Cars))
+	}
+}
+
+// stuckPipeline no procesa mensajes hasta que se cierra release
+type stuckPipeline struct {
+	release chan struct{}
+}
+
+func (p *stuckPipeline) HandleMessage(broadcast.BroadcastMessage) {
+	<-p.release
+}
+
+func TestConnectionRegistry_SlowPipeline(t *testing.T) {
+	server := startMockServer(t, broadcast.DefaultMockServerConfig())
+
+	registry := broadcast.NewConnectionRegistry()
+	defer registry.Close()
+
+	pipeline := &stuckPipeline{release: make(chan struct{})}
+	defer close(pipeline.release)
+	registry.SetPipelineFactory(func(string) broadcast.Pipeline { return pipeline })
+
+	config := broadcast.DefaultConfig()
+	config.Port = server.Port()
+	config.UpdateMS = 10
+	conn, err := registry.Add("monza", config)
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	// Los demás suscriptores del servicio siguen recibiendo los mensajes
+	messages := conn.Service().Subscribe()
+	if err := conn.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	received := 0
+	timeout := time.After(5 * time.Second)
+	for conn.Status().Dropped == 0 {
+		select {
+		case <-messages:
+			received++
+		case <-timeout:
+			t.Fatalf("timeout waiting for the pipeline queue to drop, received %d", received)
+		}
+	}
+
+	select {
+	case <-messages:
+	case <-time.After(time.Second):
+		t.Errorf("no messages after the pipeline queue filled up, received %d", received)
+	}
+}
@@ -0,0 +1,192 @@
+package integration_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"RaceAll/internal/broadcast"
+)
+
+// startRelay conecta un servicio al servidor simulado y lo re-expone en un relay
+func startRelay(t *testing.T, server *broadcast.MockServer, config broadcast.RelayConfig) (*broadcast.Service, *broadcast.Relay) {
+	t.Helper()
+
+	serviceConfig := broadcast.DefaultConfig()
+	serviceConfig.Port = server.Port()
+	serviceConfig.CommandPassword = "cmd"
+	serviceConfig.UpdateMS = 20
+	service := broadcast.NewService(serviceConfig)
+	if err := service.Start(); err != nil {
+		t.Fatalf("Service.Start() error = %v", err)
+	}
+	t.Cleanup(service.Stop)
+
+	config.Address = "127.0.0.1:0"
+	relay := broadcast.NewRelay(service, config)
+	if err := relay.Start(); err != nil {
+		t.Fatalf("Relay.Start() error = %v", err)
+	}
+	t.Cleanup(relay.Stop)
+
+	// El relay sirve la caché del protocolo, esperar a que esté completa
+	waitFor(t, 2*time.Second, "upstream entry list", func() bool {
+		_, hasTrack := service.GetTrackData()
+		return hasTrack && len(service.GetEntryList()) == len(broadcast.DefaultMockServerConfig().Cars)
+	})
+	return service, relay
+}
+
+func TestRelay_ServesDownstreamClients(t *testing.T) {
+	config := broadcast.DefaultMockServerConfig()
+	server := startMockServer(t, config)
+	_, relay := startRelay(t, server, broadcast.RelayConfig{ConnectionPassword: "relay"})
+
+	_, first := connectClient(t, relay.Addr(), "Overlay A", "relay", "")
+	_, second := connectClient(t, relay.Addr(), "Overlay B", "relay", "")
+
+	for _, session := range []*mockSession{first, second} {
+		waitFor(t, 2*time.Second, "relayed session", func() bool {
+			return session.read(func(s *mockSession) bool {
+				return s.state != nil && s.track != nil && s.realtime != nil &&
+					len(s.cars) == len(config.Cars) && len(s.carUpdates) == len(config.Cars)
+			})
+		})
+		session.read(func(s *mockSession) bool {
+			if !s.state.Success || !s.state.IsReadonly {
+				t.Errorf("state = %+v, want read-only success", *s.state)
+			}
+			if s.track.TrackName != config.Track.TrackName {
+				t.Errorf("TrackName = %q, want %q", s.track.TrackName, config.Track.TrackName)
+			}
+			return true
+		})
+	}
+
+	// Los clientes del relay no cuentan para el límite de ACC
+	if got := len(server.Clients()); got != 1 {
+		t.Errorf("upstream clients = %d, want 1", got)
+	}
+	clients := relay.Clients()
+	if len(clients) != 2 || clients[0].ConnectionId == clients[1].ConnectionId {
+		t.Errorf("relay Clients() = %+v", clients)
+	}
+
+	// Los eventos llegan a todos los clientes
+	if err := server.SendEvent(broadcast.BroadcastingEvent{Type: broadcast.BroadcastingEventTypeAccident, CarId: 2}); err != nil {
+		t.Fatalf("SendEvent() error = %v", err)
+	}
+	for _, session := range []*mockSession{first, second} {
+		waitFor(t, 2*time.Second, "relayed event", func() bool {
+			return session.read(func(s *mockSession) bool { return len(s.events) == 1 })
+		})
+	}
+}
+
+func TestRelay_WrongPassword(t *testing.T) {
+	server := startMockServer(t, broadcast.DefaultMockServerConfig())
+	_, relay := startRelay(t, server, broadcast.RelayConfig{ConnectionPassword: "relay"})
+
+	_, session := connectClient(t, relay.Addr(), "Intruder", "asd", "")
+	waitFor(t, 2*time.Second, "registration", func() bool {
+		return session.read(func(s *mockSession) bool { return s.state != nil })
+	})
+	session.read(func(s *mockSession) bool {
+		if s.state.Success || s.state.ErrorMsg == "" {
+			t.Errorf("state = %+v, want failure with an error message", *s.state)
+		}
+		return true
+	})
+	if clients := relay.Clients(); len(clients) != 0 {
+		t.Errorf("relay Clients() = %+v, want none", clients)
+	}
+}
+
+func TestRelay_CommandPolicy(t *testing.T) {
+	server := startMockServer(t, broadcast.DefaultMockServerConfig())
+	_, relay := startRelay(t, server, broadcast.RelayConfig{
+		ConnectionPassword: "relay",
+		CommandPassword:    "relay-cmd",
+		Policy:             broadcast.ForwardCommandsFrom("Director"),
+	})
+
+	director, directorSession := connectClient(t, relay.Addr(), "Director", "relay", "relay-cmd")
+	overlay, overlaySession := connectClient(t, relay.Addr(), "Overlay", "relay", "relay-cmd")
+	readonly, readonlySession := connectClient(t, relay.Addr(), "Viewer", "relay", "")
+
+	for _, session := range []*mockSession{directorSession, overlaySession, readonlySession} {
+		waitFor(t, 2*time.Second, "registration", func() bool {
+			return session.read(func(s *mockSession) bool { return s.state != nil && s.state.Success })
+		})
+	}
+
+	// Solo el director llega a ACC
+	if err := director.SetFocus(3); err != nil {
+		t.Fatalf("SetFocus() error = %v", err)
+	}
+	if err := overlay.SetFocus(2); err != nil {
+		t.Fatalf("SetFocus() error = %v", err)
+	}
+	if err := readonly.RequestHUDPage("Blank"); err != nil {
+		t.Fatalf("RequestHUDPage() error = %v", err)
+	}
+
+	waitFor(t, 2*time.Second, "command counters", func() bool {
+		stats := relay.Stats()
+		return stats.Forwarded == 1 && stats.Denied == 2
+	})
+	waitFor(t, 2*time.Second, "forwarded focus", func() bool {
+		return server.Session().FocusedCarIndex == 3
+	})
+
+	if commands := server.Commands(); len(commands) != 1 || commands[0].Type != broadcast.OutboundChangeFocus {
+		t.Errorf("upstream commands = %+v, want a single focus change", commands)
+	}
+
+	// El cambio de foco aplicado en ACC vuelve a todos por el relay
+	waitFor(t, 2*time.Second, "relayed focus", func() bool {
+		return readonlySession.read(func(s *mockSession) bool {
+			return s.realtime != nil && s.realtime.FocusedCarIndex == 3
+		})
+	})
+}
+
+func TestRelay_ClientTimeout(t *testing.T) {
+	server := startMockServer(t, broadcast.DefaultMockServerConfig())
+	_, relay := startRelay(t, server, broadcast.RelayConfig{ConnectionPassword: "relay", ClientTimeout: 200 * time.Millisecond})
+
+	// Una herramienta que se registra y se cae sin desregistrarse
+	conn, err := net.Dial("udp", relay.Addr())
+	if err != nil {
+		t.Fatalf("Dial() error = %v", err)
+	}
+	defer conn.Close()
+
+	request, err := broadcast.MarshalRegistrationRequest("Crashed", "relay", 20, "")
+	if err != nil {
+		t.Fatalf("MarshalRegistrationRequest() error = %v", err)
+	}
+	if _, err := conn.Write(request); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+	waitFor(t, 2*time.Second, "registration", func() bool { return len(relay.Clients()) == 1 })
+
+	// Un cliente que sigue enviando pedidos se mantiene
+	alive, session := connectClient(t, relay.Addr(), "Overlay", "relay", "")
+	waitFor(t, 2*time.Second, "registration", func() bool {
+		return session.read(func(s *mockSession) bool { return s.state != nil && s.state.Success })
+	})
+
+	deadline := time.Now().Add(600 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		if err := alive.RequestTrackData(); err != nil {
+			t.Fatalf("RequestTrackData() error = %v", err)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	clients := relay.Clients()
+	if len(clients) != 1 || clients[0].DisplayName != "Overlay" {
+		t.Errorf("relay Clients() = %+v, want only the live client", clients)
+	}
+}
@@ -0,0 +1,283 @@
+package integration_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"RaceAll/internal/broadcast"
+	raerrors "RaceAll/internal/errors"
+	"RaceAll/internal/logger"
+)
+
+// stateRecorder guarda las transiciones de estado de un cliente
+type stateRecorder struct {
+	mu      sync.Mutex
+	changes []broadcast.StateChange
+}
+
+func (r *stateRecorder) record(change broadcast.StateChange) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.changes = append(r.changes, change)
+}
+
+func (r *stateRecorder) all() []broadcast.StateChange {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]broadcast.StateChange(nil), r.changes...)
+}
+
+// count devuelve cuántas transiciones llegaron al estado indicado
+func (r *stateRecorder) count(to broadcast.ClientState) int {
+	n := 0
+	for _, change := range r.all() {
+		if change.To == to {
+			n++
+		}
+	}
+	return n
+}
+
+func newStateClient(address, password, commandPassword string, reconnect broadcast.ReconnectConfig) (*broadcast.Client, *stateRecorder) {
+	recorder := &stateRecorder{}
+	client := broadcast.NewClient(address, "State Test Client", password, commandPassword, 20, *logger.Get())
+	client.SetReconnectConfig(reconnect)
+	client.OnStateChanged = recorder.record
+	return client, recorder
+}
+
+func TestClientState_ConnectedAndReadonly(t *testing.T) {
+	config := broadcast.DefaultMockServerConfig()
+	server := startMockServer(t, config)
+
+	tests := []struct {
+		name            string
+		commandPassword string
+		want            broadcast.ClientState
+	}{
+		{"con contraseña de comandos", config.CommandPassword, broadcast.StateConnected},
+		{"sin contraseña de comandos", "", broadcast.StateReadonly},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, recorder := newStateClient(server.Addr(), config.ConnectionPassword, tt.commandPassword, broadcast.DefaultReconnectConfig())
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error, 1)
+			go func() { done <- client.Run(ctx) }()
+
+			waitFor(t, 2*time.Second, "registration", func() bool { return client.State() == tt.want })
+
+			cancel()
+			if err := <-done; err != nil {
+				t.Errorf("Run() error = %v", err)
+			}
+			if client.State() != broadcast.StateDisconnected {
+				t.Errorf("State() after cancel = %v, want Disconnected", client.State())
+			}
+
+			changes := recorder.all()
+			if len(changes) != 3 {
+				t.Fatalf("changes = %+v, want Registering, %v, Disconnected", changes, tt.want)
+			}
+			if changes[0].To != broadcast.StateRegistering || changes[0].Attempt != 1 {
+				t.Errorf("first change = %+v, want Registering attempt 1", changes[0])
+			}
+			if changes[1].From != broadcast.StateRegistering || changes[1].To != tt.want {
+				t.Errorf("second change = %+v, want Registering -> %v", changes[1], tt.want)
+			}
+			if tt.want == broadcast.StateReadonly && changes[1].Reason == "" {
+				t.Error("Readonly change should explain why")
+			}
+		})
+	}
+}
+
+func TestClientState_WrongPassword(t *testing.T) {
+	server := startMockServer(t, broadcast.DefaultMockServerConfig())
+	client, recorder := newStateClient(server.Addr(), "wrong", "", broadcast.ReconnectConfig{
+		InitialBackoff: 20 * time.Millisecond,
+		MaxAttempts:    2,
+	})
+
+	err := client.Run(context.Background())
+	if !errors.Is(err, raerrors.ErrRegistrationRejected) {
+		t.Fatalf("Run() error = %v, want ErrRegistrationRejected", err)
+	}
+
+	// Cada intento se rechaza con el mensaje de ACC; el primero programa un reintento
+	lost := make([]broadcast.StateChange, 0)
+	for _, change := range recorder.all() {
+		if change.To == broadcast.StateLost {
+			lost = append(lost, change)
+		}
+	}
+	if len(lost) != 2 {
+		t.Fatalf("Lost changes = %+v, want 2", lost)
+	}
+	for i, change := range lost {
+		if change.Reason != "Password incorrect" || change.Attempt != i+1 {
+			t.Errorf("Lost change %d = %+v, want reason from ErrorMsg", i, change)
+		}
+	}
+	if lost[0].RetryIn <= 0 || lost[1].RetryIn != 0 {
+		t.Errorf("RetryIn = %v/%v, want a delay only before the second attempt", lost[0].RetryIn, lost[1].RetryIn)
+	}
+}
+
+func TestClientState_RegistrationTimeout(t *testing.T) {
+	// Un puerto que recibe pero nunca responde
+	silent, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket() error = %v", err)
+	}
+	defer silent.Close()
+
+	client, recorder := newStateClient(silent.LocalAddr().String(), "asd", "", broadcast.ReconnectConfig{
+		RegistrationTimeout: 100 * time.Millisecond,
+		InitialBackoff:      10 * time.Millisecond,
+		MaxAttempts:         3,
+	})
+
+	start := time.Now()
+	err = client.Run(context.Background())
+	if !errors.Is(err, raerrors.ErrRegistrationTimeout) {
+		t.Fatalf("Run() error = %v, want ErrRegistrationTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Run() took %v, registration timeout not applied", elapsed)
+	}
+
+	if got := recorder.count(broadcast.StateRegistering); got != 3 {
+		t.Errorf("Registering changes = %d, want 3", got)
+	}
+	if client.State() != broadcast.StateLost {
+		t.Errorf("State() = %v, want Lost", client.State())
+	}
+}
+
+func TestClientState_ReconnectAfterLost(t *testing.T) {
+	config := broadcast.DefaultMockServerConfig()
+	server := startMockServer(t, config)
+	address := server.Addr()
+
+	client, recorder := newStateClient(address, config.ConnectionPassword, "", broadcast.ReconnectConfig{
+		InitialBackoff: 20 * time.Millisecond,
+		MaxBackoff:     100 * time.Millisecond,
+	})
+	client.SetTimeout(200 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go client.Run(ctx)
+
+	waitFor(t, 2*time.Second, "registration", func() bool { return client.State() == broadcast.StateReadonly })
+
+	// ACC se cierra: el cliente pasa a Lost y reintenta con backoff
+	server.Stop()
+	waitFor(t, 2*time.Second, "lost connection", func() bool { return recorder.count(broadcast.StateLost) > 0 })
+
+	// ACC vuelve en la misma dirección
+	config.Address = address
+	startMockServer(t, config)
+	waitFor(t, 3*time.Second, "registration after restart", func() bool {
+		return recorder.count(broadcast.StateReadonly) == 2 && client.State() == broadcast.StateReadonly
+	})
+
+	// Tras registrarse de nuevo los intentos vuelven a contar desde 1
+	changes := recorder.all()
+	last := changes[len(changes)-1]
+	if last.Attempt != 1 {
+		t.Errorf("attempt after reconnect = %d, want 1", last.Attempt)
+	}
+}
+
+func TestClientState_ServiceEvents(t *testing.T) {
+	config := broadcast.DefaultMockServerConfig()
+	server := startMockServer(t, config)
+
+	serviceConfig := broadcast.DefaultConfig()
+	serviceConfig.Port = server.Port()
+	serviceConfig.UpdateMS = 20
+	service := broadcast.NewService(serviceConfig)
+
+	messages := service.Subscribe()
+	if err := service.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer service.Stop()
+
+	// Los suscriptores reciben las transiciones como StateChange
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case msg := <-messages:
+			change, ok := msg.Payload.(broadcast.StateChange)
+			if !ok {
+				continue
+			}
+			if msg.Type != "StateChange" {
+				t.Errorf("Type = %q, want StateChange", msg.Type)
+			}
+			if change.To == broadcast.StateReadonly {
+				if service.State() != broadcast.StateReadonly {
+					t.Errorf("State() = %v, want Readonly", service.State())
+				}
+				return
+			}
+		case <-timeout:
+			t.Fatal("timeout waiting for Readonly state change")
+		}
+	}
+}
+
+func TestClientState_ServiceStopAfterRejection(t *testing.T) {
+	server := startMockServer(t, broadcast.DefaultMockServerConfig())
+
+	serviceConfig := broadcast.DefaultConfig()
+	serviceConfig.Port = server.Port()
+	serviceConfig.Password = "wrong"
+	serviceConfig.Reconnect = broadcast.ReconnectConfig{InitialBackoff: 10 * time.Millisecond, MaxAttempts: 2}
+	service := broadcast.NewService(serviceConfig)
+
+	messages := service.Subscribe()
+	if err := service.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	// El error llega como el último Lost, sin reintento
+	timeout := time.After(2 * time.Second)
+	for waiting := true; waiting; {
+		select {
+		case msg := <-messages:
+			change, ok := msg.Payload.(broadcast.StateChange)
+			if ok && change.To == broadcast.StateLost && change.RetryIn == 0 {
+				if !errors.Is(change.Err, raerrors.ErrRegistrationRejected) {
+					t.Errorf("Err = %v, want ErrRegistrationRejected", change.Err)
+				}
+				waiting = false
+			}
+		case <-timeout:
+			t.Fatal("timeout waiting for the final Lost state change")
+		}
+	}
+
+	// Stop no se bloquea aunque el cliente esté terminando
+	stopped := make(chan struct{})
+	go func() {
+		service.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Stop() did not return")
+	}
+
+	waitFor(t, time.Second, "service stopped", func() bool { return !service.IsRunning() })
+}
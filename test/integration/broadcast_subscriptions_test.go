@@ -0,0 +1,112 @@
+package integration_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"RaceAll/internal/broadcast"
+	"RaceAll/internal/pubsub"
+)
+
+// startMockService conecta un Service al servidor simulado
+func startMockService(t *testing.T, server *broadcast.MockServer) *broadcast.Service {
+	t.Helper()
+
+	config := broadcast.DefaultConfig()
+	config.Port = server.Port()
+	config.UpdateMS = 20
+	service := broadcast.NewService(config)
+
+	if err := service.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	t.Cleanup(service.Stop)
+	return service
+}
+
+func TestTypedSubscriptions_FilterCarsAndEvents(t *testing.T) {
+	server := startMockServer(t, broadcast.DefaultMockServerConfig())
+	service := startMockService(t, server)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	options := pubsub.Options{Policy: pubsub.DropOldest, BufferSize: 64}
+	carUpdates := service.SubscribeRealtimeCarUpdates(ctx, broadcast.Filter{
+		CarIndexes: []uint16{2},
+		Options:    options,
+	})
+	accidents := service.SubscribeEvents(ctx, broadcast.Filter{
+		CarIndexes: []uint16{3},
+		Options:    options,
+	}, broadcast.BroadcastingEventTypeAccident, broadcast.BroadcastingEventTypeSessionOver)
+	qualifying := service.SubscribeRealtimeCarUpdates(ctx, broadcast.Filter{
+		SessionTypes: []broadcast.RaceSessionType{broadcast.RaceSessionTypeQualifying},
+		Options:      options,
+	})
+
+	// Solo llegan actualizaciones del auto 2
+	for i := 0; i < 5; i++ {
+		select {
+		case update := <-carUpdates:
+			if update.CarIndex != 2 {
+				t.Fatalf("CarIndex = %d, want 2", update.CarIndex)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("timeout waiting for car updates")
+		}
+	}
+
+	waitFor(t, 2*time.Second, "readonly connection", func() bool {
+		return service.State() == broadcast.StateReadonly
+	})
+
+	sent := []broadcast.BroadcastingEvent{
+		{Type: broadcast.BroadcastingEventTypeAccident, CarId: 1, Msg: "car 1"},
+		{Type: broadcast.BroadcastingEventTypeLapCompleted, CarId: 3, Msg: "lap"},
+		{Type: broadcast.BroadcastingEventTypeAccident, CarId: 3, Msg: "car 3"},
+		{Type: broadcast.BroadcastingEventTypeSessionOver, Msg: "over"},
+	}
+	for _, event := range sent {
+		if err := server.SendEvent(event); err != nil {
+			t.Fatalf("SendEvent() error = %v", err)
+		}
+	}
+
+	// El accidente del auto 3 y el fin de sesión, que no se filtra por auto
+	for _, want := range []string{"car 3", "over"} {
+		select {
+		case event := <-accidents:
+			if event.Msg != want {
+				t.Fatalf("event = %q, want %q", event.Msg, want)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("timeout waiting for event %q", want)
+		}
+	}
+
+	// La sesión simulada es una carrera
+	select {
+	case update := <-qualifying:
+		t.Fatalf("received car %d outside of qualifying", update.CarIndex)
+	default:
+	}
+
+	// Cancelar el contexto cierra los canales
+	cancel()
+	for _, ch := range []<-chan broadcast.CarUpdate{carUpdates, qualifying} {
+		timeout := time.After(2 * time.Second)
+	drain:
+		for {
+			select {
+			case _, ok := <-ch:
+				if !ok {
+					break drain
+				}
+			case <-timeout:
+				t.Fatal("channel not closed after cancel")
+			}
+		}
+	}
+}
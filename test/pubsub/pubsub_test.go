@@ -0,0 +1,253 @@
+package pubsub_test
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"RaceAll/internal/pubsub"
+)
+
+// waitSettled waits until n messages were delivered or dropped
+func waitSettled(t *testing.T, sub *pubsub.Subscription[int], n uint64) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		stats := sub.Stats()
+		if stats.Delivered+stats.Dropped >= n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Stats() = %+v, want %d messages settled", stats, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func drain(ch <-chan int) []int {
+	var out []int
+	for {
+		select {
+		case v := <-ch:
+			out = append(out, v)
+		default:
+			return out
+		}
+	}
+}
+
+func TestHub_Policies(t *testing.T) {
+	tests := []struct {
+		name          string
+		options       pubsub.Options
+		wantReceived  []int
+		wantDelivered uint64
+		wantDropped   uint64
+	}{
+		{
+			name:          "Drop newest",
+			options:       pubsub.Options{Policy: pubsub.DropNewest, BufferSize: 3},
+			wantReceived:  []int{1, 2, 3},
+			wantDelivered: 3,
+			wantDropped:   2,
+		},
+		{
+			name:          "Drop oldest",
+			options:       pubsub.Options{Policy: pubsub.DropOldest, BufferSize: 3},
+			wantReceived:  []int{3, 4, 5},
+			wantDelivered: 3,
+			wantDropped:   2,
+		},
+		{
+			name:          "Latest only",
+			options:       pubsub.Options{Policy: pubsub.LatestOnly, BufferSize: 8},
+			wantReceived:  []int{5},
+			wantDelivered: 1,
+			wantDropped:   4,
+		},
+		{
+			name:          "Block with timeout",
+			options:       pubsub.Options{Policy: pubsub.BlockWithTimeout, BufferSize: 3, Timeout: time.Millisecond},
+			wantReceived:  []int{1, 2, 3},
+			wantDelivered: 3,
+			wantDropped:   2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hub := pubsub.NewHub[int]()
+			sub := hub.Subscribe(tt.options)
+
+			for i := 1; i <= 5; i++ {
+				hub.Publish(i)
+			}
+			waitSettled(t, sub, 5)
+
+			got := drain(sub.C())
+			if len(got) != len(tt.wantReceived) {
+				t.Fatalf("received %v, want %v", got, tt.wantReceived)
+			}
+			for i := range got {
+				if got[i] != tt.wantReceived[i] {
+					t.Fatalf("received %v, want %v", got, tt.wantReceived)
+				}
+			}
+
+			stats := sub.Stats()
+			if stats.Delivered != tt.wantDelivered || stats.Dropped != tt.wantDropped {
+				t.Errorf("Stats() = %+v, want delivered %d dropped %d", stats, tt.wantDelivered, tt.wantDropped)
+			}
+		})
+	}
+}
+
+func TestHub_BlockWithTimeoutWaitsForReader(t *testing.T) {
+	hub := pubsub.NewHub[int]()
+	sub := hub.Subscribe(pubsub.Options{Policy: pubsub.BlockWithTimeout, BufferSize: 1, Timeout: time.Second})
+
+	// The second message waits for room without blocking Publish
+	start := time.Now()
+	hub.Publish(1)
+	hub.Publish(2)
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("Publish() blocked for %v", elapsed)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	for want := 1; want <= 2; want++ {
+		if v := <-sub.C(); v != want {
+			t.Errorf("received %d, want %d", v, want)
+		}
+	}
+	if stats := sub.Stats(); stats.Dropped != 0 {
+		t.Errorf("Stats().Dropped = %d, want 0", stats.Dropped)
+	}
+}
+
+func TestHub_BlockWithTimeoutDoesNotStallOthers(t *testing.T) {
+	hub := pubsub.NewHub[int]()
+	slow := hub.Subscribe(pubsub.Options{Policy: pubsub.BlockWithTimeout, BufferSize: 1, Timeout: time.Hour})
+	fast := hub.Subscribe(pubsub.Options{BufferSize: 10})
+
+	start := time.Now()
+	for i := 1; i <= 5; i++ {
+		hub.Publish(i)
+	}
+	if got := len(drain(fast.C())); got != 5 {
+		t.Errorf("fast subscriber received %d, want 5", got)
+	}
+
+	// Unsubscribe does not wait for the pending timeout either
+	hub.Unsubscribe(slow.C())
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Publish() and Unsubscribe() took %v", elapsed)
+	}
+
+	if v := <-slow.C(); v != 1 {
+		t.Errorf("slow subscriber received %d, want 1", v)
+	}
+	if _, ok := <-slow.C(); ok {
+		t.Error("channel still open after Unsubscribe")
+	}
+}
+
+func TestHub_IndependentSubscribers(t *testing.T) {
+	hub := pubsub.NewHub[int]()
+	slow := hub.Subscribe(pubsub.Options{Policy: pubsub.DropNewest, BufferSize: 1})
+	fast := hub.Subscribe(pubsub.Options{BufferSize: 10})
+
+	for i := 1; i <= 5; i++ {
+		hub.Publish(i)
+	}
+
+	if got := len(drain(fast.C())); got != 5 {
+		t.Errorf("fast subscriber received %d, want 5", got)
+	}
+	if stats := slow.Stats(); stats.Dropped != 4 {
+		t.Errorf("slow subscriber Dropped = %d, want 4", stats.Dropped)
+	}
+}
+
+func TestHub_Unsubscribe(t *testing.T) {
+	hub := pubsub.NewHub[int]()
+	sub := hub.Subscribe(pubsub.DefaultOptions())
+
+	if _, ok := hub.Lookup(sub.C()); !ok {
+		t.Fatal("Lookup() did not find subscription")
+	}
+
+	hub.Unsubscribe(sub.C())
+	hub.Publish(1)
+
+	if _, ok := <-sub.C(); ok {
+		t.Error("channel still open after Unsubscribe")
+	}
+	if hub.Len() != 0 {
+		t.Errorf("Len() = %d, want 0", hub.Len())
+	}
+}
+
+func TestHub_SubscribeFiltered(t *testing.T) {
+	hub := pubsub.NewHub[int]()
+	even := hub.SubscribeFiltered(pubsub.DefaultOptions(), func(v int) bool { return v%2 == 0 })
+
+	for i := 1; i <= 6; i++ {
+		hub.Publish(i)
+	}
+
+	got := drain(even.C())
+	if len(got) != 3 || got[0] != 2 || got[1] != 4 || got[2] != 6 {
+		t.Fatalf("received %v, want [2 4 6]", got)
+	}
+
+	// Rejected messages are neither delivered nor dropped
+	stats := even.Stats()
+	if stats.Delivered != 3 || stats.Dropped != 0 {
+		t.Errorf("stats = %+v, want 3 delivered, 0 dropped", stats)
+	}
+}
+
+func TestHub_SubscribeContext(t *testing.T) {
+	hub := pubsub.NewHub[int]()
+	ctx, cancel := context.WithCancel(context.Background())
+	sub := hub.SubscribeContext(ctx, pubsub.DefaultOptions(), nil)
+
+	hub.Publish(1)
+	if v := <-sub.C(); v != 1 {
+		t.Fatalf("received %d, want 1", v)
+	}
+
+	cancel()
+
+	select {
+	case _, ok := <-sub.C():
+		if ok {
+			t.Fatal("received a message after cancel")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("channel not closed after cancel")
+	}
+	if hub.Len() != 0 {
+		t.Errorf("Len() = %d, want 0", hub.Len())
+	}
+}
+
+func TestHub_SubscribeContextUnsubscribed(t *testing.T) {
+	hub := pubsub.NewHub[int]()
+	before := runtime.NumGoroutine()
+
+	// Unsubscribe without cancelling the context ends the watcher
+	sub := hub.SubscribeContext(context.Background(), pubsub.DefaultOptions(), nil)
+	hub.Unsubscribe(sub.C())
+
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines, want %d", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,145 @@
+package sharedmemory_test
+
+import (
+	"runtime"
+	"testing"
+	"unsafe"
+
+	"RaceAll/internal/errors"
+	"RaceAll/internal/sharedmemory"
+)
+
+func TestResolveLayout(t *testing.T) {
+	tests := []struct {
+		name      string
+		game      sharedmemory.Game
+		smVersion string
+		wantGame  sharedmemory.Game
+		wantErr   bool
+	}{
+		{"ACC auto detected", sharedmemory.GameAuto, "1.9", sharedmemory.GameACC, false},
+		{"AC auto detected", sharedmemory.GameAuto, "1.7", sharedmemory.GameAC, false},
+		{"Empty version defaults to ACC", sharedmemory.GameAuto, "", sharedmemory.GameACC, false},
+		{"Empty version in AC mode", sharedmemory.GameAC, "", sharedmemory.GameAC, false},
+		{"AC version in ACC mode", sharedmemory.GameACC, "1.7", 0, true},
+		{"Unknown version", sharedmemory.GameAuto, "2.0", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			layout, err := sharedmemory.ResolveLayout(tt.game, tt.smVersion)
+			if tt.wantErr {
+				if !errors.IsIncompatibleError(err) {
+					t.Errorf("ResolveLayout() error = %v, want incompatible", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveLayout() error = %v", err)
+			}
+			if layout.Game != tt.wantGame {
+				t.Errorf("ResolveLayout() game = %v, want %v", layout.Game, tt.wantGame)
+			}
+		})
+	}
+}
+
+func TestKnownLayouts_ACPagesAreShorter(t *testing.T) {
+	for _, layout := range sharedmemory.KnownLayouts() {
+		if layout.Game != sharedmemory.GameAC {
+			continue
+		}
+		if layout.PhysicsSize >= sharedmemory.PhysicsPageFileSize ||
+			layout.GraphicsSize >= sharedmemory.GraphicsPageFileSize ||
+			layout.StaticSize >= sharedmemory.StaticPageFileSize {
+			t.Errorf("AC layout %+v is not shorter than ACC", layout)
+		}
+	}
+}
+
+func acLayout(t *testing.T) sharedmemory.Layout {
+	t.Helper()
+	layout, err := sharedmemory.ResolveLayout(sharedmemory.GameAC, "1.7")
+	if err != nil {
+		t.Fatalf("ResolveLayout() error = %v", err)
+	}
+	return layout
+}
+
+func TestSharedMemoryReader_ACPages(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("File-backed pages are only supported on Linux")
+	}
+
+	dir := t.TempDir()
+	layout := acLayout(t)
+
+	// The AC pages are shorter than the structs, so they are filled from a
+	// full-size struct and only the AC prefix is copied
+	writePage(t, dir, sharedmemory.PhysicsPageName, layout.PhysicsSize, func(b []byte) {
+		physics := sharedmemory.Physics{PacketId: 7}
+		copy(b, unsafe.Slice((*byte)(unsafe.Pointer(&physics)), len(b)))
+	})
+	writePage(t, dir, sharedmemory.GraphicsPageName, layout.GraphicsSize, func(b []byte) {
+		// The AC page matches ACC up to NormalizedCarPosition, then has a
+		// single car coordinate where ACC has ActiveCars
+		*(*int32)(unsafe.Pointer(&b[unsafe.Offsetof(sharedmemory.Graphics{}.CompletedLaps)])) = 3
+		*(*float32)(unsafe.Pointer(&b[unsafe.Offsetof(sharedmemory.Graphics{}.ActiveCars)])) = 12.5
+	})
+	writePage(t, dir, sharedmemory.StaticPageName, layout.StaticSize, func(b []byte) {
+		static := sharedmemory.Static{MaxRpm: 8000}
+		sharedmemory.PutUTF16(static.SMVersion[:], "1.7")
+		copy(b, unsafe.Slice((*byte)(unsafe.Pointer(&static)), len(b)))
+	})
+
+	reader := sharedmemory.NewSharedMemoryReaderAt(dir)
+	if err := reader.Connect(); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer reader.Disconnect()
+
+	if reader.Layout().Game != sharedmemory.GameAC {
+		t.Fatalf("Layout().Game = %v, want AC", reader.Layout().Game)
+	}
+
+	physics, err := reader.ReadPhysics()
+	if err != nil || physics.PacketId != 7 {
+		t.Errorf("ReadPhysics() = %v, %v; want PacketId 7", physics, err)
+	}
+
+	graphics, err := reader.ReadGraphics()
+	if err != nil {
+		t.Fatalf("ReadGraphics() error = %v", err)
+	}
+	if graphics.CompletedLaps != 3 || graphics.ActiveCars != 1 || graphics.CarCoordinates[0][0] != 12.5 {
+		t.Errorf("ReadGraphics() laps %d, active cars %d, coordinates %v",
+			graphics.CompletedLaps, graphics.ActiveCars, graphics.CarCoordinates[0])
+	}
+
+	static, err := reader.ReadStatic()
+	if err != nil || static.MaxRpm != 8000 {
+		t.Errorf("ReadStatic() MaxRpm mismatch, err = %v", err)
+	}
+}
+
+func TestSharedMemoryReader_IncompatibleVersion(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("File-backed pages are only supported on Linux")
+	}
+
+	dir := t.TempDir()
+	writePage(t, dir, sharedmemory.PhysicsPageName, sharedmemory.PhysicsPageFileSize, func([]byte) {})
+	writePage(t, dir, sharedmemory.GraphicsPageName, sharedmemory.GraphicsPageFileSize, func([]byte) {})
+	writePage(t, dir, sharedmemory.StaticPageName, sharedmemory.StaticPageFileSize, func(b []byte) {
+		sharedmemory.PutUTF16((*sharedmemory.Static)(unsafe.Pointer(&b[0])).SMVersion[:], "2.0")
+	})
+
+	reader := sharedmemory.NewSharedMemoryReaderAt(dir)
+	err := reader.Connect()
+	if !errors.IsIncompatibleError(err) {
+		t.Fatalf("Connect() error = %v, want incompatible", err)
+	}
+	if reader.IsConnected() {
+		t.Error("IsConnected() = true after incompatible version")
+	}
+}
@@ -0,0 +1,187 @@
+package sharedmemory_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+
+	raerrors "RaceAll/internal/errors"
+	"RaceAll/internal/sharedmemory"
+)
+
+func recordFrames(t *testing.T, count int, step time.Duration) []byte {
+	t.Helper()
+
+	var static sharedmemory.Static
+	copy(static.SMVersion[:], []uint16{'1', '.', '9'})
+	copy(static.CarModel[:], []uint16{'b', 'm', 'w'})
+	copy(static.Track[:], []uint16{'m', 'o', 'n', 'z', 'a'})
+
+	var buf bytes.Buffer
+	rec := sharedmemory.NewRecorder(&buf)
+	start := time.Unix(1700000000, 0)
+
+	for i := 0; i < count; i++ {
+		physics := &sharedmemory.Physics{PacketId: int32(i + 1), SpeedKmh: float32(i)}
+		graphics := &sharedmemory.Graphics{PacketId: int32(i/10 + 1), CompletedLaps: int32(i / 100)}
+		data := sharedmemory.TelemetryData{Physics: physics, Graphics: graphics, Static: &static}
+		if err := rec.WriteFrameAt(data, start.Add(time.Duration(i)*step)); err != nil {
+			t.Fatalf("WriteFrameAt(%d) error = %v", i, err)
+		}
+	}
+
+	if err := rec.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestRecording_RoundTrip(t *testing.T) {
+	const frames = 1500
+	data := recordFrames(t, frames, 10*time.Millisecond)
+
+	player, err := sharedmemory.NewPlayer(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("NewPlayer() error = %v", err)
+	}
+
+	header := player.Header()
+	if header.SMVersion != "1.9" || header.CarModel != "bmw" || header.Track != "monza" {
+		t.Errorf("Header() = %+v", header)
+	}
+	if player.FrameCount() != frames {
+		t.Errorf("FrameCount() = %d, want %d", player.FrameCount(), frames)
+	}
+	if player.Duration() != (frames-1)*10*time.Millisecond {
+		t.Errorf("Duration() = %v", player.Duration())
+	}
+
+	for i := 0; i < frames; i++ {
+		frame, err := player.Next()
+		if err != nil {
+			t.Fatalf("Next() at %d error = %v", i, err)
+		}
+		if frame.Data.Physics.PacketId != int32(i+1) {
+			t.Fatalf("frame %d PacketId = %d", i, frame.Data.Physics.PacketId)
+		}
+		if frame.Data.Graphics.CompletedLaps != int32(i/100) {
+			t.Fatalf("frame %d CompletedLaps = %d", i, frame.Data.Graphics.CompletedLaps)
+		}
+		if frame.Data.Sequence != uint64(i+1) {
+			t.Fatalf("frame %d Sequence = %d", i, frame.Data.Sequence)
+		}
+	}
+
+	if _, err := player.Next(); err != io.EOF {
+		t.Errorf("Next() at end error = %v, want io.EOF", err)
+	}
+}
+
+func TestRecording_Seek(t *testing.T) {
+	player, err := sharedmemory.NewPlayer(bytes.NewReader(recordFrames(t, 1500, 10*time.Millisecond)))
+	if err != nil {
+		t.Fatalf("NewPlayer() error = %v", err)
+	}
+
+	if err := player.Seek(12345 * time.Millisecond); err != nil {
+		t.Fatalf("Seek() error = %v", err)
+	}
+
+	frame, err := player.Next()
+	if err != nil {
+		t.Fatalf("Next() error = %v", err)
+	}
+	if frame.Offset != 12340*time.Millisecond || frame.Data.Physics.PacketId != 1235 {
+		t.Errorf("after Seek got offset %v PacketId %d", frame.Offset, frame.Data.Physics.PacketId)
+	}
+}
+
+func TestRecording_RecoversWithoutIndex(t *testing.T) {
+	data := recordFrames(t, 1100, 10*time.Millisecond)
+
+	// Drop the index and footer as if the process had crashed before Close
+	truncated := data[:bytes.LastIndexByte(data[:len(data)-16], 'I')]
+
+	player, err := sharedmemory.NewPlayer(bytes.NewReader(truncated))
+	if err != nil {
+		t.Fatalf("NewPlayer() error = %v", err)
+	}
+	if player.FrameCount() != 1100 {
+		t.Errorf("FrameCount() = %d, want 1100", player.FrameCount())
+	}
+}
+
+func TestPlayer_ServiceReplayAndStep(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.rasm")
+
+	rec, err := sharedmemory.CreateRecording(path)
+	if err != nil {
+		t.Fatalf("CreateRecording() error = %v", err)
+	}
+	start := time.Now()
+	for i := 0; i < 50; i++ {
+		physics := &sharedmemory.Physics{PacketId: int32(i + 1)}
+		rec.WriteFrameAt(sharedmemory.TelemetryData{Physics: physics}, start.Add(time.Duration(i)*time.Second))
+	}
+	rec.Close()
+
+	player, err := sharedmemory.OpenRecording(path)
+	if err != nil {
+		t.Fatalf("OpenRecording() error = %v", err)
+	}
+	defer player.Close()
+
+	svc := sharedmemory.NewServiceWithSource(player)
+	ch := svc.Subscribe()
+	if err := svc.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer svc.Stop()
+
+	waitFor := func(packetID int32) {
+		t.Helper()
+		deadline := time.After(2 * time.Second)
+		for {
+			select {
+			case data := <-ch:
+				if data.Physics.PacketId == packetID {
+					return
+				}
+			case <-deadline:
+				t.Fatalf("did not replay PacketId %d", packetID)
+			}
+		}
+	}
+
+	waitFor(1)
+
+	player.Step()
+	player.Step()
+	waitFor(3)
+
+	// 50 recorded seconds at 1000x
+	player.SetSpeed(1000)
+	player.Resume()
+	waitFor(50)
+}
+
+func TestRecording_RejectsOversizedBlock(t *testing.T) {
+	data := recordFrames(t, 10, 10*time.Millisecond)
+
+	// The footer points at the index, whose first entry points at the block
+	indexOffset := binary.LittleEndian.Uint64(data[len(data)-16:])
+	blockOffset := binary.LittleEndian.Uint64(data[indexOffset+5:])
+	binary.LittleEndian.PutUint32(data[blockOffset+21:], 0xFFFFFFFF)
+
+	player, err := sharedmemory.NewPlayer(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("NewPlayer() error = %v", err)
+	}
+	if _, err := player.Next(); !errors.Is(err, raerrors.ErrInvalidRecording) {
+		t.Errorf("Next() error = %v, want ErrInvalidRecording", err)
+	}
+}
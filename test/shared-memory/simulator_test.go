@@ -0,0 +1,162 @@
+package sharedmemory_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"RaceAll/internal/sharedmemory"
+)
+
+func testScenario() sharedmemory.Scenario {
+	scenario := sharedmemory.DefaultScenario()
+	scenario.LapTime = 60 * time.Second
+	scenario.StartFuel = 50
+	scenario.FuelPerLap = 2
+	scenario.PitEveryLaps = 2
+	scenario.PitStopDuration = 10 * time.Second
+	scenario.RainStartLap = 3
+	scenario.TimeScale = 0
+	return scenario
+}
+
+func readFrame(t *testing.T, sim *sharedmemory.Simulator) (*sharedmemory.Physics, *sharedmemory.Graphics) {
+	t.Helper()
+	physics, err := sim.ReadPhysics()
+	if err != nil {
+		t.Fatalf("ReadPhysics() error = %v", err)
+	}
+	graphics, err := sim.ReadGraphics()
+	if err != nil {
+		t.Fatalf("ReadGraphics() error = %v", err)
+	}
+	return physics, graphics
+}
+
+func TestSimulator_LapsFuelAndPitStops(t *testing.T) {
+	sim := sharedmemory.NewSimulator(testScenario())
+	sim.Connect()
+
+	sim.Advance(30 * time.Second)
+	physics, graphics := readFrame(t, sim)
+	if graphics.CompletedLaps != 0 || graphics.CurrentSectorIndex != 1 {
+		t.Errorf("mid lap: laps=%d sector=%d", graphics.CompletedLaps, graphics.CurrentSectorIndex)
+	}
+	if physics.SpeedKmh <= 0 || physics.Gear < 2 || physics.Rpms <= 0 {
+		t.Errorf("car not moving: speed=%.1f gear=%d rpm=%d", physics.SpeedKmh, physics.Gear, physics.Rpms)
+	}
+
+	sim.Advance(31 * time.Second)
+	physics, graphics = readFrame(t, sim)
+	if graphics.CompletedLaps != 1 {
+		t.Fatalf("CompletedLaps = %d, want 1", graphics.CompletedLaps)
+	}
+	if graphics.ILastTime < 59900 || graphics.ILastTime > 60100 {
+		t.Errorf("ILastTime = %d, want ~60000", graphics.ILastTime)
+	}
+	if physics.Fuel > 48.1 || physics.Fuel < 47.8 {
+		t.Errorf("Fuel = %.2f, want ~47.9", physics.Fuel)
+	}
+	if physics.TyreWear[0] <= 0 {
+		t.Error("tyres did not wear")
+	}
+
+	// End of lap 2 triggers the pit stop
+	sim.Advance(60 * time.Second)
+	physics, graphics = readFrame(t, sim)
+	if graphics.CompletedLaps != 2 || graphics.IsInPit != 1 || graphics.IsInPitLane != 1 {
+		t.Fatalf("expected pit stop after lap 2: laps=%d inPit=%d", graphics.CompletedLaps, graphics.IsInPit)
+	}
+	if physics.SpeedKmh != 0 {
+		t.Errorf("SpeedKmh in pit box = %.1f, want 0", physics.SpeedKmh)
+	}
+
+	sim.Advance(10 * time.Second)
+	physics, graphics = readFrame(t, sim)
+	if graphics.IsInPit != 0 || graphics.IsInPitLane != 1 {
+		t.Errorf("after stop: inPit=%d inPitLane=%d, want 0/1", graphics.IsInPit, graphics.IsInPitLane)
+	}
+	if physics.Fuel < 49.9 || physics.TyreWear[0] > 0.0001 {
+		t.Errorf("pit stop did not refuel/change tyres: fuel=%.2f wear=%f", physics.Fuel, physics.TyreWear[0])
+	}
+
+	sim.Advance(10 * time.Second)
+	_, graphics = readFrame(t, sim)
+	if graphics.IsInPitLane != 0 {
+		t.Error("car never left the pit lane")
+	}
+}
+
+func TestSimulator_RainStart(t *testing.T) {
+	sim := sharedmemory.NewSimulator(testScenario())
+	sim.Connect()
+
+	sim.Advance(150 * time.Second)
+	_, graphics := readFrame(t, sim)
+	if graphics.RainIntensity != 0 {
+		t.Fatalf("RainIntensity = %d before RainStartLap", graphics.RainIntensity)
+	}
+
+	sim.Advance(60 * time.Second)
+	_, graphics = readFrame(t, sim)
+	if graphics.CompletedLaps < 3 || graphics.RainIntensity == 0 || graphics.SurfaceGrip >= 1 {
+		t.Errorf("expected rain after lap 3: laps=%d rain=%d grip=%.2f",
+			graphics.CompletedLaps, graphics.RainIntensity, graphics.SurfaceGrip)
+	}
+}
+
+func TestSimulator_NoFuelConsumption(t *testing.T) {
+	scenario := testScenario()
+	scenario.FuelPerLap = 0
+	sim := sharedmemory.NewSimulator(scenario)
+	sim.Connect()
+
+	sim.Advance(90 * time.Second)
+	physics, graphics := readFrame(t, sim)
+	if physics.Fuel != scenario.StartFuel {
+		t.Errorf("Fuel = %.2f, want %.2f", physics.Fuel, scenario.StartFuel)
+	}
+	estimate := float64(graphics.FuelEstimatedLaps)
+	if math.IsInf(estimate, 0) || math.IsNaN(estimate) {
+		t.Errorf("FuelEstimatedLaps = %v", graphics.FuelEstimatedLaps)
+	}
+}
+
+func TestSimulator_Deterministic(t *testing.T) {
+	a := sharedmemory.NewSimulator(testScenario())
+	b := sharedmemory.NewSimulator(testScenario())
+	a.Connect()
+	b.Connect()
+
+	a.Advance(95 * time.Second)
+	for i := 0; i < 19; i++ {
+		b.Advance(5 * time.Second)
+	}
+
+	pa, ga := readFrame(t, a)
+	pb, gb := readFrame(t, b)
+	if *pa != *pb || *ga != *gb {
+		t.Error("simulators with the same scenario diverged")
+	}
+}
+
+func TestSimulator_FeedsService(t *testing.T) {
+	scenario := sharedmemory.DefaultScenario()
+	scenario.TimeScale = 50
+
+	svc := sharedmemory.NewServiceWithSource(sharedmemory.NewSimulator(scenario))
+	ch := svc.Subscribe()
+	if err := svc.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer svc.Stop()
+
+	select {
+	case data := <-ch:
+		if data.Static.GetTrack() != "monza" || data.Graphics.Status != sharedmemory.ACLive {
+			t.Errorf("unexpected frame: track=%q status=%d", data.Static.GetTrack(), data.Graphics.Status)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no telemetry from simulator")
+	}
+}
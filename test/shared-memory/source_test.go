@@ -0,0 +1,288 @@
+package sharedmemory_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unsafe"
+
+	"RaceAll/internal/sharedmemory"
+)
+
+func TestMemorySource_ReadRequiresConnect(t *testing.T) {
+	src := sharedmemory.NewMemorySource()
+
+	if _, err := src.ReadPhysics(); err == nil {
+		t.Error("ReadPhysics() before Connect() should fail")
+	}
+
+	if err := src.Connect(); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+
+	src.SetPhysics(sharedmemory.Physics{PacketId: 7, SpeedKmh: 212})
+	physics, err := src.ReadPhysics()
+	if err != nil {
+		t.Fatalf("ReadPhysics() error = %v", err)
+	}
+	if physics.PacketId != 7 || physics.SpeedKmh != 212 {
+		t.Errorf("ReadPhysics() = %d/%.0f, want 7/212", physics.PacketId, physics.SpeedKmh)
+	}
+}
+
+func TestService_WithMemorySource(t *testing.T) {
+	src := sharedmemory.NewMemorySource()
+	src.SetPhysics(sharedmemory.Physics{PacketId: 1, Gear: 3})
+	src.SetGraphics(sharedmemory.Graphics{PacketId: 1, Status: sharedmemory.ACLive})
+
+	svc := sharedmemory.NewServiceWithSource(src)
+	ch := svc.Subscribe()
+
+	if err := svc.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer svc.Stop()
+
+	select {
+	case data := <-ch:
+		if data.Physics.Gear != 3 {
+			t.Errorf("Physics.Gear = %d, want 3", data.Physics.Gear)
+		}
+		if data.Graphics.Status != sharedmemory.ACLive {
+			t.Errorf("Graphics.Status = %d, want ACLive", data.Graphics.Status)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no telemetry received from memory source")
+	}
+
+	if !svc.IsConnected() {
+		t.Error("IsConnected() = false while running on a memory source")
+	}
+}
+
+func TestSharedMemoryReader_FileBackedPages(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("File-backed pages are only supported on Linux")
+	}
+
+	dir := t.TempDir()
+	writePage(t, dir, sharedmemory.PhysicsPageName, sharedmemory.PhysicsPageFileSize, func(b []byte) {
+		(*sharedmemory.Physics)(unsafe.Pointer(&b[0])).PacketId = 42
+	})
+	writePage(t, dir, sharedmemory.GraphicsPageName, sharedmemory.GraphicsPageFileSize, func(b []byte) {
+		(*sharedmemory.Graphics)(unsafe.Pointer(&b[0])).CompletedLaps = 5
+	})
+	writePage(t, dir, sharedmemory.StaticPageName, sharedmemory.StaticPageFileSize, func(b []byte) {
+		(*sharedmemory.Static)(unsafe.Pointer(&b[0])).MaxRpm = 9000
+	})
+
+	reader := sharedmemory.NewSharedMemoryReaderAt(dir)
+	if err := reader.Connect(); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer reader.Disconnect()
+
+	physics, err := reader.ReadPhysics()
+	if err != nil || physics.PacketId != 42 {
+		t.Errorf("ReadPhysics() = %v, %v; want PacketId 42", physics, err)
+	}
+
+	graphics, err := reader.ReadGraphics()
+	if err != nil || graphics.CompletedLaps != 5 {
+		t.Errorf("ReadGraphics() CompletedLaps mismatch, err = %v", err)
+	}
+
+	static, err := reader.ReadStatic()
+	if err != nil || static.MaxRpm != 9000 {
+		t.Errorf("ReadStatic() MaxRpm mismatch, err = %v", err)
+	}
+}
+
+func TestSharedMemoryReader_MissingPages(t *testing.T) {
+	reader := sharedmemory.NewSharedMemoryReaderAt(t.TempDir())
+
+	if err := reader.Connect(); err == nil {
+		reader.Disconnect()
+		t.Fatal("Connect() on an empty location should fail")
+	}
+
+	if reader.IsConnected() {
+		t.Error("IsConnected() = true after failed Connect()")
+	}
+}
+
+func writePage(t *testing.T, dir, name string, size int, fill func([]byte)) {
+	t.Helper()
+
+	buf := make([]byte, size)
+	fill(buf)
+	if err := os.WriteFile(filepath.Join(dir, name), buf, 0o644); err != nil {
+		t.Fatalf("failed to write %s: %v", name, err)
+	}
+}
+
+func TestSharedMemoryReader_SnapshotIsOwned(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("File-backed pages are only supported on Linux")
+	}
+
+	dir := t.TempDir()
+	setPacket := func(id int32) {
+		writePage(t, dir, sharedmemory.PhysicsPageName, sharedmemory.PhysicsPageFileSize, func(b []byte) {
+			(*sharedmemory.Physics)(unsafe.Pointer(&b[0])).PacketId = id
+		})
+	}
+	setPacket(42)
+	writePage(t, dir, sharedmemory.GraphicsPageName, sharedmemory.GraphicsPageFileSize, func([]byte) {})
+	writePage(t, dir, sharedmemory.StaticPageName, sharedmemory.StaticPageFileSize, func([]byte) {})
+
+	reader := sharedmemory.NewSharedMemoryReaderAt(dir)
+	if err := reader.Connect(); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer reader.Disconnect()
+
+	first, err := reader.ReadPhysics()
+	if err != nil {
+		t.Fatalf("ReadPhysics() error = %v", err)
+	}
+
+	// Rewrite the page in place; the mapping sees it, the snapshot must not
+	f, err := os.OpenFile(filepath.Join(dir, sharedmemory.PhysicsPageName), os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteAt([]byte{43, 0, 0, 0}, 0); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	second, err := reader.ReadPhysics()
+	if err != nil {
+		t.Fatalf("ReadPhysics() error = %v", err)
+	}
+
+	if first.PacketId != 42 {
+		t.Errorf("first snapshot PacketId = %d, want 42", first.PacketId)
+	}
+	if second.PacketId != 43 {
+		t.Errorf("second snapshot PacketId = %d, want 43", second.PacketId)
+	}
+}
+
+func TestService_SequenceIncreases(t *testing.T) {
+	src := sharedmemory.NewMemorySource()
+	svc := sharedmemory.NewServiceWithSource(src)
+	ch := svc.Subscribe()
+
+	if err := svc.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer svc.Stop()
+
+	var last uint64
+	for i := 0; i < 3; i++ {
+		src.SetPhysics(sharedmemory.Physics{PacketId: int32(i + 1)})
+		select {
+		case data := <-ch:
+			if data.Sequence <= last {
+				t.Fatalf("Sequence = %d after %d, want increasing", data.Sequence, last)
+			}
+			last = data.Sequence
+		case <-time.After(2 * time.Second):
+			t.Fatal("no telemetry received")
+		}
+	}
+}
+
+func TestService_PublishesOnlyNewPackets(t *testing.T) {
+	src := sharedmemory.NewMemorySource()
+	src.SetPhysics(sharedmemory.Physics{PacketId: 10})
+	src.SetGraphics(sharedmemory.Graphics{PacketId: 1})
+
+	svc := sharedmemory.NewServiceWithSource(src)
+	ch := svc.Subscribe()
+
+	if err := svc.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer svc.Stop()
+
+	select {
+	case <-ch:
+	case <-time.After(2 * time.Second):
+		t.Fatal("no telemetry received")
+	}
+
+	// Same PacketId: polled but not published
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case data := <-ch:
+		t.Fatalf("unexpected frame with PacketId %d", data.Physics.PacketId)
+	default:
+	}
+	if stats := svc.Stats(); stats.Duplicates == 0 {
+		t.Error("Stats().Duplicates = 0, want > 0")
+	}
+
+	// Jump of 5 packets: 4 were never seen
+	src.SetPhysics(sharedmemory.Physics{PacketId: 15})
+	select {
+	case data := <-ch:
+		if data.Physics.PacketId != 15 {
+			t.Errorf("PacketId = %d, want 15", data.Physics.PacketId)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no telemetry received")
+	}
+
+	stats := svc.Stats()
+	if stats.Skipped != 4 {
+		t.Errorf("Stats().Skipped = %d, want 4", stats.Skipped)
+	}
+	if stats.Published != 2 {
+		t.Errorf("Stats().Published = %d, want 2", stats.Published)
+	}
+}
+
+// failingSource never connects and counts the attempts
+type failingSource struct {
+	*sharedmemory.MemorySource
+	attempts atomic.Int32
+}
+
+func (f *failingSource) Connect() error {
+	f.attempts.Add(1)
+	return errors.New("no shared memory")
+}
+
+func TestService_ReconnectDelayAppliesWhileRunning(t *testing.T) {
+	src := &failingSource{MemorySource: sharedmemory.NewMemorySource()}
+	svc := sharedmemory.NewServiceWithSource(src)
+	svc.SetReconnectDelay(5 * time.Millisecond)
+
+	if err := svc.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer svc.Stop()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for src.attempts.Load() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatalf("attempts = %d, want retries every 5ms", src.attempts.Load())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// The retry already waiting may still run, the next one uses the new delay
+	svc.SetReconnectDelay(time.Hour)
+	before := src.attempts.Load()
+	time.Sleep(100 * time.Millisecond)
+	if after := src.attempts.Load(); after > before+1 {
+		t.Errorf("attempts went from %d to %d after SetReconnectDelay(time.Hour)", before, after)
+	}
+}
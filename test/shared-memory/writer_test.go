@@ -0,0 +1,151 @@
+package sharedmemory_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"RaceAll/internal/sharedmemory"
+)
+
+func TestSharedMemoryWriter_RoundTrip(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("File-backed pages are only supported on Linux")
+	}
+
+	dir := t.TempDir()
+	writer := sharedmemory.NewSharedMemoryWriterAt(dir)
+	if err := writer.Open(); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+
+	sim := sharedmemory.NewSimulator(sharedmemory.DefaultScenario())
+	sim.Connect()
+	sim.Advance(10 * time.Second)
+
+	physics, _ := sim.ReadPhysics()
+	graphics, _ := sim.ReadGraphics()
+	static, _ := sim.ReadStatic()
+	if err := writer.WriteFrame(sharedmemory.TelemetryData{Physics: physics, Graphics: graphics, Static: static}); err != nil {
+		t.Fatalf("WriteFrame() error = %v", err)
+	}
+
+	reader := sharedmemory.NewSharedMemoryReaderAt(dir)
+	if err := reader.Connect(); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+
+	gotPhysics, err := reader.ReadPhysics()
+	if err != nil || *gotPhysics != *physics {
+		t.Errorf("ReadPhysics() does not match written page, err = %v", err)
+	}
+	gotGraphics, err := reader.ReadGraphics()
+	if err != nil || *gotGraphics != *graphics {
+		t.Errorf("ReadGraphics() does not match written page, err = %v", err)
+	}
+	gotStatic, err := reader.ReadStatic()
+	if err != nil || *gotStatic != *static {
+		t.Errorf("ReadStatic() does not match written page, err = %v", err)
+	}
+	if reader.Layout().Game != sharedmemory.GameACC {
+		t.Errorf("Layout().Game = %v, want ACC", reader.Layout().Game)
+	}
+
+	reader.Disconnect()
+	writer.Close()
+
+	if _, err := os.Stat(filepath.Join(dir, sharedmemory.PhysicsPageName)); !os.IsNotExist(err) {
+		t.Error("Close() did not remove the physics page")
+	}
+}
+
+func TestSharedMemoryWriter_RunFromService(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("File-backed pages are only supported on Linux")
+	}
+
+	dir := t.TempDir()
+	writer := sharedmemory.NewSharedMemoryWriterAt(dir)
+	if err := writer.Open(); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer writer.Close()
+
+	scenario := sharedmemory.DefaultScenario()
+	scenario.TimeScale = 10
+	svc := sharedmemory.NewServiceWithSource(sharedmemory.NewSimulator(scenario))
+	ch := svc.Subscribe()
+	if err := svc.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer svc.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go writer.Run(ctx, ch)
+
+	reader := sharedmemory.NewSharedMemoryReaderAt(dir)
+	if err := reader.Connect(); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer reader.Disconnect()
+
+	deadline := time.After(2 * time.Second)
+	for {
+		physics, err := reader.ReadPhysics()
+		if err == nil && physics.PacketId > 10 {
+			return
+		}
+		select {
+		case <-deadline:
+			t.Fatal("simulator frames never reached the pages")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestSharedMemoryWriter_NoTornFrames(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("File-backed pages are only supported on Linux")
+	}
+
+	dir := t.TempDir()
+	writer := sharedmemory.NewSharedMemoryWriterAt(dir)
+	if err := writer.Open(); err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	defer writer.Close()
+
+	if err := writer.WriteFrame(sharedmemory.TelemetryData{Physics: &sharedmemory.Physics{}, Graphics: &sharedmemory.Graphics{}, Static: &sharedmemory.Static{}}); err != nil {
+		t.Fatalf("WriteFrame() error = %v", err)
+	}
+
+	reader := sharedmemory.NewSharedMemoryReaderAt(dir)
+	if err := reader.Connect(); err != nil {
+		t.Fatalf("Connect() error = %v", err)
+	}
+	defer reader.Disconnect()
+
+	// Every frame carries its PacketId at both ends of the page
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		for id := int32(1); ctx.Err() == nil; id++ {
+			writer.WritePhysics(&sharedmemory.Physics{PacketId: id, Gas: float32(id), KerbVibration: float32(id)})
+		}
+	}()
+
+	deadline := time.Now().Add(200 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		physics, err := reader.ReadPhysics()
+		if err != nil {
+			continue
+		}
+		if physics.Gas != float32(physics.PacketId) || physics.KerbVibration != float32(physics.PacketId) {
+			t.Fatalf("torn frame: PacketId = %d, Gas = %v, KerbVibration = %v", physics.PacketId, physics.Gas, physics.KerbVibration)
+		}
+	}
+}